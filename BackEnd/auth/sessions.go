@@ -18,6 +18,11 @@ func CreateSession(db *sql.DB, w http.ResponseWriter, userID int) error {
 		return errors.New("invalid user ID")
 	}
 
+	// Suspended users cannot sign in until the suspension ends
+	if err := controllers.CheckNotSuspended(db, userID); err != nil {
+		return err
+	}
+
 	// Delete all existing sessions for the user
 	err := controllers.DeleteUserSessions(db, userID)
 	if err != nil {
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+var validSeverities = map[string]bool{
+	"info":     true,
+	"warning":  true,
+	"critical": true,
+}
+
+type AnnouncementController struct {
+	DB *sql.DB
+}
+
+func NewAnnouncementController(db *sql.DB) *AnnouncementController {
+	return &AnnouncementController{DB: db}
+}
+
+// CreateAnnouncement validates and stores an announcement with its recipients
+func (ac *AnnouncementController) CreateAnnouncement(a *models.Announcement) error {
+	a.Content = strings.TrimSpace(a.Content)
+	if a.Content == "" {
+		return errors.New("announcement content is required")
+	}
+	if a.Severity == "" {
+		a.Severity = "info"
+	}
+	if !validSeverities[a.Severity] {
+		return fmt.Errorf("invalid severity %q", a.Severity)
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	if !a.ExpiresAt.After(a.CreatedAt) {
+		return errors.New("announcement must expire in the future")
+	}
+
+	tx, err := ac.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO announcements (author_id, content, severity, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, a.AuthorID, a.Content, a.Severity, a.CreatedAt, a.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert announcement: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	a.ID = int(id)
+
+	for _, userID := range a.UserIDs {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO announcement_recipients (announcement_id, user_id)
+			VALUES (?, ?)
+		`, a.ID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to insert announcement recipient: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetActiveAnnouncements returns unexpired announcements visible to userID,
+// oldest first
+func (ac *AnnouncementController) GetActiveAnnouncements(userID int) ([]models.Announcement, error) {
+	rows, err := ac.DB.Query(`
+		SELECT a.id, a.author_id, a.content, a.severity, a.created_at, a.expires_at
+		FROM announcements a
+		WHERE a.expires_at > ?
+		  AND (
+		      NOT EXISTS (SELECT 1 FROM announcement_recipients r WHERE r.announcement_id = a.id)
+		      OR EXISTS (SELECT 1 FROM announcement_recipients r WHERE r.announcement_id = a.id AND r.user_id = ?)
+		  )
+		ORDER BY a.created_at ASC
+	`, time.Now().UTC(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []models.Announcement
+	for rows.Next() {
+		var a models.Announcement
+		if err := rows.Scan(&a.ID, &a.AuthorID, &a.Content, &a.Severity, &a.CreatedAt, &a.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+
+	return announcements, rows.Err()
+}
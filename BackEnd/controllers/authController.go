@@ -116,6 +116,12 @@ func (ac *AuthController) Login(req *models.LoginRequest) (*models.User, error)
 		return nil, errors.New("invalid credentials")
 	}
 
+	// Suspended users cannot sign in until the suspension ends
+	if err := CheckNotSuspended(ac.DB, user.ID); err != nil {
+		logger.Warning("Login refused for user %d: %v", user.ID, err)
+		return nil, err
+	}
+
 	// Clear password before returning
 	user.Password = ""
 	return user, nil
@@ -162,11 +168,12 @@ func (ac *AuthController) IsValidPassword(password string) bool {
 	return true
 }
 
-// sanitizeInput removes potentially dangerous characters to prevent XSS
+// SanitizeInput escapes HTML special characters to prevent XSS. The ampersand
+// is escaped first so the entities produced below are not encoded twice.
 func (ac *AuthController) SanitizeInput(input string) string {
+	input = strings.ReplaceAll(input, "&", "&amp;")
 	input = strings.ReplaceAll(input, "<", "&lt;")
 	input = strings.ReplaceAll(input, ">", "&gt;")
-	input = strings.ReplaceAll(input, "&", "&amp;")
 	input = strings.ReplaceAll(input, "\"", "&quot;")
 	input = strings.ReplaceAll(input, "'", "&#39;")
 	return input
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime/multipart"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/imaging"
+)
+
+var ErrAvatarUserNotFound = errors.New("user not found")
+
+type AvatarController struct {
+	DB *sql.DB
+}
+
+func NewAvatarController(db *sql.DB) *AvatarController {
+	return &AvatarController{DB: db}
+}
+
+// SetAvatar stores an uploaded image as the user's avatar and returns its
+// URL. Only the thumbnail is stored; the previous avatar is released.
+func (ac *AvatarController) SetAvatar(ctx context.Context, userID int, file multipart.File, header *multipart.FileHeader) (string, error) {
+	upload, err := prepareUpload(file, header.Size, MaxUploadSize)
+	if err != nil {
+		return "", err
+	}
+	attachment, err := upload.storeVariant(ctx, ac.DB, UploadFolderAvatars, "", imaging.VariantThumbnail)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ac.replaceAvatar(userID, attachment.URL); err != nil {
+		return "", err
+	}
+	return attachment.URL, nil
+}
+
+// RemoveAvatar clears the user's avatar and releases its file
+func (ac *AvatarController) RemoveAvatar(userID int) error {
+	return ac.replaceAvatar(userID, "")
+}
+
+// replaceAvatar stores avatarURL for the user and releases the file of the
+// previous avatar once the change is saved. Avatars set by other means, e.g.
+// OAuth providers, are not stored uploads and are left alone.
+func (ac *AvatarController) replaceAvatar(userID int, avatarURL string) error {
+	var previous sql.NullString
+	err := ac.DB.QueryRow("SELECT avatar_url FROM users WHERE id = ?", userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrAvatarUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch avatar: %w", err)
+	}
+	if _, err := ac.DB.Exec("UPDATE users SET avatar_url = ? WHERE id = ?", avatarURL, userID); err != nil {
+		return fmt.Errorf("failed to update avatar: %w", err)
+	}
+
+	releaseUploads(ac.DB, []string{previous.String})
+	return nil
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Bookmark target types
+const (
+	BookmarkTargetPost    = "post"
+	BookmarkTargetComment = "comment"
+)
+
+// Page sizes for the bookmark list
+const (
+	DefaultBookmarkPageSize = 20
+	MaxBookmarkPageSize     = 100
+)
+
+// MaxBookmarkFolderNameLength caps folder names, in characters
+const MaxBookmarkFolderNameLength = 50
+
+var (
+	ErrInvalidBookmarkTarget  = errors.New("invalid bookmark target")
+	ErrBookmarkTargetNotFound = errors.New("bookmark target not found")
+	ErrBookmarkNotFound       = errors.New("bookmark not found")
+	ErrBookmarkFolderNotFound = errors.New("bookmark folder not found")
+	ErrBookmarkFolderExists   = errors.New("bookmark folder already exists")
+	ErrInvalidBookmarkFolder  = errors.New("folder name must be 1 to 50 characters")
+)
+
+type BookmarkController struct {
+	DB *sql.DB
+}
+
+func NewBookmarkController(db *sql.DB) *BookmarkController {
+	return &BookmarkController{DB: db}
+}
+
+// BookmarkQuery selects a page of a user's bookmarks, newest first
+type BookmarkQuery struct {
+	// Before, when set, returns bookmarks older than this ID
+	Before int
+	Limit  int
+	// Type limits the list to post or comment bookmarks
+	Type string
+	// FolderID limits the list to one folder; -1 selects bookmarks that
+	// are in no folder
+	FolderID int
+}
+
+// AddBookmark bookmarks a published post or a comment on one, unless a
+// moderator hid it. Bookmarking the same target again keeps the bookmark and
+// moves it to folderID, where 0 takes it out of its folder. It reports whether a new bookmark was created.
+func (bc *BookmarkController) AddBookmark(userID int, targetType string, targetID, folderID int) (bool, error) {
+	var query string
+	switch targetType {
+	case BookmarkTargetPost:
+		query = "SELECT COUNT(*) FROM posts WHERE id = ? AND status = ? AND hidden = 0"
+	case BookmarkTargetComment:
+		query = `
+			SELECT COUNT(*) FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.id = ? AND p.status = ? AND p.hidden = 0 AND c.hidden = 0`
+	default:
+		return false, ErrInvalidBookmarkTarget
+	}
+
+	tx, err := bc.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(query, targetID, PostStatusPublished).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check bookmark target: %w", err)
+	}
+	if count == 0 {
+		return false, ErrBookmarkTargetNotFound
+	}
+
+	var folder interface{}
+	if folderID > 0 {
+		err := tx.QueryRow("SELECT COUNT(*) FROM bookmark_folders WHERE id = ? AND user_id = ?", folderID, userID).Scan(&count)
+		if err != nil {
+			return false, fmt.Errorf("failed to check bookmark folder: %w", err)
+		}
+		if count == 0 {
+			return false, ErrBookmarkFolderNotFound
+		}
+		folder = folderID
+	}
+
+	var existing int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM bookmarks WHERE user_id = ? AND target_type = ? AND target_id = ?
+	`, userID, targetType, targetID).Scan(&existing)
+	if err != nil {
+		return false, fmt.Errorf("failed to check bookmark: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO bookmarks (user_id, target_type, target_id, folder_id, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id, target_type, target_id) DO UPDATE SET folder_id = excluded.folder_id
+	`, userID, targetType, targetID, folder)
+	if err != nil {
+		return false, fmt.Errorf("failed to save bookmark: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit bookmark: %w", err)
+	}
+	return existing == 0, nil
+}
+
+// RemoveBookmark deletes the user's bookmark on a target
+func (bc *BookmarkController) RemoveBookmark(userID int, targetType string, targetID int) error {
+	if targetType != BookmarkTargetPost && targetType != BookmarkTargetComment {
+		return ErrInvalidBookmarkTarget
+	}
+	result, err := bc.DB.Exec(`
+		DELETE FROM bookmarks WHERE user_id = ? AND target_type = ? AND target_id = ?
+	`, userID, targetType, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to delete bookmark: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBookmarkNotFound
+	}
+	return nil
+}
+
+// GetBookmarks returns a page of the user's bookmarks with a summary of each
+// target, and whether older bookmarks remain
+func (bc *BookmarkController) GetBookmarks(userID int, q BookmarkQuery) ([]models.Bookmark, bool, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultBookmarkPageSize
+	}
+	if limit > MaxBookmarkPageSize {
+		limit = MaxBookmarkPageSize
+	}
+
+	query := `
+		SELECT b.id, b.target_type, b.target_id, COALESCE(b.folder_id, 0), COALESCE(f.name, ''), b.created_at,
+		       COALESCE(p.id, 0), COALESCE(p.title, ''),
+		       CASE b.target_type WHEN 'comment' THEN COALESCE(c.author, '') ELSE COALESCE(p.author, '') END,
+		       CASE b.target_type WHEN 'comment' THEN COALESCE(c.content, '') ELSE COALESCE(p.content, '') END,
+		       COALESCE(p.hidden, 0) OR (b.target_type = 'comment' AND COALESCE(c.hidden, 0))
+		FROM bookmarks b
+		LEFT JOIN bookmark_folders f ON f.id = b.folder_id
+		LEFT JOIN comments c ON b.target_type = 'comment' AND c.id = b.target_id
+		LEFT JOIN posts p ON p.id = CASE b.target_type WHEN 'comment' THEN c.post_id ELSE b.target_id END
+		WHERE b.user_id = ?`
+	args := []interface{}{userID}
+	if q.Before > 0 {
+		query += " AND b.id < ?"
+		args = append(args, q.Before)
+	}
+	switch q.Type {
+	case "":
+	case BookmarkTargetPost, BookmarkTargetComment:
+		query += " AND b.target_type = ?"
+		args = append(args, q.Type)
+	default:
+		return nil, false, ErrInvalidBookmarkTarget
+	}
+	if q.FolderID > 0 {
+		query += " AND b.folder_id = ?"
+		args = append(args, q.FolderID)
+	} else if q.FolderID < 0 {
+		query += " AND b.folder_id IS NULL"
+	}
+	query += " ORDER BY b.id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := bc.DB.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	bookmarks := make([]models.Bookmark, 0, limit)
+	for rows.Next() {
+		var b models.Bookmark
+		var content string
+		err := rows.Scan(&b.ID, &b.TargetType, &b.TargetID, &b.FolderID, &b.FolderName, &b.CreatedAt,
+			&b.PostID, &b.PostTitle, &b.Author, &content, &b.Hidden)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan bookmark: %w", err)
+		}
+		// Content a moderator hid stays bookmarked but is not shown
+		if !b.Hidden {
+			b.Excerpt = notificationExcerpt(content)
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate bookmarks: %w", err)
+	}
+
+	hasMore := len(bookmarks) > limit
+	if hasMore {
+		bookmarks = bookmarks[:limit]
+	}
+	return bookmarks, hasMore, nil
+}
+
+// BookmarkedPostIDs returns which of postIDs the user has bookmarked
+func (bc *BookmarkController) BookmarkedPostIDs(userID int, postIDs []int) (map[int]bool, error) {
+	bookmarked := make(map[int]bool)
+	if len(postIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := []interface{}{userID, BookmarkTargetPost}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+	rows, err := bc.DB.Query(`
+		SELECT target_id FROM bookmarks
+		WHERE user_id = ? AND target_type = ? AND target_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bookmarked posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmarked post: %w", err)
+		}
+		bookmarked[id] = true
+	}
+	return bookmarked, rows.Err()
+}
+
+// GetFolders lists the user's bookmark folders by name with the number of
+// bookmarks in each
+func (bc *BookmarkController) GetFolders(userID int) ([]models.BookmarkFolder, error) {
+	rows, err := bc.DB.Query(`
+		SELECT f.id, f.name, COUNT(b.id), f.created_at
+		FROM bookmark_folders f
+		LEFT JOIN bookmarks b ON b.folder_id = f.id
+		WHERE f.user_id = ?
+		GROUP BY f.id
+		ORDER BY f.name COLLATE NOCASE
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bookmark folders: %w", err)
+	}
+	defer rows.Close()
+
+	folders := make([]models.BookmarkFolder, 0)
+	for rows.Next() {
+		var f models.BookmarkFolder
+		if err := rows.Scan(&f.ID, &f.Name, &f.Count, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark folder: %w", err)
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// CreateFolder adds a bookmark folder and returns its ID
+func (bc *BookmarkController) CreateFolder(userID int, name string) (int, error) {
+	name, err := bookmarkFolderName(name)
+	if err != nil {
+		return 0, err
+	}
+	result, err := bc.DB.Exec(`
+		INSERT INTO bookmark_folders (user_id, name, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, userID, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, ErrBookmarkFolderExists
+		}
+		return 0, fmt.Errorf("failed to create bookmark folder: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get bookmark folder ID: %w", err)
+	}
+	return int(id), nil
+}
+
+// RenameFolder renames one of the user's bookmark folders
+func (bc *BookmarkController) RenameFolder(userID, folderID int, name string) error {
+	name, err := bookmarkFolderName(name)
+	if err != nil {
+		return err
+	}
+	result, err := bc.DB.Exec("UPDATE bookmark_folders SET name = ? WHERE id = ? AND user_id = ?", name, folderID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrBookmarkFolderExists
+		}
+		return fmt.Errorf("failed to rename bookmark folder: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBookmarkFolderNotFound
+	}
+	return nil
+}
+
+// DeleteFolder removes one of the user's bookmark folders. Its bookmarks
+// are kept outside any folder.
+func (bc *BookmarkController) DeleteFolder(userID, folderID int) error {
+	result, err := bc.DB.Exec("DELETE FROM bookmark_folders WHERE id = ? AND user_id = ?", folderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete bookmark folder: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBookmarkFolderNotFound
+	}
+	return nil
+}
+
+// bookmarkFolderName trims a folder name and checks its length
+func bookmarkFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > MaxBookmarkFolderNameLength {
+		return "", ErrInvalidBookmarkFolder
+	}
+	return name, nil
+}
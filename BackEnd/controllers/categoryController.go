@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Category validation errors
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("a category with this slug already exists")
+)
+
+// MaxPostCategories caps how many categories a single post can be filed under
+const MaxPostCategories = 5
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type CategoryController struct {
+	DB *sql.DB
+}
+
+func NewCategoryController(db *sql.DB) *CategoryController {
+	return &CategoryController{DB: db}
+}
+
+func validateCategory(c *models.Category) error {
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+
+	if len(c.Slug) == 0 || len(c.Slug) > 32 || !categorySlugPattern.MatchString(c.Slug) {
+		return errors.New("slug must be 1-32 lowercase letters, digits or single hyphens")
+	}
+	if c.Name == "" || len(c.Name) > 50 {
+		return errors.New("name must be between 1 and 50 characters")
+	}
+	if len(c.Description) > 500 {
+		return errors.New("description must be at most 500 characters")
+	}
+	return nil
+}
+
+// GetCategories lists categories in display order with their post counts.
+// Archived categories are only included when includeArchived is set.
+func (cc *CategoryController) GetCategories(includeArchived bool) ([]models.Category, error) {
+	rows, err := cc.DB.Query(`
+		SELECT c.id, c.slug, c.name, c.description, c.position, c.archived, c.created_at,
+		       COUNT(p.id)
+		FROM categories c
+		LEFT JOIN post_categories pc ON pc.category_id = c.id
+		LEFT JOIN posts p ON p.id = pc.post_id AND p.status = 'published' AND p.hidden = 0
+		WHERE c.archived = false OR ?
+		GROUP BY c.id
+		ORDER BY c.position, c.name COLLATE NOCASE
+	`, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make([]models.Category, 0)
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Position, &c.Archived, &c.CreatedAt, &c.PostCount); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryBySlug returns a single category, archived or not
+func (cc *CategoryController) GetCategoryBySlug(slug string) (models.Category, error) {
+	var c models.Category
+	err := cc.DB.QueryRow(`
+		SELECT c.id, c.slug, c.name, c.description, c.position, c.archived, c.created_at,
+		       (SELECT COUNT(*) FROM post_categories pc
+		        JOIN posts p ON p.id = pc.post_id AND p.status = 'published' AND p.hidden = 0
+		        WHERE pc.category_id = c.id)
+		FROM categories c
+		WHERE c.slug = ?
+	`, strings.ToLower(strings.TrimSpace(slug))).Scan(
+		&c.ID, &c.Slug, &c.Name, &c.Description, &c.Position, &c.Archived, &c.CreatedAt, &c.PostCount,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrCategoryNotFound
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to fetch category: %w", err)
+	}
+	return c, nil
+}
+
+// CreateCategory validates and stores a new category
+func (cc *CategoryController) CreateCategory(c *models.Category) error {
+	if err := validateCategory(c); err != nil {
+		return err
+	}
+
+	result, err := cc.DB.Exec(`
+		INSERT INTO categories (slug, name, description, position, archived)
+		VALUES (?, ?, ?, ?, ?)
+	`, c.Slug, c.Name, c.Description, c.Position, c.Archived)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrCategoryExists
+		}
+		return fmt.Errorf("failed to insert category: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	c.ID = int(id)
+	return nil
+}
+
+// UpdateCategory applies the non-nil fields of req to the category with the
+// given ID and returns the updated category
+func (cc *CategoryController) UpdateCategory(id int, req models.CategoryRequest) (models.Category, error) {
+	var c models.Category
+	err := cc.DB.QueryRow(`
+		SELECT id, slug, name, description, position, archived, created_at
+		FROM categories WHERE id = ?
+	`, id).Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.Position, &c.Archived, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrCategoryNotFound
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to fetch category: %w", err)
+	}
+
+	if req.Slug != nil {
+		c.Slug = *req.Slug
+	}
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.Description != nil {
+		c.Description = *req.Description
+	}
+	if req.Position != nil {
+		c.Position = *req.Position
+	}
+	if req.Archived != nil {
+		c.Archived = *req.Archived
+	}
+	if err := validateCategory(&c); err != nil {
+		return c, err
+	}
+
+	_, err = cc.DB.Exec(`
+		UPDATE categories
+		SET slug = ?, name = ?, description = ?, position = ?, archived = ?
+		WHERE id = ?
+	`, c.Slug, c.Name, c.Description, c.Position, c.Archived, c.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return c, ErrCategoryExists
+		}
+		return c, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return cc.GetCategoryBySlug(c.Slug)
+}
+
+// DeleteCategory removes a category and unlinks its posts. The posts
+// themselves are kept.
+func (cc *CategoryController) DeleteCategory(id int) error {
+	tx, err := cc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM post_categories WHERE category_id = ?", id); err != nil {
+		return fmt.Errorf("failed to unlink posts: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM post_pins WHERE category_id = ?", id); err != nil {
+		return fmt.Errorf("failed to unpin posts: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return ErrCategoryNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ResolvePostCategories parses the comma-separated category field sent by
+// the post form and checks every slug against the active categories. The
+// returned categories are deduplicated and in the order given.
+func (cc *CategoryController) ResolvePostCategories(raw string) ([]models.Category, error) {
+	var slugs []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		slug := strings.ToLower(strings.TrimSpace(part))
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+	}
+	if len(slugs) == 0 {
+		return nil, errors.New("at least one category is required")
+	}
+	if len(slugs) > MaxPostCategories {
+		return nil, fmt.Errorf("a post can have at most %d categories", MaxPostCategories)
+	}
+
+	categories := make([]models.Category, 0, len(slugs))
+	for _, slug := range slugs {
+		c, err := cc.GetCategoryBySlug(slug)
+		if errors.Is(err, ErrCategoryNotFound) || (err == nil && c.Archived) {
+			return nil, fmt.Errorf("unknown category %q", slug)
+		}
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// categorySlugs joins category slugs into the legacy posts.category text
+func categorySlugs(categories []models.Category) string {
+	slugs := make([]string, len(categories))
+	for i, c := range categories {
+		slugs[i] = c.Slug
+	}
+	return strings.Join(slugs, ",")
+}
+
+// setPostCategories replaces the category links of a post inside tx
+func setPostCategories(tx *sql.Tx, postID int, categories []models.Category) error {
+	if _, err := tx.Exec("DELETE FROM post_categories WHERE post_id = ?", postID); err != nil {
+		return fmt.Errorf("failed to clear post categories: %w", err)
+	}
+	for _, c := range categories {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO post_categories (post_id, category_id) VALUES (?, ?)
+		`, postID, c.ID)
+		if err != nil {
+			return fmt.Errorf("failed to link post category: %w", err)
+		}
+	}
+	return nil
+}
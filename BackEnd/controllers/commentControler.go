@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/richtext"
 )
 
 type CommentController struct {
@@ -25,6 +27,22 @@ func (cCtrl *CommentController) InsertComment(comment models.Comment) (int, erro
 		return 0, fmt.Errorf("comment content too long")
 	}
 
+	// Hidden posts take no new comments, and neither do locked ones
+	published, err := NewPostController(cCtrl.DB).IsPublished(comment.PostID)
+	if err != nil {
+		return 0, err
+	}
+	if !published {
+		return 0, ErrPostNotFound
+	}
+	locked, err := IsPostLocked(cCtrl.DB, comment.PostID)
+	if err != nil {
+		return 0, err
+	}
+	if locked {
+		return 0, ErrPostLocked
+	}
+
 	// Check depth limit for replies
 	if comment.ParentID.Valid {
 		var depth int
@@ -53,10 +71,10 @@ func (cCtrl *CommentController) InsertComment(comment models.Comment) (int, erro
 	}
 
 	result, err := cCtrl.DB.Exec(`
-		INSERT INTO comments (post_id, user_id, author, content, likes, dislikes, user_vote, timestamp, parent_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
-	`, comment.PostID, comment.UserID, comment.Author, comment.Content, comment.Likes, comment.Dislikes,
-		comment.UserVote, comment.Timestamp, comment.ParentID)
+		INSERT INTO comments (post_id, user_id, author, content, content_html, likes, dislikes, user_vote, timestamp, parent_id, media_path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, comment.PostID, comment.UserID, comment.Author, comment.Content, richtext.Render(comment.Content), comment.Likes, comment.Dislikes,
+		comment.UserVote, comment.Timestamp, comment.ParentID, sql.NullString{String: comment.MediaURL, Valid: comment.MediaURL != ""})
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert comment: %w", err)
 	}
@@ -66,6 +84,11 @@ func (cCtrl *CommentController) InsertComment(comment models.Comment) (int, erro
 		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
 	}
 
+	// Comments count towards the post's hot score
+	if err := database.UpdatePostScores(cCtrl.DB, comment.PostID); err != nil {
+		return 0, err
+	}
+
 	return int(commentID), nil
 }
 
@@ -78,8 +101,8 @@ func (cc *CommentController) GetCommentsByPostID(postID string) ([]models.Commen
 	// Simplified query to get all comments for the post
 	rows, err := cc.DB.Query(`
 		SELECT 
-			id, post_id, user_id, parent_id, author, content,
-			likes, dislikes, user_vote, timestamp
+			id, post_id, user_id, parent_id, author, content, COALESCE(content_html, ''),
+			likes, dislikes, user_vote, timestamp, COALESCE(media_path, ''), hidden
 		FROM comments 
 		WHERE post_id = ?
 		ORDER BY timestamp ASC
@@ -94,12 +117,17 @@ func (cc *CommentController) GetCommentsByPostID(postID string) ([]models.Commen
 		var comment models.Comment
 		err := rows.Scan(
 			&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID,
-			&comment.Author, &comment.Content, &comment.Likes, &comment.Dislikes,
-			&comment.UserVote, &comment.Timestamp,
+			&comment.Author, &comment.Content, &comment.ContentHTML, &comment.Likes, &comment.Dislikes,
+			&comment.UserVote, &comment.Timestamp, &comment.MediaURL, &comment.Hidden,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan comment: %w", err)
 		}
+		// Hidden comments keep their place in the thread so replies stay
+		// attached
+		if comment.Hidden {
+			comment.Content, comment.ContentHTML, comment.MediaURL = "", "", ""
+		}
 		comments = append(comments, comment)
 	}
 
@@ -108,17 +136,9 @@ func (cc *CommentController) GetCommentsByPostID(postID string) ([]models.Commen
 
 func (cc *CommentController) GetCommentCountByPostID(postID int) (int, error) {
 	var count int
+	// Replies carry the post_id of their thread, so a flat count covers them
 	err := cc.DB.QueryRow(`
-        WITH RECURSIVE comment_tree AS (
-            SELECT id, parent_id
-            FROM comments
-            WHERE post_id = ?
-            UNION ALL
-            SELECT c.id, c.parent_id
-            FROM comments c
-            INNER JOIN comment_tree ct ON c.parent_id = ct.id
-        )
-        SELECT COUNT(*) FROM comment_tree
+        SELECT COUNT(*) FROM comments WHERE post_id = ?
     `, postID).Scan(&count)
 
 	if err != nil {
@@ -130,26 +150,61 @@ func (cc *CommentController) GetCommentCountByPostID(postID int) (int, error) {
 
 // DeleteComment deletes a comment by its ID
 func (cc *CommentController) DeleteComment(commentID int) error {
+	tx, err := cc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	mediaPaths, err := deleteComment(tx, commentID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	releaseUploads(cc.DB, mediaPaths)
+	return nil
+}
+
+// deleteComment deletes a comment inside tx and rescores its post. It
+// returns the stored path of the comment's image, if any, for
+// releaseUploads once tx has committed.
+func deleteComment(tx *sql.Tx, commentID int) ([]string, error) {
+	var postID int
+	var mediaPath sql.NullString
+	err := tx.QueryRow("SELECT post_id, media_path FROM comments WHERE id = ?", commentID).Scan(&postID, &mediaPath)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch comment: %w", err)
+	}
+
 	// Execute the delete query
-	result, err := cc.DB.Exec(`
+	result, err := tx.Exec(`
         DELETE FROM comments 
         WHERE id = ?
     `, commentID)
 	if err != nil {
-		return fmt.Errorf("failed to delete comment: %w", err)
+		return nil, fmt.Errorf("failed to delete comment: %w", err)
 	}
 
 	// Check if the comment was actually deleted
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no comment found with ID: %d", commentID)
+		return nil, fmt.Errorf("no comment found with ID: %d", commentID)
 	}
 
-	return nil
+	if err := database.UpdatePostScores(tx, postID); err != nil {
+		return nil, err
+	}
+	if mediaPath.Valid {
+		return []string{mediaPath.String}, nil
+	}
+	return nil, nil
 }
 
 // IsCommentAuthor checks if the given user is the author of the comment
@@ -167,12 +222,22 @@ func (cc *CommentController) IsCommentAuthor(commentID, userID int) (bool, error
 	return count > 0, nil
 }
 
+// GetCommentPostID returns the ID of the post a comment belongs to
+func (cc *CommentController) GetCommentPostID(commentID int) (int, error) {
+	var postID int
+	err := cc.DB.QueryRow("SELECT post_id FROM comments WHERE id = ?", commentID).Scan(&postID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch comment post: %w", err)
+	}
+	return postID, nil
+}
+
 func (cc *CommentController) UpdateComment(commentID int, content string) error {
 	result, err := cc.DB.Exec(`
         UPDATE comments 
-        SET content = ?
+        SET content = ?, content_html = ?
         WHERE id = ?
-    `, content, commentID)
+    `, content, richtext.Render(content), commentID)
 	if err != nil {
 		return fmt.Errorf("failed to update comment: %w", err)
 	}
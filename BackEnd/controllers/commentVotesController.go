@@ -2,9 +2,14 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 )
 
+// ErrCommentNotFound is returned for comments that are missing, hidden by a
+// moderator or on a post that cannot be seen
+var ErrCommentNotFound = errors.New("comment not found")
+
 type CommentVotesController struct {
 	DB *sql.DB
 }
@@ -69,14 +74,20 @@ func (cc *CommentVotesController) GetCommentVotes(commentID int) (int, int, erro
 }
 
 func (cc *CommentVotesController) HandleCommentVote(commentID, userID int, voteType string) error {
-	// First check if comment exists
+	// First check if comment exists and can be seen
 	var exists bool
-	err := cc.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM comments WHERE id = ?)", commentID).Scan(&exists)
+	err := cc.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.id = ? AND c.hidden = 0 AND p.status = 'published' AND p.hidden = 0
+		)
+	`, commentID).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("error checking comment existence: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("comment with ID %d does not exist", commentID)
+		return ErrCommentNotFound
 	}
 
 	tx, err := cc.DB.Begin()
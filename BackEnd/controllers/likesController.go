@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
 )
 
@@ -38,8 +39,8 @@ func (Lc *LikesController) InsertLikes(like models.Likes) error {
 
 	// Insert the new like
 	_, err = Lc.DB.Exec(`
-        INSERT INTO likes (post_id, user_id, user_vote)
-        VALUES (?, ?, ?);
+        INSERT INTO likes (post_id, user_id, user_vote, created_at)
+        VALUES (?, ?, ?, CURRENT_TIMESTAMP);
     `, like.PostId, like.UserId, like.UserVote)
 	if err != nil {
 		return fmt.Errorf("failed to insert like: %w", err)
@@ -75,7 +76,8 @@ func (lc *LikesController) UpdatePostVotes(postID int) error {
 		return fmt.Errorf("failed to update post votes for post %d: %w", postID, err)
 	}
 
-	return nil
+	// Keep the feed rankings in step with the votes
+	return database.UpdatePostScores(lc.DB, postID)
 }
 
 func (lc *LikesController) GetPostVotes(postID int) (int, int, error) {
@@ -121,7 +123,7 @@ func (lc *LikesController) RemoveUserVote(postID, userID int) error {
 
 // AddUserVote adds the user's vote to the database
 func (lc *LikesController) AddUserVote(postID, userID int, vote string) error {
-	query := `INSERT INTO likes (post_id, user_id, user_vote) VALUES (?, ?, ?)`
+	query := `INSERT INTO likes (post_id, user_id, user_vote, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`
 	_, err := lc.DB.Exec(query, postID, userID, vote)
 	if err != nil {
 		return err
@@ -129,8 +131,17 @@ func (lc *LikesController) AddUserVote(postID, userID int, vote string) error {
 	return nil
 }
 
-// HandleVote handles the user's vote (like or dislike)
+// HandleVote handles the user's vote (like or dislike). Drafts and posts a
+// moderator hid cannot be voted on and give ErrPostNotFound.
 func (lc *LikesController) HandleVote(postID, userID int, vote string) error {
+	published, err := NewPostController(lc.DB).IsPublished(postID)
+	if err != nil {
+		return err
+	}
+	if !published {
+		return ErrPostNotFound
+	}
+
 	// Check if the user has already voted
 	existingVote, err := lc.CheckUserVote(postID, userID)
 	if err != nil {
@@ -203,7 +214,7 @@ func (lc *LikesController) GetUserLikesPosts(userID int) ([]models.Post, error)
         SELECT p.id, p.title, p.author, p.user_id, p.category, p.likes, p.dislikes, p.user_vote, p.content, p.image_url, p.timestamp
         FROM posts p
         INNER JOIN likes l ON p.id = l.post_id
-        WHERE l.user_id = ? AND l.user_vote = 'like';
+        WHERE l.user_id = ? AND l.user_vote = 'like' AND p.status = 'published' AND p.hidden = 0;
     `
 	rows, err := lc.DB.Query(query, userID)
 	if err != nil {
@@ -233,5 +244,9 @@ func (lc *LikesController) GetUserLikesPosts(userID int) ([]models.Post, error)
 		userLikes = append(userLikes, post)
 	}
 
+	if err := loadPostAttachments(lc.DB, userLikes); err != nil {
+		return nil, err
+	}
+
 	return userLikes, nil
 }
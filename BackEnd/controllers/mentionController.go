@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Mention source types
+const (
+	MentionSourcePost    = "post"
+	MentionSourceComment = "comment"
+	MentionSourceMessage = "message"
+)
+
+// MaxMentionsPerContent caps how many users a single post, comment or
+// message can notify
+const MaxMentionsPerContent = 20
+
+var (
+	// mentionPattern matches @nickname where the @ does not follow a word
+	// character, so email addresses are not mentions. Nicknames follow the
+	// rules in IsValidNickname.
+	mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]{3,30})\b`)
+	// mentionCodePattern matches code blocks and spans, which never mention
+	mentionCodePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+)
+
+// MentionSource identifies the content a mention appears in
+type MentionSource struct {
+	Type     string
+	ID       int
+	AuthorID int
+	// PostID is the post the content belongs to, or 0 for messages
+	PostID int
+	// Audience, when set, limits who can be mentioned; a direct message
+	// only reaches its recipient
+	Audience []int
+}
+
+// PostMentionSource describes a post as a mention source
+func PostMentionSource(post models.Post) MentionSource {
+	return MentionSource{Type: MentionSourcePost, ID: post.ID, AuthorID: post.UserID, PostID: post.ID}
+}
+
+type MentionController struct {
+	DB *sql.DB
+}
+
+func NewMentionController(db *sql.DB) *MentionController {
+	return &MentionController{DB: db}
+}
+
+// ParseMentions returns the distinct nicknames mentioned in content, in order
+// of first appearance
+func ParseMentions(content string) []string {
+	content = mentionCodePattern.ReplaceAllString(content, " ")
+
+	var nicknames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		nickname := match[1]
+		if seen[nickname] {
+			continue
+		}
+		seen[nickname] = true
+		nicknames = append(nicknames, nickname)
+		if len(nicknames) == MaxMentionsPerContent {
+			break
+		}
+	}
+	return nicknames
+}
+
+// RecordMentions stores the mentions found in content and creates a mention
+// notification for every user mentioned in this source for the first time,
+// unless they switched mention notifications off.
+// Mentions are never removed on edit, so re-saving content, or restoring a
+// mention an earlier edit dropped, does not notify anyone again. The new
+// notifications are returned for live delivery.
+func (mc *MentionController) RecordMentions(src MentionSource, content string) ([]models.Notification, error) {
+	nicknames := ParseMentions(content)
+	if len(nicknames) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(nicknames)), ",")
+	args := make([]interface{}, len(nicknames))
+	for i, nickname := range nicknames {
+		args[i] = nickname
+	}
+	rows, err := mc.DB.Query("SELECT id FROM users WHERE nickname IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan mentioned user: %w", err)
+		}
+		if id != src.AuthorID && (src.Audience == nil || containsInt(src.Audience, id)) {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	actorName := GetUsernameByID(mc.DB, src.AuthorID)
+	excerpt := notificationExcerpt(content)
+
+	tx, err := mc.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var notifications []models.Notification
+	for _, userID := range userIDs {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO mentions (source_type, source_id, mentioned_user_id, author_id, created_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, src.Type, src.ID, userID, src.AuthorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record mention: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("failed to check mention: %w", err)
+		} else if n == 0 {
+			// Already mentioned in this source
+			continue
+		}
+
+		notification := models.Notification{
+			UserID:     userID,
+			Type:       NotificationMention,
+			ActorID:    src.AuthorID,
+			ActorName:  actorName,
+			SourceType: src.Type,
+			SourceID:   src.ID,
+			PostID:     src.PostID,
+			Excerpt:    excerpt,
+		}
+		created, err := insertNotification(tx, &notification)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit mentions: %w", err)
+	}
+	return notifications, nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/imaging"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
+)
+
+// MessageMediaURLExpiry is how long the signed URL of a direct message
+// attachment stays valid; message history is signed afresh on every load
+const MessageMediaURLExpiry = 24 * time.Hour
+
+// SaveMessageAttachment stores an image to send with a direct message. Only
+// the medium variant is stored, at most 1024 pixels on its longest side. The
+// returned attachment's URL is the stored path the message refers to;
+// MediumURL is a signed URL to show it with. Message images are kept apart
+// per uploader, so only their own identical images are shared.
+func SaveMessageAttachment(ctx context.Context, db *sql.DB, userID int, file multipart.File, header *multipart.FileHeader) (models.Attachment, error) {
+	upload, err := prepareUpload(file, header.Size, MaxUploadSize)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment, err := upload.storeVariant(ctx, db, UploadFolderMessages, messageMediaPrefix(userID), imaging.VariantMedium)
+	if err != nil {
+		return attachment, err
+	}
+	attachment.MediumURL, attachment.ThumbnailURL = MessageMediaURL(attachment.URL), ""
+	return attachment, nil
+}
+
+func messageMediaPrefix(userID int) string {
+	return strconv.Itoa(userID) + "_"
+}
+
+// ValidMessageMedia reports whether mediaPath is a direct message attachment
+// uploaded by senderID that is still stored, so nobody can send someone
+// else's upload
+func ValidMessageMedia(db *sql.DB, senderID int, mediaPath string) bool {
+	key, err := storage.KeyForPath(mediaPath)
+	if err != nil || !strings.HasPrefix(key, UploadFolderMessages+"/"+messageMediaPrefix(senderID)) {
+		return false
+	}
+	var stored int
+	err = db.QueryRow("SELECT COUNT(*) FROM uploads WHERE path = ?", storage.PathForKey(key)).Scan(&stored)
+	if err != nil {
+		logger.Error("Failed to look up message attachment %s: %v", key, err)
+		return false
+	}
+	return stored > 0
+}
+
+// MessageMediaURL returns a signed URL for a direct message attachment, or
+// an empty string when there is none
+func MessageMediaURL(mediaPath string) string {
+	if mediaPath == "" {
+		return ""
+	}
+	key, err := storage.KeyForPath(mediaPath)
+	if err != nil {
+		return ""
+	}
+	signed, err := blobStore.SignedURL(key, MessageMediaURLExpiry)
+	if err != nil {
+		logger.Error("Failed to sign message attachment %s: %v", key, err)
+		return ""
+	}
+	return signed
+}
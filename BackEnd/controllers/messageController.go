@@ -13,11 +13,18 @@ import (
 )
 
 type Message struct {
-	ID         int64     `json:"id"`
-	SenderID   int64     `json:"sender_id"`
-	ReceiverID int64     `json:"receiver_id"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         int64  `json:"id"`
+	SenderID   int64  `json:"sender_id"`
+	ReceiverID int64  `json:"receiver_id"`
+	Content    string `json:"content"`
+	// ContentHTML is Content rendered by the richtext package
+	ContentHTML string `json:"content_html"`
+	// MediaURL is a signed URL of the attached image, see MessageMediaURL
+	MediaURL  string    `json:"media_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Hidden messages were taken down by a moderator and are listed without
+	// their content
+	Hidden bool `json:"hidden,omitempty"`
 }
 
 type Conversation struct {
@@ -29,6 +36,23 @@ type Conversation struct {
 	LastMessageTime time.Time `json:"last_message_time"`
 }
 
+// Contact is one entry of the contact list: every other user, with the
+// conversation summary when one exists
+type Contact struct {
+	UserID          int64      `json:"user_id"`
+	Nickname        string     `json:"nickname"`
+	IsOnline        bool       `json:"is_online"`
+	LastSeen        *time.Time `json:"last_seen,omitempty"`
+	UnreadCount     int        `json:"unread_count"`
+	LastMessage     string     `json:"last_message,omitempty"`
+	LastMessageTime *time.Time `json:"last_message_time,omitempty"`
+	LastSenderID    int64      `json:"last_sender_id,omitempty"`
+}
+
+// MessagePreviewLength is the maximum number of characters of a message
+// shown in contact list previews
+const MessagePreviewLength = 80
+
 type MessageController struct {
 	db *sql.DB
 }
@@ -40,7 +64,7 @@ func NewMessageController(db *sql.DB) *MessageController {
 func (mc *MessageController) GetMessages(userID, otherUserID int64, page int) ([]Message, error) {
 	offset := (page - 1) * 10
 	rows, err := mc.db.Query(`
-        SELECT id, sender_id, receiver_id, content, created_at 
+        SELECT id, sender_id, receiver_id, content, COALESCE(content_html, ''), COALESCE(media_url, ''), created_at, hidden 
         FROM messages 
         WHERE (sender_id = ? AND receiver_id = ?) 
            OR (sender_id = ? AND receiver_id = ?)
@@ -55,10 +79,15 @@ func (mc *MessageController) GetMessages(userID, otherUserID int64, page int) ([
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.CreatedAt)
+		var mediaPath string
+		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &msg.ContentHTML, &mediaPath, &msg.CreatedAt, &msg.Hidden)
 		if err != nil {
 			return nil, err
 		}
+		if msg.Hidden {
+			msg.Content, msg.ContentHTML, mediaPath = "", "", ""
+		}
+		msg.MediaURL = MessageMediaURL(mediaPath)
 		messages = append(messages, msg)
 	}
 	return messages, nil
@@ -72,7 +101,7 @@ func (mc *MessageController) GetConversations(userID int64) ([]Conversation, err
             COALESCE(us.is_online, false) as is_online,
             COALESCE(strftime('%Y-%m-%d %H:%M:%f', us.last_seen), CURRENT_TIMESTAMP) as last_seen,
             (
-                SELECT content 
+                SELECT CASE WHEN hidden THEN '' ELSE content END 
                 FROM messages m2 
                 WHERE (m2.sender_id = ? AND m2.receiver_id = u.id) 
                    OR (m2.sender_id = u.id AND m2.receiver_id = ?)
@@ -155,6 +184,120 @@ func (mc *MessageController) GetConversations(userID int64) ([]Conversation, err
 	return conversations, nil
 }
 
+// GetContacts returns every user except userID, ordered by the time of the
+// last message exchanged with them and then alphabetically by nickname
+func (mc *MessageController) GetContacts(userID int64, page, limit int) ([]Contact, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	rows, err := mc.db.Query(`
+        WITH ranked AS (
+            SELECT id,
+                   CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS other_id,
+                   ROW_NUMBER() OVER (
+                       PARTITION BY CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END
+                       ORDER BY created_at DESC, id DESC
+                   ) AS rn
+            FROM messages
+            WHERE sender_id = ? OR receiver_id = ?
+        ),
+        unread AS (
+            SELECT sender_id AS other_id, COUNT(*) AS unread_count
+            FROM messages
+            WHERE receiver_id = ? AND read_at IS NULL
+            GROUP BY sender_id
+        )
+        SELECT u.id, u.nickname,
+               COALESCE(us.is_online, false), us.last_seen,
+               COALESCE(un.unread_count, 0),
+               CASE WHEN lm.hidden THEN '' ELSE lm.content END, lm.created_at, lm.sender_id
+        FROM users u
+        LEFT JOIN ranked r ON r.other_id = u.id AND r.rn = 1
+        LEFT JOIN messages lm ON lm.id = r.id
+        LEFT JOIN unread un ON un.other_id = u.id
+        LEFT JOIN user_status us ON us.user_id = u.id
+        WHERE u.id <> ?
+        ORDER BY lm.created_at IS NULL, lm.created_at DESC, lm.id DESC, u.nickname COLLATE NOCASE ASC, u.id ASC
+        LIMIT ? OFFSET ?
+    `, userID, userID, userID, userID, userID, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %v", err)
+	}
+	defer rows.Close()
+
+	contacts := []Contact{}
+	for rows.Next() {
+		var c Contact
+		var lastSeen, lastMessageTime sql.NullTime
+		var lastMessage sql.NullString
+		var lastSenderID sql.NullInt64
+
+		err := rows.Scan(
+			&c.UserID,
+			&c.Nickname,
+			&c.IsOnline,
+			&lastSeen,
+			&c.UnreadCount,
+			&lastMessage,
+			&lastMessageTime,
+			&lastSenderID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contact: %v", err)
+		}
+
+		if lastSeen.Valid {
+			c.LastSeen = &lastSeen.Time
+		}
+		if lastMessage.Valid {
+			c.LastMessage = PreviewText(lastMessage.String, MessagePreviewLength)
+		}
+		if lastMessageTime.Valid {
+			c.LastMessageTime = &lastMessageTime.Time
+		}
+		c.LastSenderID = lastSenderID.Int64
+
+		contacts = append(contacts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating contacts: %v", err)
+	}
+
+	return contacts, nil
+}
+
+// GetUnreadCount returns how many messages from otherUserID userID has not read
+func (mc *MessageController) GetUnreadCount(userID, otherUserID int64) (int, error) {
+	var count int
+	err := mc.db.QueryRow(`
+        SELECT COUNT(*) FROM messages
+        WHERE receiver_id = ? AND sender_id = ? AND read_at IS NULL
+    `, userID, otherUserID).Scan(&count)
+	return count, err
+}
+
+// MarkConversationRead marks every message otherUserID sent to userID as read
+func (mc *MessageController) MarkConversationRead(userID, otherUserID int64) error {
+	_, err := mc.db.Exec(`
+        UPDATE messages SET read_at = ?
+        WHERE receiver_id = ? AND sender_id = ? AND read_at IS NULL
+    `, time.Now().UTC(), userID, otherUserID)
+	return err
+}
+
+// PreviewText shortens s to at most n characters, adding an ellipsis when cut
+func PreviewText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
 // GetUsersHandler returns a list of registered users.
 // It queries the users table and returns each user's id and nickname.
 func GetUsers(db *sql.DB) http.HandlerFunc {
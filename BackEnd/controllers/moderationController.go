@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Moderation actions recorded in the moderation log
+const (
+	ModerationPin        = "pin"
+	ModerationUnpin      = "unpin"
+	ModerationLock       = "lock"
+	ModerationUnlock     = "unlock"
+	ModerationAnnounce   = "announce"
+	ModerationUnannounce = "unannounce"
+)
+
+// Moderation actions taken on reported content, see ResolveReports
+const (
+	ModerationDismiss = "dismiss"
+	ModerationHide    = "hide"
+	ModerationDelete  = "delete"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+)
+
+// Moderation targets; warnings and suspensions target the author
+const (
+	ModerationTargetPost    = "post"
+	ModerationTargetComment = "comment"
+	ModerationTargetMessage = "message"
+	ModerationTargetUser    = "user"
+)
+
+// Moderation log page size bounds used when the caller does not pass a
+// usable limit
+const (
+	DefaultModerationLogPageSize = 50
+	MaxModerationLogPageSize     = 200
+)
+
+// MaxModerationReasonLength bounds the reason a moderator gives
+const MaxModerationReasonLength = 500
+
+// Moderation errors
+var (
+	ErrPostNotFound         = errors.New("post not found")
+	ErrPostLocked           = errors.New("this post is locked and no longer accepts comments")
+	ErrPostNotPinned        = errors.New("post is not pinned to this feed")
+	ErrPostNotInCategory    = errors.New("post is not filed under this category")
+	ErrInvalidPinPosition   = errors.New("pin position must not be negative")
+	ErrModerationReasonLong = fmt.Errorf("reason must be at most %d characters", MaxModerationReasonLength)
+)
+
+type ModerationController struct {
+	DB *sql.DB
+}
+
+func NewModerationController(db *sql.DB) *ModerationController {
+	return &ModerationController{DB: db}
+}
+
+// ModerationLogQuery selects a page of the moderation log
+type ModerationLogQuery struct {
+	// Before, when set, returns entries older than this ID
+	Before int
+	Limit  int
+	// TargetType and TargetID, when set, return the history of one target
+	TargetType string
+	TargetID   int
+}
+
+// logModerationAction appends an entry to the moderation log within the
+// transaction of the action, so both are stored or neither is
+func logModerationAction(tx *sql.Tx, entry models.ModerationLogEntry) error {
+	_, err := tx.Exec(`
+		INSERT INTO moderation_log (moderator_id, action, target_type, target_id, details, reason)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.ModeratorID, entry.Action, entry.TargetType, entry.TargetID, entry.Details, entry.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to record moderation action: %w", err)
+	}
+	return nil
+}
+
+func normalizeModerationReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > MaxModerationReasonLength {
+		return "", ErrModerationReasonLong
+	}
+	return reason, nil
+}
+
+// checkPublishedPost returns ErrPostNotFound unless the post is live;
+// drafts are invisible to moderators too
+func checkPublishedPost(tx *sql.Tx, postID int) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM posts WHERE id = ?", postID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && status != PostStatusPublished) {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch post: %w", err)
+	}
+	return nil
+}
+
+// pinScope resolves the category slug of a pin to its category ID, 0 for
+// the main feed
+func (mc *ModerationController) pinScope(categorySlug string) (int, string, error) {
+	if categorySlug == "" {
+		return 0, "the main feed", nil
+	}
+	category, err := NewCategoryController(mc.DB).GetCategoryBySlug(categorySlug)
+	if err != nil {
+		return 0, "", err
+	}
+	return category.ID, "category " + category.Slug, nil
+}
+
+// PinPost pins a post to the main feed, or to the feed of the category
+// with req.Category. A positive req.Position places it before the pins at
+// that position or later, zero after every other pin. Pinning a pinned post
+// again moves it.
+func (mc *ModerationController) PinPost(moderatorID, postID int, req models.ModerationRequest) error {
+	if req.Position < 0 {
+		return ErrInvalidPinPosition
+	}
+	reason, err := normalizeModerationReason(req.Reason)
+	if err != nil {
+		return err
+	}
+	categoryID, scope, err := mc.pinScope(req.Category)
+	if err != nil {
+		return err
+	}
+
+	tx, err := mc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkPublishedPost(tx, postID); err != nil {
+		return err
+	}
+	if categoryID != 0 {
+		var filed bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM post_categories WHERE post_id = ? AND category_id = ?)
+		`, postID, categoryID).Scan(&filed)
+		if err != nil {
+			return fmt.Errorf("failed to check post category: %w", err)
+		}
+		if !filed {
+			return ErrPostNotInCategory
+		}
+	}
+
+	position := req.Position
+	if position == 0 {
+		err = tx.QueryRow(`
+			SELECT COALESCE(MAX(position), 0) + 1 FROM post_pins WHERE category_id = ? AND post_id != ?
+		`, categoryID, postID).Scan(&position)
+	} else {
+		// Make room so the post lands before the pin holding its position
+		_, err = tx.Exec(`
+			UPDATE post_pins SET position = position + 1
+			WHERE category_id = ? AND position >= ? AND post_id != ?
+		`, categoryID, position, postID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find pin position: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO post_pins (post_id, category_id, position, pinned_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (post_id, category_id) DO UPDATE SET position = excluded.position
+	`, postID, categoryID, position, moderatorID)
+	if err != nil {
+		return fmt.Errorf("failed to pin post: %w", err)
+	}
+
+	err = logModerationAction(tx, models.ModerationLogEntry{
+		ModeratorID: moderatorID,
+		Action:      ModerationPin,
+		TargetType:  ModerationTargetPost,
+		TargetID:    postID,
+		Details:     fmt.Sprintf("pinned to %s at position %d", scope, position),
+		Reason:      reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UnpinPost removes a post's pin from the main feed, or from the feed of
+// the category with req.Category
+func (mc *ModerationController) UnpinPost(moderatorID, postID int, req models.ModerationRequest) error {
+	reason, err := normalizeModerationReason(req.Reason)
+	if err != nil {
+		return err
+	}
+	categoryID, scope, err := mc.pinScope(req.Category)
+	if err != nil {
+		return err
+	}
+
+	tx, err := mc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM post_pins WHERE post_id = ? AND category_id = ?", postID, categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to unpin post: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return ErrPostNotPinned
+	}
+
+	err = logModerationAction(tx, models.ModerationLogEntry{
+		ModeratorID: moderatorID,
+		Action:      ModerationUnpin,
+		TargetType:  ModerationTargetPost,
+		TargetID:    postID,
+		Details:     "unpinned from " + scope,
+		Reason:      reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SetPostLocked locks a post against new comments or unlocks it. Setting
+// the state a post already has is not logged.
+func (mc *ModerationController) SetPostLocked(moderatorID, postID int, locked bool, req models.ModerationRequest) error {
+	action := ModerationUnlock
+	if locked {
+		action = ModerationLock
+	}
+	return mc.setPostFlag(moderatorID, postID, "locked", locked, action, req)
+}
+
+// SetPostAnnouncement marks a post as an announcement or clears the mark.
+// Setting the state a post already has is not logged.
+func (mc *ModerationController) SetPostAnnouncement(moderatorID, postID int, announcement bool, req models.ModerationRequest) error {
+	action := ModerationUnannounce
+	if announcement {
+		action = ModerationAnnounce
+	}
+	return mc.setPostFlag(moderatorID, postID, "is_announcement", announcement, action, req)
+}
+
+// setPostFlag sets a boolean moderation column of a post and logs action
+// when the value changes
+func (mc *ModerationController) setPostFlag(moderatorID, postID int, column string, value bool, action string, req models.ModerationRequest) error {
+	reason, err := normalizeModerationReason(req.Reason)
+	if err != nil {
+		return err
+	}
+
+	tx, err := mc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkPublishedPost(tx, postID); err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE posts SET "+column+" = ? WHERE id = ? AND "+column+" != ?", value, postID, value)
+	if err != nil {
+		return fmt.Errorf("failed to %s post: %w", action, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return nil
+	}
+
+	err = logModerationAction(tx, models.ModerationLogEntry{
+		ModeratorID: moderatorID,
+		Action:      action,
+		TargetType:  ModerationTargetPost,
+		TargetID:    postID,
+		Reason:      reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// IsPostLocked reports whether a post is locked against new comments
+func IsPostLocked(db *sql.DB, postID int) (bool, error) {
+	var locked bool
+	err := db.QueryRow("SELECT locked FROM posts WHERE id = ?", postID).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check post lock: %w", err)
+	}
+	return locked, nil
+}
+
+// GetModerationLog returns a page of the moderation log, newest first, and
+// whether older entries exist
+func (mc *ModerationController) GetModerationLog(q ModerationLogQuery) ([]models.ModerationLogEntry, bool, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultModerationLogPageSize
+	}
+	if limit > MaxModerationLogPageSize {
+		limit = MaxModerationLogPageSize
+	}
+
+	query := `
+		SELECT l.id, l.moderator_id, COALESCE(u.nickname, ''), l.action, l.target_type, l.target_id,
+		       l.details, l.reason, l.created_at
+		FROM moderation_log l
+		LEFT JOIN users u ON u.id = l.moderator_id
+		WHERE 1 = 1`
+	args := []interface{}{}
+	if q.Before > 0 {
+		query += " AND l.id < ?"
+		args = append(args, q.Before)
+	}
+	if q.TargetType != "" {
+		query += " AND l.target_type = ?"
+		args = append(args, q.TargetType)
+	}
+	if q.TargetID > 0 {
+		query += " AND l.target_id = ?"
+		args = append(args, q.TargetID)
+	}
+	query += " ORDER BY l.id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := mc.DB.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch moderation log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.ModerationLogEntry, 0, limit)
+	hasMore := false
+	for rows.Next() {
+		if len(entries) == limit {
+			hasMore = true
+			break
+		}
+		var entry models.ModerationLogEntry
+		err := rows.Scan(&entry.ID, &entry.ModeratorID, &entry.Moderator, &entry.Action, &entry.TargetType,
+			&entry.TargetID, &entry.Details, &entry.Reason, &entry.CreatedAt)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan moderation log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate moderation log: %w", err)
+	}
+	return entries, hasMore, nil
+}
+
+// loadPinnedPosts returns the published posts pinned to the main feed
+// (categoryID 0) or a category's feed, in pin order
+func loadPinnedPosts(db *sql.DB, categoryID int) ([]models.Post, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.title, p.user_id, p.category, p.likes, p.dislikes,
+			   p.user_vote, p.content, COALESCE(p.content_html, ''), p.timestamp, p.image_url, p.comment_count,
+			   p.edited_at, p.status, p.view_count, p.locked, p.is_announcement, u.nickname
+		FROM post_pins pin
+		JOIN posts p ON p.id = pin.post_id
+		JOIN users u ON p.user_id = u.id
+		WHERE pin.category_id = ? AND p.status = 'published' AND p.hidden = 0
+		ORDER BY pin.position, pin.pinned_at, p.id
+	`, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		err := rows.Scan(
+			&post.ID, &post.Title, &post.UserID, &post.Category, &post.Likes, &post.Dislikes,
+			&post.UserVote, &post.Content, &post.ContentHTML, &post.Timestamp, &post.ImageUrl, &post.CommentCount,
+			&post.EditedAt, &post.Status, &post.ViewCount, &post.Locked, &post.Announcement, &post.Author,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pinned post: %w", err)
+		}
+		post.Pinned = true
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate pinned posts: %w", err)
+	}
+	return posts, nil
+}
@@ -0,0 +1,436 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Notification types
+const (
+	NotificationReply   = "reply"
+	NotificationMention = "mention"
+	NotificationVote    = "vote"
+	NotificationMessage = "message"
+	// NotificationModeration carries a moderator's warning and cannot be
+	// switched off
+	NotificationModeration = "moderation"
+)
+
+// NotificationTypes lists every notification type a user can switch off
+var NotificationTypes = []string{NotificationReply, NotificationMention, NotificationVote, NotificationMessage}
+
+// NotificationExcerptLength is the maximum number of characters of the
+// source content stored with a notification
+const NotificationExcerptLength = 100
+
+// Page sizes for the notification list
+const (
+	DefaultNotificationPageSize = 20
+	MaxNotificationPageSize     = 100
+)
+
+var (
+	ErrNotificationNotFound          = errors.New("notification not found")
+	ErrInvalidNotificationPreference = errors.New("unknown notification type")
+)
+
+type NotificationController struct {
+	DB *sql.DB
+}
+
+func NewNotificationController(db *sql.DB) *NotificationController {
+	return &NotificationController{DB: db}
+}
+
+// NotificationQuery selects a page of a user's notifications, newest first
+type NotificationQuery struct {
+	// Before, when set, returns notifications older than this ID
+	Before     int
+	Limit      int
+	UnreadOnly bool
+}
+
+// notificationExcerpt shortens content to a single line preview
+func notificationExcerpt(content string) string {
+	excerpt := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(excerpt); len(runes) > NotificationExcerptLength {
+		excerpt = string(runes[:NotificationExcerptLength]) + "…"
+	}
+	return excerpt
+}
+
+// parseNotificationPreferences reads user_status.notification_preferences:
+// "all" (the default), "none", or a comma-separated list of enabled types
+func parseNotificationPreferences(raw string) map[string]bool {
+	raw = strings.TrimSpace(raw)
+	prefs := make(map[string]bool, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		prefs[t] = raw == "" || raw == "all"
+	}
+	if raw == "" || raw == "all" || raw == "none" {
+		return prefs
+	}
+	for _, t := range strings.Split(raw, ",") {
+		if _, ok := prefs[strings.TrimSpace(t)]; ok {
+			prefs[strings.TrimSpace(t)] = true
+		}
+	}
+	return prefs
+}
+
+// formatNotificationPreferences is the inverse of parseNotificationPreferences
+func formatNotificationPreferences(prefs map[string]bool) string {
+	var enabled []string
+	for _, t := range NotificationTypes {
+		if prefs[t] {
+			enabled = append(enabled, t)
+		}
+	}
+	switch len(enabled) {
+	case 0:
+		return "none"
+	case len(NotificationTypes):
+		return "all"
+	}
+	return strings.Join(enabled, ",")
+}
+
+// loadNotificationPreferences reads a user's preferences; users without a
+// user_status row get every type
+func loadNotificationPreferences(tx *sql.Tx, userID int) (map[string]bool, error) {
+	var raw sql.NullString
+	err := tx.QueryRow("SELECT notification_preferences FROM user_status WHERE user_id = ?", userID).Scan(&raw)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load notification preferences: %w", err)
+	}
+	return parseNotificationPreferences(raw.String), nil
+}
+
+// insertNotification stores n inside tx and fills in its ID and CreatedAt. It
+// reports false without storing anything when the user has switched the
+// notification's type off; types missing from NotificationTypes are always
+// stored.
+func insertNotification(tx *sql.Tx, n *models.Notification) (bool, error) {
+	prefs, err := loadNotificationPreferences(tx, n.UserID)
+	if err != nil {
+		return false, err
+	}
+	if enabled, ok := prefs[n.Type]; ok && !enabled {
+		return false, nil
+	}
+
+	n.CreatedAt = time.Now().UTC()
+	result, err := tx.Exec(`
+		INSERT INTO notifications (user_id, type, actor_id, source_type, source_id, post_id, excerpt, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, n.UserID, n.Type, n.ActorID, n.SourceType, n.SourceID,
+		sql.NullInt64{Int64: int64(n.PostID), Valid: n.PostID != 0}, n.Excerpt, n.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert notification: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to get notification ID: %w", err)
+	}
+	n.ID = int(id)
+	return true, nil
+}
+
+// notify stores each candidate notification whose user is not the actor, has
+// not already been mentioned in the same source and has the type enabled
+func (nc *NotificationController) notify(candidates []models.Notification) ([]models.Notification, error) {
+	tx, err := nc.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var created []models.Notification
+	notified := make(map[int]bool)
+	for _, n := range candidates {
+		if n.UserID == n.ActorID || notified[n.UserID] {
+			continue
+		}
+		notified[n.UserID] = true
+
+		// A mention notification already covers this source
+		var mentioned bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM mentions WHERE source_type = ? AND source_id = ? AND mentioned_user_id = ?)
+		`, n.SourceType, n.SourceID, n.UserID).Scan(&mentioned)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check mentions: %w", err)
+		}
+		if mentioned {
+			continue
+		}
+
+		if n.ActorName == "" {
+			n.ActorName = GetUsernameByID(nc.DB, n.ActorID)
+		}
+		ok, err := insertNotification(tx, &n)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			created = append(created, n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit notifications: %w", err)
+	}
+	return created, nil
+}
+
+// NotifyReply notifies the author of the post a comment was left on and, for
+// replies, the author of the parent comment. Record the comment's mentions
+// first so mentioned authors are not notified twice.
+func (nc *NotificationController) NotifyReply(comment models.Comment) ([]models.Notification, error) {
+	reply := models.Notification{
+		Type:       NotificationReply,
+		ActorID:    comment.UserID,
+		ActorName:  comment.Author,
+		SourceType: MentionSourceComment,
+		SourceID:   comment.ID,
+		PostID:     comment.PostID,
+		Excerpt:    notificationExcerpt(comment.Content),
+	}
+
+	var candidates []models.Notification
+	if comment.ParentID.Valid {
+		var parentAuthor int
+		err := nc.DB.QueryRow("SELECT user_id FROM comments WHERE id = ?", comment.ParentID.Int64).Scan(&parentAuthor)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to fetch parent comment author: %w", err)
+		}
+		if err == nil {
+			n := reply
+			n.UserID = parentAuthor
+			candidates = append(candidates, n)
+		}
+	}
+
+	var postAuthor int
+	err := nc.DB.QueryRow("SELECT user_id FROM posts WHERE id = ?", comment.PostID).Scan(&postAuthor)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch post author: %w", err)
+	}
+	if err == nil {
+		n := reply
+		n.UserID = postAuthor
+		candidates = append(candidates, n)
+	}
+
+	return nc.notify(candidates)
+}
+
+// NotifyVote notifies the author of a post or comment that actorID voted on
+// it. Nothing is sent when the vote was just withdrawn, or when the actor has
+// already been notified about a vote on the same content, so toggling a vote
+// cannot flood anyone.
+func (nc *NotificationController) NotifyVote(sourceType string, sourceID, actorID int) ([]models.Notification, error) {
+	var ownerQuery, voteQuery string
+	switch sourceType {
+	case MentionSourcePost:
+		ownerQuery = "SELECT user_id, id, title FROM posts WHERE id = ?"
+		voteQuery = "SELECT EXISTS (SELECT 1 FROM likes WHERE post_id = ? AND user_id = ?)"
+	case MentionSourceComment:
+		ownerQuery = "SELECT user_id, post_id, content FROM comments WHERE id = ?"
+		voteQuery = "SELECT EXISTS (SELECT 1 FROM comment_votes WHERE comment_id = ? AND user_id = ?)"
+	default:
+		return nil, fmt.Errorf("cannot vote on %q", sourceType)
+	}
+
+	var voted bool
+	if err := nc.DB.QueryRow(voteQuery, sourceID, actorID).Scan(&voted); err != nil {
+		return nil, fmt.Errorf("failed to check vote: %w", err)
+	}
+	if !voted {
+		return nil, nil
+	}
+
+	var alreadyNotified bool
+	err := nc.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM notifications
+			WHERE type = ? AND source_type = ? AND source_id = ? AND actor_id = ?
+		)
+	`, NotificationVote, sourceType, sourceID, actorID).Scan(&alreadyNotified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check earlier vote notifications: %w", err)
+	}
+	if alreadyNotified {
+		return nil, nil
+	}
+
+	n := models.Notification{
+		Type:       NotificationVote,
+		ActorID:    actorID,
+		SourceType: sourceType,
+		SourceID:   sourceID,
+	}
+	var excerpt string
+	err = nc.DB.QueryRow(ownerQuery, sourceID).Scan(&n.UserID, &n.PostID, &excerpt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch voted content: %w", err)
+	}
+	n.Excerpt = notificationExcerpt(excerpt)
+
+	return nc.notify([]models.Notification{n})
+}
+
+// NotifyMessage notifies the recipient of a direct message. Record the
+// message's mentions first so a mentioned recipient is notified once.
+func (nc *NotificationController) NotifyMessage(messageID, senderID, receiverID int, content string) ([]models.Notification, error) {
+	return nc.notify([]models.Notification{{
+		UserID:     receiverID,
+		Type:       NotificationMessage,
+		ActorID:    senderID,
+		SourceType: MentionSourceMessage,
+		SourceID:   messageID,
+		Excerpt:    notificationExcerpt(content),
+	}})
+}
+
+// GetNotifications returns a page of the user's notifications, newest first,
+// and whether older ones exist
+func (nc *NotificationController) GetNotifications(userID int, q NotificationQuery) ([]models.Notification, bool, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultNotificationPageSize
+	}
+	if limit > MaxNotificationPageSize {
+		limit = MaxNotificationPageSize
+	}
+
+	query := `
+		SELECT n.id, n.user_id, n.type, n.actor_id, COALESCE(u.nickname, ''), n.source_type,
+		       n.source_id, COALESCE(n.post_id, 0), n.excerpt, n.created_at, n.read_at
+		FROM notifications n
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = ?`
+	args := []interface{}{userID}
+	if q.Before > 0 {
+		query += " AND n.id < ?"
+		args = append(args, q.Before)
+	}
+	if q.UnreadOnly {
+		query += " AND n.read_at IS NULL"
+	}
+	query += " ORDER BY n.id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := nc.DB.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]models.Notification, 0, limit)
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.ActorName, &n.SourceType,
+			&n.SourceID, &n.PostID, &n.Excerpt, &n.CreatedAt, &n.ReadAt)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate notifications: %w", err)
+	}
+
+	hasMore := len(notifications) > limit
+	if hasMore {
+		notifications = notifications[:limit]
+	}
+	return notifications, hasMore, nil
+}
+
+// UnreadCount returns how many of the user's notifications are unread
+func (nc *NotificationController) UnreadCount(userID int) (int, error) {
+	var count int
+	err := nc.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks one of the user's notifications as read. Marking an already
+// read notification is not an error.
+func (nc *NotificationController) MarkRead(userID, notificationID int) error {
+	result, err := nc.DB.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, ?)
+		WHERE id = ? AND user_id = ?
+	`, time.Now().UTC(), notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and
+// returns how many changed
+func (nc *NotificationController) MarkAllRead(userID int) (int, error) {
+	result, err := nc.DB.Exec(`
+		UPDATE notifications SET read_at = ?
+		WHERE user_id = ? AND read_at IS NULL
+	`, time.Now().UTC(), userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	return int(n), nil
+}
+
+// GetPreferences returns which notification types the user receives
+func (nc *NotificationController) GetPreferences(userID int) (map[string]bool, error) {
+	var raw sql.NullString
+	err := nc.DB.QueryRow("SELECT notification_preferences FROM user_status WHERE user_id = ?", userID).Scan(&raw)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load notification preferences: %w", err)
+	}
+	return parseNotificationPreferences(raw.String), nil
+}
+
+// UpdatePreferences switches the given notification types on or off, leaving
+// types not in changes as they were, and returns the resulting preferences
+func (nc *NotificationController) UpdatePreferences(userID int, changes map[string]bool) (map[string]bool, error) {
+	prefs, err := nc.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	for t, enabled := range changes {
+		if _, ok := prefs[t]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidNotificationPreference, t)
+		}
+		prefs[t] = enabled
+	}
+
+	_, err = nc.DB.Exec(`
+		INSERT INTO user_status (user_id, notification_preferences) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET notification_preferences = excluded.notification_preferences
+	`, userID, formatNotificationPreferences(prefs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return prefs, nil
+}
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Poll limits
+const (
+	MinPollOptions        = 2
+	MaxPollOptions        = 10
+	MaxPollQuestionLength = 200
+	MaxPollOptionLength   = 100
+)
+
+var (
+	ErrInvalidPoll     = errors.New("invalid poll")
+	ErrPollNotFound    = errors.New("poll not found")
+	ErrPollClosed      = errors.New("poll is closed")
+	ErrAlreadyVoted    = errors.New("you have already voted in this poll")
+	ErrInvalidPollVote = errors.New("invalid poll vote")
+)
+
+type PollController struct {
+	DB *sql.DB
+}
+
+func NewPollController(db *sql.DB) *PollController {
+	return &PollController{DB: db}
+}
+
+// NewPoll validates a poll request and builds the poll to store with a new
+// post. Questions and options are trimmed, options must be distinct, and a
+// close time must lie in the future.
+func NewPoll(req models.PollRequest, now time.Time) (*models.Poll, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" || len([]rune(question)) > MaxPollQuestionLength {
+		return nil, fmt.Errorf("%w: the question must be 1 to %d characters", ErrInvalidPoll, MaxPollQuestionLength)
+	}
+	if len(req.Options) < MinPollOptions || len(req.Options) > MaxPollOptions {
+		return nil, fmt.Errorf("%w: a poll needs %d to %d options", ErrInvalidPoll, MinPollOptions, MaxPollOptions)
+	}
+	if req.ClosesAt != nil && !req.ClosesAt.After(now) {
+		return nil, fmt.Errorf("%w: closes_at must be in the future", ErrInvalidPoll)
+	}
+
+	poll := &models.Poll{
+		Question:       question,
+		MultipleChoice: req.MultipleChoice,
+		Anonymous:      req.Anonymous,
+	}
+	if req.ClosesAt != nil {
+		closesAt := req.ClosesAt.UTC()
+		poll.ClosesAt = &closesAt
+	}
+
+	seen := make(map[string]bool)
+	for _, label := range req.Options {
+		label = strings.TrimSpace(label)
+		if label == "" || len([]rune(label)) > MaxPollOptionLength {
+			return nil, fmt.Errorf("%w: options must be 1 to %d characters", ErrInvalidPoll, MaxPollOptionLength)
+		}
+		if seen[strings.ToLower(label)] {
+			return nil, fmt.Errorf("%w: duplicate option %q", ErrInvalidPoll, label)
+		}
+		seen[strings.ToLower(label)] = true
+		poll.Options = append(poll.Options, models.PollOption{Label: label})
+	}
+	return poll, nil
+}
+
+// insertPoll stores a poll and its options for postID
+func insertPoll(tx *sql.Tx, postID int, poll *models.Poll) error {
+	result, err := tx.Exec(`
+		INSERT INTO polls (post_id, question, multiple_choice, anonymous, closes_at, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, postID, poll.Question, poll.MultipleChoice, poll.Anonymous, poll.ClosesAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert poll: %w", err)
+	}
+	pollID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get poll ID: %w", err)
+	}
+
+	for i, option := range poll.Options {
+		_, err := tx.Exec(`
+			INSERT INTO poll_options (poll_id, position, label) VALUES (?, ?, ?)
+		`, pollID, i, option.Label)
+		if err != nil {
+			return fmt.Errorf("failed to insert poll option: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPostPoll returns the poll of a post with its current results. When
+// viewerID is set, UserVotes holds the options that user picked. Polls of
+// unpublished or hidden posts are only visible to the post author.
+func (pc *PollController) GetPostPoll(postID, viewerID int) (*models.Poll, error) {
+	var pollID, authorID int
+	var status string
+	var hidden bool
+	err := pc.DB.QueryRow(`
+		SELECT pl.id, p.user_id, p.status, p.hidden
+		FROM polls pl
+		JOIN posts p ON p.id = pl.post_id
+		WHERE pl.post_id = ?
+	`, postID).Scan(&pollID, &authorID, &status, &hidden)
+	if err == sql.ErrNoRows {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch poll: %w", err)
+	}
+	if (status != PostStatusPublished || hidden) && (viewerID == 0 || viewerID != authorID) {
+		return nil, ErrPollNotFound
+	}
+	return pc.GetPoll(pollID, viewerID)
+}
+
+// GetPoll returns a poll with its current results, see GetPostPoll
+func (pc *PollController) GetPoll(pollID, viewerID int) (*models.Poll, error) {
+	poll := &models.Poll{ID: pollID}
+	err := pc.DB.QueryRow(`
+		SELECT post_id, question, multiple_choice, anonymous, closes_at,
+		       (SELECT COUNT(*) FROM poll_ballots WHERE poll_id = polls.id)
+		FROM polls WHERE id = ?
+	`, pollID).Scan(&poll.PostID, &poll.Question, &poll.MultipleChoice, &poll.Anonymous, &poll.ClosesAt, &poll.TotalVoters)
+	if err == sql.ErrNoRows {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch poll: %w", err)
+	}
+	poll.Closed = pollClosed(poll.ClosesAt, time.Now())
+
+	rows, err := pc.DB.Query(`
+		SELECT o.id, o.label, COUNT(v.user_id)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id = ?
+		GROUP BY o.id
+		ORDER BY o.position
+	`, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch poll options: %w", err)
+	}
+	defer rows.Close()
+
+	index := make(map[int]int)
+	for rows.Next() {
+		var option models.PollOption
+		if err := rows.Scan(&option.ID, &option.Label, &option.Votes); err != nil {
+			return nil, fmt.Errorf("failed to scan poll option: %w", err)
+		}
+		index[option.ID] = len(poll.Options)
+		poll.Options = append(poll.Options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate poll options: %w", err)
+	}
+	rows.Close()
+
+	// Voters are listed for public polls; anonymous polls only reveal the
+	// viewer's own choices
+	query := `
+		SELECT v.option_id, v.user_id, COALESCE(u.nickname, '')
+		FROM poll_votes v
+		LEFT JOIN users u ON u.id = v.user_id
+		WHERE v.poll_id = ?`
+	args := []interface{}{pollID}
+	if poll.Anonymous {
+		query += " AND v.user_id = ?"
+		args = append(args, viewerID)
+	}
+	query += " ORDER BY v.rowid"
+
+	rows, err = pc.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch poll votes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var optionID, userID int
+		var nickname string
+		if err := rows.Scan(&optionID, &userID, &nickname); err != nil {
+			return nil, fmt.Errorf("failed to scan poll vote: %w", err)
+		}
+		if viewerID != 0 && userID == viewerID {
+			poll.UserVotes = append(poll.UserVotes, optionID)
+		}
+		if i, ok := index[optionID]; ok && !poll.Anonymous {
+			poll.Options[i].Voters = append(poll.Options[i].Voters, nickname)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate poll votes: %w", err)
+	}
+	return poll, nil
+}
+
+// Vote casts the user's ballot in the poll of a published post. A single
+// choice poll takes exactly one option, a multiple choice poll one or more.
+// Ballots are final: the ballot row is written in the same transaction as
+// the votes, so a second vote fails even when two requests race.
+func (pc *PollController) Vote(pollID, userID int, optionIDs []int) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var multipleChoice bool
+	var closesAt *time.Time
+	var status string
+	var hidden bool
+	err = tx.QueryRow(`
+		SELECT pl.multiple_choice, pl.closes_at, p.status, p.hidden
+		FROM polls pl
+		JOIN posts p ON p.id = pl.post_id
+		WHERE pl.id = ?
+	`, pollID).Scan(&multipleChoice, &closesAt, &status, &hidden)
+	if err == sql.ErrNoRows {
+		return ErrPollNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch poll: %w", err)
+	}
+	// Nobody votes in the polls of posts a moderator hid
+	if status != PostStatusPublished || hidden {
+		return ErrPollNotFound
+	}
+	if pollClosed(closesAt, time.Now()) {
+		return ErrPollClosed
+	}
+
+	if len(optionIDs) == 0 {
+		return fmt.Errorf("%w: pick an option", ErrInvalidPollVote)
+	}
+	if !multipleChoice && len(optionIDs) > 1 {
+		return fmt.Errorf("%w: this poll takes a single option", ErrInvalidPollVote)
+	}
+	seen := make(map[int]bool)
+	for _, id := range optionIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: duplicate option", ErrInvalidPollVote)
+		}
+		seen[id] = true
+
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM poll_options WHERE id = ? AND poll_id = ?", id, pollID).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check poll option: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: unknown option %d", ErrInvalidPollVote, id)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO poll_ballots (poll_id, user_id, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, pollID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrAlreadyVoted
+		}
+		return fmt.Errorf("failed to record ballot: %w", err)
+	}
+	for _, id := range optionIDs {
+		_, err := tx.Exec(`
+			INSERT INTO poll_votes (poll_id, option_id, user_id) VALUES (?, ?, ?)
+		`, pollID, id, userID)
+		if err != nil {
+			return fmt.Errorf("failed to record poll vote: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit poll vote: %w", err)
+	}
+	return nil
+}
+
+// pollClosed reports whether a poll with the given close time is closed
+func pollClosed(closesAt *time.Time, now time.Time) bool {
+	return closesAt != nil && !now.Before(*closesAt)
+}
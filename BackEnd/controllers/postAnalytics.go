@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Analytics range bounds, in days, used when the caller does not pass a
+// usable range
+const (
+	DefaultAnalyticsDays = 30
+	MaxAnalyticsDays     = 365
+)
+
+// GetPostAnalytics returns the lifetime totals of a post and its views,
+// votes and comments for each of the last days UTC days up to now. It
+// returns sql.ErrNoRows when the post does not exist.
+func (pc *PostController) GetPostAnalytics(postID, days int, now time.Time) (models.PostAnalytics, error) {
+	if days <= 0 {
+		days = DefaultAnalyticsDays
+	}
+	if days > MaxAnalyticsDays {
+		days = MaxAnalyticsDays
+	}
+
+	analytics := models.PostAnalytics{PostID: postID}
+	err := pc.DB.QueryRow(`
+		SELECT view_count, COALESCE(likes, 0), COALESCE(dislikes, 0), comment_count
+		FROM posts WHERE id = ?
+	`, postID).Scan(&analytics.Views, &analytics.Likes, &analytics.Dislikes, &analytics.Comments)
+	if errors.Is(err, sql.ErrNoRows) {
+		return analytics, sql.ErrNoRows
+	}
+	if err != nil {
+		return analytics, fmt.Errorf("failed to fetch post totals: %w", err)
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1)).Format(time.DateOnly)
+	analytics.Days = make([]models.PostDayStats, days)
+	index := make(map[string]*models.PostDayStats, days)
+	for i := range analytics.Days {
+		stats := &analytics.Days[i]
+		stats.Day = today.AddDate(0, 0, i-(days-1)).Format(time.DateOnly)
+		index[stats.Day] = stats
+	}
+
+	// Gather the activity of every day in range into one row per day
+	rows, err := pc.DB.Query(`
+		SELECT day, SUM(views), SUM(likes), SUM(dislikes), SUM(comments) FROM (
+			SELECT day, views, 0 AS likes, 0 AS dislikes, 0 AS comments
+			FROM post_views WHERE post_id = ? AND day >= ?
+			UNION ALL
+			SELECT date(created_at), 0,
+			       CASE WHEN user_vote = 'like' THEN 1 ELSE 0 END,
+			       CASE WHEN user_vote = 'dislike' THEN 1 ELSE 0 END, 0
+			FROM likes WHERE post_id = ? AND created_at IS NOT NULL AND date(created_at) >= ?
+			UNION ALL
+			SELECT date(timestamp), 0, 0, 0, 1
+			FROM comments WHERE post_id = ? AND date(timestamp) >= ?
+		)
+		GROUP BY day
+	`, postID, since, postID, since, postID, since)
+	if err != nil {
+		return analytics, fmt.Errorf("failed to fetch post analytics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day sql.NullString
+		var counts models.PostDayStats
+		if err := rows.Scan(&day, &counts.Views, &counts.Likes, &counts.Dislikes, &counts.Comments); err != nil {
+			return analytics, fmt.Errorf("failed to scan post analytics: %w", err)
+		}
+		// Activity stamped in the future by a skewed clock is left out
+		if stats, ok := index[day.String]; ok {
+			counts.Day = stats.Day
+			*stats = counts
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return analytics, fmt.Errorf("failed to iterate post analytics: %w", err)
+	}
+
+	return analytics, nil
+}
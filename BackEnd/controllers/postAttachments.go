@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Attachment limits
+const (
+	MaxPostAttachments = 10
+	MaxAltTextLength   = 500
+)
+
+var (
+	ErrTooManyAttachments = fmt.Errorf("a post can have at most %d attachments", MaxPostAttachments)
+	ErrAltTextTooLong     = fmt.Errorf("alt text must be at most %d characters", MaxAltTextLength)
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// SavePostAttachments stores the files uploaded with a post request. Files
+// come from the legacy post-file field followed by the post-files field, and
+// the n-th alt-text value describes the n-th file. Every file is checked
+// before any is stored, so nothing is kept when one is rejected. Completed
+// resumable uploads named by upload-id fields follow the files, with the alt
+// text they were uploaded with.
+func SavePostAttachments(db *sql.DB, r *http.Request, userID int) ([]models.Attachment, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	headers := append(r.MultipartForm.File["post-file"], r.MultipartForm.File["post-files"]...)
+	uploadIDs := r.MultipartForm.Value["upload-id"]
+	if len(headers)+len(uploadIDs) > MaxPostAttachments {
+		return nil, ErrTooManyAttachments
+	}
+	altTexts := r.MultipartForm.Value["alt-text"]
+
+	resumable := NewResumableUploadController(db, ResumableUploadDir())
+	linked := make([]models.Attachment, 0, len(uploadIDs))
+	for _, id := range uploadIDs {
+		attachment, err := resumable.CompletedUpload(userID, strings.TrimSpace(id), UploadTargetPost)
+		if err != nil {
+			return nil, err
+		}
+		linked = append(linked, attachment)
+	}
+
+	uploads := make([]*preparedUpload, 0, len(headers))
+	for i, header := range headers {
+		if i < len(altTexts) && len([]rune(strings.TrimSpace(altTexts[i]))) > MaxAltTextLength {
+			return nil, ErrAltTextTooLong
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving file: %v", err)
+		}
+		upload, err := prepareUpload(file, header.Size, MaxUploadSize)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+
+	// Stored files that end up unused, e.g. because saving the post fails,
+	// are deleted by the upload sweeper
+	attachments := make([]models.Attachment, 0, len(uploads))
+	for i, upload := range uploads {
+		attachment, err := upload.store(r.Context(), db, UploadFolderPosts, "")
+		if err != nil {
+			return nil, err
+		}
+		if i < len(altTexts) {
+			attachment.AltText = strings.TrimSpace(altTexts[i])
+		}
+		attachments = append(attachments, attachment)
+	}
+	return append(attachments, linked...), nil
+}
+
+// setPostAttachments makes attachments the post's attachments in the given
+// order. Attachments with an ID keep their file and take the new position
+// and alt text, those without one are added, and stored attachments that
+// are missing from the list are deleted. It returns the files of the deleted
+// attachments, to be released once the transaction commits.
+func setPostAttachments(tx *sql.Tx, postID int, attachments []models.Attachment) ([]string, error) {
+	if len(attachments) > MaxPostAttachments {
+		return nil, ErrTooManyAttachments
+	}
+
+	rows, err := tx.Query("SELECT id, path, medium_path, thumbnail_path FROM post_attachments WHERE post_id = ?", postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
+	}
+	existing := make(map[int][]string)
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.URL, &a.MediumURL, &a.ThumbnailURL); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		existing[a.ID] = attachmentFiles(a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate attachments: %w", err)
+	}
+
+	for i, attachment := range attachments {
+		if attachment.ID != 0 {
+			if _, ok := existing[attachment.ID]; !ok {
+				return nil, ErrAttachmentNotFound
+			}
+			delete(existing, attachment.ID)
+			_, err := tx.Exec("UPDATE post_attachments SET position = ?, alt_text = ? WHERE id = ?",
+				i, attachment.AltText, attachment.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update attachment: %w", err)
+			}
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO post_attachments (post_id, position, path, medium_path, thumbnail_path, alt_text, mime_type, width, height, size, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, postID, i, attachment.URL, variantPath(attachment.MediumURL, attachment.URL),
+			variantPath(attachment.ThumbnailURL, attachment.URL), attachment.AltText, attachment.MimeType,
+			attachment.Width, attachment.Height, attachment.Size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert attachment: %w", err)
+		}
+	}
+
+	var removed []string
+	for id, files := range existing {
+		if _, err := tx.Exec("DELETE FROM post_attachments WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete attachment: %w", err)
+		}
+		removed = append(removed, files...)
+	}
+	return removed, nil
+}
+
+// variantPath returns the value stored for a variant file: empty when the
+// original stands in for it
+func variantPath(variant, original string) string {
+	if variant == original {
+		return ""
+	}
+	return variant
+}
+
+// attachmentImageURL returns the legacy image_url value for a post with the
+// given attachments: the first attachment's file
+func attachmentImageURL(attachments []models.Attachment) sql.NullString {
+	if len(attachments) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: attachments[0].URL, Valid: true}
+}
+
+// GetPostAttachments returns the attachments of the given posts in display
+// order, keyed by post ID
+func GetPostAttachments(db *sql.DB, postIDs []int) (map[int][]models.Attachment, error) {
+	attachments := make(map[int][]models.Attachment)
+	if len(postIDs) == 0 {
+		return attachments, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		args[i] = id
+	}
+	rows, err := db.Query(`
+		SELECT id, post_id, position, path,
+		       COALESCE(NULLIF(medium_path, ''), path), COALESCE(NULLIF(thumbnail_path, ''), path),
+		       alt_text, mime_type, width, height, size
+		FROM post_attachments
+		WHERE post_id IN (`+placeholders+`)
+		ORDER BY post_id, position
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.Attachment
+		err := rows.Scan(&a.ID, &a.PostID, &a.Position, &a.URL, &a.MediumURL, &a.ThumbnailURL, &a.AltText, &a.MimeType, &a.Width, &a.Height, &a.Size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments[a.PostID] = append(attachments[a.PostID], a)
+	}
+	return attachments, rows.Err()
+}
+
+// loadPostAttachments fills in the Attachments of posts; posts without any
+// get an empty list
+func loadPostAttachments(db *sql.DB, posts []models.Post) error {
+	postIDs := make([]int, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+	attachments, err := GetPostAttachments(db, postIDs)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Attachments = attachments[posts[i].ID]
+		if posts[i].Attachments == nil {
+			posts[i].Attachments = make([]models.Attachment, 0)
+		}
+	}
+	return nil
+}
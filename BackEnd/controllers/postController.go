@@ -2,11 +2,17 @@ package controllers
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/richtext"
 )
 
 type PostController struct {
@@ -35,11 +41,30 @@ func (pc *PostController) InsertPost(post models.Post) (int, error) {
 	// Set author name using user's nickname
 	post.Author = user.Nickname
 
+	if len(post.Categories) > 0 {
+		post.Category = categorySlugs(post.Categories)
+	}
+
+	post.Status, post.PublishAt, err = normalizePostStatus(post.Status, post.PublishAt, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if post.Attachments != nil {
+		post.ImageUrl = attachmentImageURL(post.Attachments)
+	}
+
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Insert the post with the UserID
-	result, err := pc.DB.Exec(`
-		INSERT INTO posts (title, user_id, author, category, likes, dislikes, user_vote, content, timestamp, image_url)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
-	`, post.Title, post.UserID, post.Author, post.Category, post.Likes, post.Dislikes, post.UserVote, post.Content, post.Timestamp, post.ImageUrl)
+	result, err := tx.Exec(`
+		INSERT INTO posts (title, user_id, author, category, likes, dislikes, user_vote, content, content_html, timestamp, image_url, status, publish_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, post.Title, post.UserID, post.Author, post.Category, post.Likes, post.Dislikes, post.UserVote, post.Content, richtext.Render(post.Content), post.Timestamp, post.ImageUrl, post.Status, post.PublishAt)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert post: %w", err)
 	}
@@ -50,16 +75,41 @@ func (pc *PostController) InsertPost(post models.Post) (int, error) {
 		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
 	}
 
+	if err := setPostCategories(tx, int(postID), post.Categories); err != nil {
+		return 0, err
+	}
+
+	if len(post.Attachments) > 0 {
+		if _, err := setPostAttachments(tx, int(postID), post.Attachments); err != nil {
+			return 0, err
+		}
+	}
+
+	if post.Poll != nil {
+		if err := insertPoll(tx, int(postID), post.Poll); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := database.UpdatePostScores(tx, int(postID)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return int(postID), nil
 }
 
 func (pc *PostController) GetAllPosts() ([]models.Post, error) {
 	rows, err := pc.DB.Query(`
 		SELECT p.id, p.title, p.user_id, p.author, p.category, p.likes, p.dislikes, 
-			   p.user_vote, p.content, p.timestamp, p.image_url,
+			   p.user_vote, p.content, COALESCE(p.content_html, ''), p.timestamp, p.image_url,
 			   u.nickname
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
+		WHERE p.status = 'published' AND p.hidden = 0
 		ORDER BY p.timestamp DESC
 	`)
 	if err != nil {
@@ -75,7 +125,7 @@ func (pc *PostController) GetAllPosts() ([]models.Post, error) {
 		err := rows.Scan(
 			&post.ID, &post.Title, &post.UserID, &post.Author,
 			&post.Category, &post.Likes, &post.Dislikes,
-			&post.UserVote, &post.Content, &post.Timestamp, &post.ImageUrl,
+			&post.UserVote, &post.Content, &post.ContentHTML, &post.Timestamp, &post.ImageUrl,
 			&nickname,
 		)
 		if err != nil {
@@ -87,9 +137,179 @@ func (pc *PostController) GetAllPosts() ([]models.Post, error) {
 		posts = append(posts, post)
 	}
 
+	if err := loadPostAttachments(pc.DB, posts); err != nil {
+		return nil, err
+	}
+
 	return posts, nil
 }
 
+// ErrInvalidCursor is returned when a feed cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid feed cursor")
+
+// Feed page size bounds used when the caller does not pass a usable limit.
+const (
+	DefaultFeedPageSize = 20
+	MaxFeedPageSize     = 100
+)
+
+// encodeFeedCursor packs the sort key of the last post on a page into an
+// opaque token.
+func encodeFeedCursor(timestamp string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(timestamp + "|" + strconv.Itoa(id)))
+}
+
+func decodeFeedCursor(cursor string) (string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	sep := strings.LastIndex(string(raw), "|")
+	if sep < 0 {
+		return "", 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(string(raw[sep+1:]))
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return string(raw[:sep]), id, nil
+}
+
+// FeedQuery selects a page of the post feed
+type FeedQuery struct {
+	// Cursor is the token returned with the previous page; empty starts at
+	// the first post
+	Cursor string
+	Limit  int
+	// Category restricts the feed to posts filed under this slug
+	Category string
+	// Sort is one of the FeedSort modes, FeedSortNew when empty
+	Sort string
+	// Window restricts the top feed to posts published within it, one of
+	// the FeedWindow values; FeedWindowAll when empty
+	Window string
+}
+
+// GetPostsPage returns up to q.Limit posts in q.Sort order, starting after
+// q.Cursor. The returned cursor is empty when there are no more posts.
+// Comment bodies are not loaded; CommentCount comes from the maintained
+// posts.comment_count column.
+func (pc *PostController) GetPostsPage(q FeedQuery) ([]models.Post, string, error) {
+	cursor, limit := q.Cursor, q.Limit
+	if limit <= 0 {
+		limit = DefaultFeedPageSize
+	}
+	if limit > MaxFeedPageSize {
+		limit = MaxFeedPageSize
+	}
+	order, err := feedOrderFor(q.Sort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+		SELECT p.id, p.title, p.user_id, p.author, p.category, p.likes, p.dislikes,
+			   p.user_vote, p.content, COALESCE(p.content_html, ''), p.timestamp, p.image_url, p.comment_count,
+			   p.edited_at, p.status, p.view_count, p.locked, p.is_announcement, ` + order.key + `, u.nickname
+		FROM posts p
+		JOIN users u ON p.user_id = u.id`
+	conditions := []string{"p.status = 'published'", "p.hidden = 0"}
+	args := []interface{}{}
+	// Posts pinned to this feed lead its first page instead
+	pinScope := 0
+	if q.Category != "" {
+		category, err := NewCategoryController(pc.DB).GetCategoryBySlug(q.Category)
+		if err != nil {
+			return nil, "", err
+		}
+		query += `
+		JOIN post_categories pc ON pc.post_id = p.id AND pc.category_id = ?`
+		args = append(args, category.ID)
+		pinScope = category.ID
+	}
+	conditions = append(conditions, "p.id NOT IN (SELECT post_id FROM post_pins WHERE category_id = ?)")
+	args = append(args, pinScope)
+	if q.Sort == FeedSortTop {
+		since, err := feedWindowStart(q.Window, time.Now())
+		if err != nil {
+			return nil, "", err
+		}
+		if !since.IsZero() {
+			conditions = append(conditions, "p.timestamp >= ?")
+			args = append(args, since)
+		}
+	}
+	if cursor != "" {
+		key, id, err := decodeFeedCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		value, err := order.parse(key)
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, "("+order.column+" < ? OR ("+order.column+" = ? AND p.id < ?))")
+		args = append(args, value, value, id)
+	}
+	query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	query += `
+		ORDER BY ` + order.column + ` DESC, p.id DESC
+		LIMIT ?`
+	// Fetch one extra row to learn whether another page exists
+	args = append(args, limit+1)
+
+	var pinned []models.Post
+	if cursor == "" {
+		pinned, err = loadPinnedPosts(pc.DB, pinScope)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	rows, err := pc.DB.Query(query, args...)
+	if err != nil {
+		logger.Error("Database query failed in GetPostsPage: %v", err)
+		return nil, "", fmt.Errorf("failed to fetch posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := make([]models.Post, 0, len(pinned)+limit)
+	posts = append(posts, pinned...)
+	var lastKey, nextCursor string
+	for rows.Next() {
+		var post models.Post
+		var sortKey, nickname string
+		err := rows.Scan(
+			&post.ID, &post.Title, &post.UserID, &post.Author,
+			&post.Category, &post.Likes, &post.Dislikes,
+			&post.UserVote, &post.Content, &post.ContentHTML, &post.Timestamp, &post.ImageUrl,
+			&post.CommentCount, &post.EditedAt, &post.Status, &post.ViewCount, &post.Locked, &post.Announcement,
+			&sortKey, &nickname,
+		)
+		if err != nil {
+			logger.Error("Row scan failed in GetPostsPage: %v", err)
+			return nil, "", fmt.Errorf("failed to scan post: %w", err)
+		}
+		if len(posts)-len(pinned) == limit {
+			nextCursor = encodeFeedCursor(lastKey, posts[len(posts)-1].ID)
+			break
+		}
+		post.Author = nickname
+		posts = append(posts, post)
+		lastKey = sortKey
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to iterate posts: %w", err)
+	}
+
+	if err := loadPostAttachments(pc.DB, posts); err != nil {
+		return nil, "", err
+	}
+
+	return posts, nextCursor, nil
+}
+
 func (pc *PostController) GetPostByID(postID string) (models.Post, error) {
 	var post models.Post
 	logger.Info("Attempting to fetch post with ID: %s", postID)
@@ -101,15 +321,16 @@ func (pc *PostController) GetPostByID(postID string) (models.Post, error) {
 
 	err := pc.DB.QueryRow(`
         SELECT p.id, p.title, p.user_id, p.author, p.category, p.likes, p.dislikes, 
-               p.user_vote, p.content, p.timestamp, p.image_url,
-               u.nickname
+               p.user_vote, p.content, COALESCE(p.content_html, ''), p.timestamp, p.image_url, p.edited_at,
+               p.status, p.publish_at, p.view_count, p.locked, p.is_announcement, p.hidden, u.nickname
         FROM posts p
         JOIN users u ON p.user_id = u.id
         WHERE p.id = ?
     `, postID).Scan(
 		&post.ID, &post.Title, &post.UserID, &post.Author,
 		&post.Category, &post.Likes, &post.Dislikes,
-		&post.UserVote, &post.Content, &post.Timestamp, &post.ImageUrl,
+		&post.UserVote, &post.Content, &post.ContentHTML, &post.Timestamp, &post.ImageUrl, &post.EditedAt,
+		&post.Status, &post.PublishAt, &post.ViewCount, &post.Locked, &post.Announcement, &post.Hidden,
 		&post.Author, // Update author with current nickname
 	)
 
@@ -122,11 +343,33 @@ func (pc *PostController) GetPostByID(postID string) (models.Post, error) {
 		return post, fmt.Errorf("failed to fetch post: %w", err)
 	}
 
+	posts := []models.Post{post}
+	if err := loadPostAttachments(pc.DB, posts); err != nil {
+		return post, err
+	}
+	post = posts[0]
+
 	logger.Info("Successfully fetched post with ID %s: %+v", postID, post)
 	return post, nil
 }
 
 func (pc *PostController) UpdatePost(post models.Post) error {
+	_, err := pc.UpdatePostWithStatus(post, "", nil)
+	return err
+}
+
+// UpdatePostWithStatus saves an edit and, when status is given, moves the
+// post to that status in the same transaction, so both are stored or
+// neither is. It reports whether the post went live.
+func (pc *PostController) UpdatePostWithStatus(post models.Post, status string, publishAt *time.Time) (bool, error) {
+	now := time.Now().UTC()
+	if status != "" {
+		var err error
+		if status, publishAt, err = normalizePostStatus(status, publishAt, now); err != nil {
+			return false, err
+		}
+	}
+
 	// Get user details for the post author
 	var user models.User
 	err := pc.DB.QueryRow(`
@@ -134,21 +377,46 @@ func (pc *PostController) UpdatePost(post models.Post) error {
 		FROM users 
 		WHERE id = ?`, post.UserID).Scan(&user.Nickname)
 	if err != nil {
-		return fmt.Errorf("failed to fetch user details: %w", err)
+		return false, fmt.Errorf("failed to fetch user details: %w", err)
 	}
 
 	// Update author name using user's current nickname
 	post.Author = user.Nickname
 
-	// Prepare the SQL statement for updating the post
+	if post.Categories != nil {
+		post.Category = categorySlugs(post.Categories)
+	}
+	if post.Attachments != nil {
+		post.ImageUrl = attachmentImageURL(post.Attachments)
+	}
+
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed, err := postContentChanged(tx, post)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		if err := ensureBaseRevision(tx, post.ID); err != nil {
+			return false, err
+		}
+	}
+
+	// Prepare the SQL statement for updating the post. The creation
+	// timestamp is kept; edits are tracked in edited_at and post_revisions.
 	query := `
 	UPDATE posts
-	SET title = ?, author = ?, category = ?, likes = ?, dislikes = ?, user_vote = ?, content = ?, image_url = ?, timestamp = ?
+	SET title = ?, author = ?, category = ?, likes = ?, dislikes = ?, user_vote = ?, content = ?, content_html = ?,
+	    image_url = ?, edited_at = CASE WHEN ? THEN ? ELSE edited_at END
 	WHERE id = ? AND user_id = ?;
 	`
 
 	// Execute the SQL statement with the post data
-	result, err := pc.DB.Exec(query,
+	result, err := tx.Exec(query,
 		post.Title,
 		post.Author,
 		post.Category,
@@ -156,25 +424,60 @@ func (pc *PostController) UpdatePost(post models.Post) error {
 		post.Dislikes,
 		post.UserVote,
 		post.Content,
+		richtext.Render(post.Content),
 		post.ImageUrl,
-		post.Timestamp,
+		changed,
+		now,
 		post.ID,
 		post.UserID,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update post: %w", err)
+		return false, fmt.Errorf("failed to update post: %w", err)
 	}
 
 	// Check if any rows were affected
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return false, fmt.Errorf("failed to check rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no post found with ID %d or unauthorized", post.ID)
+		return false, fmt.Errorf("no post found with ID %d or unauthorized", post.ID)
 	}
 
-	return nil
+	if post.Categories != nil {
+		if err := setPostCategories(tx, post.ID, post.Categories); err != nil {
+			return false, err
+		}
+	}
+
+	var removedFiles []string
+	if post.Attachments != nil {
+		if removedFiles, err = setPostAttachments(tx, post.ID, post.Attachments); err != nil {
+			return false, err
+		}
+	}
+
+	if changed {
+		if err := recordRevision(tx, post.ID, post.UserID, nil, now); err != nil {
+			return false, err
+		}
+	}
+
+	wentLive := false
+	if status != "" {
+		if wentLive, err = setPostStatus(tx, post.ID, post.UserID, status, publishAt, now); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Files of dropped attachments go once nothing refers to them
+	releaseUploads(pc.DB, removedFiles)
+
+	return wentLive, nil
 }
 
 // DeletePost deletes a post from the database by its ID, along with its comments and associated images
@@ -191,66 +494,155 @@ func (pc *PostController) DeletePost(postID, userID int) error {
 	}
 	defer tx.Rollback() // Rollback in case of error
 
+	imagePaths, err := deletePost(tx, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Delete the image files nothing else refers to, now that the rows are
+	// gone for good
+	releaseUploads(pc.DB, imagePaths)
+
+	return nil
+}
+
+// deletePost deletes a post of userID with everything attached to it inside
+// tx, and returns the stored paths of its images for releaseUploads once tx
+// has committed
+func deletePost(tx *sql.Tx, postID, userID int) ([]string, error) {
+	// Images attached to the comments go with them
+	var imagePaths []string
+	rows, err := tx.Query(`
+		SELECT DISTINCT c.media_path FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.post_id = ? AND p.user_id = ? AND c.media_path IS NOT NULL;
+	`, postID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comment media: %w", err)
+	}
+	for rows.Next() {
+		var mediaPath string
+		if err := rows.Scan(&mediaPath); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan comment media: %w", err)
+		}
+		imagePaths = append(imagePaths, mediaPath)
+	}
+	rows.Close()
+
 	// Step 1: Delete all comments associated with the post
 	_, err = tx.Exec(`
 		DELETE FROM comments 
 		WHERE post_id = ?;
 	`, postID)
 	if err != nil {
-		return fmt.Errorf("failed to delete comments: %w", err)
+		return nil, fmt.Errorf("failed to delete comments: %w", err)
 	}
 
-	// Step 2: Fetch image paths associated with the post before deleting the post
-	var imagePaths []string
-	rows, err := tx.Query(`
+	// Drop the post's edit history
+	_, err = tx.Exec(`
+		DELETE FROM post_revisions
+		WHERE post_id = ?;
+	`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post revisions: %w", err)
+	}
+
+	// Drop the post's daily view counts
+	_, err = tx.Exec(`
+		DELETE FROM post_views
+		WHERE post_id = ?;
+	`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post views: %w", err)
+	}
+
+	// Take the post off every feed it is pinned to
+	_, err = tx.Exec(`
+		DELETE FROM post_pins
+		WHERE post_id = ?;
+	`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post pins: %w", err)
+	}
+
+	// Unlink the post from its categories
+	_, err = tx.Exec(`
+		DELETE FROM post_categories
+		WHERE post_id = ?;
+	`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post categories: %w", err)
+	}
+
+	// Step 2: Fetch image paths associated with the post before deleting the
+	// post; image_url repeats the first attachment for older clients, and
+	// attachments may have scaled down variants
+	rows, err = tx.Query(`
 		SELECT image_url FROM posts 
-		WHERE id = ? AND user_id = ?;
-	`, postID, userID)
+		WHERE id = ? AND user_id = ?
+		UNION
+		SELECT a.path FROM post_attachments a
+		JOIN posts p ON p.id = a.post_id
+		WHERE a.post_id = ? AND p.user_id = ?
+		UNION
+		SELECT a.medium_path FROM post_attachments a
+		JOIN posts p ON p.id = a.post_id
+		WHERE a.post_id = ? AND p.user_id = ?
+		UNION
+		SELECT a.thumbnail_path FROM post_attachments a
+		JOIN posts p ON p.id = a.post_id
+		WHERE a.post_id = ? AND p.user_id = ?;
+	`, postID, userID, postID, userID, postID, userID, postID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch image paths: %w", err)
+		return nil, fmt.Errorf("failed to fetch image paths: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var imagePath sql.NullString // Use sql.NullString to handle NULL values
 		if err := rows.Scan(&imagePath); err != nil {
-			return fmt.Errorf("failed to scan image path: %w", err)
+			return nil, fmt.Errorf("failed to scan image path: %w", err)
 		}
 		if imagePath.Valid && imagePath.String != "" { // Only append non-empty paths
 			imagePaths = append(imagePaths, imagePath.String)
 		}
 	}
 
+	rows.Close()
+
+	_, err = tx.Exec(`
+		DELETE FROM post_attachments
+		WHERE post_id = ? AND post_id IN (SELECT id FROM posts WHERE user_id = ?);
+	`, postID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post attachments: %w", err)
+	}
+
 	// Step 3: Delete the post
 	result, err := tx.Exec(`
 		DELETE FROM posts 
 		WHERE id = ? AND user_id = ?;
 	`, postID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete post: %w", err)
+		return nil, fmt.Errorf("failed to delete post: %w", err)
 	}
 
 	// Check if the post was actually deleted
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return errors.New("no post found with the given ID or user ID")
-	}
-
-	// Step 4: Delete the image files from the upload folder
-	err = RemoveImages(imagePaths)
-	if err != nil {
-		return fmt.Errorf("failed to delete image files: %w", err)
+		return nil, errors.New("no post found with the given ID or user ID")
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
+	return imagePaths, nil
 }
 
 func (pc *PostController) IsPostAuthor(postID, userID int) (bool, error) {
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Post statuses
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+// Publishing errors
+var (
+	ErrInvalidPostStatus = errors.New("status must be draft, scheduled or published")
+	ErrInvalidPublishAt  = errors.New("scheduled posts need a publish_at time in the future")
+	ErrAlreadyPublished  = errors.New("published posts cannot be moved back to draft or scheduled")
+)
+
+// PublishInterval is how often the scheduled post publisher runs
+var PublishInterval = 30 * time.Second
+
+// normalizePostStatus validates the status and publish time of a post that
+// is about to be stored. An empty status means published.
+func normalizePostStatus(status string, publishAt *time.Time, now time.Time) (string, *time.Time, error) {
+	switch status {
+	case "", PostStatusPublished:
+		return PostStatusPublished, nil, nil
+	case PostStatusDraft:
+		return PostStatusDraft, nil, nil
+	case PostStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return "", nil, ErrInvalidPublishAt
+		}
+		at := publishAt.UTC()
+		return PostStatusScheduled, &at, nil
+	default:
+		return "", nil, ErrInvalidPostStatus
+	}
+}
+
+// CheckPostStatus validates moving a post from its current status to status
+// without storing anything, so edits can be refused before they are saved
+func CheckPostStatus(current, status string, publishAt *time.Time) error {
+	status, _, err := normalizePostStatus(status, publishAt, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if current == PostStatusPublished && status != PostStatusPublished {
+		return ErrAlreadyPublished
+	}
+	return nil
+}
+
+// SetPostStatus moves one of the user's posts between draft, scheduled and
+// published. It reports whether the post went live, in which case its
+// timestamp is reset to now so it enters the feed at the top.
+func (pc *PostController) SetPostStatus(postID, userID int, status string, publishAt *time.Time) (bool, error) {
+	now := time.Now().UTC()
+	status, publishAt, err := normalizePostStatus(status, publishAt, now)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	wentLive, err := setPostStatus(tx, postID, userID, status, publishAt, now)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return wentLive, nil
+}
+
+// setPostStatus applies a normalized status within tx and reports whether
+// the post went live
+func setPostStatus(tx *sql.Tx, postID, userID int, status string, publishAt *time.Time, now time.Time) (bool, error) {
+	var current string
+	err := tx.QueryRow("SELECT status FROM posts WHERE id = ? AND user_id = ?", postID, userID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("no post found with ID %d or unauthorized", postID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch post status: %w", err)
+	}
+
+	if current == PostStatusPublished {
+		if status != PostStatusPublished {
+			return false, ErrAlreadyPublished
+		}
+		return false, nil
+	}
+
+	if status == PostStatusPublished {
+		_, err = tx.Exec(`
+			UPDATE posts SET status = ?, publish_at = NULL, timestamp = ? WHERE id = ?
+		`, status, now, postID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE posts SET status = ?, publish_at = ? WHERE id = ?
+		`, status, publishAt, postID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update post status: %w", err)
+	}
+	// The hot score counts from the new timestamp
+	if err := database.UpdatePostScores(tx, postID); err != nil {
+		return false, err
+	}
+	return status == PostStatusPublished, nil
+}
+
+// IsPublished reports whether a post exists, is live and was not hidden by a
+// moderator
+func (pc *PostController) IsPublished(postID int) (bool, error) {
+	var status string
+	err := pc.DB.QueryRow("SELECT status FROM posts WHERE id = ? AND hidden = 0", postID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch post status: %w", err)
+	}
+	return status == PostStatusPublished, nil
+}
+
+// GetDrafts returns the user's draft and scheduled posts, most recently
+// created first
+func (pc *PostController) GetDrafts(userID int) ([]models.Post, error) {
+	rows, err := pc.DB.Query(`
+		SELECT p.id, p.title, p.user_id, p.category, p.content, COALESCE(p.content_html, ''), p.timestamp, p.image_url,
+		       p.edited_at, p.status, p.publish_at, u.nickname
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.user_id = ? AND p.status IN ('draft', 'scheduled')
+		ORDER BY p.timestamp DESC, p.id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := make([]models.Post, 0)
+	for rows.Next() {
+		var post models.Post
+		err := rows.Scan(&post.ID, &post.Title, &post.UserID, &post.Category, &post.Content,
+			&post.ContentHTML, &post.Timestamp, &post.ImageUrl, &post.EditedAt, &post.Status, &post.PublishAt, &post.Author)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan draft: %w", err)
+		}
+		post.IsAuthor = true
+		drafts = append(drafts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate drafts: %w", err)
+	}
+
+	if err := loadPostAttachments(pc.DB, drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// PublishDuePosts publishes every scheduled post whose publish time has
+// passed and returns them. Each post's timestamp becomes its publish time.
+func (pc *PostController) PublishDuePosts(now time.Time) ([]models.Post, error) {
+	rows, err := pc.DB.Query(`
+		SELECT id FROM posts
+		WHERE status = 'scheduled' AND publish_at <= ?
+		ORDER BY publish_at
+	`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch due posts: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan due post: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate due posts: %w", err)
+	}
+
+	published := make([]models.Post, 0, len(ids))
+	for _, id := range ids {
+		// The status check keeps a post that was edited back to draft in
+		// the meantime from going live
+		result, err := pc.DB.Exec(`
+			UPDATE posts SET status = 'published', timestamp = publish_at
+			WHERE id = ? AND status = 'scheduled'
+		`, id)
+		if err != nil {
+			return published, fmt.Errorf("failed to publish post %d: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		if err := database.UpdatePostScores(pc.DB, id); err != nil {
+			return published, err
+		}
+
+		post, err := pc.GetPostByID(strconv.Itoa(id))
+		if err != nil {
+			return published, err
+		}
+		published = append(published, post)
+	}
+	return published, nil
+}
+
+// PublishScheduledPosts periodically publishes scheduled posts that are due
+// and hands each one to onPublish, until ctx is cancelled
+func PublishScheduledPosts(ctx context.Context, db *sql.DB, onPublish func(models.Post)) {
+	pc := NewPostController(db)
+	publish := func() {
+		posts, err := pc.PublishDuePosts(time.Now())
+		if err != nil {
+			logger.Error("Failed to publish scheduled posts: %v", err)
+		}
+		for _, post := range posts {
+			logger.Info("Published scheduled post %d", post.ID)
+			onPublish(post)
+		}
+	}
+
+	logger.Info("Running initial publish of scheduled posts...")
+	publish()
+
+	ticker := time.NewTicker(PublishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping scheduled post publisher...")
+			return
+		case <-ticker.C:
+			publish()
+		}
+	}
+}
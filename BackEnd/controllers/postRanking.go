@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Feed sort modes. Hot, top and controversial sort on scores stored with
+// each post, see database.UpdatePostScores.
+const (
+	// FeedSortNew shows the newest posts first
+	FeedSortNew = "new"
+	// FeedSortHot weighs votes and comments against age
+	FeedSortHot = "hot"
+	// FeedSortTop shows the posts with the most net likes first
+	FeedSortTop = "top"
+	// FeedSortControversial shows the posts with the most evenly split
+	// votes first
+	FeedSortControversial = "controversial"
+)
+
+// Windows of the top feed
+const (
+	FeedWindowDay   = "day"
+	FeedWindowWeek  = "week"
+	FeedWindowMonth = "month"
+	FeedWindowAll   = "all"
+)
+
+var (
+	ErrInvalidFeedSort   = errors.New("sort must be new, hot, top or controversial")
+	ErrInvalidFeedWindow = errors.New("window must be day, week, month or all")
+)
+
+// feedOrder is the sort key of a feed: the column it sorts on, how that is
+// selected into a cursor, and how a cursor's key is read back
+type feedOrder struct {
+	column string
+	key    string
+	parse  func(key string) (any, error)
+}
+
+func feedOrderFor(sort string) (feedOrder, error) {
+	switch sort {
+	case "", FeedSortNew:
+		return feedOrder{column: "p.timestamp", key: "CAST(p.timestamp AS TEXT)", parse: func(key string) (any, error) {
+			return key, nil
+		}}, nil
+	case FeedSortHot:
+		return feedOrder{column: "p.hot_score", key: "p.hot_score", parse: parseFloatKey}, nil
+	case FeedSortTop:
+		return feedOrder{column: "p.top_score", key: "p.top_score", parse: func(key string) (any, error) {
+			score, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			return score, nil
+		}}, nil
+	case FeedSortControversial:
+		return feedOrder{column: "p.controversy_score", key: "p.controversy_score", parse: parseFloatKey}, nil
+	default:
+		return feedOrder{}, ErrInvalidFeedSort
+	}
+}
+
+// parseFloatKey reads a score cursor key. Scores are scanned into the key
+// with the shortest exact formatting, so they compare equal when read back.
+func parseFloatKey(key string) (any, error) {
+	score, err := strconv.ParseFloat(key, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return score, nil
+}
+
+// feedWindowStart returns the earliest publish time a top feed window takes,
+// or the zero time for all posts
+func feedWindowStart(window string, now time.Time) (time.Time, error) {
+	switch window {
+	case "", FeedWindowAll:
+		return time.Time{}, nil
+	case FeedWindowDay:
+		return now.AddDate(0, 0, -1), nil
+	case FeedWindowWeek:
+		return now.AddDate(0, 0, -7), nil
+	case FeedWindowMonth:
+		return now.AddDate(0, -1, 0), nil
+	default:
+		return time.Time{}, ErrInvalidFeedWindow
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/richtext"
+)
+
+// ErrRevisionNotFound is returned when a post has no revision with the
+// requested number
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ensureBaseRevision records the post as originally published as revision 1
+// before its first edit, so every later revision has something to diff against
+func ensureBaseRevision(tx *sql.Tx, postID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO post_revisions (post_id, revision, editor_id, title, content, category, image_url, created_at)
+		SELECT id, 1, user_id, title, content, category, image_url, timestamp
+		FROM posts
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_id = ?)
+	`, postID, postID)
+	if err != nil {
+		return fmt.Errorf("failed to record base revision: %w", err)
+	}
+	return nil
+}
+
+// postContentChanged reports whether post differs from the stored row in any
+// field that is tracked by revisions
+func postContentChanged(tx *sql.Tx, post models.Post) (bool, error) {
+	var title, content, category string
+	var imageUrl sql.NullString
+	err := tx.QueryRow(`
+		SELECT title, content, category, image_url FROM posts WHERE id = ?
+	`, post.ID).Scan(&title, &content, &category, &imageUrl)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch current post: %w", err)
+	}
+	return title != post.Title || content != post.Content || category != post.Category ||
+		imageUrl.String != post.ImageUrl.String || imageUrl.Valid != post.ImageUrl.Valid, nil
+}
+
+// recordRevision snapshots the current state of a post as its next revision
+func recordRevision(tx *sql.Tx, postID, editorID int, rolledBackFrom *int, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO post_revisions (post_id, revision, editor_id, title, content, category, image_url, rolled_back_from, created_at)
+		SELECT id,
+		       (SELECT COALESCE(MAX(revision), 0) + 1 FROM post_revisions WHERE post_id = ?),
+		       ?, title, content, category, image_url, ?, ?
+		FROM posts
+		WHERE id = ?
+	`, postID, editorID, rolledBackFrom, at, postID)
+	if err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+	return nil
+}
+
+// GetPostRevisions returns the edit history of a post, oldest first. A post
+// that was never edited has no revisions.
+func (pc *PostController) GetPostRevisions(postID int) ([]models.PostRevision, error) {
+	rows, err := pc.DB.Query(`
+		SELECT r.id, r.post_id, r.revision, r.editor_id, COALESCE(u.nickname, ''),
+		       r.title, r.content, r.category, COALESCE(r.image_url, ''), r.rolled_back_from, r.created_at
+		FROM post_revisions r
+		LEFT JOIN users u ON u.id = r.editor_id
+		WHERE r.post_id = ?
+		ORDER BY r.revision
+	`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]models.PostRevision, 0)
+	for rows.Next() {
+		var r models.PostRevision
+		var rolledBackFrom sql.NullInt64
+		err := rows.Scan(&r.ID, &r.PostID, &r.Revision, &r.EditorID, &r.Editor,
+			&r.Title, &r.Content, &r.Category, &r.ImageUrl, &rolledBackFrom, &r.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		if rolledBackFrom.Valid {
+			from := int(rolledBackFrom.Int64)
+			r.RolledBackFrom = &from
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// RollbackPost restores the title, content and categories of an earlier
+// revision. Attachments are left as they are, since revisions do not keep
+// their images. The rollback is itself recorded as a new revision, so
+// history is never rewritten. Callers must check that editorID is the
+// author or a moderator.
+func (pc *PostController) RollbackPost(postID, revision, editorID int) error {
+	tx, err := pc.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var title, content, category string
+	err = tx.QueryRow(`
+		SELECT title, content, category
+		FROM post_revisions
+		WHERE post_id = ? AND revision = ?
+	`, postID, revision).Scan(&title, &content, &category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRevisionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch revision: %w", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := tx.Exec(`
+		UPDATE posts
+		SET title = ?, content = ?, content_html = ?, category = ?, edited_at = ?
+		WHERE id = ?
+	`, title, content, richtext.Render(content), category, now, postID)
+	if err != nil {
+		return fmt.Errorf("failed to roll back post: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Relink the categories named in the snapshot that still exist
+	if _, err := tx.Exec("DELETE FROM post_categories WHERE post_id = ?", postID); err != nil {
+		return fmt.Errorf("failed to clear post categories: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO post_categories (post_id, category_id)
+		SELECT ?, id FROM categories
+		WHERE ',' || ? || ',' LIKE '%,' || slug || ',%'
+	`, postID, category)
+	if err != nil {
+		return fmt.Errorf("failed to relink post categories: %w", err)
+	}
+
+	if err := recordRevision(tx, postID, editorID, &revision, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
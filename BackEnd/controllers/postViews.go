@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// PostViewWindow is how long repeat views of a post by the same viewer
+// count as one
+var PostViewWindow = 30 * time.Minute
+
+// PostViewFlushInterval is how often buffered views are written to the
+// database; view counts lag behind by up to this long
+var PostViewFlushInterval = 30 * time.Second
+
+// postViewDay identifies the views of a post on one UTC day
+type postViewDay struct {
+	postID int
+	day    string
+}
+
+// postViews buffers counted views until the next flush. seen holds when
+// each viewer last counted a view of a post.
+var postViews = struct {
+	sync.Mutex
+	seen    map[string]time.Time
+	pending map[postViewDay]int
+}{
+	seen:    make(map[string]time.Time),
+	pending: make(map[postViewDay]int),
+}
+
+// RecordPostView counts a view of a post by viewer, a key for the user or
+// session behind the request, unless the same viewer was counted within
+// PostViewWindow. It reports whether the view was counted.
+func RecordPostView(postID int, viewer string, now time.Time) bool {
+	key := viewer + "|" + strconv.Itoa(postID)
+
+	postViews.Lock()
+	defer postViews.Unlock()
+	if last, ok := postViews.seen[key]; ok && now.Sub(last) < PostViewWindow {
+		return false
+	}
+	postViews.seen[key] = now
+	postViews.pending[postViewDay{postID: postID, day: now.UTC().Format(time.DateOnly)}]++
+	return true
+}
+
+// FlushPostViews writes the buffered views to post_views and
+// posts.view_count and returns how many were written. Views that fail to
+// be written stay buffered for the next flush.
+func FlushPostViews(db *sql.DB) (int, error) {
+	postViews.Lock()
+	pending := postViews.pending
+	postViews.pending = make(map[postViewDay]int)
+	// Viewers outside the window would be counted again anyway
+	cutoff := time.Now().Add(-PostViewWindow)
+	for key, last := range postViews.seen {
+		if last.Before(cutoff) {
+			delete(postViews.seen, key)
+		}
+	}
+	postViews.Unlock()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	written, err := writePostViews(db, pending)
+	if err != nil {
+		postViews.Lock()
+		for key, views := range pending {
+			postViews.pending[key] += views
+		}
+		postViews.Unlock()
+		return 0, err
+	}
+	return written, nil
+}
+
+func writePostViews(db *sql.DB, pending map[postViewDay]int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	written := 0
+	totals := make(map[int]int)
+	for key, views := range pending {
+		// Views of a post deleted since they were counted are dropped
+		_, err := tx.Exec(`
+			INSERT INTO post_views (post_id, day, views)
+			SELECT ?, ?, ? WHERE EXISTS (SELECT 1 FROM posts WHERE id = ?)
+			ON CONFLICT (post_id, day) DO UPDATE SET views = views + excluded.views
+		`, key.postID, key.day, views, key.postID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record views of post %d: %w", key.postID, err)
+		}
+		totals[key.postID] += views
+	}
+	for postID, views := range totals {
+		result, err := tx.Exec("UPDATE posts SET view_count = view_count + ? WHERE id = ?", views, postID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update view count of post %d: %w", postID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			written += views
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, nil
+}
+
+// FlushPostViewsPeriodically writes buffered views every
+// PostViewFlushInterval, and once more when ctx is cancelled so no views
+// are lost on shutdown
+func FlushPostViewsPeriodically(ctx context.Context, db *sql.DB) {
+	flush := func() {
+		if _, err := FlushPostViews(db); err != nil {
+			logger.Error("Failed to write post views: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(PostViewFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Writing buffered post views before stopping...")
+			flush()
+			return
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -29,8 +29,9 @@ func (pc *ProfileController) GetUserProfile(w http.ResponseWriter, r *http.Reque
 	}
 
 	var user models.User
+	var avatarURL string
 	err := pc.db.QueryRow(`
-		SELECT id, nickname, first_name, last_name, email, created_at
+		SELECT id, nickname, first_name, last_name, email, created_at, COALESCE(avatar_url, '')
 		FROM users 
 		WHERE id = ?`, userID).Scan(
 		&user.ID,
@@ -39,6 +40,7 @@ func (pc *ProfileController) GetUserProfile(w http.ResponseWriter, r *http.Reque
 		&user.LastName,
 		&user.Email,
 		&user.CreatedAt,
+		&avatarURL,
 	)
 
 	if err == sql.ErrNoRows {
@@ -60,6 +62,7 @@ func (pc *ProfileController) GetUserProfile(w http.ResponseWriter, r *http.Reque
 		LastName:  user.LastName,
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"), // RFC3339 format
+		AvatarURL: avatarURL,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -126,7 +129,7 @@ func (pc *ProfileController) GetUserPosts(w http.ResponseWriter, r *http.Request
                COALESCE(EXISTS(SELECT 1 FROM likes l2 WHERE l2.post_id = p.id AND l2.user_id = ? AND l2.user_vote = 'like'), 0) as is_liked
         FROM posts p
         LEFT JOIN likes l ON p.id = l.post_id AND l.user_vote = 'like'
-        WHERE p.user_id = ?
+        WHERE p.user_id = ? AND p.status = 'published' AND p.hidden = 0
         GROUP BY p.id, p.title, p.content, p.timestamp
         ORDER BY p.timestamp DESC`, userID, userID)
 
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Report reasons a reporter picks from
+var ReportReasons = []string{"spam", "harassment", "hate", "sexual", "violence", "misinformation", "other"}
+
+// Report statuses; a report is resolved by the moderator action taken on
+// its target
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// MaxReportDetailsLength bounds the free text of a report
+const MaxReportDetailsLength = 1000
+
+// ReportExcerptLength is the maximum number of characters of the reported
+// content shown in the moderation queue
+const ReportExcerptLength = 200
+
+// Report errors
+var (
+	ErrInvalidReportTarget  = errors.New("target_type must be post, comment or message")
+	ErrInvalidReportReason  = fmt.Errorf("reason must be one of %s", strings.Join(ReportReasons, ", "))
+	ErrReportDetailsLong    = fmt.Errorf("details must be at most %d characters", MaxReportDetailsLength)
+	ErrReportTargetNotFound = errors.New("reported content not found")
+	ErrReportOwnContent     = errors.New("you cannot report your own content")
+	ErrAlreadyReported      = errors.New("you have already reported this content")
+)
+
+type ReportController struct {
+	DB *sql.DB
+}
+
+func NewReportController(db *sql.DB) *ReportController {
+	return &ReportController{DB: db}
+}
+
+// reportTarget is the reported content as it is now
+type reportTarget struct {
+	authorID int
+	// recipientID is the receiver of a direct message
+	recipientID int
+	postID      int
+	content     string
+	hidden      bool
+}
+
+// loadReportTarget fetches a post, comment or message that can be reported.
+// Drafts and comments on drafts count as missing.
+func loadReportTarget(db *sql.DB, targetType string, targetID int) (reportTarget, error) {
+	var t reportTarget
+	var err error
+	switch targetType {
+	case ModerationTargetPost:
+		err = db.QueryRow(`
+			SELECT user_id, id, content, hidden FROM posts WHERE id = ? AND status = 'published'
+		`, targetID).Scan(&t.authorID, &t.postID, &t.content, &t.hidden)
+	case ModerationTargetComment:
+		err = db.QueryRow(`
+			SELECT c.user_id, c.post_id, c.content, c.hidden
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.id = ? AND p.status = 'published'
+		`, targetID).Scan(&t.authorID, &t.postID, &t.content, &t.hidden)
+	case ModerationTargetMessage:
+		err = db.QueryRow(`
+			SELECT sender_id, receiver_id, content, hidden FROM messages WHERE id = ?
+		`, targetID).Scan(&t.authorID, &t.recipientID, &t.content, &t.hidden)
+	default:
+		return t, ErrInvalidReportTarget
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrReportTargetNotFound
+	}
+	if err != nil {
+		return t, fmt.Errorf("failed to fetch reported %s: %w", targetType, err)
+	}
+	return t, nil
+}
+
+// CreateReport files a report by reporterID. Each user reports a piece of
+// content once; direct messages can only be reported by their recipient.
+func (rc *ReportController) CreateReport(reporterID int, req models.ReportRequest) (models.Report, error) {
+	report := models.Report{
+		ReporterID: reporterID,
+		TargetType: strings.ToLower(strings.TrimSpace(req.TargetType)),
+		TargetID:   req.TargetID,
+		Reason:     strings.ToLower(strings.TrimSpace(req.Reason)),
+		Details:    strings.TrimSpace(req.Details),
+		Status:     ReportStatusOpen,
+	}
+	if !slices.Contains(ReportReasons, report.Reason) {
+		return report, ErrInvalidReportReason
+	}
+	if len([]rune(report.Details)) > MaxReportDetailsLength {
+		return report, ErrReportDetailsLong
+	}
+
+	target, err := loadReportTarget(rc.DB, report.TargetType, report.TargetID)
+	if err != nil {
+		return report, err
+	}
+	if report.TargetType == ModerationTargetMessage && target.recipientID != reporterID && target.authorID != reporterID {
+		return report, ErrReportTargetNotFound
+	}
+	if target.authorID == reporterID {
+		return report, ErrReportOwnContent
+	}
+
+	report.CreatedAt = time.Now().UTC()
+	result, err := rc.DB.Exec(`
+		INSERT INTO reports (reporter_id, target_type, target_id, target_author_id, reason, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, reporterID, report.TargetType, report.TargetID, target.authorID, report.Reason, report.Details, report.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return report, ErrAlreadyReported
+		}
+		return report, fmt.Errorf("failed to store report: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return report, fmt.Errorf("failed to get report ID: %w", err)
+	}
+	report.ID = int(id)
+	report.Reporter = GetUsernameByID(rc.DB, reporterID)
+	return report, nil
+}
@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Report queue page size bounds used when the caller does not pass a
+// usable limit
+const (
+	DefaultReportQueuePageSize = 20
+	MaxReportQueuePageSize     = 100
+)
+
+// Suspension lengths, in days
+const (
+	DefaultSuspensionDays = 7
+	MaxSuspensionDays     = 365
+)
+
+// Report moderation errors
+var (
+	ErrInvalidReportAction    = errors.New("action must be dismiss, hide, delete, warn or suspend")
+	ErrNoOpenReports          = errors.New("no open reports on this content")
+	ErrInvalidSuspension      = fmt.Errorf("days must be between 1 and %d", MaxSuspensionDays)
+	ErrCannotSuspendModerator = errors.New("moderators cannot be suspended")
+	ErrAccountSuspended       = errors.New("account is suspended")
+)
+
+// reportActions lists the actions ResolveReports takes
+var reportActions = []string{ModerationDismiss, ModerationHide, ModerationDelete, ModerationWarn, ModerationSuspend}
+
+// ReportQueueQuery selects a page of the moderation queue
+type ReportQueueQuery struct {
+	// Status is open, the default, or resolved
+	Status string
+	Page   int
+	Limit  int
+}
+
+// ReportResolution is the outcome of a moderator action on reported content
+type ReportResolution struct {
+	// Resolved counts the reports the action closed
+	Resolved int
+	// SuspendedUntil is set by suspensions, along with the suspended
+	// SuspendedUserID
+	SuspendedUntil  *time.Time
+	SuspendedUserID int
+	// Notifications holds the stored warning, to be published to the author
+	Notifications []models.Notification
+}
+
+// GetReportQueue returns a page of reported content with the reports on
+// each, the most reported first and then the longest waiting
+func (mc *ModerationController) GetReportQueue(q ReportQueueQuery) ([]models.ReportedTarget, bool, error) {
+	status := q.Status
+	if status == "" {
+		status = ReportStatusOpen
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultReportQueuePageSize
+	}
+	if limit > MaxReportQueuePageSize {
+		limit = MaxReportQueuePageSize
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+
+	rows, err := mc.DB.Query(`
+		SELECT target_type, target_id, MAX(target_author_id)
+		FROM reports
+		WHERE status = ?
+		GROUP BY target_type, target_id
+		ORDER BY COUNT(*) DESC, MIN(id) ASC
+		LIMIT ? OFFSET ?
+	`, status, limit+1, (page-1)*limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch report queue: %w", err)
+	}
+	targets := make([]models.ReportedTarget, 0, limit+1)
+	for rows.Next() {
+		var t models.ReportedTarget
+		if err := rows.Scan(&t.TargetType, &t.TargetID, &t.AuthorID); err != nil {
+			rows.Close()
+			return nil, false, fmt.Errorf("failed to scan report queue: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate report queue: %w", err)
+	}
+
+	hasMore := len(targets) > limit
+	if hasMore {
+		targets = targets[:limit]
+	}
+	for i := range targets {
+		if err := mc.fillReportedTarget(&targets[i], status); err != nil {
+			return nil, false, err
+		}
+	}
+	return targets, hasMore, nil
+}
+
+// fillReportedTarget loads the reports with status on t and the content
+// they are about
+func (mc *ModerationController) fillReportedTarget(t *models.ReportedTarget, status string) error {
+	content, err := loadReportTarget(mc.DB, t.TargetType, t.TargetID)
+	switch {
+	case errors.Is(err, ErrReportTargetNotFound):
+		t.Deleted = true
+	case err != nil:
+		return err
+	default:
+		t.AuthorID = content.authorID
+		t.PostID = content.postID
+		t.Excerpt = PreviewText(content.content, ReportExcerptLength)
+		t.Hidden = content.hidden
+	}
+	t.Author = GetUsernameByID(mc.DB, t.AuthorID)
+
+	rows, err := mc.DB.Query(`
+		SELECT r.id, r.reporter_id, COALESCE(u.nickname, ''), r.reason, r.details, r.status,
+		       r.resolution, r.created_at, r.resolved_at
+		FROM reports r
+		LEFT JOIN users u ON u.id = r.reporter_id
+		WHERE r.target_type = ? AND r.target_id = ? AND r.status = ?
+		ORDER BY r.id
+	`, t.TargetType, t.TargetID, status)
+	if err != nil {
+		return fmt.Errorf("failed to fetch reports: %w", err)
+	}
+	defer rows.Close()
+
+	t.Reasons = make(map[string]int)
+	for rows.Next() {
+		r := models.Report{TargetType: t.TargetType, TargetID: t.TargetID}
+		var resolvedAt sql.NullTime
+		err := rows.Scan(&r.ID, &r.ReporterID, &r.Reporter, &r.Reason, &r.Details, &r.Status,
+			&r.Resolution, &r.CreatedAt, &resolvedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan report: %w", err)
+		}
+		if resolvedAt.Valid {
+			r.ResolvedAt = &resolvedAt.Time
+		}
+		t.Reasons[r.Reason]++
+		t.Reports = append(t.Reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate reports: %w", err)
+	}
+
+	t.ReportCount = len(t.Reports)
+	if t.ReportCount > 0 {
+		t.FirstReportedAt = t.Reports[0].CreatedAt
+		t.LatestReportedAt = t.Reports[t.ReportCount-1].CreatedAt
+	}
+	return nil
+}
+
+// ResolveReports takes action on reported content and closes its open
+// reports:
+//
+//   - dismiss leaves the content as it is
+//   - hide takes it out of feeds and search and blanks it in threads
+//   - delete removes it
+//   - warn sends its author a moderation notification
+//   - suspend keeps its author from signing in for req.Days days
+//
+// The action, the closed reports and the moderation log entry are stored
+// together. Warnings and suspensions still reach the author of content
+// deleted since it was reported.
+func (mc *ModerationController) ResolveReports(moderatorID int, targetType string, targetID int, action string, req models.ModerationRequest) (ReportResolution, error) {
+	var res ReportResolution
+	if !slices.Contains(reportActions, action) {
+		return res, ErrInvalidReportAction
+	}
+	reason, err := normalizeModerationReason(req.Reason)
+	if err != nil {
+		return res, err
+	}
+	days := req.Days
+	if days == 0 {
+		days = DefaultSuspensionDays
+	}
+	if action == ModerationSuspend && (days < 0 || days > MaxSuspensionDays) {
+		return res, ErrInvalidSuspension
+	}
+
+	var authorID int
+	err = mc.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(MAX(target_author_id), 0) FROM reports
+		WHERE target_type = ? AND target_id = ? AND status = ?
+	`, targetType, targetID, ReportStatusOpen).Scan(&res.Resolved, &authorID)
+	if err != nil {
+		return res, fmt.Errorf("failed to count open reports: %w", err)
+	}
+	if res.Resolved == 0 {
+		return res, ErrNoOpenReports
+	}
+	target, err := loadReportTarget(mc.DB, targetType, targetID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrReportTargetNotFound) {
+		return res, err
+	}
+	if exists {
+		authorID = target.authorID
+	} else if action == ModerationHide || action == ModerationDelete {
+		return res, ErrReportTargetNotFound
+	}
+	if action == ModerationSuspend {
+		moderator, err := HasRole(mc.DB, authorID, RoleModerator)
+		if err != nil {
+			return res, fmt.Errorf("failed to check author role: %w", err)
+		}
+		if moderator {
+			return res, ErrCannotSuspendModerator
+		}
+	}
+
+	tx, err := mc.DB.Begin()
+	if err != nil {
+		return res, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entry := models.ModerationLogEntry{
+		ModeratorID: moderatorID,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Reason:      reason,
+		Details:     fmt.Sprintf("resolved %d reports", res.Resolved),
+	}
+	// released holds the stored paths of deleted images, for releaseUploads
+	// once the transaction has committed
+	var released []string
+	switch action {
+	case ModerationHide:
+		table := map[string]string{
+			ModerationTargetPost:    "posts",
+			ModerationTargetComment: "comments",
+			ModerationTargetMessage: "messages",
+		}[targetType]
+		if _, err := tx.Exec("UPDATE "+table+" SET hidden = 1 WHERE id = ?", targetID); err != nil {
+			return res, fmt.Errorf("failed to hide %s: %w", targetType, err)
+		}
+	case ModerationDelete:
+		// Posts and comments are deleted the way their authors delete them
+		switch targetType {
+		case ModerationTargetPost:
+			released, err = deletePost(tx, targetID, authorID)
+		case ModerationTargetComment:
+			released, err = deleteComment(tx, targetID)
+		case ModerationTargetMessage:
+			var mediaPath sql.NullString
+			err = tx.QueryRow("DELETE FROM messages WHERE id = ? RETURNING media_url", targetID).Scan(&mediaPath)
+			if err != nil {
+				err = fmt.Errorf("failed to delete message: %w", err)
+			} else if mediaPath.Valid {
+				released = []string{mediaPath.String}
+			}
+		}
+		if err != nil {
+			return res, err
+		}
+	case ModerationWarn:
+		n := models.Notification{
+			UserID:     authorID,
+			Type:       NotificationModeration,
+			SourceType: targetType,
+			SourceID:   targetID,
+			PostID:     target.postID,
+			Excerpt:    notificationExcerpt(reason),
+		}
+		if _, err := insertNotification(tx, &n); err != nil {
+			return res, err
+		}
+		res.Notifications = append(res.Notifications, n)
+		entry.TargetType, entry.TargetID = ModerationTargetUser, authorID
+		entry.Details = fmt.Sprintf("for %s %d, resolved %d reports", targetType, targetID, res.Resolved)
+	case ModerationSuspend:
+		until := time.Now().UTC().AddDate(0, 0, days)
+		if _, err := tx.Exec("UPDATE users SET suspended_until = ? WHERE id = ?", until, authorID); err != nil {
+			return res, fmt.Errorf("failed to suspend user: %w", err)
+		}
+		// Signing the author out ends every session they hold
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", authorID); err != nil {
+			return res, fmt.Errorf("failed to end sessions: %w", err)
+		}
+		res.SuspendedUntil, res.SuspendedUserID = &until, authorID
+		entry.TargetType, entry.TargetID = ModerationTargetUser, authorID
+		entry.Details = fmt.Sprintf("until %s for %s %d, resolved %d reports",
+			until.Format(time.RFC3339), targetType, targetID, res.Resolved)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reports SET status = ?, resolution = ?, resolved_by = ?, resolved_at = ?
+		WHERE target_type = ? AND target_id = ? AND status = ?
+	`, ReportStatusResolved, action, moderatorID, time.Now().UTC(), targetType, targetID, ReportStatusOpen)
+	if err != nil {
+		return res, fmt.Errorf("failed to resolve reports: %w", err)
+	}
+	if err := logModerationAction(tx, entry); err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, fmt.Errorf("failed to commit report resolution: %w", err)
+	}
+
+	releaseUploads(mc.DB, released)
+	return res, nil
+}
+
+// SuspendedUntil reports whether the user is suspended and until when.
+// Unknown users are not suspended.
+func SuspendedUntil(db *sql.DB, userID int) (time.Time, bool, error) {
+	var until sql.NullTime
+	err := db.QueryRow("SELECT suspended_until FROM users WHERE id = ?", userID).Scan(&until)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, fmt.Errorf("failed to check suspension: %w", err)
+	}
+	if !until.Valid || !until.Time.After(time.Now()) {
+		return time.Time{}, false, nil
+	}
+	return until.Time, true, nil
+}
+
+// CheckNotSuspended returns ErrAccountSuspended, with the end of the
+// suspension, while the user is suspended
+func CheckNotSuspended(db *sql.DB, userID int) error {
+	until, suspended, err := SuspendedUntil(db, userID)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		return fmt.Errorf("%w until %s", ErrAccountSuspended, until.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,466 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/imaging"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Resumable uploads follow the tus protocol: the client creates an upload
+// with the length of the file, then sends it in chunks, asking for the
+// offset to resume from when a connection drops. Chunks are appended to a
+// partial file on local disk. Once the last byte arrives the file is
+// processed and stored like a form upload, and the completed upload can be
+// linked to a post, comment or message by its ID until it expires.
+
+// TusVersion is the version of the tus protocol the server speaks
+const TusVersion = "1.0.0"
+
+// MaxResumableUploadSize is the largest file a resumable upload takes
+const MaxResumableUploadSize = 100 << 20
+
+// ResumableUploadExpiry is how long an upload is kept after its last chunk.
+// Incomplete uploads are deleted with their data, completed ones with the
+// files nothing else came to use.
+var ResumableUploadExpiry = 24 * time.Hour
+
+// Targets of a resumable upload, given in the target metadata. They decide
+// how the file is stored: post images with all their variants, comment and
+// message images as a single medium variant.
+const (
+	UploadTargetPost    = "post"
+	UploadTargetComment = "comment"
+	UploadTargetMessage = "message"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+	ErrUploadBusy           = errors.New("upload is being written by another request")
+	ErrUploadIncomplete     = errors.New("upload is not complete")
+	ErrUploadTooLarge       = fmt.Errorf("%w: file size exceeds %dMB limit", ErrInvalidUpload, MaxResumableUploadSize>>20)
+	ErrInvalidUploadTarget  = fmt.Errorf("%w: target must be %s, %s or %s", ErrInvalidUpload,
+		UploadTargetPost, UploadTargetComment, UploadTargetMessage)
+)
+
+// ResumableUploadDir returns the directory that keeps the data of
+// incomplete uploads, RESUMABLE_UPLOAD_DIR or "uploads_partial". It must
+// not be inside the blob store, whose sweeper would delete the data.
+func ResumableUploadDir() string {
+	if dir := os.Getenv("RESUMABLE_UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads_partial"
+}
+
+// activeUploads holds the IDs of the uploads a request is writing to, so
+// two requests never append to the same upload
+var activeUploads = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: make(map[string]bool)}
+
+// claimUpload marks an upload as being written to; the returned function
+// releases it
+func claimUpload(id string) (func(), error) {
+	activeUploads.Lock()
+	defer activeUploads.Unlock()
+	if activeUploads.ids[id] {
+		return nil, ErrUploadBusy
+	}
+	activeUploads.ids[id] = true
+	return func() {
+		activeUploads.Lock()
+		delete(activeUploads.ids, id)
+		activeUploads.Unlock()
+	}, nil
+}
+
+type ResumableUploadController struct {
+	DB *sql.DB
+	// Dir keeps the data of incomplete uploads
+	Dir string
+}
+
+func NewResumableUploadController(db *sql.DB, dir string) *ResumableUploadController {
+	return &ResumableUploadController{DB: db, Dir: dir}
+}
+
+func (rc *ResumableUploadController) partialPath(id string) string {
+	return filepath.Join(rc.Dir, id)
+}
+
+// Create starts an upload of length bytes for userID. The metadata gives the
+// target, and optionally the filename and, for posts, the alt text.
+func (rc *ResumableUploadController) Create(userID int, length int64, metadata map[string]string) (models.ResumableUpload, error) {
+	upload := models.ResumableUpload{
+		UserID:   userID,
+		Target:   metadata["target"],
+		Filename: strings.TrimSpace(metadata["filename"]),
+		AltText:  strings.TrimSpace(metadata["alt_text"]),
+		Length:   length,
+	}
+	switch {
+	case length <= 0:
+		return upload, fmt.Errorf("%w: the upload length must be positive", ErrInvalidUpload)
+	case length > MaxResumableUploadSize:
+		return upload, ErrUploadTooLarge
+	case upload.Target != UploadTargetPost && upload.Target != UploadTargetComment && upload.Target != UploadTargetMessage:
+		return upload, ErrInvalidUploadTarget
+	case len([]rune(upload.AltText)) > MaxAltTextLength:
+		return upload, ErrAltTextTooLong
+	}
+	if len(upload.Filename) > 255 {
+		upload.Filename = upload.Filename[:255]
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return upload, err
+	}
+	upload.ID = hex.EncodeToString(b)
+	upload.ExpiresAt = time.Now().Add(ResumableUploadExpiry)
+
+	if err := os.MkdirAll(rc.Dir, 0o755); err != nil {
+		return upload, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	file, err := os.OpenFile(rc.partialPath(upload.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return upload, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	file.Close()
+
+	_, err = rc.DB.Exec(`
+		INSERT INTO resumable_uploads (id, user_id, target, filename, alt_text, length, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, upload.ID, userID, upload.Target, upload.Filename, upload.AltText, length, upload.ExpiresAt)
+	if err != nil {
+		os.Remove(rc.partialPath(upload.ID))
+		return upload, fmt.Errorf("failed to create upload: %w", err)
+	}
+	return upload, nil
+}
+
+// get returns an unexpired upload of userID. The attachment of a completed
+// upload holds stored paths.
+func (rc *ResumableUploadController) get(userID int, id string) (models.ResumableUpload, error) {
+	var upload models.ResumableUpload
+	var completed bool
+	var a models.Attachment
+	err := rc.DB.QueryRow(`
+		SELECT id, user_id, target, filename, alt_text, length, upload_offset, expires_at,
+		       completed_at IS NOT NULL, path, COALESCE(NULLIF(medium_path, ''), path),
+		       COALESCE(NULLIF(thumbnail_path, ''), path), mime_type, width, height, size
+		FROM resumable_uploads
+		WHERE id = ? AND user_id = ? AND expires_at > ?
+	`, id, userID, time.Now()).Scan(&upload.ID, &upload.UserID, &upload.Target, &upload.Filename, &upload.AltText,
+		&upload.Length, &upload.Offset, &upload.ExpiresAt, &completed, &a.URL, &a.MediumURL, &a.ThumbnailURL,
+		&a.MimeType, &a.Width, &a.Height, &a.Size)
+	if err == sql.ErrNoRows {
+		return upload, ErrUploadNotFound
+	}
+	if err != nil {
+		return upload, fmt.Errorf("failed to fetch upload: %w", err)
+	}
+	if completed {
+		a.AltText = upload.AltText
+		upload.Attachment = &a
+	}
+	return upload, nil
+}
+
+// Get returns an upload of userID. Message images are private, so the
+// attachment of a completed message upload carries a signed MediumURL.
+func (rc *ResumableUploadController) Get(userID int, id string) (models.ResumableUpload, error) {
+	upload, err := rc.get(userID, id)
+	if err != nil {
+		return upload, err
+	}
+	signMessageUpload(&upload)
+	return upload, nil
+}
+
+func signMessageUpload(upload *models.ResumableUpload) {
+	if upload.Attachment != nil && upload.Target == UploadTargetMessage {
+		upload.Attachment.MediumURL, upload.Attachment.ThumbnailURL = MessageMediaURL(upload.Attachment.URL), ""
+	}
+}
+
+// Append writes a chunk read from body at offset, which must be the upload's
+// current offset. When the connection drops, the bytes that arrived are kept
+// and the error is returned with the upload at its new offset. The upload is
+// completed once its last byte arrives; an upload that holds no valid image
+// is then deleted.
+func (rc *ResumableUploadController) Append(ctx context.Context, userID int, id string, offset int64, body io.Reader) (models.ResumableUpload, error) {
+	release, err := claimUpload(id)
+	if err != nil {
+		return models.ResumableUpload{}, err
+	}
+	defer release()
+
+	upload, err := rc.get(userID, id)
+	if err != nil {
+		return upload, err
+	}
+	if offset != upload.Offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+	if upload.Attachment != nil {
+		signMessageUpload(&upload)
+		return upload, nil
+	}
+
+	var readErr error
+	if upload.Offset < upload.Length {
+		file, err := os.OpenFile(rc.partialPath(id), os.O_WRONLY, 0)
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Warning("Data of upload %s is gone", id)
+			return upload, ErrUploadNotFound
+		}
+		if err != nil {
+			return upload, fmt.Errorf("failed to open upload file: %w", err)
+		}
+		// Bytes past the recorded offset come from a write that failed
+		if err := file.Truncate(offset); err != nil {
+			file.Close()
+			return upload, fmt.Errorf("failed to truncate upload file: %w", err)
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return upload, fmt.Errorf("failed to seek upload file: %w", err)
+		}
+
+		remaining := upload.Length - offset
+		n, err := io.Copy(file, io.LimitReader(body, remaining+1))
+		if n > remaining {
+			file.Truncate(offset)
+			file.Close()
+			return upload, ErrUploadTooLarge
+		}
+		readErr = err
+		if err := file.Close(); err != nil {
+			return upload, fmt.Errorf("failed to write upload file: %w", err)
+		}
+
+		upload.Offset = offset + n
+		upload.ExpiresAt = time.Now().Add(ResumableUploadExpiry)
+		_, err = rc.DB.Exec("UPDATE resumable_uploads SET upload_offset = ?, expires_at = ? WHERE id = ?",
+			upload.Offset, upload.ExpiresAt, id)
+		if err != nil {
+			return upload, fmt.Errorf("failed to record upload offset: %w", err)
+		}
+		if readErr != nil {
+			return upload, fmt.Errorf("failed to read chunk: %w", readErr)
+		}
+	}
+
+	if upload.Offset < upload.Length {
+		return upload, nil
+	}
+	// A completion that failed is retried by sending an empty chunk at the
+	// end of the file
+	if err := rc.complete(ctx, &upload); err != nil {
+		return upload, err
+	}
+	signMessageUpload(&upload)
+	return upload, nil
+}
+
+// complete processes and stores the data of an upload that has fully
+// arrived, and records the stored files
+func (rc *ResumableUploadController) complete(ctx context.Context, upload *models.ResumableUpload) error {
+	file, err := os.Open(rc.partialPath(upload.ID))
+	if err != nil {
+		return fmt.Errorf("failed to open upload file: %w", err)
+	}
+	prepared, err := prepareUpload(file, upload.Length, MaxResumableUploadSize)
+	file.Close()
+	if errors.Is(err, ErrInvalidUpload) {
+		// The data will never become a valid image
+		rc.remove(upload.ID, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	var attachment models.Attachment
+	switch upload.Target {
+	case UploadTargetPost:
+		attachment, err = prepared.store(ctx, rc.DB, UploadFolderPosts, "")
+	case UploadTargetComment:
+		attachment, err = prepared.storeVariant(ctx, rc.DB, UploadFolderComments, "", imaging.VariantMedium)
+	case UploadTargetMessage:
+		attachment, err = prepared.storeVariant(ctx, rc.DB, UploadFolderMessages, messageMediaPrefix(upload.UserID), imaging.VariantMedium)
+	default:
+		return ErrInvalidUploadTarget
+	}
+	if err != nil {
+		return err
+	}
+
+	upload.ExpiresAt = time.Now().Add(ResumableUploadExpiry)
+	_, err = rc.DB.Exec(`
+		UPDATE resumable_uploads
+		SET completed_at = CURRENT_TIMESTAMP, expires_at = ?, path = ?, medium_path = ?, thumbnail_path = ?,
+		    mime_type = ?, width = ?, height = ?, size = ?
+		WHERE id = ?
+	`, upload.ExpiresAt, attachment.URL, variantPath(attachment.MediumURL, attachment.URL),
+		variantPath(attachment.ThumbnailURL, attachment.URL), attachment.MimeType,
+		attachment.Width, attachment.Height, attachment.Size, upload.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record completed upload: %w", err)
+	}
+	if err := os.Remove(rc.partialPath(upload.ID)); err != nil {
+		logger.Warning("Failed to remove data of upload %s: %v", upload.ID, err)
+	}
+
+	attachment.AltText = upload.AltText
+	upload.Attachment = &attachment
+	return nil
+}
+
+// Terminate deletes an upload of userID with its data. The files of a
+// completed upload go unless something links to them.
+func (rc *ResumableUploadController) Terminate(userID int, id string) error {
+	release, err := claimUpload(id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	upload, err := rc.get(userID, id)
+	if err != nil {
+		return err
+	}
+	var files []string
+	if upload.Attachment != nil {
+		files = attachmentFiles(*upload.Attachment)
+	}
+	return rc.remove(id, files)
+}
+
+// remove deletes an upload's row and data, then releases its stored files
+func (rc *ResumableUploadController) remove(id string, files []string) error {
+	if _, err := rc.DB.Exec("DELETE FROM resumable_uploads WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	if err := os.Remove(rc.partialPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warning("Failed to remove data of upload %s: %v", id, err)
+	}
+	releaseUploads(rc.DB, files)
+	return nil
+}
+
+// CompletedUpload returns the attachment of a completed upload of userID
+// for the given target, to link it to what the user is posting. Its URLs are
+// stored paths.
+func (rc *ResumableUploadController) CompletedUpload(userID int, id, target string) (models.Attachment, error) {
+	upload, err := rc.get(userID, id)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if upload.Attachment == nil {
+		return models.Attachment{}, ErrUploadIncomplete
+	}
+	if upload.Target != target {
+		return models.Attachment{}, fmt.Errorf("%w: upload %s is for a %s", ErrInvalidUpload, id, upload.Target)
+	}
+	return *upload.Attachment, nil
+}
+
+// RemoveExpiredResumableUploads deletes expired uploads with their data and
+// returns how many it deleted. Uploads a request is writing to are left for
+// the next run.
+func RemoveExpiredResumableUploads(db *sql.DB, dir string) (int, error) {
+	rows, err := db.Query(`
+		SELECT id, path, medium_path, thumbnail_path FROM resumable_uploads WHERE expires_at <= ?
+	`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch expired uploads: %w", err)
+	}
+	type expired struct {
+		id    string
+		files []string
+	}
+	var uploads []expired
+	for rows.Next() {
+		var e expired
+		var original, medium, thumbnail string
+		if err := rows.Scan(&e.id, &original, &medium, &thumbnail); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired upload: %w", err)
+		}
+		if original != "" {
+			e.files = attachmentFiles(models.Attachment{URL: original, MediumURL: medium, ThumbnailURL: thumbnail})
+		}
+		uploads = append(uploads, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate expired uploads: %w", err)
+	}
+
+	rc := NewResumableUploadController(db, dir)
+	removed := 0
+	for _, e := range uploads {
+		release, err := claimUpload(e.id)
+		if err != nil {
+			continue
+		}
+		// A chunk may have arrived since, extending the upload
+		result, err := db.Exec("DELETE FROM resumable_uploads WHERE id = ? AND expires_at <= ?", e.id, time.Now())
+		if err != nil {
+			release()
+			return removed, fmt.Errorf("failed to delete expired upload: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			rc.remove(e.id, e.files)
+			removed++
+		}
+		release()
+	}
+	return removed, nil
+}
+
+// CleanupExpiredResumableUploads periodically removes expired uploads
+func CleanupExpiredResumableUploads(ctx context.Context, db *sql.DB, dir string) {
+	cleanup := func() {
+		removed, err := RemoveExpiredResumableUploads(db, dir)
+		if err != nil {
+			logger.Error("Failed to clean up expired uploads: %v", err)
+		} else if removed > 0 {
+			logger.Info("Removed %d expired resumable uploads", removed)
+		}
+	}
+
+	logger.Info("Running initial cleanup of expired resumable uploads...")
+	cleanup()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping resumable upload cleanup task...")
+			return
+		case <-ticker.C:
+			cleanup()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// GetUserRole returns the role stored for a user
+func GetUserRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch user role: %w", err)
+	}
+	return role, nil
+}
+
+// HasRole reports whether the user holds one of the given roles. Admins
+// implicitly hold every role.
+func HasRole(db *sql.DB, userID int, roles ...string) (bool, error) {
+	role, err := GetUserRole(db, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if role == RoleAdmin {
+		return true, nil
+	}
+	for _, r := range roles {
+		if role == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsModerator reports whether the user may perform moderation actions
+func IsModerator(db *sql.DB, userID int) (bool, error) {
+	return HasRole(db, userID, RoleModerator)
+}
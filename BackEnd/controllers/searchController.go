@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Search errors
+var (
+	ErrSearchUnavailable = errors.New("search is not available")
+	ErrEmptySearchQuery  = errors.New("search query is empty")
+	ErrInvalidSearchType = errors.New("search type must be all, posts or comments")
+)
+
+// Search result types accepted in SearchQuery.Type
+const (
+	SearchAll      = "all"
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+)
+
+// Search limits
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 50
+	maxSearchTerms        = 10
+)
+
+// recencyHalfLifeDays controls the recency boost: a result this many days
+// old gets half the boost of one posted now
+const recencyHalfLifeDays = 7.0
+
+// Markers FTS5 puts around matches; they cannot occur in escaped output, so
+// they are swapped for <mark> tags after escaping
+const (
+	matchOpen  = "\x02"
+	matchClose = "\x03"
+)
+
+// SearchQuery describes a search request
+type SearchQuery struct {
+	Query string
+	// Type is one of SearchAll, SearchPosts or SearchComments
+	Type string
+	// Author restricts results to a nickname
+	Author string
+	// Category restricts results to posts, or comments on posts, filed
+	// under this slug
+	Category string
+	// From and To bound the result timestamp; zero values are open
+	From, To time.Time
+	Page     int
+	Limit    int
+}
+
+type SearchController struct {
+	DB *sql.DB
+}
+
+func NewSearchController(db *sql.DB) *SearchController {
+	return &SearchController{DB: db}
+}
+
+// Enabled reports whether the FTS5 indexes exist. They are missing when
+// SQLite was built without the sqlite_fts5 tag.
+func (sc *SearchController) Enabled() bool {
+	var count int
+	err := sc.DB.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('posts_fts', 'comments_fts')
+	`).Scan(&count)
+	return err == nil && count == 2
+}
+
+// buildMatchQuery turns free text into an FTS5 query that matches every
+// term, treating the last one as a prefix. Terms are quoted so operators
+// and punctuation in user input are matched literally.
+func buildMatchQuery(text string) string {
+	terms := strings.Fields(text)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.Trim(term, `"`)
+		if term == "" {
+			continue
+		}
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	if len(quoted) == 0 {
+		return ""
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
+
+// highlight escapes text for HTML and turns the FTS5 match markers into
+// <mark> tags
+func highlight(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, matchOpen, "<mark>")
+	return strings.ReplaceAll(escaped, matchClose, "</mark>")
+}
+
+// searchFilters returns the shared WHERE conditions for the author,
+// category and date filters, given the post alias, the alias of the row
+// being matched and its args
+func searchFilters(q SearchQuery, post, row string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if q.Author != "" {
+		conditions = append(conditions, "u.nickname = ? COLLATE NOCASE")
+		args = append(args, q.Author)
+	}
+	if q.Category != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM post_categories pc
+			JOIN categories cat ON cat.id = pc.category_id
+			WHERE pc.post_id = `+post+`.id AND cat.slug = ?)`)
+		args = append(args, strings.ToLower(q.Category))
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "julianday("+row+".timestamp) >= julianday(?)")
+		args = append(args, q.From.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "julianday("+row+".timestamp) < julianday(?)")
+		args = append(args, q.To.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// Search runs a ranked full-text search over posts and comments. Results
+// are ordered by bm25 relevance, boosted for recent content, and the bool
+// reports whether another page exists.
+func (sc *SearchController) Search(q SearchQuery) ([]models.SearchResult, bool, error) {
+	if !sc.Enabled() {
+		return nil, false, ErrSearchUnavailable
+	}
+	match := buildMatchQuery(q.Query)
+	if match == "" {
+		return nil, false, ErrEmptySearchQuery
+	}
+	if q.Type == "" {
+		q.Type = SearchAll
+	}
+	if q.Type != SearchAll && q.Type != SearchPosts && q.Type != SearchComments {
+		return nil, false, ErrInvalidSearchType
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchPageSize
+	}
+	if q.Limit > MaxSearchPageSize {
+		q.Limit = MaxSearchPageSize
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+
+	// bm25 is negative with better matches lower, so it is negated and
+	// scaled by a boost that decays with age
+	recency := func(row string) string {
+		return fmt.Sprintf("(1.0 + 1.0 / (1.0 + MAX(julianday('now') - julianday(%s.timestamp), 0) / %.1f))", row, recencyHalfLifeDays)
+	}
+
+	var parts []string
+	var args []interface{}
+	if q.Type != SearchComments {
+		filters, filterArgs := searchFilters(q, "p", "p")
+		parts = append(parts, `
+		SELECT 'post' AS type, p.id, p.id AS post_id, p.title,
+		       snippet(posts_fts, 0, '`+matchOpen+`', '`+matchClose+`', '…', 12) AS title_snippet,
+		       snippet(posts_fts, 1, '`+matchOpen+`', '`+matchClose+`', '…', 24) AS snippet,
+		       u.nickname, strftime('%Y-%m-%dT%H:%M:%SZ', p.timestamp) AS ts,
+		       -bm25(posts_fts, 4.0, 1.0) * `+recency("p")+` AS score
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		JOIN users u ON u.id = p.user_id
+		WHERE posts_fts MATCH ? AND p.status = 'published' AND p.hidden = 0`+filters)
+		args = append(args, match)
+		args = append(args, filterArgs...)
+	}
+	if q.Type != SearchPosts {
+		filters, filterArgs := searchFilters(q, "p", "c")
+		parts = append(parts, `
+		SELECT 'comment' AS type, c.id, c.post_id, p.title,
+		       '' AS title_snippet,
+		       snippet(comments_fts, 0, '`+matchOpen+`', '`+matchClose+`', '…', 24) AS snippet,
+		       u.nickname, strftime('%Y-%m-%dT%H:%M:%SZ', c.timestamp) AS ts,
+		       -bm25(comments_fts) * `+recency("c")+` AS score
+		FROM comments_fts
+		JOIN comments c ON c.id = comments_fts.rowid
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = c.user_id
+		WHERE comments_fts MATCH ? AND p.status = 'published' AND p.hidden = 0 AND c.hidden = 0`+filters)
+		args = append(args, match)
+		args = append(args, filterArgs...)
+	}
+
+	query := strings.Join(parts, "\n\t\tUNION ALL") + `
+		ORDER BY score DESC, ts DESC
+		LIMIT ? OFFSET ?`
+	// Fetch one extra row to learn whether another page exists
+	args = append(args, q.Limit+1, (q.Page-1)*q.Limit)
+
+	rows, err := sc.DB.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0, q.Limit)
+	for rows.Next() {
+		var r models.SearchResult
+		var title, titleSnippet, snippet, ts string
+		if err := rows.Scan(&r.Type, &r.ID, &r.PostID, &title, &titleSnippet, &snippet, &r.Author, &ts, &r.Score); err != nil {
+			return nil, false, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if strings.Contains(titleSnippet, matchOpen) {
+			r.Title = highlight(titleSnippet)
+		} else {
+			r.Title = html.EscapeString(title)
+		}
+		r.Snippet = highlight(snippet)
+		r.Timestamp, _ = time.Parse(time.RFC3339, ts)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate search results: %w", err)
+	}
+
+	hasMore := len(results) > q.Limit
+	if hasMore {
+		results = results[:q.Limit]
+	}
+	return results, hasMore, nil
+}
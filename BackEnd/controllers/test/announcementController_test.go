@@ -0,0 +1,109 @@
+// controllers/test/announcementController_test.go
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestCreateAnnouncement tests validation of announcements
+func TestCreateAnnouncement(t *testing.T) {
+	clearTables()
+
+	admin := registerTestUser(t)
+	ac := controllers.NewAnnouncementController(testDB)
+	now := time.Now().UTC()
+
+	valid := &models.Announcement{
+		AuthorID:  admin.ID,
+		Content:   "Maintenance at 22:00 UTC",
+		Severity:  "warning",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := ac.CreateAnnouncement(valid); err != nil {
+		t.Fatalf("Failed to create announcement: %v", err)
+	}
+	if valid.ID <= 0 {
+		t.Errorf("Expected announcement ID to be > 0, got %d", valid.ID)
+	}
+
+	invalid := []*models.Announcement{
+		{AuthorID: admin.ID, Content: "  ", ExpiresAt: now.Add(time.Hour)},
+		{AuthorID: admin.ID, Content: "Bad severity", Severity: "urgent", ExpiresAt: now.Add(time.Hour)},
+		{AuthorID: admin.ID, Content: "Already expired", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)},
+	}
+	for _, a := range invalid {
+		if err := ac.CreateAnnouncement(a); err == nil {
+			t.Errorf("Expected error for announcement %+v", a)
+		}
+	}
+}
+
+// TestGetActiveAnnouncements tests expiry and recipient filtering
+func TestGetActiveAnnouncements(t *testing.T) {
+	clearTables()
+
+	admin := registerTestUser(t)
+	target := registerTestUser(t)
+	other := registerTestUser(t)
+	ac := controllers.NewAnnouncementController(testDB)
+	now := time.Now().UTC()
+
+	announcements := []*models.Announcement{
+		{AuthorID: admin.ID, Content: "Everyone", CreatedAt: now.Add(-2 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{AuthorID: admin.ID, Content: "Targeted", CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour), UserIDs: []int{target.ID}},
+		{AuthorID: admin.ID, Content: "Expired", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	}
+	for _, a := range announcements[:2] {
+		if err := ac.CreateAnnouncement(a); err != nil {
+			t.Fatalf("Failed to create announcement: %v", err)
+		}
+	}
+	// Expired announcements cannot be created through the controller
+	_, err := testDB.Exec(`INSERT INTO announcements (author_id, content, severity, created_at, expires_at) VALUES (?, ?, 'info', ?, ?)`,
+		admin.ID, announcements[2].Content, announcements[2].CreatedAt, announcements[2].ExpiresAt)
+	if err != nil {
+		t.Fatalf("Failed to insert expired announcement: %v", err)
+	}
+
+	forTarget, err := ac.GetActiveAnnouncements(target.ID)
+	if err != nil {
+		t.Fatalf("Failed to get announcements: %v", err)
+	}
+	if len(forTarget) != 2 || forTarget[0].Content != "Everyone" || forTarget[1].Content != "Targeted" {
+		t.Errorf("Expected [Everyone Targeted] for target user, got %+v", forTarget)
+	}
+
+	forOther, err := ac.GetActiveAnnouncements(other.ID)
+	if err != nil {
+		t.Fatalf("Failed to get announcements: %v", err)
+	}
+	if len(forOther) != 1 || forOther[0].Content != "Everyone" {
+		t.Errorf("Expected [Everyone] for other user, got %+v", forOther)
+	}
+}
+
+// TestHasRole tests role checks used by admin and moderator routes
+func TestHasRole(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+	admin := registerTestUser(t)
+	if _, err := testDB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", admin.ID); err != nil {
+		t.Fatalf("Failed to promote admin: %v", err)
+	}
+
+	if ok, err := controllers.HasRole(testDB, user.ID, controllers.RoleAdmin); err != nil || ok {
+		t.Errorf("Expected regular user not to be admin, got %v (err %v)", ok, err)
+	}
+	if ok, err := controllers.IsModerator(testDB, admin.ID); err != nil || !ok {
+		t.Errorf("Expected admin to count as moderator, got %v (err %v)", ok, err)
+	}
+	if ok, err := controllers.HasRole(testDB, 999999, controllers.RoleUser); err != nil || ok {
+		t.Errorf("Expected unknown user to hold no role, got %v (err %v)", ok, err)
+	}
+}
@@ -243,6 +243,13 @@ func TestSanitizeInput(t *testing.T) {
 				t.Errorf("SanitizeInput(%s) changed normal text", test.input)
 			}
 
+			if test.input == "Text with <tags> & \"quotes\"" {
+				expected := "Text with &lt;tags&gt; &amp; &quot;quotes&quot;"
+				if result != expected {
+					t.Errorf("SanitizeInput(%s) = %s, expected %s", test.input, result, expected)
+				}
+			}
+
 			// Test that the original input was properly sanitized
 			if test.input == "<script>alert('XSS')</script>" {
 				if !strings.Contains(result, "script") {
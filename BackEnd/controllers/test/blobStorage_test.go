@@ -0,0 +1,180 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
+)
+
+// useTestBlobStore points uploads at a fresh local store for one test
+func useTestBlobStore(t *testing.T) *storage.LocalStore {
+	t.Helper()
+	store := storage.NewLocalStore(t.TempDir(), []byte("test secret"))
+	previous := controllers.Blobs()
+	controllers.SetBlobStore(store)
+	testDB.Exec("DELETE FROM uploads")
+	t.Cleanup(func() {
+		controllers.SetBlobStore(previous)
+		testDB.Exec("DELETE FROM uploads")
+	})
+	return store
+}
+
+// storedKeys lists the keys in a store
+func storedKeys(t *testing.T, store storage.BlobStore) []string {
+	t.Helper()
+	var keys []string
+	err := store.List(context.Background(), "", func(info storage.BlobInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list the store: %v", err)
+	}
+	return keys
+}
+
+// uploadedFile returns the first file of the post-files field of a request
+func uploadedFile(t *testing.T, size [2]int) (multipart.File, *multipart.FileHeader) {
+	t.Helper()
+	header := newUploadRequest(t, [][2]int{size}).MultipartForm.File["post-files"][0]
+	file, err := header.Open()
+	if err != nil {
+		t.Fatalf("Failed to open upload: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file, header
+}
+
+func blobExists(store storage.BlobStore, storedPath string) bool {
+	key, err := storage.KeyForPath(storedPath)
+	if err != nil {
+		return false
+	}
+	blob, err := store.Get(context.Background(), key)
+	if err != nil {
+		return false
+	}
+	blob.Body.Close()
+	return true
+}
+
+// TestPostAttachmentsUseBlobStore tests that post uploads go through the
+// configured store
+func TestPostAttachmentsUseBlobStore(t *testing.T) {
+	store := useTestBlobStore(t)
+
+	attachments, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{800, 400}}), 1)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	a := attachments[0]
+	if !strings.HasPrefix(a.URL, "uploads/posts/") || !blobExists(store, a.URL) || !blobExists(store, a.ThumbnailURL) {
+		t.Fatalf("Expected the files in the store, got %+v", a)
+	}
+	if fileExists(a.URL) {
+		t.Error("Expected nothing to be written to the default uploads directory")
+	}
+}
+
+// TestAvatars tests setting, replacing and removing an avatar
+func TestAvatars(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	withoutUploadGrace(t)
+	user := registerTestUser(t)
+	ac := controllers.NewAvatarController(testDB)
+
+	file, header := uploadedFile(t, [2]int{1000, 1000})
+	first, err := ac.SetAvatar(context.Background(), user.ID, file, header)
+	if err != nil {
+		t.Fatalf("Failed to set avatar: %v", err)
+	}
+	if !strings.HasPrefix(first, "uploads/avatars/") || !blobExists(store, first) {
+		t.Fatalf("Expected the avatar in the store, got %q", first)
+	}
+	var stored string
+	testDB.QueryRow("SELECT avatar_url FROM users WHERE id = ?", user.ID).Scan(&stored)
+	if stored != first {
+		t.Errorf("Expected avatar_url %q, got %q", first, stored)
+	}
+
+	// Only the thumbnail is stored
+	if keys := storedKeys(t, store); len(keys) != 1 {
+		t.Errorf("Expected a single stored avatar file, found %v", keys)
+	}
+
+	file, header = uploadedFile(t, [2]int{50, 50})
+	second, err := ac.SetAvatar(context.Background(), user.ID, file, header)
+	if err != nil {
+		t.Fatalf("Failed to replace avatar: %v", err)
+	}
+	if blobExists(store, first) || !blobExists(store, second) {
+		t.Error("Expected the previous avatar to be deleted")
+	}
+
+	if err := ac.RemoveAvatar(user.ID); err != nil {
+		t.Fatalf("Failed to remove avatar: %v", err)
+	}
+	if blobExists(store, second) {
+		t.Error("Expected the avatar file to be deleted")
+	}
+	if err := ac.RemoveAvatar(987654); !errors.Is(err, controllers.ErrAvatarUserNotFound) {
+		t.Errorf("Expected user not found, got %v", err)
+	}
+}
+
+// TestMessageAttachments tests that direct message images are private and
+// bound to their uploader
+func TestMessageAttachments(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	sender := registerTestUser(t)
+	receiver := registerTestUser(t)
+
+	file, header := uploadedFile(t, [2]int{1500, 1000})
+	attachment, err := controllers.SaveMessageAttachment(context.Background(), testDB, sender.ID, file, header)
+	if err != nil {
+		t.Fatalf("Failed to save attachment: %v", err)
+	}
+	if attachment.Width != 1024 || !blobExists(store, attachment.URL) {
+		t.Errorf("Expected the medium variant to be kept, got %+v", attachment)
+	}
+	if !controllers.ValidMessageMedia(testDB, sender.ID, attachment.URL) {
+		t.Error("Expected the sender's upload to be valid")
+	}
+	if controllers.ValidMessageMedia(testDB, receiver.ID, attachment.URL) || controllers.ValidMessageMedia(testDB, sender.ID, "uploads/posts/x.png") {
+		t.Error("Expected other users' uploads and post images to be refused")
+	}
+
+	_, err = testDB.Exec(`
+		INSERT INTO messages (sender_id, receiver_id, content, media_url, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, sender.ID, receiver.ID, "Look", attachment.URL, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	messages, err := controllers.NewMessageController(testDB).GetMessages(int64(receiver.ID), int64(sender.ID), 1)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+
+	signed, err := url.Parse(messages[0].MediaURL)
+	if err != nil || signed.Path != "/"+attachment.URL {
+		t.Fatalf("Expected a signed URL of the attachment, got %q", messages[0].MediaURL)
+	}
+	key, _ := storage.KeyForPath(attachment.URL)
+	if !store.VerifyURL(key, signed.Query(), time.Now()) {
+		t.Error("Expected the signed URL to verify")
+	}
+	if store.VerifyURL(key, signed.Query(), time.Now().Add(controllers.MessageMediaURLExpiry+time.Minute)) {
+		t.Error("Expected the signed URL to expire")
+	}
+}
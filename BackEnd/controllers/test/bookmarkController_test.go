@@ -0,0 +1,156 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestBookmarks tests adding, listing, filing and removing bookmarks
+func TestBookmarks(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	reader := registerTestUser(t)
+
+	postController := controllers.NewPostController(testDB)
+	postID, err := postController.InsertPost(models.Post{
+		UserID: author.ID, Author: author.Nickname, Title: "Worth keeping", Content: "Long read", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	draftID, err := postController.InsertPost(models.Post{
+		UserID: author.ID, Title: "Draft", Content: "Unfinished", Category: "General", Timestamp: time.Now(),
+		Status: controllers.PostStatusDraft,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert draft: %v", err)
+	}
+	commentID, err := controllers.NewCommentController(testDB).InsertComment(models.Comment{
+		PostID: postID, UserID: author.ID, Author: author.Nickname, Content: "Good point", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+
+	bc := controllers.NewBookmarkController(testDB)
+
+	if _, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetPost, draftID, 0); !errors.Is(err, controllers.ErrBookmarkTargetNotFound) {
+		t.Errorf("Expected drafts to be unbookmarkable, got %v", err)
+	}
+	if _, err := bc.AddBookmark(reader.ID, "user", author.ID, 0); !errors.Is(err, controllers.ErrInvalidBookmarkTarget) {
+		t.Errorf("Expected an invalid target error, got %v", err)
+	}
+
+	folderID, err := bc.CreateFolder(reader.ID, " Reading ")
+	if err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if _, err := bc.CreateFolder(reader.ID, "Reading"); !errors.Is(err, controllers.ErrBookmarkFolderExists) {
+		t.Errorf("Expected a duplicate folder error, got %v", err)
+	}
+	if _, err := bc.AddBookmark(author.ID, controllers.BookmarkTargetPost, postID, folderID); !errors.Is(err, controllers.ErrBookmarkFolderNotFound) {
+		t.Errorf("Expected other users' folders to be rejected, got %v", err)
+	}
+
+	if created, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetPost, postID, 0); err != nil || !created {
+		t.Fatalf("Failed to bookmark post: %v", err)
+	}
+	if created, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetComment, commentID, folderID); err != nil || !created {
+		t.Fatalf("Failed to bookmark comment: %v", err)
+	}
+	// Bookmarking again moves the bookmark instead of duplicating it
+	if created, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetPost, postID, folderID); err != nil || created {
+		t.Fatalf("Expected the post bookmark to move, got created=%v err=%v", created, err)
+	}
+
+	page, hasMore, err := bc.GetBookmarks(reader.ID, controllers.BookmarkQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to get bookmarks: %v", err)
+	}
+	if len(page) != 1 || !hasMore || page[0].TargetType != controllers.BookmarkTargetComment {
+		t.Fatalf("Expected the comment bookmark first with more to come, got %+v (hasMore=%v)", page, hasMore)
+	}
+	if page[0].PostID != postID || page[0].PostTitle != "Worth keeping" || page[0].Excerpt != "Good point" || page[0].FolderName != "Reading" {
+		t.Errorf("Unexpected comment bookmark summary: %+v", page[0])
+	}
+	page, hasMore, err = bc.GetBookmarks(reader.ID, controllers.BookmarkQuery{Limit: 1, Before: page[0].ID})
+	if err != nil || len(page) != 1 || hasMore || page[0].TargetID != postID {
+		t.Fatalf("Expected the post bookmark on the last page, got %+v (hasMore=%v, %v)", page, hasMore, err)
+	}
+
+	folders, err := bc.GetFolders(reader.ID)
+	if err != nil || len(folders) != 1 || folders[0].Count != 2 {
+		t.Fatalf("Expected one folder with two bookmarks, got %+v (%v)", folders, err)
+	}
+
+	bookmarked, err := bc.BookmarkedPostIDs(reader.ID, []int{postID, draftID})
+	if err != nil || !bookmarked[postID] || bookmarked[draftID] {
+		t.Errorf("Unexpected bookmarked posts: %v (%v)", bookmarked, err)
+	}
+	if bookmarked, _ := bc.BookmarkedPostIDs(author.ID, []int{postID}); bookmarked[postID] {
+		t.Error("Bookmarks must be private to their owner")
+	}
+
+	// Deleting a folder keeps its bookmarks
+	if err := bc.DeleteFolder(reader.ID, folderID); err != nil {
+		t.Fatalf("Failed to delete folder: %v", err)
+	}
+	if page, _, err := bc.GetBookmarks(reader.ID, controllers.BookmarkQuery{FolderID: -1}); err != nil || len(page) != 2 {
+		t.Fatalf("Expected both bookmarks outside any folder, got %+v (%v)", page, err)
+	}
+
+	if err := bc.RemoveBookmark(reader.ID, controllers.BookmarkTargetComment, commentID); err != nil {
+		t.Fatalf("Failed to remove bookmark: %v", err)
+	}
+	if err := bc.RemoveBookmark(reader.ID, controllers.BookmarkTargetComment, commentID); !errors.Is(err, controllers.ErrBookmarkNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+// TestBookmarksRemovedWithTarget tests that deleting a post drops the
+// bookmarks on it and on its comments
+func TestBookmarksRemovedWithTarget(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	reader := registerTestUser(t)
+
+	postController := controllers.NewPostController(testDB)
+	postID, err := postController.InsertPost(models.Post{
+		UserID: author.ID, Title: "Short lived", Content: "content", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	commentID, err := controllers.NewCommentController(testDB).InsertComment(models.Comment{
+		PostID: postID, UserID: author.ID, Author: author.Nickname, Content: "comment", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+
+	bc := controllers.NewBookmarkController(testDB)
+	if _, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetPost, postID, 0); err != nil {
+		t.Fatalf("Failed to bookmark post: %v", err)
+	}
+	if _, err := bc.AddBookmark(reader.ID, controllers.BookmarkTargetComment, commentID, 0); err != nil {
+		t.Fatalf("Failed to bookmark comment: %v", err)
+	}
+
+	if err := postController.DeletePost(postID, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+
+	bookmarks, _, err := bc.GetBookmarks(reader.ID, controllers.BookmarkQuery{})
+	if err != nil {
+		t.Fatalf("Failed to get bookmarks: %v", err)
+	}
+	if len(bookmarks) != 0 {
+		t.Errorf("Expected bookmarks to be removed with their targets, got %+v", bookmarks)
+	}
+}
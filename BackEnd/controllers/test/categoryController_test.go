@@ -0,0 +1,212 @@
+// controllers/test/categoryController_test.go
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// clearTestCategories removes categories created by tests, keeping the seeded ones
+func clearTestCategories(t *testing.T) {
+	if _, err := testDB.Exec("DELETE FROM categories WHERE slug LIKE 'test-%'"); err != nil {
+		t.Fatalf("Failed to clear test categories: %v", err)
+	}
+}
+
+// TestCategoryCRUD tests creating, updating, archiving and deleting categories
+func TestCategoryCRUD(t *testing.T) {
+	clearTables()
+	clearTestCategories(t)
+
+	cc := controllers.NewCategoryController(testDB)
+
+	category := &models.Category{Slug: " Test-Rust ", Name: "Rust", Position: 50}
+	if err := cc.CreateCategory(category); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	if category.ID <= 0 || category.Slug != "test-rust" {
+		t.Errorf("Unexpected category after create: %+v", category)
+	}
+
+	if err := cc.CreateCategory(&models.Category{Slug: "test-rust", Name: "Again"}); err != controllers.ErrCategoryExists {
+		t.Errorf("Expected ErrCategoryExists for duplicate slug, got %v", err)
+	}
+
+	invalid := []*models.Category{
+		{Slug: "", Name: "Empty slug"},
+		{Slug: "test bad", Name: "Space in slug"},
+		{Slug: "test--double", Name: "Double hyphen"},
+		{Slug: "test-noname", Name: "  "},
+	}
+	for _, c := range invalid {
+		if err := cc.CreateCategory(c); err == nil {
+			t.Errorf("Expected validation error for %+v", c)
+		}
+	}
+
+	name := "Rust Lang"
+	archived := true
+	updated, err := cc.UpdateCategory(category.ID, models.CategoryRequest{Name: &name, Archived: &archived})
+	if err != nil {
+		t.Fatalf("Failed to update category: %v", err)
+	}
+	if updated.Name != name || !updated.Archived || updated.Slug != "test-rust" {
+		t.Errorf("Unexpected category after update: %+v", updated)
+	}
+
+	active, err := cc.GetCategories(false)
+	if err != nil {
+		t.Fatalf("Failed to list categories: %v", err)
+	}
+	for _, c := range active {
+		if c.ID == category.ID {
+			t.Error("Archived category should not be listed")
+		}
+	}
+	all, err := cc.GetCategories(true)
+	if err != nil {
+		t.Fatalf("Failed to list categories: %v", err)
+	}
+	found := false
+	for _, c := range all {
+		found = found || c.ID == category.ID
+	}
+	if !found {
+		t.Error("Archived category should be listed when archived ones are included")
+	}
+
+	if _, err := cc.UpdateCategory(99999, models.CategoryRequest{Name: &name}); err != controllers.ErrCategoryNotFound {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+
+	if err := cc.DeleteCategory(category.ID); err != nil {
+		t.Fatalf("Failed to delete category: %v", err)
+	}
+	if err := cc.DeleteCategory(category.ID); err != controllers.ErrCategoryNotFound {
+		t.Errorf("Expected ErrCategoryNotFound on second delete, got %v", err)
+	}
+}
+
+// TestPostCategories tests validation of post categories, feed filtering and post counts
+func TestPostCategories(t *testing.T) {
+	clearTables()
+	clearTestCategories(t)
+
+	user := registerTestUser(t)
+	cc := controllers.NewCategoryController(testDB)
+	pc := controllers.NewPostController(testDB)
+
+	archivedCategory := &models.Category{Slug: "test-old", Name: "Old", Archived: true}
+	if err := cc.CreateCategory(archivedCategory); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	if _, err := cc.ResolvePostCategories("technology, nonsense"); err == nil {
+		t.Error("Expected error for unknown category")
+	}
+	if _, err := cc.ResolvePostCategories("test-old"); err == nil {
+		t.Error("Expected error for archived category")
+	}
+	if _, err := cc.ResolvePostCategories(" , "); err == nil {
+		t.Error("Expected error for empty category list")
+	}
+
+	categories, err := cc.ResolvePostCategories("Technology,gaming,technology")
+	if err != nil {
+		t.Fatalf("Failed to resolve categories: %v", err)
+	}
+	if len(categories) != 2 {
+		t.Fatalf("Expected 2 categories, got %d", len(categories))
+	}
+
+	techGaming, err := pc.InsertPost(models.Post{
+		UserID:     user.ID,
+		Title:      "Tech and games",
+		Content:    "Both",
+		Timestamp:  time.Now(),
+		Categories: categories,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	scienceOnly, err := cc.ResolvePostCategories("science")
+	if err != nil {
+		t.Fatalf("Failed to resolve categories: %v", err)
+	}
+	scienceID, err := pc.InsertPost(models.Post{
+		UserID:     user.ID,
+		Title:      "Science",
+		Content:    "Only science",
+		Timestamp:  time.Now(),
+		Categories: scienceOnly,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	post, err := pc.GetPostByID(strconv.Itoa(techGaming))
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	if post.Category != "technology,gaming" {
+		t.Errorf("Expected legacy category text %q, got %q", "technology,gaming", post.Category)
+	}
+
+	posts, _, err := pc.GetPostsPage(controllers.FeedQuery{Category: "gaming"})
+	if err != nil {
+		t.Fatalf("Failed to fetch filtered feed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].ID != techGaming {
+		t.Errorf("Expected only post %d in gaming feed, got %+v", techGaming, posts)
+	}
+
+	if _, _, err := pc.GetPostsPage(controllers.FeedQuery{Category: "does-not-exist"}); err != controllers.ErrCategoryNotFound {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+
+	counts := map[string]int{}
+	list, err := cc.GetCategories(false)
+	if err != nil {
+		t.Fatalf("Failed to list categories: %v", err)
+	}
+	for _, c := range list {
+		counts[c.Slug] = c.PostCount
+	}
+	if counts["technology"] != 1 || counts["gaming"] != 1 || counts["science"] != 1 || counts["music"] != 0 {
+		t.Errorf("Unexpected post counts: %v", counts)
+	}
+
+	// Moving a post to another category updates the links
+	post, err = pc.GetPostByID(strconv.Itoa(scienceID))
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	post.Categories = categories[1:]
+	if err := pc.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	gaming, err := cc.GetCategoryBySlug("gaming")
+	if err != nil {
+		t.Fatalf("Failed to fetch category: %v", err)
+	}
+	if gaming.PostCount != 2 {
+		t.Errorf("Expected gaming post count 2 after update, got %d", gaming.PostCount)
+	}
+
+	// Deleting a post drops it from the counts
+	if err := pc.DeletePost(techGaming, user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	gaming, err = cc.GetCategoryBySlug("gaming")
+	if err != nil {
+		t.Fatalf("Failed to fetch category: %v", err)
+	}
+	if gaming.PostCount != 1 {
+		t.Errorf("Expected gaming post count 1 after delete, got %d", gaming.PostCount)
+	}
+}
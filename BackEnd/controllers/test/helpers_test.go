@@ -86,6 +86,26 @@ func clearTables() {
 		"users",
 		"messages",
 		"user_status",
+		"ws_tickets",
+		"announcements",
+		"post_categories",
+		"post_revisions",
+		"mentions",
+		"notifications",
+		"comment_votes",
+		"bookmarks",
+		"bookmark_folders",
+		"polls",
+		"poll_options",
+		"poll_ballots",
+		"poll_votes",
+		"post_attachments",
+		"uploads",
+		"resumable_uploads",
+		"post_views",
+		"post_pins",
+		"reports",
+		// moderation_log is append only; tests read it per target
 	}
 
 	for _, table := range tables {
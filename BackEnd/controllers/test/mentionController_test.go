@@ -0,0 +1,126 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestParseMentions tests extraction of @nickname mentions
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"single", "hello @alice", []string{"alice"}},
+		{"start and punctuation", "@alice, @bob_2!", []string{"alice", "bob_2"}},
+		{"duplicates", "@alice @alice", []string{"alice"}},
+		{"email is not a mention", "mail bob@example.com", nil},
+		{"too short", "@ab", nil},
+		{"too long", "@abcdefghijklmnopqrstuvwxyz12345", nil},
+		{"code is ignored", "`@alice` and\n```\n@bob\n```\n@carol", []string{"carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := controllers.ParseMentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, expected %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRecordMentions tests that mentions notify each user once per source
+func TestRecordMentions(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	alice := registerTestUser(t)
+	bob := registerTestUser(t)
+
+	postController := controllers.NewPostController(testDB)
+	mentionController := controllers.NewMentionController(testDB)
+
+	post := models.Post{
+		UserID:    author.ID,
+		Title:     "Mentions",
+		Content:   "Hi @" + alice.Nickname + " and @" + author.Nickname + " and @nobody_here",
+		Category:  "General",
+		Timestamp: time.Now(),
+	}
+	postID, err := postController.InsertPost(post)
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	post.ID = postID
+
+	notifications, err := mentionController.RecordMentions(controllers.PostMentionSource(post), post.Content)
+	if err != nil {
+		t.Fatalf("Failed to record mentions: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != alice.ID {
+		t.Fatalf("Expected one notification for the mentioned user, got %+v", notifications)
+	}
+	n := notifications[0]
+	if n.Type != controllers.NotificationMention || n.ActorID != author.ID || n.ActorName != author.Nickname ||
+		n.PostID != postID || n.SourceType != controllers.MentionSourcePost || n.ID == 0 {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+
+	// Editing the post to add a mention only notifies the new user
+	post.Content += " cc @" + bob.Nickname
+	notifications, err = mentionController.RecordMentions(controllers.PostMentionSource(post), post.Content)
+	if err != nil {
+		t.Fatalf("Failed to record mentions after edit: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != bob.ID {
+		t.Fatalf("Expected one notification for the newly mentioned user, got %+v", notifications)
+	}
+
+	// Dropping and restoring a mention does not notify again
+	if _, err := mentionController.RecordMentions(controllers.PostMentionSource(post), "nobody"); err != nil {
+		t.Fatalf("Failed to record mentions: %v", err)
+	}
+	notifications, err = mentionController.RecordMentions(controllers.PostMentionSource(post), post.Content)
+	if err != nil {
+		t.Fatalf("Failed to record mentions: %v", err)
+	}
+	if len(notifications) != 0 {
+		t.Errorf("Expected no repeated notifications, got %+v", notifications)
+	}
+
+	var stored int
+	testDB.QueryRow("SELECT COUNT(*) FROM notifications WHERE source_type = 'post' AND source_id = ?", postID).Scan(&stored)
+	if stored != 2 {
+		t.Errorf("Expected 2 stored notifications, got %d", stored)
+	}
+
+	// A direct message only reaches its audience
+	notifications, err = mentionController.RecordMentions(controllers.MentionSource{
+		Type:     controllers.MentionSourceMessage,
+		ID:       1,
+		AuthorID: author.ID,
+		Audience: []int{bob.ID},
+	}, "@"+alice.Nickname+" @"+bob.Nickname)
+	if err != nil {
+		t.Fatalf("Failed to record message mentions: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != bob.ID || notifications[0].PostID != 0 {
+		t.Errorf("Expected only the recipient to be notified, got %+v", notifications)
+	}
+
+	// Deleting the post removes its mentions and notifications
+	if err := postController.DeletePost(postID, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	var remaining int
+	testDB.QueryRow("SELECT COUNT(*) FROM mentions WHERE source_type = 'post' AND source_id = ?", postID).Scan(&remaining)
+	testDB.QueryRow("SELECT COUNT(*) FROM notifications WHERE source_type = 'post' AND source_id = ?", postID).Scan(&stored)
+	if remaining != 0 || stored != 0 {
+		t.Errorf("Expected mentions and notifications to be removed, got %d and %d", remaining, stored)
+	}
+}
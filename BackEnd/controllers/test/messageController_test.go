@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 )
@@ -368,6 +369,109 @@ func TestGetUserById(t *testing.T) {
 	}
 }
 
+// TestGetContacts tests ordering, unread counts and pagination of the contact list
+func TestGetContacts(t *testing.T) {
+	clearTables()
+	ensureMessagesTableExists(t)
+
+	me := registerTestUser(t)
+	recent := registerTestUser(t)
+	older := registerTestUser(t)
+	silent := registerTestUser(t)
+
+	// Rename the users so alphabetical ordering is predictable
+	for id, nickname := range map[int]string{recent.ID: "zed", older.ID: "yan", silent.ID: "amy"} {
+		if _, err := testDB.Exec("UPDATE users SET nickname = ? WHERE id = ?", nickname, id); err != nil {
+			t.Fatalf("Failed to rename user: %v", err)
+		}
+	}
+
+	messageController = controllers.NewMessageController(testDB)
+
+	_, err := testDB.Exec(`
+		INSERT INTO messages (sender_id, receiver_id, content, created_at)
+		VALUES (?, ?, 'old news', datetime('now', '-1 hour')),
+		       (?, ?, 'hello there', datetime('now', '-1 minute')),
+		       (?, ?, 'are you around?', datetime('now'))`,
+		older.ID, me.ID, me.ID, recent.ID, recent.ID, me.ID)
+	if err != nil {
+		t.Fatalf("Failed to insert test messages: %v", err)
+	}
+
+	contacts, err := messageController.GetContacts(int64(me.ID), 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to get contacts: %v", err)
+	}
+
+	if len(contacts) != 3 {
+		t.Fatalf("Expected 3 contacts, got %d", len(contacts))
+	}
+
+	expected := []string{"zed", "yan", "amy"}
+	for i, nickname := range expected {
+		if contacts[i].Nickname != nickname {
+			t.Errorf("Expected contact %d to be %s, got %s", i, nickname, contacts[i].Nickname)
+		}
+	}
+
+	if contacts[0].LastMessage != "are you around?" || contacts[0].UnreadCount != 1 {
+		t.Errorf("Unexpected summary for most recent contact: %+v", contacts[0])
+	}
+	if contacts[2].LastMessageTime != nil || contacts[2].UnreadCount != 0 {
+		t.Errorf("Expected no conversation with silent contact, got %+v", contacts[2])
+	}
+
+	// Reading the conversation clears the unread count
+	if err := messageController.MarkConversationRead(int64(me.ID), int64(recent.ID)); err != nil {
+		t.Fatalf("Failed to mark conversation read: %v", err)
+	}
+	unread, err := messageController.GetUnreadCount(int64(me.ID), int64(recent.ID))
+	if err != nil || unread != 0 {
+		t.Errorf("Expected 0 unread messages after reading, got %d (err %v)", unread, err)
+	}
+
+	// Pagination
+	page2, err := messageController.GetContacts(int64(me.ID), 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to get contacts page 2: %v", err)
+	}
+	if len(page2) != 1 || page2[0].Nickname != "amy" {
+		t.Errorf("Expected [amy] on page 2, got %+v", page2)
+	}
+}
+
+// TestGetContactsTies checks that conversations whose last messages share a
+// timestamp keep a stable order across pages
+func TestGetContactsTies(t *testing.T) {
+	clearTables()
+	ensureMessagesTableExists(t)
+
+	me := registerTestUser(t)
+	first := registerTestUser(t)
+	second := registerTestUser(t)
+	messageController = controllers.NewMessageController(testDB)
+
+	at := time.Now().UTC()
+	_, err := testDB.Exec(`
+		INSERT INTO messages (sender_id, receiver_id, content, created_at)
+		VALUES (?, ?, 'first', ?), (?, ?, 'second', ?)`,
+		first.ID, me.ID, at, second.ID, me.ID, at)
+	if err != nil {
+		t.Fatalf("Failed to insert test messages: %v", err)
+	}
+
+	// The later message wins the tie on every page
+	for page, want := range map[int]int{1: second.ID, 2: first.ID} {
+		contacts, err := messageController.GetContacts(int64(me.ID), page, 1)
+		if err != nil {
+			t.Fatalf("Failed to get contacts page %d: %v", page, err)
+		}
+		if len(contacts) != 1 || contacts[0].UserID != int64(want) {
+			t.Errorf("Expected user %d on page %d, got %+v", want, page, contacts)
+		}
+	}
+}
+
 // Helper function to ensure the messages table exists
 func ensureMessagesTableExists(t *testing.T) {
 	_, err := testDB.Exec(`
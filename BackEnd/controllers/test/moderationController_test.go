@@ -0,0 +1,209 @@
+package test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestPostPins tests pinning posts to the main and category feeds and that
+// pins lead the first page of the feed in order
+func TestPostPins(t *testing.T) {
+	clearTables()
+	author := registerTestUser(t)
+	moderator := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	mc := controllers.NewModerationController(testDB)
+
+	gaming, err := controllers.NewCategoryController(testDB).ResolvePostCategories("gaming")
+	if err != nil {
+		t.Fatalf("Failed to resolve categories: %v", err)
+	}
+	now := time.Now().UTC()
+	insert := func(title string, age time.Duration, categories []models.Category) int {
+		t.Helper()
+		id, err := pc.InsertPost(models.Post{
+			UserID: author.ID, Title: title, Content: "Pinned?", Category: "General",
+			Timestamp: now.Add(-age), Categories: categories,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		return id
+	}
+	oldRules := insert("Rules", 72*time.Hour, nil)
+	oldFaq := insert("FAQ", 48*time.Hour, gaming)
+	middle := insert("Middle", time.Hour, gaming)
+	newest := insert("Newest", 0, nil)
+
+	if err := mc.PinPost(moderator.ID, oldFaq, models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to pin post: %v", err)
+	}
+	if err := mc.PinPost(moderator.ID, oldRules, models.ModerationRequest{Position: 1, Reason: "Read first"}); err != nil {
+		t.Fatalf("Failed to pin post: %v", err)
+	}
+	if err := mc.PinPost(moderator.ID, oldFaq, models.ModerationRequest{Category: "gaming"}); err != nil {
+		t.Fatalf("Failed to pin post to category: %v", err)
+	}
+
+	// Pins come first on the first page only and are not repeated later
+	posts, next, err := pc.GetPostsPage(controllers.FeedQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to get feed: %v", err)
+	}
+	if len(posts) != 3 || posts[0].ID != oldRules || posts[1].ID != oldFaq || posts[2].ID != newest {
+		t.Fatalf("Expected Rules, FAQ then Newest on the first page, got %+v", posts)
+	}
+	if !posts[0].Pinned || !posts[1].Pinned || posts[2].Pinned {
+		t.Error("Expected only the pinned posts to be marked pinned")
+	}
+	rest := feedIDs(t, pc, controllers.FeedQuery{Cursor: next})
+	if !sameIDs(rest, []int{middle}) {
+		t.Errorf("Expected only Middle after the first page, got %v", rest)
+	}
+
+	// Pinning again moves a pin
+	if err := mc.PinPost(moderator.ID, oldRules, models.ModerationRequest{Position: 5}); err != nil {
+		t.Fatalf("Failed to move pin: %v", err)
+	}
+	posts, _, err = pc.GetPostsPage(controllers.FeedQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to get feed: %v", err)
+	}
+	if posts[0].ID != oldFaq || posts[1].ID != oldRules {
+		t.Errorf("Expected FAQ before Rules after moving the pin, got %d, %d", posts[0].ID, posts[1].ID)
+	}
+
+	// The category feed has its own pins
+	posts, _, err = pc.GetPostsPage(controllers.FeedQuery{Limit: 10, Category: "gaming"})
+	if err != nil {
+		t.Fatalf("Failed to get category feed: %v", err)
+	}
+	if len(posts) != 2 || posts[0].ID != oldFaq || !posts[0].Pinned || posts[1].ID != middle {
+		t.Errorf("Expected pinned FAQ then Middle in the gaming feed, got %+v", posts)
+	}
+
+	if err := mc.PinPost(moderator.ID, newest, models.ModerationRequest{Category: "gaming"}); !errors.Is(err, controllers.ErrPostNotInCategory) {
+		t.Errorf("Expected ErrPostNotInCategory, got %v", err)
+	}
+	if err := mc.PinPost(moderator.ID, newest, models.ModerationRequest{Category: "missing"}); !errors.Is(err, controllers.ErrCategoryNotFound) {
+		t.Errorf("Expected ErrCategoryNotFound, got %v", err)
+	}
+	if err := mc.PinPost(moderator.ID, newest+1000, models.ModerationRequest{}); !errors.Is(err, controllers.ErrPostNotFound) {
+		t.Errorf("Expected ErrPostNotFound, got %v", err)
+	}
+
+	if err := mc.UnpinPost(moderator.ID, oldFaq, models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to unpin post: %v", err)
+	}
+	if err := mc.UnpinPost(moderator.ID, oldFaq, models.ModerationRequest{}); !errors.Is(err, controllers.ErrPostNotPinned) {
+		t.Errorf("Expected ErrPostNotPinned, got %v", err)
+	}
+	ids := feedIDs(t, pc, controllers.FeedQuery{})
+	if !sameIDs(ids, []int{oldRules, newest, middle, oldFaq}) {
+		t.Errorf("Expected the unpinned post back in place, got %v", ids)
+	}
+
+	// Deleting a post removes its pins
+	if err := pc.DeletePost(oldRules, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	var pins int
+	testDB.QueryRow("SELECT COUNT(*) FROM post_pins WHERE post_id = ?", oldRules).Scan(&pins)
+	if pins != 0 {
+		t.Errorf("Expected no pins for a deleted post, got %d", pins)
+	}
+}
+
+// TestPostLockAndAnnouncement tests that locked posts reject comments and
+// that every change is logged
+func TestPostLockAndAnnouncement(t *testing.T) {
+	clearTables()
+	author := registerTestUser(t)
+	moderator := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	cc := controllers.NewCommentController(testDB)
+	mc := controllers.NewModerationController(testDB)
+	postID := insertViewedPost(t, pc, author)
+
+	comment := models.Comment{
+		PostID: postID, UserID: author.ID, Author: author.Nickname, Content: "Still open?", Timestamp: time.Now(),
+	}
+	if err := mc.SetPostLocked(moderator.ID, postID, true, models.ModerationRequest{Reason: "Heated"}); err != nil {
+		t.Fatalf("Failed to lock post: %v", err)
+	}
+	// Locking again changes nothing and is not logged
+	if err := mc.SetPostLocked(moderator.ID, postID, true, models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to lock post again: %v", err)
+	}
+	if _, err := cc.InsertComment(comment); !errors.Is(err, controllers.ErrPostLocked) {
+		t.Errorf("Expected ErrPostLocked, got %v", err)
+	}
+	if err := mc.SetPostLocked(moderator.ID, postID, false, models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to unlock post: %v", err)
+	}
+	if _, err := cc.InsertComment(comment); err != nil {
+		t.Errorf("Expected comments after unlocking, got %v", err)
+	}
+
+	if err := mc.SetPostAnnouncement(moderator.ID, postID, true, models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to mark announcement: %v", err)
+	}
+	post := mustGetPost(t, pc, postID)
+	if !post.Announcement || post.Locked {
+		t.Errorf("Expected an unlocked announcement, got locked=%v announcement=%v", post.Locked, post.Announcement)
+	}
+
+	long := make([]rune, controllers.MaxModerationReasonLength+1)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if err := mc.SetPostLocked(moderator.ID, postID, true, models.ModerationRequest{Reason: string(long)}); !errors.Is(err, controllers.ErrModerationReasonLong) {
+		t.Errorf("Expected ErrModerationReasonLong, got %v", err)
+	}
+
+	entries, hasMore, err := mc.GetModerationLog(controllers.ModerationLogQuery{
+		TargetType: controllers.ModerationTargetPost, TargetID: postID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to get moderation log: %v", err)
+	}
+	if hasMore || len(entries) != 3 {
+		t.Fatalf("Expected 3 log entries, got %d", len(entries))
+	}
+	actions := []string{controllers.ModerationAnnounce, controllers.ModerationUnlock, controllers.ModerationLock}
+	for i, entry := range entries {
+		if entry.Action != actions[i] || entry.ModeratorID != moderator.ID || entry.Moderator != moderator.Nickname {
+			t.Errorf("Unexpected log entry %d: %+v", i, entry)
+		}
+	}
+	if entries[2].Reason != "Heated" {
+		t.Errorf("Expected the lock reason to be logged, got %q", entries[2].Reason)
+	}
+
+	page, hasMore, err := mc.GetModerationLog(controllers.ModerationLogQuery{
+		Limit: 2, TargetType: controllers.ModerationTargetPost, TargetID: postID,
+	})
+	if err != nil || !hasMore || len(page) != 2 {
+		t.Fatalf("Expected a first page of 2 with more, got %d (%v)", len(page), err)
+	}
+	page, hasMore, err = mc.GetModerationLog(controllers.ModerationLogQuery{
+		Limit: 2, Before: page[1].ID, TargetType: controllers.ModerationTargetPost, TargetID: postID,
+	})
+	if err != nil || hasMore || len(page) != 1 || page[0].Action != controllers.ModerationLock {
+		t.Errorf("Expected the lock entry on the last page, got %+v (%v)", page, err)
+	}
+}
+
+func mustGetPost(t *testing.T, pc *controllers.PostController, postID int) models.Post {
+	t.Helper()
+	post, err := pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	return post
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestNotifyReply tests reply notifications for post and parent comment authors
+func TestNotifyReply(t *testing.T) {
+	clearTables()
+
+	postAuthor := registerTestUser(t)
+	commenter := registerTestUser(t)
+	replier := registerTestUser(t)
+
+	postID, err := controllers.NewPostController(testDB).InsertPost(models.Post{
+		UserID: postAuthor.ID, Title: "Replies", Content: "content", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	commentController := controllers.NewCommentController(testDB)
+	notificationController := controllers.NewNotificationController(testDB)
+
+	comment := models.Comment{PostID: postID, UserID: commenter.ID, Author: commenter.Nickname, Content: "First", Timestamp: time.Now()}
+	comment.ID, err = commentController.InsertComment(comment)
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	notifications, err := notificationController.NotifyReply(comment)
+	if err != nil {
+		t.Fatalf("Failed to notify reply: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != postAuthor.ID || notifications[0].Type != controllers.NotificationReply {
+		t.Fatalf("Expected the post author to be notified, got %+v", notifications)
+	}
+
+	// A reply notifies both the parent comment's author and the post author,
+	// except where a mention already did
+	reply := models.Comment{
+		PostID: postID, UserID: replier.ID, Author: replier.Nickname, Timestamp: time.Now(),
+		Content:  "@" + postAuthor.Nickname + " agreed",
+		ParentID: sql.NullInt64{Int64: int64(comment.ID), Valid: true},
+	}
+	reply.ID, err = commentController.InsertComment(reply)
+	if err != nil {
+		t.Fatalf("Failed to insert reply: %v", err)
+	}
+	_, err = controllers.NewMentionController(testDB).RecordMentions(controllers.MentionSource{
+		Type: controllers.MentionSourceComment, ID: reply.ID, AuthorID: replier.ID, PostID: postID,
+	}, reply.Content)
+	if err != nil {
+		t.Fatalf("Failed to record mentions: %v", err)
+	}
+	notifications, err = notificationController.NotifyReply(reply)
+	if err != nil {
+		t.Fatalf("Failed to notify reply: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != commenter.ID {
+		t.Fatalf("Expected only the parent comment author to get a reply notification, got %+v", notifications)
+	}
+
+	// Replying to your own post notifies nobody
+	own := models.Comment{PostID: postID, UserID: postAuthor.ID, Author: postAuthor.Nickname, Content: "Thanks", Timestamp: time.Now()}
+	own.ID, err = commentController.InsertComment(own)
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	if notifications, err = notificationController.NotifyReply(own); err != nil || len(notifications) != 0 {
+		t.Errorf("Expected no notifications for an own post, got %+v (%v)", notifications, err)
+	}
+}
+
+// TestNotifyVote tests that vote notifications are sent once per voter
+func TestNotifyVote(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	voter := registerTestUser(t)
+
+	postID, err := controllers.NewPostController(testDB).InsertPost(models.Post{
+		UserID: author.ID, Title: "Votes", Content: "content", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	likesController := controllers.NewLikesController(testDB)
+	notificationController := controllers.NewNotificationController(testDB)
+
+	if err := likesController.HandleVote(postID, voter.ID, "like"); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	notifications, err := notificationController.NotifyVote(controllers.MentionSourcePost, postID, voter.ID)
+	if err != nil {
+		t.Fatalf("Failed to notify vote: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserID != author.ID || notifications[0].Excerpt != "Votes" {
+		t.Fatalf("Expected the author to be notified, got %+v", notifications)
+	}
+
+	// Withdrawing and repeating the vote does not notify again
+	for i := 0; i < 2; i++ {
+		if err := likesController.HandleVote(postID, voter.ID, "like"); err != nil {
+			t.Fatalf("Failed to vote: %v", err)
+		}
+		notifications, err = notificationController.NotifyVote(controllers.MentionSourcePost, postID, voter.ID)
+		if err != nil || len(notifications) != 0 {
+			t.Errorf("Expected no repeated vote notification, got %+v (%v)", notifications, err)
+		}
+	}
+
+	// Voting on your own post notifies nobody
+	if err := likesController.HandleVote(postID, author.ID, "like"); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if notifications, err = notificationController.NotifyVote(controllers.MentionSourcePost, postID, author.ID); err != nil || len(notifications) != 0 {
+		t.Errorf("Expected no notification for an own vote, got %+v (%v)", notifications, err)
+	}
+}
+
+// TestNotificationCenter tests listing, marking read and preferences
+func TestNotificationCenter(t *testing.T) {
+	clearTables()
+
+	sender := registerTestUser(t)
+	receiver := registerTestUser(t)
+	nc := controllers.NewNotificationController(testDB)
+
+	for i := 1; i <= 3; i++ {
+		notifications, err := nc.NotifyMessage(i, sender.ID, receiver.ID, "hello there")
+		if err != nil || len(notifications) != 1 {
+			t.Fatalf("Failed to notify message: %+v (%v)", notifications, err)
+		}
+	}
+
+	page, hasMore, err := nc.GetNotifications(receiver.ID, controllers.NotificationQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if len(page) != 2 || !hasMore || page[0].ID < page[1].ID || page[0].ActorName != sender.Nickname {
+		t.Fatalf("Unexpected first page: %+v (hasMore %v)", page, hasMore)
+	}
+	rest, hasMore, err := nc.GetNotifications(receiver.ID, controllers.NotificationQuery{Before: page[1].ID})
+	if err != nil || len(rest) != 1 || hasMore {
+		t.Fatalf("Unexpected second page: %+v (hasMore %v, %v)", rest, hasMore, err)
+	}
+
+	// Users can only mark their own notifications
+	if err := nc.MarkRead(sender.ID, page[0].ID); !errors.Is(err, controllers.ErrNotificationNotFound) {
+		t.Errorf("Expected ErrNotificationNotFound, got %v", err)
+	}
+	if err := nc.MarkRead(receiver.ID, page[0].ID); err != nil {
+		t.Fatalf("Failed to mark read: %v", err)
+	}
+	if unread, _ := nc.UnreadCount(receiver.ID); unread != 2 {
+		t.Errorf("Expected 2 unread notifications, got %d", unread)
+	}
+	unreadPage, _, err := nc.GetNotifications(receiver.ID, controllers.NotificationQuery{UnreadOnly: true})
+	if err != nil || len(unreadPage) != 2 {
+		t.Errorf("Expected 2 unread notifications listed, got %d (%v)", len(unreadPage), err)
+	}
+	if marked, err := nc.MarkAllRead(receiver.ID); err != nil || marked != 2 {
+		t.Errorf("Expected 2 notifications marked read, got %d (%v)", marked, err)
+	}
+	if unread, _ := nc.UnreadCount(receiver.ID); unread != 0 {
+		t.Errorf("Expected no unread notifications, got %d", unread)
+	}
+
+	// Switched off types are not stored
+	prefs, err := nc.UpdatePreferences(receiver.ID, map[string]bool{controllers.NotificationMessage: false})
+	if err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	if prefs[controllers.NotificationMessage] || !prefs[controllers.NotificationMention] {
+		t.Errorf("Unexpected preferences: %v", prefs)
+	}
+	if notifications, err := nc.NotifyMessage(4, sender.ID, receiver.ID, "muted"); err != nil || len(notifications) != 0 {
+		t.Errorf("Expected muted message notification to be skipped, got %+v (%v)", notifications, err)
+	}
+	if _, err := nc.UpdatePreferences(receiver.ID, map[string]bool{"bogus": true}); !errors.Is(err, controllers.ErrInvalidNotificationPreference) {
+		t.Errorf("Expected ErrInvalidNotificationPreference, got %v", err)
+	}
+	prefs, err = nc.GetPreferences(receiver.ID)
+	if err != nil || prefs[controllers.NotificationMessage] || !prefs[controllers.NotificationVote] {
+		t.Errorf("Preferences were not persisted: %v (%v)", prefs, err)
+	}
+}
@@ -0,0 +1,218 @@
+package test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// insertPollPost creates a published post carrying a poll built from req
+func insertPollPost(t *testing.T, authorID int, req models.PollRequest) (int, *models.Poll) {
+	t.Helper()
+	poll, err := controllers.NewPoll(req, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to build poll: %v", err)
+	}
+	postID, err := controllers.NewPostController(testDB).InsertPost(models.Post{
+		UserID: authorID, Title: "Decision", Content: "Please vote", Category: "General", Timestamp: time.Now(), Poll: poll,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	poll, err = controllers.NewPollController(testDB).GetPostPoll(postID, 0)
+	if err != nil {
+		t.Fatalf("Failed to get poll: %v", err)
+	}
+	return postID, poll
+}
+
+// TestNewPoll tests poll validation
+func TestNewPoll(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name string
+		req  models.PollRequest
+	}{
+		{"No question", models.PollRequest{Question: "  ", Options: []string{"a", "b"}}},
+		{"One option", models.PollRequest{Question: "Q", Options: []string{"a"}}},
+		{"Empty option", models.PollRequest{Question: "Q", Options: []string{"a", " "}}},
+		{"Duplicate options", models.PollRequest{Question: "Q", Options: []string{"Yes", "yes"}}},
+		{"Closed already", models.PollRequest{Question: "Q", Options: []string{"a", "b"}, ClosesAt: &past}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := controllers.NewPoll(tt.req, time.Now()); !errors.Is(err, controllers.ErrInvalidPoll) {
+				t.Errorf("Expected an invalid poll error, got %v", err)
+			}
+		})
+	}
+
+	poll, err := controllers.NewPoll(models.PollRequest{Question: " Lunch? ", Options: []string{" Pizza", "Sushi "}}, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to build poll: %v", err)
+	}
+	if poll.Question != "Lunch?" || len(poll.Options) != 2 || poll.Options[0].Label != "Pizza" || poll.Options[1].Label != "Sushi" {
+		t.Errorf("Expected trimmed question and options, got %+v", poll)
+	}
+}
+
+// TestPollVoting tests single and multiple choice voting, public voters and
+// the one ballot per user rule
+func TestPollVoting(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	alice := registerTestUser(t)
+	bob := registerTestUser(t)
+	pc := controllers.NewPollController(testDB)
+
+	_, single := insertPollPost(t, author.ID, models.PollRequest{Question: "Ship it?", Options: []string{"Yes", "No"}})
+	yes, no := single.Options[0].ID, single.Options[1].ID
+
+	if err := pc.Vote(single.ID, alice.ID, []int{yes, no}); !errors.Is(err, controllers.ErrInvalidPollVote) {
+		t.Errorf("Expected a single choice poll to reject two options, got %v", err)
+	}
+	if err := pc.Vote(single.ID, alice.ID, nil); !errors.Is(err, controllers.ErrInvalidPollVote) {
+		t.Errorf("Expected an empty vote to be rejected, got %v", err)
+	}
+	if err := pc.Vote(single.ID, alice.ID, []int{yes}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if err := pc.Vote(single.ID, alice.ID, []int{no}); !errors.Is(err, controllers.ErrAlreadyVoted) {
+		t.Errorf("Expected a second ballot to be rejected, got %v", err)
+	}
+
+	_, multi := insertPollPost(t, author.ID, models.PollRequest{
+		Question: "Which days?", Options: []string{"Mon", "Tue", "Wed"}, MultipleChoice: true,
+	})
+	if err := pc.Vote(multi.ID, bob.ID, []int{multi.Options[0].ID, multi.Options[2].ID}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if err := pc.Vote(multi.ID, alice.ID, []int{single.Options[0].ID}); !errors.Is(err, controllers.ErrInvalidPollVote) {
+		t.Errorf("Expected options of another poll to be rejected, got %v", err)
+	}
+
+	results, err := pc.GetPoll(multi.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("Failed to get poll: %v", err)
+	}
+	if results.TotalVoters != 1 || results.Options[0].Votes != 1 || results.Options[1].Votes != 0 || results.Options[2].Votes != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if len(results.UserVotes) != 2 {
+		t.Errorf("Expected the viewer's two choices, got %v", results.UserVotes)
+	}
+	if len(results.Options[0].Voters) != 1 || results.Options[0].Voters[0] != bob.Nickname {
+		t.Errorf("Expected public voters to be listed, got %v", results.Options[0].Voters)
+	}
+}
+
+// TestAnonymousAndClosedPolls tests that anonymous polls hide voters and
+// that closed polls reject votes
+func TestAnonymousAndClosedPolls(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	voter := registerTestUser(t)
+	pc := controllers.NewPollController(testDB)
+
+	_, poll := insertPollPost(t, author.ID, models.PollRequest{
+		Question: "Secret?", Options: []string{"a", "b"}, Anonymous: true,
+	})
+	if err := pc.Vote(poll.ID, voter.ID, []int{poll.Options[1].ID}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	results, err := pc.GetPoll(poll.ID, author.ID)
+	if err != nil {
+		t.Fatalf("Failed to get poll: %v", err)
+	}
+	if results.Options[1].Votes != 1 || len(results.Options[1].Voters) != 0 || len(results.UserVotes) != 0 {
+		t.Errorf("Expected counts without voters, got %+v", results)
+	}
+	if own, _ := pc.GetPoll(poll.ID, voter.ID); len(own.UserVotes) != 1 {
+		t.Errorf("Expected voters to see their own choice, got %+v", own)
+	}
+
+	closesAt := time.Now().Add(time.Hour)
+	_, closing := insertPollPost(t, author.ID, models.PollRequest{
+		Question: "Soon closed", Options: []string{"a", "b"}, ClosesAt: &closesAt,
+	})
+	if _, err := testDB.Exec("UPDATE polls SET closes_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), closing.ID); err != nil {
+		t.Fatalf("Failed to close poll: %v", err)
+	}
+	if err := pc.Vote(closing.ID, voter.ID, []int{closing.Options[0].ID}); !errors.Is(err, controllers.ErrPollClosed) {
+		t.Errorf("Expected a closed poll to reject votes, got %v", err)
+	}
+	if results, _ := pc.GetPoll(closing.ID, 0); !results.Closed {
+		t.Error("Expected the poll to report it is closed")
+	}
+}
+
+// TestPollConcurrentVotes tests that racing ballots from one user only count
+// once
+func TestPollConcurrentVotes(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	voter := registerTestUser(t)
+	pc := controllers.NewPollController(testDB)
+
+	_, poll := insertPollPost(t, author.ID, models.PollRequest{Question: "Race", Options: []string{"a", "b"}})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- pc.Vote(poll.ID, voter.ID, []int{poll.Options[i%2].ID})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one ballot to succeed, got %d", succeeded)
+	}
+	results, err := pc.GetPoll(poll.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to get poll: %v", err)
+	}
+	if results.TotalVoters != 1 || results.Options[0].Votes+results.Options[1].Votes != 1 {
+		t.Errorf("Expected a single counted vote, got %+v", results)
+	}
+}
+
+// TestPollRemovedWithPost tests that deleting a post deletes its poll
+func TestPollRemovedWithPost(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	postID, poll := insertPollPost(t, author.ID, models.PollRequest{Question: "Gone", Options: []string{"a", "b"}})
+	pc := controllers.NewPollController(testDB)
+	if err := pc.Vote(poll.ID, author.ID, []int{poll.Options[0].ID}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	if err := controllers.NewPostController(testDB).DeletePost(postID, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if _, err := pc.GetPoll(poll.ID, 0); !errors.Is(err, controllers.ErrPollNotFound) {
+		t.Errorf("Expected the poll to be deleted, got %v", err)
+	}
+	var votes int
+	testDB.QueryRow("SELECT COUNT(*) FROM poll_votes WHERE poll_id = ?", poll.ID).Scan(&votes)
+	if votes != 0 {
+		t.Errorf("Expected poll votes to be deleted, found %d", votes)
+	}
+}
@@ -0,0 +1,325 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// newUploadRequest builds a parsed multipart post request carrying PNG files
+// of the given sizes in the post-files field, with one alt-text per file
+func newUploadRequest(t *testing.T, sizes [][2]int, altTexts ...string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, size := range sizes {
+		part, err := writer.CreateFormFile("post-files", "image.png")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if err := png.Encode(part, image.NewRGBA(image.Rect(0, 0, size[0], size[1]))); err != nil {
+			t.Fatalf("Failed to encode image: %v", err)
+		}
+	}
+	for _, alt := range altTexts {
+		writer.WriteField("alt-text", alt)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/create", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(10 << 20); err != nil {
+		t.Fatalf("Failed to parse form: %v", err)
+	}
+	return req
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// removeUploads deletes the files in the default uploads directory along
+// with the uploads rows tracking them
+func removeUploads() {
+	os.RemoveAll("uploads")
+	testDB.Exec("DELETE FROM uploads")
+}
+
+// withoutUploadGrace lets unreferenced uploads be deleted right away
+func withoutUploadGrace(t *testing.T) {
+	t.Helper()
+	previous := controllers.UploadGracePeriod
+	controllers.UploadGracePeriod = 0
+	t.Cleanup(func() { controllers.UploadGracePeriod = previous })
+}
+
+// TestPostAttachments tests storing, listing, editing and deleting the
+// attachments of a post
+func TestPostAttachments(t *testing.T) {
+	clearTables()
+	t.Cleanup(removeUploads)
+	withoutUploadGrace(t)
+
+	user := registerTestUser(t)
+
+	attachments, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{40, 30}, {10, 20}}, "A chart", ""), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	if len(attachments) != 2 || attachments[0].URL == attachments[1].URL {
+		t.Fatalf("Expected two distinct files, got %+v", attachments)
+	}
+	if attachments[0].Width != 40 || attachments[0].Height != 30 || attachments[0].MimeType != "image/png" || attachments[0].AltText != "A chart" {
+		t.Errorf("Unexpected attachment details: %+v", attachments[0])
+	}
+
+	pc := controllers.NewPostController(testDB)
+	postID, err := pc.InsertPost(models.Post{
+		UserID: user.ID, Title: "Gallery", Content: "Pictures", Category: "General", Timestamp: time.Now(), Attachments: attachments,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	post, err := pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if len(post.Attachments) != 2 || post.Attachments[0].AltText != "A chart" || post.Attachments[1].Width != 10 {
+		t.Fatalf("Expected both attachments in order, got %+v", post.Attachments)
+	}
+	if !post.ImageUrl.Valid || post.ImageUrl.String != attachments[0].URL {
+		t.Errorf("Expected image_url to follow the first attachment, got %+v", post.ImageUrl)
+	}
+
+	// Drop the first attachment and add a new one at the end
+	added, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{5, 5}}), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachment: %v", err)
+	}
+	dropped := post.Attachments[0]
+	post.Attachments = append(post.Attachments[1:], added...)
+	if err := pc.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	if fileExists(dropped.URL) {
+		t.Error("Expected the dropped attachment's file to be removed")
+	}
+	post, err = pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if len(post.Attachments) != 2 || post.Attachments[0].URL != attachments[1].URL || post.Attachments[1].URL != added[0].URL {
+		t.Fatalf("Unexpected attachments after update: %+v", post.Attachments)
+	}
+	if post.ImageUrl.String != attachments[1].URL {
+		t.Errorf("Expected image_url to move to the new first attachment, got %q", post.ImageUrl.String)
+	}
+
+	if err := pc.DeletePost(postID, user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	for _, a := range post.Attachments {
+		if fileExists(a.URL) {
+			t.Errorf("Expected %s to be removed with the post", a.URL)
+		}
+	}
+	var rows int
+	testDB.QueryRow("SELECT COUNT(*) FROM post_attachments WHERE post_id = ?", postID).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected attachment rows to be deleted, found %d", rows)
+	}
+}
+
+// TestSavePostAttachmentsLimits tests that rejected uploads leave no files
+// behind
+func TestSavePostAttachmentsLimits(t *testing.T) {
+	t.Cleanup(removeUploads)
+
+	sizes := make([][2]int, controllers.MaxPostAttachments+1)
+	for i := range sizes {
+		sizes[i] = [2]int{1, 1}
+	}
+	if _, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, sizes), 1); !errors.Is(err, controllers.ErrTooManyAttachments) {
+		t.Errorf("Expected too many attachments, got %v", err)
+	}
+
+	// The second file is not an image, so the first one must not be kept
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("post-files", "ok.png")
+	png.Encode(part, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	part, _ = writer.CreateFormFile("post-files", "notes.txt")
+	part.Write([]byte("just text"))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/create", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.ParseMultipartForm(10 << 20)
+
+	if _, err := controllers.SavePostAttachments(testDB, req, 1); !errors.Is(err, controllers.ErrInvalidUpload) {
+		t.Errorf("Expected an invalid upload error, got %v", err)
+	}
+	if entries, _ := os.ReadDir("uploads/posts"); len(entries) != 0 {
+		t.Errorf("Expected no files to be kept, found %d", len(entries))
+	}
+}
+
+// TestAttachmentVariants tests that uploads are re-encoded with scaled down
+// variants, and that the variants go away with their attachment
+func TestAttachmentVariants(t *testing.T) {
+	clearTables()
+	t.Cleanup(removeUploads)
+	withoutUploadGrace(t)
+
+	user := registerTestUser(t)
+	attachments, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{1600, 800}, {100, 50}}), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	large, small := attachments[0], attachments[1]
+	if large.Width != 1600 || large.Height != 800 {
+		t.Errorf("Expected the original size to be kept, got %dx%d", large.Width, large.Height)
+	}
+	for _, variant := range []string{large.MediumURL, large.ThumbnailURL} {
+		if variant == large.URL || !fileExists(variant) {
+			t.Errorf("Expected a separate variant file, got %q", variant)
+		}
+	}
+	if small.MediumURL != small.URL || small.ThumbnailURL != small.URL {
+		t.Errorf("Expected a small image to stand in for its variants, got %+v", small)
+	}
+
+	pc := controllers.NewPostController(testDB)
+	postID, err := pc.InsertPost(models.Post{
+		UserID: user.ID, Title: "Panorama", Content: "Wide", Category: "General", Timestamp: time.Now(), Attachments: attachments,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	post, err := pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if post.Attachments[0].MediumURL != large.MediumURL || post.Attachments[0].ThumbnailURL != large.ThumbnailURL ||
+		post.Attachments[1].ThumbnailURL != small.URL {
+		t.Errorf("Unexpected variant URLs: %+v", post.Attachments)
+	}
+
+	if err := pc.DeletePost(postID, user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	for _, file := range []string{large.URL, large.MediumURL, large.ThumbnailURL, small.URL} {
+		if fileExists(file) {
+			t.Errorf("Expected %s to be removed with the post", file)
+		}
+	}
+}
+
+// TestUploadStripsMetadata tests that EXIF data does not reach the stored file
+func TestUploadStripsMetadata(t *testing.T) {
+	t.Cleanup(removeUploads)
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	// An APP1 segment with a GPS note right after the SOI marker
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00GPS 51.5007N 0.1246W")
+	data := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data = append(data, jpg.Bytes()[2:]...)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("post-files", "photo.jpg")
+	part.Write(data)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/create", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.ParseMultipartForm(10 << 20)
+
+	attachments, err := controllers.SavePostAttachments(testDB, req, 1)
+	if err != nil {
+		t.Fatalf("Failed to save attachment: %v", err)
+	}
+	stored, err := os.ReadFile(attachments[0].URL)
+	if err != nil {
+		t.Fatalf("Failed to read stored file: %v", err)
+	}
+	if bytes.Contains(stored, []byte("Exif")) || bytes.Contains(stored, []byte("GPS")) {
+		t.Error("Expected the metadata to be stripped")
+	}
+	if attachments[0].Size != int64(len(stored)) || attachments[0].MimeType != "image/jpeg" {
+		t.Errorf("Unexpected attachment details: %+v", attachments[0])
+	}
+}
+
+// newFileRequest builds a parsed multipart post request carrying one file
+func newFileRequest(t *testing.T, name string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("post-files", name)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/create", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(10 << 20); err != nil {
+		t.Fatalf("Failed to parse form: %v", err)
+	}
+	return req
+}
+
+// TestSVGUploads tests that SVG uploads are sanitized, and refused when the
+// configuration says so
+func TestSVGUploads(t *testing.T) {
+	t.Cleanup(removeUploads)
+	svg := []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><script>alert(2)</script><rect width="5" height="5"/></svg>`)
+
+	for _, mode := range []string{"", controllers.SVGModeAttachment} {
+		t.Setenv("UPLOAD_SVG_MODE", mode)
+		attachments, err := controllers.SavePostAttachments(testDB, newFileRequest(t, "logo.svg", svg), 1)
+		if err != nil {
+			t.Fatalf("Mode %q: failed to save SVG: %v", mode, err)
+		}
+		a := attachments[0]
+		if a.MimeType != "image/svg+xml" || !strings.HasSuffix(a.URL, ".svg") || a.ThumbnailURL != a.URL {
+			t.Errorf("Mode %q: unexpected attachment %+v", mode, a)
+		}
+		stored, err := os.ReadFile(a.URL)
+		if err != nil {
+			t.Fatalf("Failed to read stored file: %v", err)
+		}
+		if bytes.Contains(stored, []byte("alert")) || !bytes.Contains(stored, []byte("<rect")) {
+			t.Errorf("Mode %q: expected a sanitized drawing, got %s", mode, stored)
+		}
+	}
+
+	// HTML is text too, but not an SVG document
+	if _, err := controllers.SavePostAttachments(testDB, newFileRequest(t, "page.svg", []byte("<html><script>alert(1)</script></html>")), 1); !errors.Is(err, controllers.ErrInvalidUpload) {
+		t.Errorf("Expected HTML to be refused, got %v", err)
+	}
+
+	for _, mode := range []string{controllers.SVGModeReject, "bogus"} {
+		t.Setenv("UPLOAD_SVG_MODE", mode)
+		if _, err := controllers.SavePostAttachments(testDB, newFileRequest(t, "logo.svg", svg), 1); !errors.Is(err, controllers.ErrInvalidUpload) {
+			t.Errorf("Mode %q: expected SVG to be refused, got %v", mode, err)
+		}
+	}
+}
@@ -130,6 +130,108 @@ func TestGetAllPosts(t *testing.T) {
 	}
 }
 
+// TestGetPostsPage tests cursor pagination of the feed and the maintained comment count
+func TestGetPostsPage(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+	postController = controllers.NewPostController(testDB)
+	commentController := controllers.NewCommentController(testDB)
+
+	// Two posts share a timestamp so the id tie-breaker is exercised
+	base := time.Now().UTC().Truncate(time.Second)
+	timestamps := []time.Time{base, base, base.Add(-time.Minute), base.Add(-2 * time.Minute), base.Add(-3 * time.Minute)}
+	postIDs := make([]int, len(timestamps))
+	for i, ts := range timestamps {
+		postID, err := postController.InsertPost(models.Post{
+			UserID:    user.ID,
+			Title:     "Feed Post " + strconv.Itoa(i+1),
+			Content:   "Feed content",
+			Category:  "General",
+			Timestamp: ts,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert test post %d: %v", i+1, err)
+		}
+		postIDs[i] = postID
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := commentController.InsertComment(models.Comment{
+			PostID:    postIDs[0],
+			UserID:    user.ID,
+			Author:    user.Nickname,
+			Content:   "Comment " + strconv.Itoa(i+1),
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert comment: %v", err)
+		}
+	}
+
+	var seen []int
+	cursor := ""
+	pages := 0
+	for {
+		posts, next, err := postController.GetPostsPage(controllers.FeedQuery{Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatalf("Failed to get posts page: %v", err)
+		}
+		pages++
+		if len(posts) > 2 {
+			t.Fatalf("Expected at most 2 posts per page, got %d", len(posts))
+		}
+		for _, post := range posts {
+			seen = append(seen, post.ID)
+			if len(post.Comments) != 0 {
+				t.Errorf("Expected no comment bodies in the feed for post %d", post.ID)
+			}
+			if post.ID == postIDs[0] && post.CommentCount != 2 {
+				t.Errorf("Expected comment count 2, got %d", post.CommentCount)
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	expected := []int{postIDs[1], postIDs[0], postIDs[2], postIDs[3], postIDs[4]}
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %d posts across pages, got %d", len(expected), len(seen))
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("Post %d: expected ID %d, got %d", i, expected[i], seen[i])
+		}
+	}
+
+	// Deleting a comment keeps the counter in step
+	comments, err := commentController.GetCommentsByPostID(strconv.Itoa(postIDs[0]))
+	if err != nil || len(comments) == 0 {
+		t.Fatalf("Failed to load comments: %v", err)
+	}
+	if err := commentController.DeleteComment(comments[0].ID); err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+	posts, _, err := postController.GetPostsPage(controllers.FeedQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to get posts page: %v", err)
+	}
+	for _, post := range posts {
+		if post.ID == postIDs[0] && post.CommentCount != 1 {
+			t.Errorf("Expected comment count 1 after delete, got %d", post.CommentCount)
+		}
+	}
+
+	if _, _, err := postController.GetPostsPage(controllers.FeedQuery{Cursor: "not-a-cursor!", Limit: 2}); err != controllers.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
 // TestGetPostByID tests the GetPostByID function
 func TestGetPostByID(t *testing.T) {
 	clearTables()
@@ -273,6 +375,330 @@ func TestUpdatePost(t *testing.T) {
 	}
 }
 
+// TestRenderedContentHTML tests that posts and comments cache sanitized HTML
+// rendered from their Markdown source
+func TestRenderedContentHTML(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+	postController = controllers.NewPostController(testDB)
+	commentController := controllers.NewCommentController(testDB)
+
+	postID, err := postController.InsertPost(models.Post{
+		UserID:    user.ID,
+		Title:     "Markdown post",
+		Content:   "**bold** & <script>alert(1)</script>",
+		Category:  "General",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert test post: %v", err)
+	}
+
+	post, err := postController.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	want := "<p><strong>bold</strong> &amp; &lt;script&gt;alert(1)&lt;/script&gt;</p>"
+	if post.ContentHTML != want {
+		t.Errorf("Unexpected post HTML: expected %s, got %s", want, post.ContentHTML)
+	}
+
+	post.Content = "- [link](javascript:alert(1))"
+	if err := postController.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	post, err = postController.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get updated post: %v", err)
+	}
+	if want := "<ul><li>link</li></ul>"; post.ContentHTML != want {
+		t.Errorf("Unexpected updated post HTML: expected %s, got %s", want, post.ContentHTML)
+	}
+
+	commentID, err := commentController.InsertComment(models.Comment{
+		PostID:    postID,
+		UserID:    user.ID,
+		Author:    user.Nickname,
+		Content:   "||spoiler||",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	if err := commentController.UpdateComment(commentID, "`code`"); err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+	comments, err := commentController.GetCommentsByPostID(strconv.Itoa(postID))
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("Failed to get comments: %v (%d)", err, len(comments))
+	}
+	if want := "<p><code>code</code></p>"; comments[0].ContentHTML != want {
+		t.Errorf("Unexpected comment HTML: expected %s, got %s", want, comments[0].ContentHTML)
+	}
+}
+
+// TestPostRevisions tests that edits are recorded as revisions and can be rolled back
+func TestPostRevisions(t *testing.T) {
+	clearTables()
+
+	author := registerTestUser(t)
+	postController = controllers.NewPostController(testDB)
+
+	created := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	postID, err := postController.InsertPost(models.Post{
+		UserID:    author.ID,
+		Title:     "Original title",
+		Content:   "Original content",
+		Category:  "technology",
+		Timestamp: created,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	revisions, err := postController.GetPostRevisions(postID)
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("Expected no revisions before the first edit, got %d", len(revisions))
+	}
+
+	post, err := postController.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if post.EditedAt != nil {
+		t.Errorf("Expected nil EditedAt on a new post, got %v", post.EditedAt)
+	}
+
+	// A save without changes records nothing
+	if err := postController.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	post.Title = "Edited title"
+	if err := postController.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	post.Content = "Edited content"
+	if err := postController.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+
+	revisions, err = postController.GetPostRevisions(postID)
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Title != "Original title" || revisions[0].Revision != 1 || !revisions[0].CreatedAt.Equal(created) {
+		t.Errorf("Unexpected base revision: %+v", revisions[0])
+	}
+	if revisions[2].Title != "Edited title" || revisions[2].Content != "Edited content" || revisions[2].Editor != author.Nickname {
+		t.Errorf("Unexpected latest revision: %+v", revisions[2])
+	}
+
+	post, err = postController.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if post.EditedAt == nil {
+		t.Error("Expected EditedAt to be set after an edit")
+	}
+	if !post.Timestamp.Equal(created) {
+		t.Errorf("Expected creation timestamp %v to be kept, got %v", created, post.Timestamp)
+	}
+
+	// Roll back to the original as another user (authorization is the handler's job);
+	// the image added since stays
+	if _, err := testDB.Exec("UPDATE posts SET image_url = 'uploads/kept.png' WHERE id = ?", postID); err != nil {
+		t.Fatalf("Failed to set post image: %v", err)
+	}
+	moderator := registerTestUser(t)
+	if err := postController.RollbackPost(postID, 1, moderator.ID); err != nil {
+		t.Fatalf("Failed to roll back post: %v", err)
+	}
+	post, err = postController.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if post.Title != "Original title" || post.Content != "Original content" {
+		t.Errorf("Expected original title and content after rollback, got %q / %q", post.Title, post.Content)
+	}
+	if post.ImageUrl.String != "uploads/kept.png" {
+		t.Errorf("Expected the current image to be kept after rollback, got %q", post.ImageUrl.String)
+	}
+
+	revisions, err = postController.GetPostRevisions(postID)
+	if err != nil {
+		t.Fatalf("Failed to get revisions: %v", err)
+	}
+	last := revisions[len(revisions)-1]
+	if len(revisions) != 4 || last.EditorID != moderator.ID || last.RolledBackFrom == nil || *last.RolledBackFrom != 1 {
+		t.Errorf("Expected rollback recorded as revision 4 by the moderator, got %+v", last)
+	}
+
+	if err := postController.RollbackPost(postID, 99, author.ID); err != controllers.ErrRevisionNotFound {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+// TestDraftsAndScheduledPosts tests that unpublished posts stay out of the feed until published
+func TestDraftsAndScheduledPosts(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+	postController = controllers.NewPostController(testDB)
+
+	now := time.Now().UTC()
+	draftID, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Title: "Draft", Content: "Not ready", Timestamp: now,
+		Status: controllers.PostStatusDraft,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert draft: %v", err)
+	}
+	publishAt := now.Add(time.Hour)
+	scheduledID, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Title: "Scheduled", Content: "Later", Timestamp: now,
+		Status: controllers.PostStatusScheduled, PublishAt: &publishAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert scheduled post: %v", err)
+	}
+	publicID, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Title: "Public", Content: "Now", Timestamp: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	past := now.Add(-time.Minute)
+	if _, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Title: "Bad", Content: "Past", Timestamp: now,
+		Status: controllers.PostStatusScheduled, PublishAt: &past,
+	}); err != controllers.ErrInvalidPublishAt {
+		t.Errorf("Expected ErrInvalidPublishAt, got %v", err)
+	}
+	if _, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Title: "Bad", Content: "Status", Timestamp: now, Status: "hidden",
+	}); err != controllers.ErrInvalidPostStatus {
+		t.Errorf("Expected ErrInvalidPostStatus, got %v", err)
+	}
+
+	feedIDs := func() []int {
+		posts, _, err := postController.GetPostsPage(controllers.FeedQuery{})
+		if err != nil {
+			t.Fatalf("Failed to get feed: %v", err)
+		}
+		ids := make([]int, len(posts))
+		for i, p := range posts {
+			ids[i] = p.ID
+		}
+		return ids
+	}
+	if ids := feedIDs(); len(ids) != 1 || ids[0] != publicID {
+		t.Errorf("Expected only the published post in the feed, got %v", ids)
+	}
+
+	drafts, err := postController.GetDrafts(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get drafts: %v", err)
+	}
+	if len(drafts) != 2 {
+		t.Fatalf("Expected 2 drafts, got %d", len(drafts))
+	}
+	other := registerTestUser(t)
+	if others, _ := postController.GetDrafts(other.ID); len(others) != 0 {
+		t.Errorf("Expected no drafts for another user, got %d", len(others))
+	}
+
+	// Nothing is due yet
+	published, err := postController.PublishDuePosts(now)
+	if err != nil || len(published) != 0 {
+		t.Fatalf("Expected nothing to publish, got %d (%v)", len(published), err)
+	}
+	published, err = postController.PublishDuePosts(publishAt.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to publish due posts: %v", err)
+	}
+	if len(published) != 1 || published[0].ID != scheduledID || published[0].Status != controllers.PostStatusPublished {
+		t.Fatalf("Expected the scheduled post to be published, got %+v", published)
+	}
+	if !published[0].Timestamp.Equal(publishAt) {
+		t.Errorf("Expected timestamp %v to match publish time, got %v", publishAt, published[0].Timestamp)
+	}
+
+	// Publishing a draft by hand
+	if _, err := postController.SetPostStatus(draftID, other.ID, controllers.PostStatusPublished, nil); err == nil {
+		t.Error("Expected error when another user publishes the draft")
+	}
+	wentLive, err := postController.SetPostStatus(draftID, user.ID, controllers.PostStatusPublished, nil)
+	if err != nil || !wentLive {
+		t.Fatalf("Expected draft to go live, got %v (%v)", wentLive, err)
+	}
+	if _, err := postController.SetPostStatus(draftID, user.ID, controllers.PostStatusDraft, nil); err != controllers.ErrAlreadyPublished {
+		t.Errorf("Expected ErrAlreadyPublished, got %v", err)
+	}
+	for _, tc := range []struct {
+		current, status string
+		publishAt       *time.Time
+		want            error
+	}{
+		{controllers.PostStatusDraft, controllers.PostStatusPublished, nil, nil},
+		{controllers.PostStatusDraft, controllers.PostStatusScheduled, &past, controllers.ErrInvalidPublishAt},
+		{controllers.PostStatusDraft, "archived", nil, controllers.ErrInvalidPostStatus},
+		{controllers.PostStatusPublished, controllers.PostStatusDraft, nil, controllers.ErrAlreadyPublished},
+	} {
+		if err := controllers.CheckPostStatus(tc.current, tc.status, tc.publishAt); err != tc.want {
+			t.Errorf("CheckPostStatus(%s, %s) = %v, want %v", tc.current, tc.status, err, tc.want)
+		}
+	}
+
+	// An edit with a refused status change is not saved either
+	live, err := postController.GetPostByID(strconv.Itoa(draftID))
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	title := live.Title
+	live.Title = "Back to draft"
+	if _, err := postController.UpdatePostWithStatus(live, controllers.PostStatusDraft, nil); err != controllers.ErrAlreadyPublished {
+		t.Errorf("Expected ErrAlreadyPublished, got %v", err)
+	}
+	if live, _ = postController.GetPostByID(strconv.Itoa(draftID)); live.Title != title || live.Status != controllers.PostStatusPublished {
+		t.Errorf("Expected the edit to be rolled back, got %q (%s)", live.Title, live.Status)
+	}
+
+	// The scheduled post sorts by its publish time, an hour ahead in this test
+	if ids := feedIDs(); len(ids) != 3 || ids[0] != scheduledID || ids[1] != draftID || ids[2] != publicID {
+		t.Errorf("Expected scheduled, then published draft, then original post, got %v", ids)
+	}
+
+	// An edit can publish a draft along with it
+	editedID, err := postController.InsertPost(models.Post{
+		UserID: user.ID, Author: user.Nickname, Title: "Draft to edit", Content: "Draft content",
+		Timestamp: now, Status: controllers.PostStatusDraft,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert draft: %v", err)
+	}
+	edited, err := postController.GetPostByID(strconv.Itoa(editedID))
+	if err != nil {
+		t.Fatalf("Failed to get draft: %v", err)
+	}
+	edited.Title = "Edited and published"
+	wentLive, err = postController.UpdatePostWithStatus(edited, controllers.PostStatusPublished, nil)
+	if err != nil || !wentLive {
+		t.Fatalf("Expected the edit to publish the draft, got %v (%v)", wentLive, err)
+	}
+	if edited, _ = postController.GetPostByID(strconv.Itoa(editedID)); edited.Title != "Edited and published" ||
+		edited.Status != controllers.PostStatusPublished {
+		t.Errorf("Expected the edit and status saved together, got %q (%s)", edited.Title, edited.Status)
+	}
+}
+
 // TestDeletePost tests the DeletePost function
 func TestDeletePost(t *testing.T) {
 	clearTables()
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// feedIDs pages through a feed one post at a time and returns the post IDs
+// in order
+func feedIDs(t *testing.T, pc *controllers.PostController, q controllers.FeedQuery) []int {
+	t.Helper()
+	var ids []int
+	q.Limit = 1
+	for {
+		posts, next, err := pc.GetPostsPage(q)
+		if err != nil {
+			t.Fatalf("Failed to get %s feed: %v", q.Sort, err)
+		}
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		if next == "" {
+			return ids
+		}
+		q.Cursor = next
+	}
+}
+
+func sameIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFeedSorting tests the hot, top and controversial feeds and that their
+// scores follow votes and comments
+func TestFeedSorting(t *testing.T) {
+	clearTables()
+	author := registerTestUser(t)
+	voters := make([]*models.User, 5)
+	for i := range voters {
+		voters[i] = registerTestUser(t)
+	}
+	pc := controllers.NewPostController(testDB)
+	lc := controllers.NewLikesController(testDB)
+	cc := controllers.NewCommentController(testDB)
+
+	now := time.Now().UTC()
+	insert := func(title string, published time.Time, likes, dislikes int) int {
+		t.Helper()
+		id, err := pc.InsertPost(models.Post{
+			UserID: author.ID, Title: title, Content: "Ranked", Category: "General", Timestamp: published,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		for i := 0; i < likes+dislikes; i++ {
+			vote := "like"
+			if i >= likes {
+				vote = "dislike"
+			}
+			if err := lc.HandleVote(id, voters[i].ID, vote); err != nil {
+				t.Fatalf("Failed to vote: %v", err)
+			}
+		}
+		return id
+	}
+	fresh := insert("Fresh", now, 1, 0)
+	split := insert("Split", now.Add(-time.Minute), 2, 2)
+	older := insert("Older", now.Add(-72*time.Hour), 3, 0)
+	oldest := insert("Oldest", now.Add(-10*24*time.Hour), 5, 0)
+
+	if got, want := feedIDs(t, pc, controllers.FeedQuery{}), []int{fresh, split, older, oldest}; !sameIDs(got, want) {
+		t.Errorf("New: expected %v, got %v", want, got)
+	}
+	if got, want := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortHot}), []int{fresh, split, older, oldest}; !sameIDs(got, want) {
+		t.Errorf("Hot: expected %v, got %v", want, got)
+	}
+	if got, want := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortTop}), []int{oldest, older, fresh, split}; !sameIDs(got, want) {
+		t.Errorf("Top of all time: expected %v, got %v", want, got)
+	}
+	if got, want := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortTop, Window: controllers.FeedWindowWeek}), []int{older, fresh, split}; !sameIDs(got, want) {
+		t.Errorf("Top of the week: expected %v, got %v", want, got)
+	}
+	if got, want := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortTop, Window: controllers.FeedWindowDay}), []int{fresh, split}; !sameIDs(got, want) {
+		t.Errorf("Top of the day: expected %v, got %v", want, got)
+	}
+	if got := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortControversial}); len(got) != 4 || got[0] != split {
+		t.Errorf("Controversial: expected %d first, got %v", split, got)
+	}
+
+	// Comments lift a post in the hot feed
+	for i := 0; i < 4; i++ {
+		_, err := cc.InsertComment(models.Comment{
+			PostID: split, UserID: voters[i].ID, Author: voters[i].Nickname, Content: "Discuss", Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert comment: %v", err)
+		}
+	}
+	if got := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortHot}); got[0] != split {
+		t.Errorf("Expected the discussed post to be hottest, got %v", got)
+	}
+
+	// Votes move the scores too
+	for i := 0; i < 3; i++ {
+		if err := lc.HandleVote(fresh, voters[i+1].ID, "like"); err != nil {
+			t.Fatalf("Failed to vote: %v", err)
+		}
+	}
+	if got := feedIDs(t, pc, controllers.FeedQuery{Sort: controllers.FeedSortTop, Window: controllers.FeedWindowDay}); got[0] != fresh {
+		t.Errorf("Expected the liked post on top, got %v", got)
+	}
+
+	if _, _, err := pc.GetPostsPage(controllers.FeedQuery{Sort: "best"}); err != controllers.ErrInvalidFeedSort {
+		t.Errorf("Expected ErrInvalidFeedSort, got %v", err)
+	}
+	if _, _, err := pc.GetPostsPage(controllers.FeedQuery{Sort: controllers.FeedSortTop, Window: "year"}); err != controllers.ErrInvalidFeedWindow {
+		t.Errorf("Expected ErrInvalidFeedWindow, got %v", err)
+	}
+	if _, _, err := pc.GetPostsPage(controllers.FeedQuery{Sort: controllers.FeedSortHot, Cursor: "bm90LWEtc2NvcmV8MQ"}); err != controllers.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
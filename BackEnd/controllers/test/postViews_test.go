@@ -0,0 +1,180 @@
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+func insertViewedPost(t *testing.T, pc *controllers.PostController, author *models.User) int {
+	t.Helper()
+	id, err := pc.InsertPost(models.Post{
+		UserID: author.ID, Title: "Viewed", Content: "Read me", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	return id
+}
+
+func postViewCount(t *testing.T, pc *controllers.PostController, postID int) int {
+	t.Helper()
+	post, err := pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	return post.ViewCount
+}
+
+// TestPostViews tests that views are deduplicated per viewer within the
+// window and only reach the database when flushed
+func TestPostViews(t *testing.T) {
+	clearTables()
+	if _, err := controllers.FlushPostViews(testDB); err != nil {
+		t.Fatalf("Failed to flush views left by other tests: %v", err)
+	}
+	author := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	postID := insertViewedPost(t, pc, author)
+	viewer := "test-viewer-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	now := time.Now()
+	if !controllers.RecordPostView(postID, viewer, now) {
+		t.Error("Expected the first view to count")
+	}
+	if controllers.RecordPostView(postID, viewer, now.Add(time.Minute)) {
+		t.Error("Expected a repeat view within the window not to count")
+	}
+	if !controllers.RecordPostView(postID, viewer+"-other", now.Add(time.Minute)) {
+		t.Error("Expected a view by another viewer to count")
+	}
+	if !controllers.RecordPostView(postID, viewer, now.Add(controllers.PostViewWindow)) {
+		t.Error("Expected a view after the window to count")
+	}
+
+	if count := postViewCount(t, pc, postID); count != 0 {
+		t.Errorf("Expected no views before the flush, got %d", count)
+	}
+	written, err := controllers.FlushPostViews(testDB)
+	if err != nil {
+		t.Fatalf("Failed to flush views: %v", err)
+	}
+	if written != 3 {
+		t.Errorf("Expected 3 views written, got %d", written)
+	}
+	if count := postViewCount(t, pc, postID); count != 3 {
+		t.Errorf("Expected a view count of 3, got %d", count)
+	}
+	if written, _ := controllers.FlushPostViews(testDB); written != 0 {
+		t.Errorf("Expected an empty second flush, wrote %d", written)
+	}
+
+	// Views of a post deleted before the flush are dropped
+	gone := insertViewedPost(t, pc, author)
+	controllers.RecordPostView(gone, viewer, now)
+	if err := pc.DeletePost(gone, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if written, err := controllers.FlushPostViews(testDB); err != nil || written != 0 {
+		t.Errorf("Expected no views written for a deleted post, got %d (%v)", written, err)
+	}
+	var rows int
+	testDB.QueryRow("SELECT COUNT(*) FROM post_views WHERE post_id = ?", gone).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected no view rows for a deleted post, got %d", rows)
+	}
+}
+
+// TestPostAnalytics tests the per day views, votes and comments of a post
+func TestPostAnalytics(t *testing.T) {
+	clearTables()
+	if _, err := controllers.FlushPostViews(testDB); err != nil {
+		t.Fatalf("Failed to flush views left by other tests: %v", err)
+	}
+	author := registerTestUser(t)
+	voter := registerTestUser(t)
+	critic := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	lc := controllers.NewLikesController(testDB)
+	cc := controllers.NewCommentController(testDB)
+	postID := insertViewedPost(t, pc, author)
+
+	now := time.Now().UTC()
+	today := now.Format(time.DateOnly)
+	twoDaysAgo := now.AddDate(0, 0, -2)
+
+	viewer := "analytics-viewer-" + strconv.FormatInt(now.UnixNano(), 10)
+	controllers.RecordPostView(postID, viewer+"-1", twoDaysAgo)
+	controllers.RecordPostView(postID, viewer+"-1", now)
+	controllers.RecordPostView(postID, viewer+"-2", now)
+	if _, err := controllers.FlushPostViews(testDB); err != nil {
+		t.Fatalf("Failed to flush views: %v", err)
+	}
+
+	if err := lc.HandleVote(postID, voter.ID, "like"); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if err := lc.HandleVote(postID, critic.ID, "dislike"); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	// Move the dislike back two days
+	_, err := testDB.Exec("UPDATE likes SET created_at = ? WHERE user_id = ?", twoDaysAgo.Format(time.DateTime), critic.ID)
+	if err != nil {
+		t.Fatalf("Failed to backdate vote: %v", err)
+	}
+
+	// Comment timestamps in another time zone land on their UTC day
+	eastAfrica := time.FixedZone("EAT", 3*60*60)
+	for _, at := range []time.Time{now.In(eastAfrica), now, twoDaysAgo.In(eastAfrica)} {
+		_, err := cc.InsertComment(models.Comment{
+			PostID: postID, UserID: voter.ID, Author: voter.Nickname, Content: "Nice", Timestamp: at,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert comment: %v", err)
+		}
+	}
+
+	analytics, err := pc.GetPostAnalytics(postID, 7, now)
+	if err != nil {
+		t.Fatalf("Failed to get analytics: %v", err)
+	}
+	if analytics.Views != 3 || analytics.Likes != 1 || analytics.Dislikes != 1 || analytics.Comments != 3 {
+		t.Errorf("Unexpected totals: %+v", analytics)
+	}
+	if len(analytics.Days) != 7 {
+		t.Fatalf("Expected 7 days, got %d", len(analytics.Days))
+	}
+	if last := analytics.Days[6]; last.Day != today {
+		t.Errorf("Expected the last day to be %s, got %s", today, last.Day)
+	}
+
+	expected := map[string]models.PostDayStats{
+		today:                            {Day: today, Views: 2, Likes: 1, Comments: 2},
+		twoDaysAgo.Format(time.DateOnly): {Day: twoDaysAgo.Format(time.DateOnly), Views: 1, Dislikes: 1, Comments: 1},
+	}
+	for _, day := range analytics.Days {
+		want, ok := expected[day.Day]
+		if !ok {
+			want = models.PostDayStats{Day: day.Day}
+		}
+		if day != want {
+			t.Errorf("Expected %+v, got %+v", want, day)
+		}
+	}
+
+	// The range is clamped
+	analytics, err = pc.GetPostAnalytics(postID, 10000, now)
+	if err != nil {
+		t.Fatalf("Failed to get analytics: %v", err)
+	}
+	if len(analytics.Days) != controllers.MaxAnalyticsDays {
+		t.Errorf("Expected %d days, got %d", controllers.MaxAnalyticsDays, len(analytics.Days))
+	}
+
+	if _, err := pc.GetPostAnalytics(postID+1000, 7, now); err == nil {
+		t.Error("Expected an error for a missing post")
+	}
+}
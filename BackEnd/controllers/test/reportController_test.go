@@ -0,0 +1,307 @@
+package test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+func insertTestMessage(t *testing.T, senderID, receiverID int, content string) int {
+	t.Helper()
+	result, err := testDB.Exec(`
+		INSERT INTO messages (sender_id, receiver_id, content, created_at)
+		VALUES (?, ?, ?, datetime('now'))`,
+		senderID, receiverID, content)
+	if err != nil {
+		t.Fatalf("Failed to insert test message: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return int(id)
+}
+
+func mustReport(t *testing.T, rc *controllers.ReportController, reporterID int, targetType string, targetID int, reason string) {
+	t.Helper()
+	_, err := rc.CreateReport(reporterID, models.ReportRequest{TargetType: targetType, TargetID: targetID, Reason: reason})
+	if err != nil {
+		t.Fatalf("Failed to report %s %d: %v", targetType, targetID, err)
+	}
+}
+
+// TestReports tests filing reports and the moderation queue grouping them
+// by target
+func TestReports(t *testing.T) {
+	clearTables()
+	author := registerTestUser(t)
+	reporter := registerTestUser(t)
+	bystander := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	cc := controllers.NewCommentController(testDB)
+	rc := controllers.NewReportController(testDB)
+	mc := controllers.NewModerationController(testDB)
+
+	postID := insertViewedPost(t, pc, author)
+	commentID, err := cc.InsertComment(models.Comment{
+		PostID: postID, UserID: author.ID, Author: author.Nickname, Content: "Rude reply", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	messageID := insertTestMessage(t, author.ID, reporter.ID, "Rude message")
+
+	report, err := rc.CreateReport(reporter.ID, models.ReportRequest{
+		TargetType: "Post", TargetID: postID, Reason: " Spam ", Details: "  Selling things  ",
+	})
+	if err != nil {
+		t.Fatalf("Failed to report post: %v", err)
+	}
+	if report.ID == 0 || report.TargetType != "post" || report.Reason != "spam" || report.Details != "Selling things" ||
+		report.Status != controllers.ReportStatusOpen || report.Reporter != reporter.Nickname {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	mustReport(t, rc, bystander.ID, controllers.ModerationTargetPost, postID, "harassment")
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetMessage, messageID, "harassment")
+	mustReport(t, rc, bystander.ID, controllers.ModerationTargetComment, commentID, "hate")
+
+	invalid := []struct {
+		name       string
+		reporterID int
+		req        models.ReportRequest
+		want       error
+	}{
+		{"duplicate", reporter.ID, models.ReportRequest{TargetType: "post", TargetID: postID, Reason: "other"}, controllers.ErrAlreadyReported},
+		{"own content", author.ID, models.ReportRequest{TargetType: "post", TargetID: postID, Reason: "spam"}, controllers.ErrReportOwnContent},
+		{"unknown reason", reporter.ID, models.ReportRequest{TargetType: "comment", TargetID: commentID, Reason: "boring"}, controllers.ErrInvalidReportReason},
+		{"unknown target type", reporter.ID, models.ReportRequest{TargetType: "user", TargetID: author.ID, Reason: "spam"}, controllers.ErrInvalidReportTarget},
+		{"missing post", reporter.ID, models.ReportRequest{TargetType: "post", TargetID: postID + 1000, Reason: "spam"}, controllers.ErrReportTargetNotFound},
+		{"someone else's message", bystander.ID, models.ReportRequest{TargetType: "message", TargetID: messageID, Reason: "spam"}, controllers.ErrReportTargetNotFound},
+		{"long details", reporter.ID, models.ReportRequest{
+			TargetType: "comment", TargetID: commentID, Reason: "other", Details: strings.Repeat("x", controllers.MaxReportDetailsLength+1),
+		}, controllers.ErrReportDetailsLong},
+	}
+	for _, tt := range invalid {
+		if _, err := rc.CreateReport(tt.reporterID, tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	targets, hasMore, err := mc.GetReportQueue(controllers.ReportQueueQuery{})
+	if err != nil {
+		t.Fatalf("Failed to get report queue: %v", err)
+	}
+	if hasMore || len(targets) != 3 {
+		t.Fatalf("Expected 3 reported targets, got %d", len(targets))
+	}
+	// The most reported target leads, the rest follow by their first report
+	post := targets[0]
+	if post.TargetType != "post" || post.TargetID != postID || post.ReportCount != 2 || len(post.Reports) != 2 {
+		t.Errorf("Expected the post with 2 reports first, got %+v", post)
+	}
+	if post.Reasons["spam"] != 1 || post.Reasons["harassment"] != 1 {
+		t.Errorf("Unexpected reason counts: %v", post.Reasons)
+	}
+	if post.AuthorID != author.ID || post.Author != author.Nickname || post.Excerpt != "Read me" || post.PostID != postID {
+		t.Errorf("Unexpected post details: %+v", post)
+	}
+	if post.Reports[0].Reporter != reporter.Nickname || post.FirstReportedAt.After(post.LatestReportedAt) {
+		t.Errorf("Expected reports oldest first, got %+v", post.Reports)
+	}
+	if targets[1].TargetType != "message" || targets[1].Excerpt != "Rude message" || targets[1].PostID != 0 {
+		t.Errorf("Expected the message second, got %+v", targets[1])
+	}
+	if targets[2].TargetType != "comment" || targets[2].PostID != postID {
+		t.Errorf("Expected the comment third, got %+v", targets[2])
+	}
+
+	page, hasMore, err := mc.GetReportQueue(controllers.ReportQueueQuery{Limit: 2, Page: 2})
+	if err != nil || hasMore || len(page) != 1 || page[0].TargetType != "comment" {
+		t.Errorf("Expected the comment alone on the second page, got %+v (%v)", page, err)
+	}
+}
+
+// TestReportActions tests that moderator actions apply to the reported
+// content or its author, close the reports and are logged for good
+func TestReportActions(t *testing.T) {
+	clearTables()
+	author := registerTestUser(t)
+	reporter := registerTestUser(t)
+	moderator := registerTestUser(t)
+	if _, err := testDB.Exec("UPDATE users SET role = 'moderator' WHERE id = ?", moderator.ID); err != nil {
+		t.Fatalf("Failed to promote moderator: %v", err)
+	}
+	pc := controllers.NewPostController(testDB)
+	cc := controllers.NewCommentController(testDB)
+	rc := controllers.NewReportController(testDB)
+	mc := controllers.NewModerationController(testDB)
+
+	// Hiding a post takes it out of the feed but keeps it for its author
+	hiddenID := insertViewedPost(t, pc, author)
+	lc := controllers.NewLikesController(testDB)
+	if err := lc.HandleVote(hiddenID, reporter.ID, "like"); err != nil {
+		t.Fatalf("Failed to like post: %v", err)
+	}
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetPost, hiddenID, "spam")
+	res, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, hiddenID, controllers.ModerationHide,
+		models.ModerationRequest{Reason: "Advertising"})
+	if err != nil || res.Resolved != 1 {
+		t.Fatalf("Failed to hide post: %d resolved (%v)", res.Resolved, err)
+	}
+	if !mustGetPost(t, pc, hiddenID).Hidden {
+		t.Error("Expected the post to be hidden")
+	}
+	feed, _, err := pc.GetPostsPage(controllers.FeedQuery{})
+	if err != nil {
+		t.Fatalf("Failed to get feed: %v", err)
+	}
+	for _, p := range feed {
+		if p.ID == hiddenID {
+			t.Error("Expected the hidden post to be left out of the feed")
+		}
+	}
+	if _, err := cc.InsertComment(models.Comment{
+		PostID: hiddenID, UserID: reporter.ID, Author: reporter.Nickname, Content: "Still here?", Timestamp: time.Now(),
+	}); !errors.Is(err, controllers.ErrPostNotFound) {
+		t.Errorf("Expected ErrPostNotFound commenting on a hidden post, got %v", err)
+	}
+	if err := lc.HandleVote(hiddenID, reporter.ID, "dislike"); !errors.Is(err, controllers.ErrPostNotFound) {
+		t.Errorf("Expected ErrPostNotFound voting on a hidden post, got %v", err)
+	}
+	liked, err := lc.GetUserLikesPosts(reporter.ID)
+	if err != nil || len(liked) != 0 {
+		t.Errorf("Expected the hidden post left out of liked posts, got %+v (%v)", liked, err)
+	}
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, hiddenID, controllers.ModerationHide,
+		models.ModerationRequest{}); !errors.Is(err, controllers.ErrNoOpenReports) {
+		t.Errorf("Expected ErrNoOpenReports once resolved, got %v", err)
+	}
+	resolved, _, err := mc.GetReportQueue(controllers.ReportQueueQuery{Status: controllers.ReportStatusResolved})
+	if err != nil || len(resolved) != 1 || !resolved[0].Hidden || resolved[0].Reports[0].Resolution != controllers.ModerationHide ||
+		resolved[0].Reports[0].ResolvedAt == nil {
+		t.Errorf("Expected the hidden post among resolved reports, got %+v (%v)", resolved, err)
+	}
+
+	// Hidden comments and messages keep their place without their content
+	postID := insertViewedPost(t, pc, author)
+	commentID, err := cc.InsertComment(models.Comment{
+		PostID: postID, UserID: author.ID, Author: author.Nickname, Content: "Rude reply", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetComment, commentID, "harassment")
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetComment, commentID, controllers.ModerationHide,
+		models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to hide comment: %v", err)
+	}
+	comments, err := cc.GetCommentsByPostID(strconv.Itoa(postID))
+	if err != nil || len(comments) != 1 || !comments[0].Hidden || comments[0].Content != "" {
+		t.Errorf("Expected the comment listed blank, got %+v (%v)", comments, err)
+	}
+	err = controllers.NewCommentVotesController(testDB).HandleCommentVote(commentID, reporter.ID, "like")
+	if !errors.Is(err, controllers.ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound voting on a hidden comment, got %v", err)
+	}
+
+	messageID := insertTestMessage(t, author.ID, reporter.ID, "Rude message")
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetMessage, messageID, "harassment")
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetMessage, messageID, controllers.ModerationHide,
+		models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to hide message: %v", err)
+	}
+	messages, err := controllers.NewMessageController(testDB).GetMessages(int64(reporter.ID), int64(author.ID), 1)
+	if err != nil || len(messages) != 1 || !messages[0].Hidden || messages[0].Content != "" {
+		t.Errorf("Expected the message listed blank, got %+v (%v)", messages, err)
+	}
+
+	// Deleting removes the content
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetPost, postID, "spam")
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, postID, controllers.ModerationDelete,
+		models.ModerationRequest{}); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if _, err := pc.GetPostByID(strconv.Itoa(postID)); err == nil {
+		t.Error("Expected the post to be deleted")
+	}
+
+	// Warnings reach the author even after the content is gone
+	warnedID := insertViewedPost(t, pc, author)
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetPost, warnedID, "misinformation")
+	if err := pc.DeletePost(warnedID, author.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	res, err = mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, warnedID, controllers.ModerationWarn,
+		models.ModerationRequest{Reason: "Check your sources"})
+	if err != nil || len(res.Notifications) != 1 {
+		t.Fatalf("Failed to warn author: %+v (%v)", res, err)
+	}
+	notifications, _, err := controllers.NewNotificationController(testDB).GetNotifications(author.ID, controllers.NotificationQuery{})
+	if err != nil || len(notifications) == 0 || notifications[0].Type != controllers.NotificationModeration ||
+		notifications[0].Excerpt != "Check your sources" {
+		t.Errorf("Expected a moderation notification, got %+v (%v)", notifications, err)
+	}
+
+	// Suspending signs the author out and keeps them out
+	suspendedID := insertViewedPost(t, pc, author)
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetPost, suspendedID, "spam")
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, suspendedID, controllers.ModerationSuspend,
+		models.ModerationRequest{Days: controllers.MaxSuspensionDays + 1}); !errors.Is(err, controllers.ErrInvalidSuspension) {
+		t.Errorf("Expected ErrInvalidSuspension, got %v", err)
+	}
+	if err := controllers.AddSession(testDB, "suspended-session", author.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to add session: %v", err)
+	}
+	res, err = mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, suspendedID, controllers.ModerationSuspend,
+		models.ModerationRequest{Days: 3})
+	if err != nil || res.SuspendedUntil == nil {
+		t.Fatalf("Failed to suspend author: %+v (%v)", res, err)
+	}
+	if until := time.Until(*res.SuspendedUntil); until < 71*time.Hour || until > 73*time.Hour {
+		t.Errorf("Expected a 3 day suspension, got %v", until)
+	}
+	if _, valid := controllers.IsValidSession(testDB, "suspended-session"); valid {
+		t.Error("Expected the author's sessions to be ended")
+	}
+	_, err = authController.Login(&models.LoginRequest{Identifier: author.Nickname, Password: "Test@123"})
+	if !errors.Is(err, controllers.ErrAccountSuspended) {
+		t.Errorf("Expected ErrAccountSuspended on login, got %v", err)
+	}
+
+	// Moderators cannot be suspended, and unknown actions are refused
+	modPostID := insertViewedPost(t, pc, moderator)
+	mustReport(t, rc, reporter.ID, controllers.ModerationTargetPost, modPostID, "spam")
+	if _, err := mc.ResolveReports(author.ID, controllers.ModerationTargetPost, modPostID, controllers.ModerationSuspend,
+		models.ModerationRequest{}); !errors.Is(err, controllers.ErrCannotSuspendModerator) {
+		t.Errorf("Expected ErrCannotSuspendModerator, got %v", err)
+	}
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, modPostID, "ban",
+		models.ModerationRequest{}); !errors.Is(err, controllers.ErrInvalidReportAction) {
+		t.Errorf("Expected ErrInvalidReportAction, got %v", err)
+	}
+	if _, err := mc.ResolveReports(moderator.ID, controllers.ModerationTargetPost, modPostID, controllers.ModerationDismiss,
+		models.ModerationRequest{}); err != nil {
+		t.Errorf("Failed to dismiss reports: %v", err)
+	}
+
+	// Warnings and suspensions are logged against the author
+	entries, _, err := mc.GetModerationLog(controllers.ModerationLogQuery{
+		TargetType: controllers.ModerationTargetUser, TargetID: author.ID,
+	})
+	if err != nil || len(entries) != 2 || entries[0].Action != controllers.ModerationSuspend || entries[1].Action != controllers.ModerationWarn {
+		t.Fatalf("Expected the suspension and warning logged, got %+v (%v)", entries, err)
+	}
+	if entries[1].Reason != "Check your sources" {
+		t.Errorf("Expected the warning reason logged, got %q", entries[1].Reason)
+	}
+
+	// The log cannot be rewritten
+	if _, err := testDB.Exec("UPDATE moderation_log SET reason = '' WHERE id = ?", entries[0].ID); err == nil {
+		t.Error("Expected moderation log entries to be unchangeable")
+	}
+	if _, err := testDB.Exec("DELETE FROM moderation_log WHERE id = ?", entries[0].ID); err == nil {
+		t.Error("Expected moderation log entries to be undeletable")
+	}
+}
@@ -0,0 +1,289 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// pngData encodes a blank image of the given size
+func pngData(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// droppedReader returns its data, then fails like a dropped connection
+type droppedReader struct {
+	data []byte
+}
+
+func (d *droppedReader) Read(p []byte) (int, error) {
+	if len(d.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, d.data)
+	d.data = d.data[n:]
+	return n, nil
+}
+
+func newResumableUploads(t *testing.T) *controllers.ResumableUploadController {
+	t.Helper()
+	testDB.Exec("DELETE FROM resumable_uploads")
+	t.Cleanup(func() { testDB.Exec("DELETE FROM resumable_uploads") })
+	return controllers.NewResumableUploadController(testDB, t.TempDir())
+}
+
+// uploadWhole sends data as a single chunk
+func uploadWhole(t *testing.T, rc *controllers.ResumableUploadController, userID int, target string, data []byte) models.ResumableUpload {
+	t.Helper()
+	upload, err := rc.Create(userID, int64(len(data)), map[string]string{"target": target})
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	upload, err = rc.Append(context.Background(), userID, upload.ID, 0, bytes.NewReader(data))
+	if err != nil || upload.Attachment == nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+	return upload
+}
+
+// TestResumableUpload tests sending a file in chunks, resuming after a
+// dropped connection and linking the completed upload to a post
+func TestResumableUpload(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	rc := newResumableUploads(t)
+	user := registerTestUser(t)
+	other := registerTestUser(t)
+	ctx := context.Background()
+	data := pngData(t, 1200, 600)
+
+	if _, err := rc.Create(user.ID, controllers.MaxResumableUploadSize+1, map[string]string{"target": "post"}); !errors.Is(err, controllers.ErrUploadTooLarge) {
+		t.Errorf("Expected a too large upload to be refused, got %v", err)
+	}
+	if _, err := rc.Create(user.ID, 10, map[string]string{"target": "avatar"}); !errors.Is(err, controllers.ErrInvalidUploadTarget) {
+		t.Errorf("Expected an unknown target to be refused, got %v", err)
+	}
+
+	upload, err := rc.Create(user.ID, int64(len(data)), map[string]string{"target": "post", "filename": "wide.png", "alt_text": " A banner "})
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	if upload.Offset != 0 || upload.AltText != "A banner" || upload.ExpiresAt.Before(time.Now()) {
+		t.Errorf("Unexpected new upload %+v", upload)
+	}
+	if _, err := rc.Get(other.ID, upload.ID); !errors.Is(err, controllers.ErrUploadNotFound) {
+		t.Errorf("Expected other users not to see the upload, got %v", err)
+	}
+
+	half := int64(len(data) / 2)
+	upload, err = rc.Append(ctx, user.ID, upload.ID, 0, bytes.NewReader(data[:half]))
+	if err != nil || upload.Offset != half || upload.Attachment != nil {
+		t.Fatalf("Expected the first half to be written, got %+v, %v", upload, err)
+	}
+	if _, err := rc.Append(ctx, user.ID, upload.ID, 0, bytes.NewReader(data)); !errors.Is(err, controllers.ErrUploadOffsetMismatch) {
+		t.Errorf("Expected a chunk at the wrong offset to be refused, got %v", err)
+	}
+
+	// The bytes that arrive before a connection drops are kept
+	upload, err = rc.Append(ctx, user.ID, upload.ID, half, &droppedReader{data: data[half : half+10]})
+	if err == nil || upload.Offset != half+10 {
+		t.Fatalf("Expected a partial chunk to be kept, got %+v, %v", upload, err)
+	}
+	if upload, err = rc.Get(user.ID, upload.ID); err != nil || upload.Offset != half+10 {
+		t.Fatalf("Expected to resume at %d, got %+v, %v", half+10, upload, err)
+	}
+
+	extra := append(append([]byte{}, data[half+10:]...), 'x')
+	if _, err := rc.Append(ctx, user.ID, upload.ID, half+10, bytes.NewReader(extra)); !errors.Is(err, controllers.ErrUploadTooLarge) {
+		t.Errorf("Expected a chunk past the end to be refused, got %v", err)
+	}
+
+	upload, err = rc.Append(ctx, user.ID, upload.ID, half+10, bytes.NewReader(data[half+10:]))
+	if err != nil || upload.Attachment == nil {
+		t.Fatalf("Failed to complete upload: %+v, %v", upload, err)
+	}
+	a := *upload.Attachment
+	if a.Width != 1200 || a.AltText != "A banner" || a.URL == a.ThumbnailURL || !blobExists(store, a.URL) || !blobExists(store, a.ThumbnailURL) {
+		t.Errorf("Expected the original and its variants to be stored, got %+v", a)
+	}
+	if fileExists(filepath.Join(rc.Dir, upload.ID)) {
+		t.Error("Expected the partial data to be removed")
+	}
+
+	// Linking the upload to a post
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("upload-id", upload.ID)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/create", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(10 << 20); err != nil {
+		t.Fatalf("Failed to parse form: %v", err)
+	}
+	if _, err := controllers.SavePostAttachments(testDB, req, other.ID); !errors.Is(err, controllers.ErrUploadNotFound) {
+		t.Errorf("Expected another user's upload to be refused, got %v", err)
+	}
+	attachments, err := controllers.SavePostAttachments(testDB, req, user.ID)
+	if err != nil || len(attachments) != 1 || attachments[0].URL != a.URL || attachments[0].AltText != "A banner" {
+		t.Fatalf("Expected the upload as the attachment, got %+v, %v", attachments, err)
+	}
+	pc := controllers.NewPostController(testDB)
+	postID, err := pc.InsertPost(models.Post{
+		UserID: user.ID, Title: "Resumed", Content: "Big picture", Category: "General", Timestamp: time.Now(), Attachments: attachments,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	post, err := pc.GetPostByID(strconv.Itoa(postID))
+	if err != nil || len(post.Attachments) != 1 || post.Attachments[0].ThumbnailURL != a.ThumbnailURL {
+		t.Errorf("Expected the post to show the upload, got %+v, %v", post, err)
+	}
+
+	// Terminating the upload keeps the files the post uses
+	if err := rc.Terminate(user.ID, upload.ID); err != nil {
+		t.Fatalf("Failed to terminate upload: %v", err)
+	}
+	if _, err := rc.Get(user.ID, upload.ID); !errors.Is(err, controllers.ErrUploadNotFound) {
+		t.Errorf("Expected the upload to be gone, got %v", err)
+	}
+	if !blobExists(store, a.URL) {
+		t.Error("Expected the post's file to be kept")
+	}
+}
+
+// TestResumableUploadTargets tests linking completed uploads to comments
+// and messages
+func TestResumableUploadTargets(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	withoutUploadGrace(t)
+	rc := newResumableUploads(t)
+	user := registerTestUser(t)
+
+	pending, err := rc.Create(user.ID, 100, map[string]string{"target": "comment"})
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	if _, err := rc.CompletedUpload(user.ID, pending.ID, controllers.UploadTargetComment); !errors.Is(err, controllers.ErrUploadIncomplete) {
+		t.Errorf("Expected an incomplete upload to be refused, got %v", err)
+	}
+
+	upload := uploadWhole(t, rc, user.ID, controllers.UploadTargetComment, pngData(t, 2000, 1000))
+	if upload.Attachment.Width != 1024 || !blobExists(store, upload.Attachment.URL) {
+		t.Errorf("Expected the medium variant for a comment, got %+v", upload.Attachment)
+	}
+	if _, err := rc.CompletedUpload(user.ID, upload.ID, controllers.UploadTargetMessage); !errors.Is(err, controllers.ErrInvalidUpload) {
+		t.Errorf("Expected a comment upload to be refused for a message, got %v", err)
+	}
+	attachment, err := rc.CompletedUpload(user.ID, upload.ID, controllers.UploadTargetComment)
+	if err != nil {
+		t.Fatalf("Failed to get completed upload: %v", err)
+	}
+
+	postID, err := controllers.NewPostController(testDB).InsertPost(models.Post{
+		UserID: user.ID, Title: "Thread", Content: "Reply with a picture", Category: "General", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	cc := controllers.NewCommentController(testDB)
+	commentID, err := cc.InsertComment(models.Comment{
+		PostID: postID, UserID: user.ID, Author: user.Nickname, Content: "Look", Timestamp: time.Now(), MediaURL: attachment.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	comments, err := cc.GetCommentsByPostID(strconv.Itoa(postID))
+	if err != nil || len(comments) != 1 || comments[0].MediaURL != attachment.URL {
+		t.Fatalf("Expected the comment to carry the image, got %+v, %v", comments, err)
+	}
+
+	// The image goes with the comment once the upload is gone too
+	if err := rc.Terminate(user.ID, upload.ID); err != nil {
+		t.Fatalf("Failed to terminate upload: %v", err)
+	}
+	if !blobExists(store, attachment.URL) {
+		t.Fatal("Expected the comment's image to be kept")
+	}
+	if err := cc.DeleteComment(commentID); err != nil {
+		t.Fatalf("Failed to delete comment: %v", err)
+	}
+	if blobExists(store, attachment.URL) {
+		t.Error("Expected the image to be deleted with the comment")
+	}
+
+	message := uploadWhole(t, rc, user.ID, controllers.UploadTargetMessage, pngData(t, 50, 50))
+	if message.Attachment.MediumURL == message.Attachment.URL || message.Attachment.ThumbnailURL != "" {
+		t.Errorf("Expected a signed URL for a message image, got %+v", message.Attachment)
+	}
+	attachment, err = rc.CompletedUpload(user.ID, message.ID, controllers.UploadTargetMessage)
+	if err != nil || !controllers.ValidMessageMedia(testDB, user.ID, attachment.URL) {
+		t.Errorf("Expected the upload to be valid message media, got %+v, %v", attachment, err)
+	}
+}
+
+// TestResumableUploadExpiry tests that expired and invalid uploads are
+// removed with their data
+func TestResumableUploadExpiry(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	withoutUploadGrace(t)
+	rc := newResumableUploads(t)
+	user := registerTestUser(t)
+	ctx := context.Background()
+
+	invalid, err := rc.Create(user.ID, 5, map[string]string{"target": "post"})
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	if _, err := rc.Append(ctx, user.ID, invalid.ID, 0, bytes.NewReader([]byte("hello"))); !errors.Is(err, controllers.ErrInvalidUpload) {
+		t.Errorf("Expected a file that is no image to be refused, got %v", err)
+	}
+	if _, err := rc.Get(user.ID, invalid.ID); !errors.Is(err, controllers.ErrUploadNotFound) || fileExists(filepath.Join(rc.Dir, invalid.ID)) {
+		t.Errorf("Expected the invalid upload to be deleted, got %v", err)
+	}
+
+	incomplete, err := rc.Create(user.ID, 1000, map[string]string{"target": "post"})
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	completed := uploadWhole(t, rc, user.ID, controllers.UploadTargetPost, pngData(t, 30, 30))
+	kept := uploadWhole(t, rc, user.ID, controllers.UploadTargetComment, pngData(t, 20, 20))
+
+	testDB.Exec("UPDATE resumable_uploads SET expires_at = ? WHERE id IN (?, ?)", time.Now().Add(-time.Minute), incomplete.ID, completed.ID)
+	if _, err := rc.Get(user.ID, incomplete.ID); !errors.Is(err, controllers.ErrUploadNotFound) {
+		t.Errorf("Expected an expired upload to be gone, got %v", err)
+	}
+
+	removed, err := controllers.RemoveExpiredResumableUploads(testDB, rc.Dir)
+	if err != nil || removed != 2 {
+		t.Fatalf("Expected two expired uploads to be removed, got %d, %v", removed, err)
+	}
+	if fileExists(filepath.Join(rc.Dir, incomplete.ID)) {
+		t.Error("Expected the partial data to be removed")
+	}
+	if blobExists(store, completed.Attachment.URL) {
+		t.Error("Expected the files of an expired upload to be deleted")
+	}
+	if _, err := rc.Get(user.ID, kept.ID); err != nil || !blobExists(store, kept.Attachment.URL) {
+		t.Errorf("Expected an unexpired upload to be kept, got %v", err)
+	}
+}
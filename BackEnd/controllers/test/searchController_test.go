@@ -0,0 +1,153 @@
+// controllers/test/searchController_test.go
+package test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// TestSearch tests ranking, filters, highlighting and index maintenance.
+// It needs SQLite built with FTS5: go test -tags sqlite_fts5 ./...
+func TestSearch(t *testing.T) {
+	sc := controllers.NewSearchController(testDB)
+	if !sc.Enabled() {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+	clearTables()
+
+	user := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	cc := controllers.NewCommentController(testDB)
+	categories := controllers.NewCategoryController(testDB)
+
+	gaming, err := categories.ResolvePostCategories("gaming")
+	if err != nil {
+		t.Fatalf("Failed to resolve categories: %v", err)
+	}
+
+	now := time.Now().UTC()
+	oldID, err := pc.InsertPost(models.Post{
+		UserID:    user.ID,
+		Title:     "Gopher meetup notes",
+		Content:   "Notes from the gopher meetup",
+		Timestamp: now.AddDate(0, 0, -60),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	newID, err := pc.InsertPost(models.Post{
+		UserID:     user.ID,
+		Title:      "Gopher meetup <recap>",
+		Content:    "Notes from the gopher meetup",
+		Timestamp:  now,
+		Categories: gaming,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	commentID, err := cc.InsertComment(models.Comment{
+		PostID:    oldID,
+		UserID:    user.ID,
+		Author:    user.Nickname,
+		Content:   "Will there be another gopher meetup?",
+		Timestamp: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+
+	results, hasMore, err := sc.Search(controllers.SearchQuery{Query: "gopher meet", Type: controllers.SearchPosts})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if hasMore || len(results) != 2 {
+		t.Fatalf("Expected 2 post results, got %d (hasMore %v)", len(results), hasMore)
+	}
+	if results[0].ID != newID {
+		t.Errorf("Expected the recent post to rank first, got %d", results[0].ID)
+	}
+	if !strings.Contains(results[0].Title, "<mark>Gopher</mark>") || !strings.Contains(results[0].Title, "&lt;recap&gt;") {
+		t.Errorf("Expected escaped, highlighted title, got %q", results[0].Title)
+	}
+
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "another", Type: controllers.SearchComments})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != commentID || results[0].PostID != oldID || results[0].Type != "comment" {
+		t.Errorf("Unexpected comment results: %+v", results)
+	}
+
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "gopher", Category: "gaming"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != newID {
+		t.Errorf("Expected only the gaming post, got %+v", results)
+	}
+
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "gopher", From: now.AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected the new post and the comment after the from date, got %d", len(results))
+	}
+
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "gopher", Author: "nobody"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results for unknown author, got %d", len(results))
+	}
+
+	results, hasMore, err = sc.Search(controllers.SearchQuery{Query: "gopher", Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || !hasMore {
+		t.Errorf("Expected a full first page with more results, got %d (hasMore %v)", len(results), hasMore)
+	}
+
+	// Operators in user input are matched literally instead of erroring
+	if _, _, err := sc.Search(controllers.SearchQuery{Query: `gopher" OR (NEAR`}); err != nil {
+		t.Errorf("Expected quoted query to succeed, got %v", err)
+	}
+	if _, _, err := sc.Search(controllers.SearchQuery{Query: "   "}); err != controllers.ErrEmptySearchQuery {
+		t.Errorf("Expected ErrEmptySearchQuery, got %v", err)
+	}
+
+	// Edits and deletes keep the index current
+	post, err := pc.GetPostByID(strconv.Itoa(oldID))
+	if err != nil {
+		t.Fatalf("Failed to fetch post: %v", err)
+	}
+	post.Title = "Rustacean gathering"
+	post.Content = "Nothing about that other language"
+	if err := pc.UpdatePost(post); err != nil {
+		t.Fatalf("Failed to update post: %v", err)
+	}
+	if err := pc.DeletePost(newID, user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "gopher", Type: controllers.SearchPosts})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no posts after edit and delete, got %+v", results)
+	}
+	results, _, err = sc.Search(controllers.SearchQuery{Query: "rustacean"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != oldID {
+		t.Errorf("Expected the edited post, got %+v", results)
+	}
+}
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+func refCount(path string) int {
+	count := -1
+	testDB.QueryRow("SELECT ref_count FROM uploads WHERE path = ?", path).Scan(&count)
+	return count
+}
+
+// TestUploadDeduplication tests that identical uploads share one blob, which
+// is deleted with its last reference
+func TestUploadDeduplication(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	withoutUploadGrace(t)
+	user := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+
+	var postIDs []int
+	var first models.Attachment
+	for i := 0; i < 2; i++ {
+		attachments, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{1200, 600}}), user.ID)
+		if err != nil {
+			t.Fatalf("Failed to save attachments: %v", err)
+		}
+		if i == 0 {
+			first = attachments[0]
+		} else if attachments[0].URL != first.URL || attachments[0].ThumbnailURL != first.ThumbnailURL {
+			t.Fatalf("Expected the same content to share its files, got %+v and %+v", first, attachments[0])
+		}
+		postID, err := pc.InsertPost(models.Post{
+			UserID: user.ID, Title: "Same picture", Content: "Again", Category: "General", Timestamp: time.Now(), Attachments: attachments,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		postIDs = append(postIDs, postID)
+	}
+
+	if keys := storedKeys(t, store); len(keys) != 3 {
+		t.Errorf("Expected the original and two variants once, found %v", keys)
+	}
+	// Each post refers to the original from its attachment and its image_url
+	if got := refCount(first.URL); got != 4 {
+		t.Errorf("Expected 4 references to the original, got %d", got)
+	}
+	if got := refCount(first.ThumbnailURL); got != 2 {
+		t.Errorf("Expected 2 references to the thumbnail, got %d", got)
+	}
+
+	if err := pc.DeletePost(postIDs[0], user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if !blobExists(store, first.URL) || !blobExists(store, first.ThumbnailURL) || refCount(first.URL) != 2 {
+		t.Error("Expected the files to stay while another post uses them")
+	}
+	if err := pc.DeletePost(postIDs[1], user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if keys := storedKeys(t, store); len(keys) != 0 {
+		t.Errorf("Expected the files to go with the last post, found %v", keys)
+	}
+	if got := refCount(first.URL); got != -1 {
+		t.Errorf("Expected the uploads row to be removed, got ref_count %d", got)
+	}
+}
+
+// TestUploadGracePeriod tests that recently uploaded files are kept even
+// when nothing refers to them
+func TestUploadGracePeriod(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	user := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+
+	attachments, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{30, 30}}), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	postID, err := pc.InsertPost(models.Post{
+		UserID: user.ID, Title: "Quick", Content: "Gone soon", Category: "General", Timestamp: time.Now(), Attachments: attachments,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	if err := pc.DeletePost(postID, user.ID); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if !blobExists(store, attachments[0].URL) {
+		t.Error("Expected a file uploaded within the grace period to be kept")
+	}
+
+	report, err := controllers.SweepUploads(context.Background(), testDB, false)
+	if err != nil {
+		t.Fatalf("Failed to sweep uploads: %v", err)
+	}
+	if report.Pending != 1 || len(report.Orphans) != 0 || !blobExists(store, attachments[0].URL) {
+		t.Errorf("Expected the file to be pending, got %+v", report)
+	}
+}
+
+// TestSweepUploads tests the dry run and the deletion of orphaned uploads
+func TestSweepUploads(t *testing.T) {
+	clearTables()
+	store := useTestBlobStore(t)
+	withoutUploadGrace(t)
+	user := registerTestUser(t)
+	pc := controllers.NewPostController(testDB)
+	ctx := context.Background()
+
+	used, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{20, 20}}), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	if _, err := pc.InsertPost(models.Post{
+		UserID: user.ID, Title: "Kept", Content: "Used", Category: "General", Timestamp: time.Now(), Attachments: used,
+	}); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	unused, err := controllers.SavePostAttachments(testDB, newUploadRequest(t, [][2]int{{10, 10}}), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to save attachments: %v", err)
+	}
+	// A file the uploads table does not know about, e.g. from an older version
+	if err := store.Put(ctx, "posts/stray.png", strings.NewReader("stray"), "image/png"); err != nil {
+		t.Fatalf("Failed to store stray file: %v", err)
+	}
+
+	report, err := controllers.SweepUploads(ctx, testDB, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	orphans := make(map[string]bool)
+	for _, orphan := range report.Orphans {
+		orphans[orphan.Path] = true
+	}
+	if !report.DryRun || report.Scanned != 3 || report.Referenced != 1 || len(orphans) != 2 ||
+		!orphans[unused[0].URL] || !orphans["uploads/posts/stray.png"] || report.OrphanBytes == 0 {
+		t.Errorf("Unexpected dry run report %+v", report)
+	}
+	if !blobExists(store, unused[0].URL) || !blobExists(store, "uploads/posts/stray.png") {
+		t.Fatal("Expected a dry run to keep every file")
+	}
+
+	report, err = controllers.SweepUploads(ctx, testDB, false)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if report.DryRun || len(report.Orphans) != 2 {
+		t.Errorf("Unexpected sweep report %+v", report)
+	}
+	if keys := storedKeys(t, store); len(keys) != 1 || "uploads/"+keys[0] != used[0].URL {
+		t.Errorf("Expected only the used file to be left, found %v", keys)
+	}
+	if refCount(unused[0].URL) != -1 {
+		t.Error("Expected the orphan's uploads row to be removed")
+	}
+
+	// A referenced file that went missing is reported, and its row kept
+	key := strings.TrimPrefix(used[0].URL, "uploads/")
+	store.Delete(ctx, key)
+	report, err = controllers.SweepUploads(ctx, testDB, false)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != used[0].URL || refCount(used[0].URL) != 2 {
+		t.Errorf("Expected the missing file to be reported, got %+v", report)
+	}
+}
+
+// TestSweptMessageMedia tests that a message cannot refer to an upload the
+// sweeper has deleted
+func TestSweptMessageMedia(t *testing.T) {
+	clearTables()
+	useTestBlobStore(t)
+	withoutUploadGrace(t)
+	sender := registerTestUser(t)
+
+	file, header := uploadedFile(t, [2]int{40, 40})
+	attachment, err := controllers.SaveMessageAttachment(context.Background(), testDB, sender.ID, file, header)
+	if err != nil {
+		t.Fatalf("Failed to save attachment: %v", err)
+	}
+	if !controllers.ValidMessageMedia(testDB, sender.ID, attachment.URL) {
+		t.Fatal("Expected the upload to be valid")
+	}
+	if _, err := controllers.SweepUploads(context.Background(), testDB, false); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if controllers.ValidMessageMedia(testDB, sender.ID, attachment.URL) {
+		t.Error("Expected a swept upload to be refused")
+	}
+}
@@ -0,0 +1,76 @@
+// controllers/test/wsTicketController_test.go
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+)
+
+// TestIssueAndConsumeWSTicket tests that a ticket resolves to its user exactly once
+func TestIssueAndConsumeWSTicket(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+
+	ticket, expiresAt, err := controllers.IssueWSTicket(testDB, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to issue websocket ticket: %v", err)
+	}
+	if ticket == "" {
+		t.Fatal("Expected non-empty ticket")
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Errorf("Expected ticket expiry in the future, got %v", expiresAt)
+	}
+
+	userID, err := controllers.ConsumeWSTicket(testDB, ticket)
+	if err != nil {
+		t.Fatalf("Failed to consume websocket ticket: %v", err)
+	}
+	if userID != user.ID {
+		t.Errorf("Expected ticket for user %d, got %d", user.ID, userID)
+	}
+
+	// A second redemption must fail
+	if _, err := controllers.ConsumeWSTicket(testDB, ticket); err != controllers.ErrInvalidWSTicket {
+		t.Errorf("Expected ErrInvalidWSTicket on reuse, got %v", err)
+	}
+
+	// Unknown and empty tickets are rejected
+	if _, err := controllers.ConsumeWSTicket(testDB, "does-not-exist"); err != controllers.ErrInvalidWSTicket {
+		t.Errorf("Expected ErrInvalidWSTicket for unknown ticket, got %v", err)
+	}
+	if _, err := controllers.ConsumeWSTicket(testDB, ""); err != controllers.ErrInvalidWSTicket {
+		t.Errorf("Expected ErrInvalidWSTicket for empty ticket, got %v", err)
+	}
+
+	// Invalid users cannot be issued tickets
+	if _, _, err := controllers.IssueWSTicket(testDB, 0); err == nil {
+		t.Error("Expected error when issuing ticket for invalid user ID")
+	}
+}
+
+// TestConsumeExpiredWSTicket tests that expired tickets are rejected and removed
+func TestConsumeExpiredWSTicket(t *testing.T) {
+	clearTables()
+
+	user := registerTestUser(t)
+
+	_, err := testDB.Exec("INSERT INTO ws_tickets (ticket, user_id, expires_at) VALUES (?, ?, ?)",
+		"expired-ticket", user.ID, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to insert expired ticket: %v", err)
+	}
+
+	if _, err := controllers.ConsumeWSTicket(testDB, "expired-ticket"); err != controllers.ErrInvalidWSTicket {
+		t.Errorf("Expected ErrInvalidWSTicket for expired ticket, got %v", err)
+	}
+
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM ws_tickets WHERE ticket = ?", "expired-ticket").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected expired ticket to be deleted, found %d rows", count)
+	}
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
+)
+
+// Uploads are stored under the SHA-256 of their contents, so identical files
+// share one blob. The uploads table counts the rows referring to each blob;
+// database triggers keep the counts as rows come and go.
+
+// UploadGracePeriod is how long an upload may go unreferenced before it is
+// deleted. It covers the time between uploading a file and saving the post,
+// avatar or message that uses it.
+var UploadGracePeriod = time.Hour
+
+// UploadSweepInterval is how often the upload sweeper runs
+var UploadSweepInterval = 6 * time.Hour
+
+// Upload sweeper modes, selected with the UPLOAD_SWEEP variable
+const (
+	// UploadSweepOn deletes orphaned uploads
+	UploadSweepOn = "on"
+	// UploadSweepDryRun only logs what the sweeper would delete
+	UploadSweepDryRun = "dry-run"
+	// UploadSweepOff disables the periodic sweeper
+	UploadSweepOff = "off"
+)
+
+// UploadSweepMode returns the configured sweeper mode, UploadSweepOn by
+// default. Unknown values fall back to a dry run rather than delete files.
+func UploadSweepMode() string {
+	switch mode := os.Getenv("UPLOAD_SWEEP"); mode {
+	case "":
+		return UploadSweepOn
+	case UploadSweepOn, UploadSweepDryRun, UploadSweepOff:
+		return mode
+	default:
+		logger.Warning("Unknown UPLOAD_SWEEP %q, sweeping uploads as a dry run", mode)
+		return UploadSweepDryRun
+	}
+}
+
+// uploadLocks serialize storing and deleting a blob, so a blob is never
+// deleted right after an identical upload found it in place
+var uploadLocks [64]sync.Mutex
+
+func uploadLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &uploadLocks[h.Sum32()%uint32(len(uploadLocks))]
+}
+
+// storeBlob stores data under its content hash in the given folder and
+// returns its stored path. Identical data is stored once; uploading it again
+// only marks it as recently used.
+func storeBlob(ctx context.Context, db *sql.DB, folder, prefix, extension string, data []byte, contentType string) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := folder + "/" + prefix + hash + extension
+	storedPath := storage.PathForKey(key)
+
+	lock := uploadLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var stored int
+	err := db.QueryRow("SELECT COUNT(*) FROM uploads WHERE path = ? AND sha256 = ?", storedPath, hash).Scan(&stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up upload: %w", err)
+	}
+	if stored == 0 {
+		if err := blobStore.Put(ctx, key, bytes.NewReader(data), contentType); err != nil {
+			return "", fmt.Errorf("error saving file: %v", err)
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO uploads (path, sha256, mime_type, size, last_used_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			sha256 = excluded.sha256, mime_type = excluded.mime_type,
+			size = excluded.size, last_used_at = excluded.last_used_at
+	`, storedPath, hash, contentType, len(data), time.Now().Unix())
+	if err != nil {
+		return "", fmt.Errorf("failed to record upload: %w", err)
+	}
+	return storedPath, nil
+}
+
+// releaseUploads deletes the blobs of stored paths that no row refers to any
+// more, unless they were uploaded within the grace period; the sweeper takes
+// care of those. Call it once the transaction that dropped the references
+// has committed.
+func releaseUploads(db *sql.DB, paths []string) {
+	cutoff := time.Now().Add(-UploadGracePeriod).Unix()
+	seen := make(map[string]bool)
+	for _, storedPath := range paths {
+		if seen[storedPath] || !strings.HasPrefix(storedPath, storage.URLPrefix) {
+			continue
+		}
+		seen[storedPath] = true
+		key, err := storage.KeyForPath(storedPath)
+		if err != nil {
+			logger.Warning("Not removing %q: %v", storedPath, err)
+			continue
+		}
+		if _, err := deleteOrphanBlob(context.Background(), db, key, cutoff); err != nil {
+			logger.Warning("Failed to remove upload %s: %v", storedPath, err)
+		}
+	}
+}
+
+// deleteOrphanBlob deletes a blob and its uploads row when nothing refers to
+// it and it was last uploaded no later than cutoff. Blobs without a row are
+// deleted too; the caller checks their age. It reports whether the blob was
+// deleted.
+func deleteOrphanBlob(ctx context.Context, db *sql.DB, key string, cutoff int64) (bool, error) {
+	storedPath := storage.PathForKey(key)
+	lock := uploadLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var refCount int
+	var lastUsed int64
+	err := db.QueryRow("SELECT ref_count, last_used_at FROM uploads WHERE path = ?", storedPath).Scan(&refCount, &lastUsed)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return false, fmt.Errorf("failed to look up upload: %w", err)
+	case refCount > 0 || lastUsed > cutoff:
+		return false, nil
+	default:
+		result, err := db.Exec("DELETE FROM uploads WHERE path = ? AND ref_count = 0", storedPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete upload: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return false, nil
+		}
+	}
+
+	if err := blobStore.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SweepUploads finds blobs that no row refers to and that have not been
+// uploaded within the grace period, and deletes them. Reference counts are
+// recounted first. A dry run only reports what would be deleted.
+func SweepUploads(ctx context.Context, db *sql.DB, dryRun bool) (models.UploadSweepReport, error) {
+	report := models.UploadSweepReport{DryRun: dryRun, Orphans: make([]models.OrphanUpload, 0), Missing: make([]string, 0)}
+	now := time.Now()
+	cutoff := now.Add(-UploadGracePeriod)
+
+	type upload struct {
+		refCount int
+		lastUsed int64
+		listed   bool
+	}
+	uploads := make(map[string]*upload)
+
+	// A dry run recounts in a transaction it rolls back
+	tx, err := db.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := database.CountUploadReferences(tx); err != nil {
+		return report, fmt.Errorf("failed to count upload references: %w", err)
+	}
+	rows, err := tx.Query("SELECT path, ref_count, last_used_at FROM uploads")
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch uploads: %w", err)
+	}
+	for rows.Next() {
+		var storedPath string
+		var u upload
+		if err := rows.Scan(&storedPath, &u.refCount, &u.lastUsed); err != nil {
+			rows.Close()
+			return report, fmt.Errorf("failed to scan upload: %w", err)
+		}
+		uploads[storedPath] = &u
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to iterate uploads: %w", err)
+	}
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			return report, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+	tx.Rollback()
+
+	err = blobStore.List(ctx, "", func(info storage.BlobInfo) error {
+		report.Scanned++
+		storedPath := storage.PathForKey(info.Key)
+		u := uploads[storedPath]
+		if u != nil {
+			u.listed = true
+			if u.refCount > 0 {
+				report.Referenced++
+				return nil
+			}
+		}
+
+		lastUsed := info.ModTime
+		if u != nil && u.lastUsed > lastUsed.Unix() {
+			lastUsed = time.Unix(u.lastUsed, 0)
+		}
+		if lastUsed.After(cutoff) {
+			report.Pending++
+			return nil
+		}
+
+		if !dryRun {
+			deleted, err := deleteOrphanBlob(ctx, db, info.Key, cutoff.Unix())
+			if err != nil {
+				logger.Warning("Failed to remove orphaned upload %s: %v", storedPath, err)
+				return nil
+			}
+			if !deleted {
+				return nil
+			}
+		}
+		report.Orphans = append(report.Orphans, models.OrphanUpload{Path: storedPath, Size: info.Size, LastUsed: lastUsed})
+		report.OrphanBytes += info.Size
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	// Rows whose blob is gone: referenced ones point at missing files, the
+	// others are dropped
+	for storedPath, u := range uploads {
+		if u.listed {
+			continue
+		}
+		if u.refCount > 0 {
+			report.Missing = append(report.Missing, storedPath)
+			continue
+		}
+		if !dryRun && u.lastUsed <= cutoff.Unix() {
+			_, err := db.Exec("DELETE FROM uploads WHERE path = ? AND ref_count = 0 AND last_used_at <= ?", storedPath, cutoff.Unix())
+			if err != nil {
+				logger.Warning("Failed to drop upload row %s: %v", storedPath, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// SweepUploadsPeriodically runs the upload sweeper every UploadSweepInterval
+// until ctx is cancelled, logging what it finds
+func SweepUploadsPeriodically(ctx context.Context, db *sql.DB, dryRun bool) {
+	sweep := func() {
+		report, err := SweepUploads(ctx, db, dryRun)
+		if err != nil {
+			logger.Error("Failed to sweep uploads: %v", err)
+			return
+		}
+		verb := "Deleted"
+		if dryRun {
+			verb = "Would delete"
+		}
+		logger.Info("%s %d orphaned uploads (%d bytes) of %d; %d pending, %d referenced files missing",
+			verb, len(report.Orphans), report.OrphanBytes, report.Scanned, report.Pending, len(report.Missing))
+		if dryRun {
+			for _, orphan := range report.Orphans {
+				logger.Info("Orphaned upload %s (%d bytes, last used %s)", orphan.Path, orphan.Size, orphan.LastUsed.Format(time.RFC3339))
+			}
+		}
+	}
+
+	logger.Info("Running initial sweep of orphaned uploads...")
+	sweep()
+
+	ticker := time.NewTicker(UploadSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping upload sweeper...")
+			return
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
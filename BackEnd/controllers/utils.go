@@ -1,16 +1,85 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"path"
 	"strings"
-	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/imaging"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
+)
+
+// ErrInvalidUpload wraps upload errors caused by the file itself
+var ErrInvalidUpload = errors.New("invalid upload")
+
+// MaxUploadSize is the largest file a form upload takes; resumable uploads
+// take up to MaxResumableUploadSize
+const MaxUploadSize = 20 << 20
+
+// allowedUploadTypes maps the accepted content types to file extensions
+var allowedUploadTypes = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/svg+xml": ".svg",
+}
+
+// SVG upload modes, selected with the UPLOAD_SVG_MODE variable. SVG uploads
+// are always sanitized; the mode decides how they are served.
+const (
+	// SVGModeSanitize serves sanitized SVG inline, like other images
+	SVGModeSanitize = "sanitize"
+	// SVGModeAttachment serves sanitized SVG as a download only
+	SVGModeAttachment = "attachment"
+	// SVGModeReject refuses SVG uploads
+	SVGModeReject = "reject"
+)
+
+// SVGUploadMode returns the configured SVG upload mode. It defaults to
+// SVGModeSanitize; unknown values reject SVG rather than guess.
+func SVGUploadMode() string {
+	switch mode := os.Getenv("UPLOAD_SVG_MODE"); mode {
+	case "":
+		return SVGModeSanitize
+	case SVGModeSanitize, SVGModeAttachment, SVGModeReject:
+		return mode
+	default:
+		logger.Warning("Unknown UPLOAD_SVG_MODE %q, rejecting SVG uploads", mode)
+		return SVGModeReject
+	}
+}
+
+// Upload folders, the first part of a blob key
+const (
+	UploadFolderPosts    = "posts"
+	UploadFolderAvatars  = "avatars"
+	UploadFolderMessages = "messages"
+	UploadFolderComments = "comments"
 )
 
-func UploadFile(r *http.Request, fieldName string, userID int) (string, error) {
+// blobStore holds every uploaded file; main replaces it from the
+// environment
+var blobStore storage.BlobStore = storage.NewLocalStore("uploads", nil)
+
+// SetBlobStore selects the store uploads are kept in
+func SetBlobStore(store storage.BlobStore) {
+	blobStore = store
+}
+
+// Blobs returns the store uploads are kept in
+func Blobs() storage.BlobStore {
+	return blobStore
+}
+
+func UploadFile(db *sql.DB, r *http.Request, fieldName string, userID int) (string, error) {
 	file, header, err := r.FormFile(fieldName)
 	if err != nil {
 		if err == http.ErrMissingFile {
@@ -20,75 +89,157 @@ func UploadFile(r *http.Request, fieldName string, userID int) (string, error) {
 	}
 	defer file.Close()
 
-	// Check file size (20MB limit)
-	if header.Size > 20 * 1024 * 1024 { 
-		return "", fmt.Errorf("file size exceeds 20MB limit")
+	attachment, err := saveUploadedFile(r.Context(), db, file, header, UploadFolderPosts, userID)
+	if err != nil {
+		return "", err
+	}
+	return attachment.URL, nil
+}
+
+// preparedUpload is an uploaded image that passed the checks, re-encoded
+// and with its scaled down variants, ready to be stored
+type preparedUpload struct {
+	contentType string
+	extension   string
+	original    imaging.Image
+	// variants holds the variants smaller than the original, by name
+	variants map[string]imaging.Image
+}
+
+// saveUploadedFile checks, processes and stores an uploaded image in the
+// given upload folder
+func saveUploadedFile(ctx context.Context, db *sql.DB, file multipart.File, header *multipart.FileHeader, folder string, userID int) (models.Attachment, error) {
+	upload, err := prepareUpload(file, header.Size, MaxUploadSize)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	return upload.store(ctx, db, folder, "")
+}
+
+// prepareUpload checks the type and size of an uploaded image and processes
+// it; nothing is stored yet
+func prepareUpload(file io.ReadSeeker, size, maxSize int64) (*preparedUpload, error) {
+	// Check file size
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: file size exceeds %dMB limit", ErrInvalidUpload, maxSize>>20)
 	}
 
 	// Read first 512 bytes to detect content type
 	buff := make([]byte, 512)
-	_, err = file.Read(buff)
-	if err != nil {
-		return "", fmt.Errorf("error reading file header: %v", err)
+	n, err := file.Read(buff)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading file header: %v", err)
 	}
 
 	// Reset file pointer
 	file.Seek(0, 0)
 
 	// Check file type
-	contentType := http.DetectContentType(buff)
-	allowedTypes := map[string]string{
-		"image/jpeg":    ".jpg",
-		"image/png":     ".png",
-		"image/gif":     ".gif",
-		"image/svg+xml": ".svg",
+	contentType := http.DetectContentType(buff[:n])
+	svgMode := SVGUploadMode()
+
+	// SVG is XML and sniffs as text; it is only kept as a sanitized document
+	var svg []byte
+	if strings.HasPrefix(contentType, "text/") && svgMode != SVGModeReject {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %v", err)
+		}
+		if svg, err = imaging.SanitizeSVG(data); err == nil {
+			contentType = "image/svg+xml"
+		}
 	}
 
-	extension, allowed := allowedTypes[contentType]
-	if !allowed {
-		return "", fmt.Errorf("invalid file type. Only JPEG, PNG, GIF and SVG files are allowed")
+	extension, allowed := allowedUploadTypes[contentType]
+	if !allowed || (contentType == "image/svg+xml" && svg == nil) {
+		if svgMode == SVGModeReject {
+			return nil, fmt.Errorf("%w: invalid file type. Only JPEG, PNG and GIF files are allowed", ErrInvalidUpload)
+		}
+		return nil, fmt.Errorf("%w: invalid file type. Only JPEG, PNG, GIF and SVG files are allowed", ErrInvalidUpload)
 	}
 
-	// Create unique filename
-	filename := fmt.Sprintf("%d_%s%s", userID, time.Now().Format("20060102150405"), extension)
-	uploadDir := "uploads/posts" // Configure your upload directory
-
-	// Ensure upload directory exists
-	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
-		return "", fmt.Errorf("error creating upload directory: %v", err)
+	upload := &preparedUpload{contentType: contentType, extension: extension}
+	if contentType == "image/svg+xml" {
+		upload.original = imaging.Image{Data: svg}
+		return upload, nil
 	}
 
-	// Create new file
-	filepath := path.Join(uploadDir, filename)
-	dst, err := os.Create(filepath)
+	// Raster images are re-encoded, which drops their metadata, and scaled
+	// down
+	result, err := imaging.Process(file)
 	if err != nil {
-		return "", fmt.Errorf("error creating file: %v", err)
+		if errors.Is(err, imaging.ErrInvalidImage) || errors.Is(err, imaging.ErrImageTooLarge) ||
+			errors.Is(err, imaging.ErrUnsupportedFormat) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+		}
+		return nil, fmt.Errorf("error processing image: %v", err)
 	}
-	defer dst.Close()
+	upload.original = result.Original
+	upload.variants = result.Variants
+	return upload, nil
+}
 
-	// Copy file contents
-	if _, err = io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("error saving file: %v", err)
+// store stores the original and its variants. The returned attachment
+// describes the stored files; its URLs are stored paths, see
+// storage.PathForKey, and variants the original stands in for share its path.
+func (u *preparedUpload) store(ctx context.Context, db *sql.DB, folder, prefix string) (models.Attachment, error) {
+	attachment, err := u.storeImage(ctx, db, folder, prefix, u.original)
+	if err != nil {
+		return attachment, err
 	}
-
-	return filepath, nil
+	for _, variant := range imaging.Variants {
+		img, ok := u.variants[variant.Name]
+		if !ok {
+			continue
+		}
+		stored, err := storeBlob(ctx, db, folder, prefix, u.extension, img.Data, u.contentType)
+		if err != nil {
+			return models.Attachment{}, err
+		}
+		switch variant.Name {
+		case imaging.VariantMedium:
+			attachment.MediumURL = stored
+		case imaging.VariantThumbnail:
+			attachment.ThumbnailURL = stored
+		}
+	}
+	return attachment, nil
 }
 
-func RemoveImages(imagePaths []string) error {
-	for _, imagePath := range imagePaths {
-		// Remove the "uploads/" prefix if it exists in the imagePath
-		cleanedPath := strings.TrimPrefix(imagePath, "/")
+// storeVariant stores a single file: the named variant, or the original when
+// it is small enough to stand in for it. The attachment describes that file.
+func (u *preparedUpload) storeVariant(ctx context.Context, db *sql.DB, folder, prefix, variant string) (models.Attachment, error) {
+	img, ok := u.variants[variant]
+	if !ok {
+		img = u.original
+	}
+	return u.storeImage(ctx, db, folder, prefix, img)
+}
 
-		// Check if the file exists before attempting to delete it
-		if _, err := os.Stat(cleanedPath); os.IsNotExist(err) {
-			return nil
-		}
+func (u *preparedUpload) storeImage(ctx context.Context, db *sql.DB, folder, prefix string, img imaging.Image) (models.Attachment, error) {
+	stored, err := storeBlob(ctx, db, folder, prefix, u.extension, img.Data, u.contentType)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	return models.Attachment{
+		URL:          stored,
+		MediumURL:    stored,
+		ThumbnailURL: stored,
+		MimeType:     u.contentType,
+		Width:        img.Width,
+		Height:       img.Height,
+		Size:         int64(len(img.Data)),
+	}, nil
+}
 
-		// Delete the file
-		if err := os.Remove(cleanedPath); err != nil {
-			return fmt.Errorf("failed to delete image file %s: %w", imagePath, err)
+// attachmentFiles returns the stored files of an attachment: the original
+// and whichever variants have their own file
+func attachmentFiles(attachment models.Attachment) []string {
+	files := []string{attachment.URL}
+	for _, variant := range []string{attachment.MediumURL, attachment.ThumbnailURL} {
+		if variant != "" && variant != attachment.URL {
+			files = append(files, variant)
 		}
-
 	}
-	return nil
+	return files
 }
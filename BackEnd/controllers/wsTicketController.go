@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// WSTicketTTL is how long a WebSocket connection ticket stays valid
+const WSTicketTTL = 30 * time.Second
+
+// ErrInvalidWSTicket is returned for unknown, used or expired tickets
+var ErrInvalidWSTicket = errors.New("invalid or expired websocket ticket")
+
+// IssueWSTicket creates a short-lived, single-use ticket that lets userID
+// open a WebSocket connection without relying on the session cookie
+func IssueWSTicket(db *sql.DB, userID int) (string, time.Time, error) {
+	if userID <= 0 {
+		return "", time.Time{}, errors.New("invalid user ID")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(WSTicketTTL)
+
+	_, err := db.Exec("INSERT INTO ws_tickets (ticket, user_id, expires_at) VALUES (?, ?, ?)",
+		ticket, userID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return ticket, expiresAt, nil
+}
+
+// ConsumeWSTicket redeems a ticket and returns the user it was issued to.
+// The ticket is deleted whether or not it is still valid.
+func ConsumeWSTicket(db *sql.DB, ticket string) (int, error) {
+	if ticket == "" {
+		return 0, ErrInvalidWSTicket
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var expiresAt time.Time
+	err = tx.QueryRow("SELECT user_id, expires_at FROM ws_tickets WHERE ticket = ?", ticket).
+		Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidWSTicket
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM ws_tickets WHERE ticket = ?", ticket); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return 0, ErrInvalidWSTicket
+	}
+	return userID, nil
+}
+
+// CleanupExpiredWSTickets periodically removes tickets that were never redeemed
+func CleanupExpiredWSTickets(ctx context.Context, db *sql.DB) {
+	logger.Info("Running initial cleanup of expired websocket tickets...")
+	_, err := db.Exec("DELETE FROM ws_tickets WHERE expires_at < ?", time.Now())
+	if err != nil {
+		log.Printf("Failed to clean up expired websocket tickets: %v\n", err)
+	}
+
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopping websocket ticket cleanup task...")
+			return
+		case <-ticker.C:
+			_, err := db.Exec("DELETE FROM ws_tickets WHERE expires_at < ?", time.Now())
+			if err != nil {
+				log.Printf("Failed to clean up expired websocket tickets: %v\n", err)
+			}
+		}
+	}
+}
@@ -3,10 +3,13 @@ package database
 
 import (
 	"database/sql"
+	"mime"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/richtext"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -62,6 +65,8 @@ func Init(env string) (*sql.DB, error) {
             password TEXT NOT NULL,
             bio TEXT DEFAULT '',
             avatar_url TEXT DEFAULT '',
+            role TEXT NOT NULL DEFAULT 'user' CHECK(role IN ('user', 'moderator', 'admin')),
+            suspended_until DATETIME DEFAULT NULL,
             created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
             updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
         );
@@ -78,8 +83,11 @@ func Init(env string) (*sql.DB, error) {
             sender_id INTEGER NOT NULL,
             receiver_id INTEGER NOT NULL,
             content TEXT NOT NULL,
+            content_html TEXT,
+            media_url TEXT DEFAULT NULL,
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             read_at TIMESTAMP,
+            hidden BOOLEAN NOT NULL DEFAULT 0,
             FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
             FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
         );
@@ -98,6 +106,7 @@ func Init(env string) (*sql.DB, error) {
             is_online BOOLEAN DEFAULT false,
             last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            notification_preferences TEXT DEFAULT 'all',
             FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
         );
     `)
@@ -124,10 +133,27 @@ func Init(env string) (*sql.DB, error) {
             dislikes INTEGER DEFAULT 0,
             user_vote TEXT,
             content TEXT NOT NULL,
+            content_html TEXT,
             image_url TEXT,
+            comment_count INTEGER NOT NULL DEFAULT 0,
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+            edited_at DATETIME DEFAULT NULL,
+            status TEXT NOT NULL DEFAULT 'published' CHECK(status IN ('draft', 'scheduled', 'published')),
+            publish_at DATETIME DEFAULT NULL,
+            hot_score REAL NOT NULL DEFAULT 0,
+            top_score INTEGER NOT NULL DEFAULT 0,
+            controversy_score REAL NOT NULL DEFAULT 0,
+            view_count INTEGER NOT NULL DEFAULT 0,
+            locked BOOLEAN NOT NULL DEFAULT 0,
+            is_announcement BOOLEAN NOT NULL DEFAULT 0,
+            hidden BOOLEAN NOT NULL DEFAULT 0,
             FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
         );
+        CREATE INDEX IF NOT EXISTS idx_posts_timestamp ON posts(timestamp DESC, id DESC);
+        CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at);
+        CREATE INDEX IF NOT EXISTS idx_posts_hot ON posts(status, hot_score DESC, id DESC);
+        CREATE INDEX IF NOT EXISTS idx_posts_top ON posts(status, top_score DESC, id DESC);
+        CREATE INDEX IF NOT EXISTS idx_posts_controversial ON posts(status, controversy_score DESC, id DESC);
     `)
 	if err != nil {
 		logger.Error("Failed to create posts table: %v", err)
@@ -143,20 +169,40 @@ func Init(env string) (*sql.DB, error) {
             parent_id INTEGER DEFAULT NULL,
             author TEXT NOT NULL,
             content TEXT NOT NULL,
+            content_html TEXT,
             likes INTEGER DEFAULT 0,
             dislikes INTEGER DEFAULT 0,
             user_vote TEXT,
             timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+            media_path TEXT DEFAULT NULL,
+            hidden BOOLEAN NOT NULL DEFAULT 0,
             FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
             FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
             FOREIGN KEY (parent_id) REFERENCES comments (id) ON DELETE CASCADE
         );
+        CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
     `)
 	if err != nil {
 		logger.Error("Failed to create comments table: %v", err)
 		return nil, err
 	}
 
+	// Keep posts.comment_count in step with the comments table
+	_, err = DB.Exec(`
+        CREATE TRIGGER IF NOT EXISTS trg_comments_count_insert AFTER INSERT ON comments
+        BEGIN
+            UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_comments_count_delete AFTER DELETE ON comments
+        BEGIN
+            UPDATE posts SET comment_count = MAX(comment_count - 1, 0) WHERE id = OLD.post_id;
+        END;
+    `)
+	if err != nil {
+		logger.Error("Failed to create comment count triggers: %v", err)
+		return nil, err
+	}
+
 	// Create Sessions table
 	_, err = DB.Exec(`
         CREATE TABLE IF NOT EXISTS sessions (
@@ -186,6 +232,45 @@ func Init(env string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	// Create WebSocket Tickets table
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS ws_tickets (
+            ticket TEXT PRIMARY KEY,
+            user_id INTEGER NOT NULL,
+            expires_at DATETIME NOT NULL,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+    `)
+	if err != nil {
+		logger.Error("Failed to create ws_tickets table: %v", err)
+		return nil, err
+	}
+
+	// Create Announcements tables
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS announcements (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            author_id INTEGER NOT NULL,
+            content TEXT NOT NULL,
+            severity TEXT NOT NULL DEFAULT 'info' CHECK(severity IN ('info', 'warning', 'critical')),
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            expires_at DATETIME NOT NULL,
+            FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_announcements_expires_at ON announcements(expires_at);
+        CREATE TABLE IF NOT EXISTS announcement_recipients (
+            announcement_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            PRIMARY KEY (announcement_id, user_id),
+            FOREIGN KEY (announcement_id) REFERENCES announcements (id) ON DELETE CASCADE,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+    `)
+	if err != nil {
+		logger.Error("Failed to create announcements tables: %v", err)
+		return nil, err
+	}
+
 	// Create Likes table
 	_, err = DB.Exec(`
         CREATE TABLE IF NOT EXISTS likes (
@@ -193,6 +278,7 @@ func Init(env string) (*sql.DB, error) {
             post_id INTEGER NOT NULL,
             user_id INTEGER NOT NULL,
             user_vote TEXT CHECK(user_vote IN ('like', 'dislike')),
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
             FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
             FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
         );
@@ -220,19 +306,648 @@ func Init(env string) (*sql.DB, error) {
 		return nil, err
 	}
 
+	// Create Post Revisions table
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS post_revisions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            post_id INTEGER NOT NULL,
+            revision INTEGER NOT NULL,
+            editor_id INTEGER NOT NULL,
+            title TEXT NOT NULL,
+            content TEXT NOT NULL,
+            category TEXT NOT NULL,
+            image_url TEXT,
+            rolled_back_from INTEGER DEFAULT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (post_id, revision),
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+            FOREIGN KEY (editor_id) REFERENCES users (id)
+        );
+    `)
+	if err != nil {
+		logger.Error("Failed to create post_revisions table: %v", err)
+		return nil, err
+	}
+
+	// Create Categories and Post Categories tables
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS categories (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            slug TEXT NOT NULL UNIQUE,
+            name TEXT NOT NULL,
+            description TEXT NOT NULL DEFAULT '',
+            position INTEGER NOT NULL DEFAULT 0,
+            archived BOOLEAN NOT NULL DEFAULT false,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE TABLE IF NOT EXISTS post_categories (
+            post_id INTEGER NOT NULL,
+            category_id INTEGER NOT NULL,
+            PRIMARY KEY (post_id, category_id),
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+            FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_post_categories_category ON post_categories(category_id, post_id);
+    `)
+	if err != nil {
+		logger.Error("Failed to create categories tables: %v", err)
+		return nil, err
+	}
+
+	// Create Mentions and Notifications tables
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS mentions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            source_type TEXT NOT NULL CHECK(source_type IN ('post', 'comment', 'message')),
+            source_id INTEGER NOT NULL,
+            mentioned_user_id INTEGER NOT NULL,
+            author_id INTEGER NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (source_type, source_id, mentioned_user_id),
+            FOREIGN KEY (mentioned_user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_mentions_user ON mentions(mentioned_user_id, created_at DESC);
+        CREATE TABLE IF NOT EXISTS notifications (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id INTEGER NOT NULL,
+            type TEXT NOT NULL,
+            actor_id INTEGER NOT NULL,
+            source_type TEXT NOT NULL,
+            source_id INTEGER NOT NULL,
+            post_id INTEGER DEFAULT NULL,
+            excerpt TEXT NOT NULL DEFAULT '',
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            read_at DATETIME DEFAULT NULL,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, id DESC);
+    `)
+	if err != nil {
+		logger.Error("Failed to create mentions tables: %v", err)
+		return nil, err
+	}
+
+	// Drop mentions and their notifications along with the content they
+	// point at
+	_, err = DB.Exec(`
+        CREATE TRIGGER IF NOT EXISTS trg_posts_mentions_delete AFTER DELETE ON posts
+        BEGIN
+            DELETE FROM mentions WHERE source_type = 'post' AND source_id = OLD.id;
+            DELETE FROM notifications WHERE source_type = 'post' AND source_id = OLD.id;
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_comments_mentions_delete AFTER DELETE ON comments
+        BEGIN
+            DELETE FROM mentions WHERE source_type = 'comment' AND source_id = OLD.id;
+            DELETE FROM notifications WHERE source_type = 'comment' AND source_id = OLD.id;
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_messages_mentions_delete AFTER DELETE ON messages
+        BEGIN
+            DELETE FROM mentions WHERE source_type = 'message' AND source_id = OLD.id;
+            DELETE FROM notifications WHERE source_type = 'message' AND source_id = OLD.id;
+        END;
+    `)
+	if err != nil {
+		logger.Error("Failed to create mention cleanup triggers: %v", err)
+		return nil, err
+	}
+
+	// Create Bookmarks tables; bookmarks are private to their owner and
+	// disappear with the post or comment they point at
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS bookmark_folders (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id INTEGER NOT NULL,
+            name TEXT NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (user_id, name),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE TABLE IF NOT EXISTS bookmarks (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id INTEGER NOT NULL,
+            target_type TEXT NOT NULL CHECK(target_type IN ('post', 'comment')),
+            target_id INTEGER NOT NULL,
+            folder_id INTEGER DEFAULT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (user_id, target_type, target_id),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (folder_id) REFERENCES bookmark_folders (id) ON DELETE SET NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_bookmarks_user ON bookmarks(user_id, id DESC);
+        CREATE INDEX IF NOT EXISTS idx_bookmarks_target ON bookmarks(target_type, target_id);
+        CREATE TRIGGER IF NOT EXISTS trg_posts_bookmarks_delete AFTER DELETE ON posts
+        BEGIN
+            DELETE FROM bookmarks WHERE target_type = 'post' AND target_id = OLD.id;
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_comments_bookmarks_delete AFTER DELETE ON comments
+        BEGIN
+            DELETE FROM bookmarks WHERE target_type = 'comment' AND target_id = OLD.id;
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_bookmark_folders_delete AFTER DELETE ON bookmark_folders
+        BEGIN
+            UPDATE bookmarks SET folder_id = NULL WHERE folder_id = OLD.id;
+        END;
+    `)
+	if err != nil {
+		logger.Error("Failed to create bookmarks tables: %v", err)
+		return nil, err
+	}
+
+	// Create Polls tables. A ballot row records that a user voted, so a
+	// second vote fails on its primary key; poll_votes holds the options
+	// the ballot picked.
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS polls (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            post_id INTEGER NOT NULL UNIQUE,
+            question TEXT NOT NULL,
+            multiple_choice BOOLEAN NOT NULL DEFAULT 0,
+            anonymous BOOLEAN NOT NULL DEFAULT 0,
+            closes_at DATETIME DEFAULT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+        );
+        CREATE TABLE IF NOT EXISTS poll_options (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            poll_id INTEGER NOT NULL,
+            position INTEGER NOT NULL,
+            label TEXT NOT NULL,
+            FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_poll_options_poll ON poll_options(poll_id, position);
+        CREATE TABLE IF NOT EXISTS poll_ballots (
+            poll_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (poll_id, user_id),
+            FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE TABLE IF NOT EXISTS poll_votes (
+            poll_id INTEGER NOT NULL,
+            option_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            PRIMARY KEY (option_id, user_id),
+            FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE,
+            FOREIGN KEY (option_id) REFERENCES poll_options (id) ON DELETE CASCADE,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_poll_votes_poll ON poll_votes(poll_id);
+        CREATE TRIGGER IF NOT EXISTS trg_posts_polls_delete AFTER DELETE ON posts
+        BEGIN
+            DELETE FROM poll_votes WHERE poll_id IN (SELECT id FROM polls WHERE post_id = OLD.id);
+            DELETE FROM poll_ballots WHERE poll_id IN (SELECT id FROM polls WHERE post_id = OLD.id);
+            DELETE FROM poll_options WHERE poll_id IN (SELECT id FROM polls WHERE post_id = OLD.id);
+            DELETE FROM polls WHERE post_id = OLD.id;
+        END;
+    `)
+	if err != nil {
+		logger.Error("Failed to create polls tables: %v", err)
+		return nil, err
+	}
+
+	// Create Post Attachments table; posts.image_url keeps the first
+	// attachment for older clients
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS post_attachments (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            post_id INTEGER NOT NULL,
+            position INTEGER NOT NULL DEFAULT 0,
+            path TEXT NOT NULL,
+            alt_text TEXT NOT NULL DEFAULT '',
+            mime_type TEXT NOT NULL DEFAULT '',
+            width INTEGER NOT NULL DEFAULT 0,
+            height INTEGER NOT NULL DEFAULT 0,
+            size INTEGER NOT NULL DEFAULT 0,
+            medium_path TEXT NOT NULL DEFAULT '',
+            thumbnail_path TEXT NOT NULL DEFAULT '',
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_post_attachments_post ON post_attachments(post_id, position);
+    `)
+	if err != nil {
+		logger.Error("Failed to create post attachments table: %v", err)
+		return nil, err
+	}
+
+	// Create Resumable Uploads table; incomplete data is kept on disk until
+	// the upload completes, then the file is stored like any other upload
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS resumable_uploads (
+            id TEXT PRIMARY KEY,
+            user_id INTEGER NOT NULL,
+            target TEXT NOT NULL CHECK(target IN ('post', 'comment', 'message')),
+            filename TEXT NOT NULL DEFAULT '',
+            alt_text TEXT NOT NULL DEFAULT '',
+            length INTEGER NOT NULL,
+            upload_offset INTEGER NOT NULL DEFAULT 0,
+            path TEXT NOT NULL DEFAULT '',
+            medium_path TEXT NOT NULL DEFAULT '',
+            thumbnail_path TEXT NOT NULL DEFAULT '',
+            mime_type TEXT NOT NULL DEFAULT '',
+            width INTEGER NOT NULL DEFAULT 0,
+            height INTEGER NOT NULL DEFAULT 0,
+            size INTEGER NOT NULL DEFAULT 0,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            completed_at DATETIME DEFAULT NULL,
+            expires_at DATETIME NOT NULL,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires ON resumable_uploads(expires_at);
+    `)
+	if err != nil {
+		logger.Error("Failed to create resumable uploads table: %v", err)
+		return nil, err
+	}
+
+	// Create Post Views table; views are counted per post and UTC day for
+	// the author's analytics, posts.view_count keeps the running total
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS post_views (
+            post_id INTEGER NOT NULL,
+            day TEXT NOT NULL,
+            views INTEGER NOT NULL DEFAULT 0,
+            PRIMARY KEY (post_id, day),
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+        );
+    `)
+	if err != nil {
+		logger.Error("Failed to create post views table: %v", err)
+		return nil, err
+	}
+
+	// Create Post Pins table; category_id 0 pins a post to the main feed,
+	// otherwise to that category's feed. Lower positions come first.
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS post_pins (
+            post_id INTEGER NOT NULL,
+            category_id INTEGER NOT NULL DEFAULT 0,
+            position INTEGER NOT NULL DEFAULT 0,
+            pinned_by INTEGER NOT NULL,
+            pinned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (post_id, category_id),
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_post_pins_scope ON post_pins(category_id, position);
+    `)
+	if err != nil {
+		logger.Error("Failed to create post pins table: %v", err)
+		return nil, err
+	}
+
+	// Create Moderation Log table; targets are not foreign keys so entries
+	// outlive the content they describe
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS moderation_log (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            moderator_id INTEGER NOT NULL,
+            action TEXT NOT NULL,
+            target_type TEXT NOT NULL,
+            target_id INTEGER NOT NULL,
+            details TEXT NOT NULL DEFAULT '',
+            reason TEXT NOT NULL DEFAULT '',
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS idx_moderation_log_target ON moderation_log(target_type, target_id);
+    `)
+	if err != nil {
+		logger.Error("Failed to create moderation log table: %v", err)
+		return nil, err
+	}
+
+	// Moderation log entries are written once and never changed or removed
+	_, err = DB.Exec(`
+        CREATE TRIGGER IF NOT EXISTS trg_moderation_log_no_update BEFORE UPDATE ON moderation_log
+        BEGIN
+            SELECT RAISE(ABORT, 'moderation log entries cannot be changed');
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_moderation_log_no_delete BEFORE DELETE ON moderation_log
+        BEGIN
+            SELECT RAISE(ABORT, 'moderation log entries cannot be deleted');
+        END;
+    `)
+	if err != nil {
+		logger.Error("Failed to create moderation log triggers: %v", err)
+		return nil, err
+	}
+
+	// Create Reports table; each user reports a post, comment or message at
+	// most once. target_author_id is kept so the author can still be warned
+	// or suspended after the content is gone.
+	_, err = DB.Exec(`
+        CREATE TABLE IF NOT EXISTS reports (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            reporter_id INTEGER NOT NULL,
+            target_type TEXT NOT NULL CHECK(target_type IN ('post', 'comment', 'message')),
+            target_id INTEGER NOT NULL,
+            target_author_id INTEGER NOT NULL,
+            reason TEXT NOT NULL CHECK(reason IN ('spam', 'harassment', 'hate', 'sexual', 'violence', 'misinformation', 'other')),
+            details TEXT NOT NULL DEFAULT '',
+            status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'resolved')),
+            resolution TEXT NOT NULL DEFAULT '',
+            resolved_by INTEGER DEFAULT NULL,
+            resolved_at DATETIME DEFAULT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (reporter_id, target_type, target_id),
+            FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(status, target_type, target_id);
+    `)
+	if err != nil {
+		logger.Error("Failed to create reports table: %v", err)
+		return nil, err
+	}
+
+	if err := migratePostImages(DB); err != nil {
+		logger.Error("Failed to migrate post images to attachments: %v", err)
+		return nil, err
+	}
+
+	if err := createUploadTracking(DB); err != nil {
+		logger.Error("Failed to create upload tracking: %v", err)
+		return nil, err
+	}
+
+	if err := seedCategories(DB); err != nil {
+		logger.Error("Failed to seed categories: %v", err)
+		return nil, err
+	}
+
+	if err := createSearchIndexes(DB); err != nil {
+		if !strings.Contains(err.Error(), "no such module: fts5") {
+			logger.Error("Failed to create search indexes: %v", err)
+			return nil, err
+		}
+		logger.Warning("SQLite was built without FTS5, search is disabled (build with -tags sqlite_fts5)")
+	}
+
+	if err := renderMissingHTML(DB); err != nil {
+		logger.Error("Failed to render cached content HTML: %v", err)
+		return nil, err
+	}
+
 	return DB, nil
 }
 
+// migratePostImages turns the image_url of posts written before
+// attachments existed into their first attachment
+func migratePostImages(DB *sql.DB) error {
+	rows, err := DB.Query(`
+		SELECT id, image_url FROM posts
+		WHERE image_url IS NOT NULL AND image_url != ''
+		  AND id NOT IN (SELECT post_id FROM post_attachments)
+	`)
+	if err != nil {
+		return err
+	}
+	type image struct {
+		postID int
+		path   string
+	}
+	var images []image
+	for rows.Next() {
+		var img image
+		if err := rows.Scan(&img.postID, &img.path); err != nil {
+			rows.Close()
+			return err
+		}
+		images = append(images, img)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		_, err := DB.Exec(`
+			INSERT INTO post_attachments (post_id, position, path, mime_type, created_at)
+			VALUES (?, 0, ?, ?, CURRENT_TIMESTAMP)
+		`, img.postID, img.path, mime.TypeByExtension(path.Ext(img.path)))
+		if err != nil {
+			return err
+		}
+	}
+	if len(images) > 0 {
+		logger.Info("Migrated %d post images to attachments", len(images))
+	}
+	return nil
+}
+
+// renderMissingHTML fills content_html for rows written before rendered HTML
+// was cached, or by code paths that only store the source
+func renderMissingHTML(DB *sql.DB) error {
+	for _, table := range []string{"posts", "comments", "messages"} {
+		rows, err := DB.Query("SELECT id, content FROM " + table + " WHERE content_html IS NULL")
+		if err != nil {
+			return err
+		}
+		pending := map[int64]string{}
+		for rows.Next() {
+			var id int64
+			var content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return err
+			}
+			pending[id] = richtext.Render(content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		tx, err := DB.Begin()
+		if err != nil {
+			return err
+		}
+		for id, rendered := range pending {
+			if _, err := tx.Exec("UPDATE "+table+" SET content_html = ? WHERE id = ?", rendered, id); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		logger.Info("Rendered cached HTML for %d %s", len(pending), table)
+	}
+	return nil
+}
+
+// createSearchIndexes sets up FTS5 indexes over post titles and bodies and
+// comment bodies. The indexes use external content, so triggers keep them in
+// step with the source tables and a new index is rebuilt from existing rows.
+func createSearchIndexes(DB *sql.DB) error {
+	var existing int
+	err := DB.QueryRow(`
+        SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('posts_fts', 'comments_fts')
+    `).Scan(&existing)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+        CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+            title, content,
+            content = 'posts', content_rowid = 'id',
+            tokenize = 'porter unicode61'
+        );
+        CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+            content,
+            content = 'comments', content_rowid = 'id',
+            tokenize = 'porter unicode61'
+        );
+
+        CREATE TRIGGER IF NOT EXISTS trg_posts_fts_insert AFTER INSERT ON posts
+        BEGIN
+            INSERT INTO posts_fts (rowid, title, content) VALUES (NEW.id, NEW.title, NEW.content);
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_posts_fts_delete AFTER DELETE ON posts
+        BEGIN
+            INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', OLD.id, OLD.title, OLD.content);
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_posts_fts_update AFTER UPDATE OF title, content ON posts
+        BEGIN
+            INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', OLD.id, OLD.title, OLD.content);
+            INSERT INTO posts_fts (rowid, title, content) VALUES (NEW.id, NEW.title, NEW.content);
+        END;
+
+        CREATE TRIGGER IF NOT EXISTS trg_comments_fts_insert AFTER INSERT ON comments
+        BEGIN
+            INSERT INTO comments_fts (rowid, content) VALUES (NEW.id, NEW.content);
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_comments_fts_delete AFTER DELETE ON comments
+        BEGIN
+            INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', OLD.id, OLD.content);
+        END;
+        CREATE TRIGGER IF NOT EXISTS trg_comments_fts_update AFTER UPDATE OF content ON comments
+        BEGIN
+            INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', OLD.id, OLD.content);
+            INSERT INTO comments_fts (rowid, content) VALUES (NEW.id, NEW.content);
+        END;
+    `)
+	if err != nil {
+		return err
+	}
+
+	if existing < 2 {
+		logger.Info("Building search indexes from existing posts and comments")
+		_, err = DB.Exec(`
+            INSERT INTO posts_fts (posts_fts) VALUES ('rebuild');
+            INSERT INTO comments_fts (comments_fts) VALUES ('rebuild');
+        `)
+	}
+	return err
+}
+
+// defaultCategories mirrors the options the post form offered before
+// categories were stored in the database
+var defaultCategories = []struct{ slug, name string }{
+	{"technology", "Technology"},
+	{"programming", "Programming"},
+	{"gaming", "Gaming"},
+	{"science", "Science"},
+	{"movies", "Movies"},
+	{"music", "Music"},
+	{"art", "Art"},
+	{"food", "Food"},
+	{"news", "News"},
+	{"fashion", "Fashion"},
+	{"business", "Business"},
+	{"sports", "Sports"},
+}
+
+// seedCategories inserts the default categories on a fresh database and links
+// posts that only carry the legacy comma-separated category text
+func seedCategories(DB *sql.DB) error {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM categories").Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		for i, c := range defaultCategories {
+			_, err := DB.Exec(`
+				INSERT OR IGNORE INTO categories (slug, name, position) VALUES (?, ?, ?)
+			`, c.slug, c.name, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := DB.Exec(`
+        INSERT OR IGNORE INTO post_categories (post_id, category_id)
+        SELECT p.id, c.id
+        FROM posts p
+        JOIN categories c
+          ON ',' || REPLACE(LOWER(p.category), ' ', '') || ',' LIKE '%,' || c.slug || ',%'
+        WHERE NOT EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id)
+    `)
+	return err
+}
+
 // Function to Add Missing Columns for Existing Messages and User Status Tables
 func addMissingColumns(DB *sql.DB) {
 	// Columns to add for messages table
 	messageColumns := map[string]string{
-		"read_at":    "TIMESTAMP DEFAULT NULL",
-		"created_at": "TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
-		"updated_at": "TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
-		"is_deleted": "BOOLEAN DEFAULT false",
-		"reply_to":   "INTEGER DEFAULT NULL",
-		"media_url":  "TEXT DEFAULT NULL",
+		"read_at":      "TIMESTAMP DEFAULT NULL",
+		"created_at":   "TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"updated_at":   "TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"is_deleted":   "BOOLEAN DEFAULT false",
+		"reply_to":     "INTEGER DEFAULT NULL",
+		"media_url":    "TEXT DEFAULT NULL",
+		"content_html": "TEXT DEFAULT NULL",
+		"hidden":       "BOOLEAN NOT NULL DEFAULT 0",
+	}
+
+	// Columns to add for posts table
+	postColumns := map[string]string{
+		"comment_count": "INTEGER NOT NULL DEFAULT 0",
+		"edited_at":     "DATETIME DEFAULT NULL",
+		"status":        "TEXT NOT NULL DEFAULT 'published' CHECK(status IN ('draft', 'scheduled', 'published'))",
+		"publish_at":    "DATETIME DEFAULT NULL",
+		"content_html":  "TEXT DEFAULT NULL",
+		// Ranking scores, see database.UpdatePostScores
+		"hot_score":         "REAL NOT NULL DEFAULT 0",
+		"top_score":         "INTEGER NOT NULL DEFAULT 0",
+		"controversy_score": "REAL NOT NULL DEFAULT 0",
+		"view_count":        "INTEGER NOT NULL DEFAULT 0",
+		// Moderation state, see controllers.ModerationController
+		"locked":          "BOOLEAN NOT NULL DEFAULT 0",
+		"is_announcement": "BOOLEAN NOT NULL DEFAULT 0",
+		"hidden":          "BOOLEAN NOT NULL DEFAULT 0",
+	}
+
+	// Columns to add for likes table; votes cast before created_at existed
+	// have no date and are left out of the per day analytics
+	likeColumns := map[string]string{
+		"created_at": "DATETIME DEFAULT NULL",
+	}
+
+	// Columns to add for comments table
+	commentColumns := map[string]string{
+		"content_html": "TEXT DEFAULT NULL",
+		"media_path":   "TEXT DEFAULT NULL",
+		"hidden":       "BOOLEAN NOT NULL DEFAULT 0",
+	}
+
+	// Columns to add for users table
+	userColumns := map[string]string{
+		"role":            "TEXT NOT NULL DEFAULT 'user' CHECK(role IN ('user', 'moderator', 'admin'))",
+		"suspended_until": "DATETIME DEFAULT NULL",
+	}
+
+	// Columns to add for post_attachments table; an empty variant path means
+	// the original is small enough to stand in for it
+	postAttachmentColumns := map[string]string{
+		"medium_path":    "TEXT NOT NULL DEFAULT ''",
+		"thumbnail_path": "TEXT NOT NULL DEFAULT ''",
 	}
 
 	// Columns to add for user_status table
@@ -254,6 +969,74 @@ func addMissingColumns(DB *sql.DB) {
 		}
 	}
 
+	// Add columns to posts table
+	scoresAdded := false
+	for column, definition := range postColumns {
+		_, err := DB.Exec("ALTER TABLE posts ADD COLUMN " + column + " " + definition)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			logger.Warning("Posts table - Column '%s' already exists or failed to add: %v", column, err)
+		} else if err == nil {
+			if column == "comment_count" {
+				// Backfill the counter for posts created before it existed
+				_, err = DB.Exec("UPDATE posts SET comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id)")
+				if err != nil {
+					logger.Warning("Posts table - Failed to backfill comment_count: %v", err)
+				}
+			}
+			if strings.HasSuffix(column, "_score") {
+				scoresAdded = true
+			}
+			logger.Info("Posts table - Added column '%s' successfully", column)
+		}
+	}
+	// Score posts ranked before the scores existed, once comment_count is
+	// filled in too
+	if scoresAdded {
+		if err := UpdatePostScores(DB); err != nil {
+			logger.Warning("Posts table - Failed to backfill ranking scores: %v", err)
+		}
+	}
+
+	// Add columns to comments table
+	for column, definition := range commentColumns {
+		_, err := DB.Exec("ALTER TABLE comments ADD COLUMN " + column + " " + definition)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			logger.Warning("Comments table - Column '%s' already exists or failed to add: %v", column, err)
+		} else if err == nil {
+			logger.Info("Comments table - Added column '%s' successfully", column)
+		}
+	}
+
+	// Add columns to likes table
+	for column, definition := range likeColumns {
+		_, err := DB.Exec("ALTER TABLE likes ADD COLUMN " + column + " " + definition)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") && !strings.Contains(err.Error(), "no such table") {
+			logger.Warning("Likes table - Column '%s' already exists or failed to add: %v", column, err)
+		} else if err == nil {
+			logger.Info("Likes table - Added column '%s' successfully", column)
+		}
+	}
+
+	// Add columns to users table
+	for column, definition := range userColumns {
+		_, err := DB.Exec("ALTER TABLE users ADD COLUMN " + column + " " + definition)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			logger.Warning("Users table - Column '%s' already exists or failed to add: %v", column, err)
+		} else {
+			logger.Info("Users table - Added column '%s' successfully", column)
+		}
+	}
+
+	// Add columns to post_attachments table
+	for column, definition := range postAttachmentColumns {
+		_, err := DB.Exec("ALTER TABLE post_attachments ADD COLUMN " + column + " " + definition)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") && !strings.Contains(err.Error(), "no such table") {
+			logger.Warning("Post attachments table - Column '%s' already exists or failed to add: %v", column, err)
+		} else if err == nil {
+			logger.Info("Post attachments table - Added column '%s' successfully", column)
+		}
+	}
+
 	// Add columns to user_status table
 	for column, definition := range userStatusColumns {
 		_, err := DB.Exec("ALTER TABLE user_status ADD COLUMN " + column + " " + definition)
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/ranking"
+)
+
+// queryExecer is what both *sql.DB and *sql.Tx offer
+type queryExecer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// UpdatePostScores recomputes the hot, top and controversial scores of the
+// given posts, or of every post when none is given, from their votes,
+// comment count and publish time. Call it whenever one of those changes.
+func UpdatePostScores(db queryExecer, postIDs ...int) error {
+	query := "SELECT id, COALESCE(likes, 0), COALESCE(dislikes, 0), comment_count, timestamp FROM posts"
+	args := make([]any, len(postIDs))
+	if len(postIDs) > 0 {
+		query += " WHERE id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",") + ")"
+		for i, id := range postIDs {
+			args[i] = id
+		}
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch post scores: %w", err)
+	}
+
+	type scores struct {
+		id               int
+		hot, controversy float64
+		top              int
+	}
+	var updates []scores
+	for rows.Next() {
+		var id, likes, dislikes, comments int
+		var published sql.NullTime
+		if err := rows.Scan(&id, &likes, &dislikes, &comments, &published); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan post scores: %w", err)
+		}
+		if !published.Valid {
+			published.Time = time.Now()
+		}
+		updates = append(updates, scores{
+			id:          id,
+			hot:         ranking.Hot(likes, dislikes, comments, published.Time),
+			top:         ranking.Top(likes, dislikes),
+			controversy: ranking.Controversy(likes, dislikes),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate post scores: %w", err)
+	}
+
+	for _, s := range updates {
+		_, err := db.Exec("UPDATE posts SET hot_score = ?, top_score = ?, controversy_score = ? WHERE id = ?",
+			s.hot, s.top, s.controversy, s.id)
+		if err != nil {
+			return fmt.Errorf("failed to update scores of post %d: %w", s.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// uploadReferences lists the columns that hold stored upload paths such as
+// "uploads/posts/<sha256>.png". Every row naming a path counts as one
+// reference to it in uploads.ref_count. Post revisions are left out on
+// purpose: an image removed from a post goes, even if an old revision named
+// it.
+var uploadReferences = []struct{ table, column string }{
+	{"post_attachments", "path"},
+	{"post_attachments", "medium_path"},
+	{"post_attachments", "thumbnail_path"},
+	{"posts", "image_url"},
+	{"users", "avatar_url"},
+	{"messages", "media_url"},
+	{"comments", "media_path"},
+	// Completed resumable uploads keep their files until they expire
+	{"resumable_uploads", "path"},
+	{"resumable_uploads", "medium_path"},
+	{"resumable_uploads", "thumbnail_path"},
+}
+
+// uploadPathPrefix marks column values that are stored uploads rather than
+// external URLs, e.g. avatars from OAuth providers
+const uploadPathPrefix = "uploads/"
+
+// createUploadTracking creates the uploads table and the triggers that keep
+// its reference counts in step with the referencing columns, then counts the
+// references of existing rows
+func createUploadTracking(DB *sql.DB) error {
+	_, err := DB.Exec(`
+        CREATE TABLE IF NOT EXISTS uploads (
+            path TEXT PRIMARY KEY,
+            sha256 TEXT NOT NULL DEFAULT '',
+            mime_type TEXT NOT NULL DEFAULT '',
+            size INTEGER NOT NULL DEFAULT 0,
+            ref_count INTEGER NOT NULL DEFAULT 0,
+            last_used_at INTEGER NOT NULL DEFAULT 0,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS idx_uploads_unreferenced ON uploads(ref_count, last_used_at);
+    `)
+	if err != nil {
+		return err
+	}
+
+	var triggers strings.Builder
+	for _, ref := range uploadReferences {
+		name := "trg_" + ref.table + "_" + ref.column + "_uploads"
+		fmt.Fprintf(&triggers, `
+        CREATE TRIGGER IF NOT EXISTS %[1]s_insert AFTER INSERT ON %[2]s
+        BEGIN
+            %[4]s
+        END;
+        CREATE TRIGGER IF NOT EXISTS %[1]s_delete AFTER DELETE ON %[2]s
+        BEGIN
+            %[5]s
+        END;
+        CREATE TRIGGER IF NOT EXISTS %[1]s_update AFTER UPDATE OF %[3]s ON %[2]s
+        WHEN OLD.%[3]s IS NOT NEW.%[3]s
+        BEGIN
+            %[5]s
+            %[4]s
+        END;
+        `, name, ref.table, ref.column, addUploadReference("NEW."+ref.column), dropUploadReference("OLD."+ref.column))
+	}
+	if _, err := DB.Exec(triggers.String()); err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := CountUploadReferences(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func addUploadReference(value string) string {
+	return fmt.Sprintf(`INSERT INTO uploads (path, ref_count) SELECT %[1]s, 1 WHERE substr(%[1]s, 1, %[2]d) = '%[3]s'
+            ON CONFLICT(path) DO UPDATE SET ref_count = ref_count + 1;`, value, len(uploadPathPrefix), uploadPathPrefix)
+}
+
+func dropUploadReference(value string) string {
+	return fmt.Sprintf(`UPDATE uploads SET ref_count = MAX(ref_count - 1, 0) WHERE path = %s;`, value)
+}
+
+// CountUploadReferences recounts the references of every upload from the
+// referencing columns, adding rows for referenced paths that have none. The
+// triggers keep the counts exact; this repairs counts written before they
+// existed or changed behind their back.
+func CountUploadReferences(tx *sql.Tx) error {
+	selects := make([]string, len(uploadReferences))
+	for i, ref := range uploadReferences {
+		selects[i] = fmt.Sprintf("SELECT %s AS path FROM %s", ref.column, ref.table)
+	}
+	if _, err := tx.Exec("UPDATE uploads SET ref_count = 0 WHERE ref_count != 0"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+        INSERT INTO uploads (path, ref_count)
+        SELECT path, COUNT(*) FROM (`+strings.Join(selects, " UNION ALL ")+`)
+        WHERE substr(path, 1, ?) = ?
+        GROUP BY path
+        ON CONFLICT(path) DO UPDATE SET ref_count = excluded.ref_count;
+    `, len(uploadPathPrefix), uploadPathPrefix)
+	return err
+}
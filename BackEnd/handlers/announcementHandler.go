@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
+)
+
+// CreateAnnouncementHandler stores an announcement and pushes it through the
+// hub to every connected client or to the listed users
+func CreateAnnouncementHandler(ac *controllers.AnnouncementController, hub *websockets.MessageHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(ac.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to send announcements",
+			})
+			return
+		}
+
+		var req models.AnnouncementRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("Failed to decode announcement request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		expiresIn := time.Duration(req.ExpiresInMinutes) * time.Minute
+		if expiresIn <= 0 {
+			expiresIn = time.Hour
+		}
+
+		now := time.Now().UTC()
+		announcement := &models.Announcement{
+			AuthorID:  userID,
+			Content:   req.Content,
+			Severity:  req.Severity,
+			CreatedAt: now,
+			ExpiresAt: now.Add(expiresIn),
+			UserIDs:   req.UserIDs,
+		}
+
+		if err := ac.CreateAnnouncement(announcement); err != nil {
+			logger.Warning("Failed to create announcement: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		hub.Announce(announcement)
+		logger.Info("Announcement %d sent by admin %d", announcement.ID, userID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":       "success",
+			"announcement": announcement,
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -83,6 +84,14 @@ func LoginHandler(ac *controllers.AuthController) http.HandlerFunc {
 
 		// Authenticate user
 		user, err := ac.Login(&req)
+		if errors.Is(err, controllers.ErrAccountSuspended) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Your " + err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			logger.Warning("Login failed: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// BookmarkRequest is the body of a bookmark request; FolderID 0 keeps the
+// bookmark outside any folder
+type BookmarkRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	FolderID   int    `json:"folder_id"`
+}
+
+// BookmarksHandler lists the user's bookmarks on GET, adds or moves one on
+// POST and removes one on DELETE (?type=post&id=1). The list takes limit,
+// before (the nextBefore value of the previous page), type and folder, where
+// folder=none selects bookmarks outside any folder.
+func BookmarksHandler(bc *controllers.BookmarkController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(bc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to manage bookmarks",
+			})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			listBookmarks(w, r, bc, userID)
+		case http.MethodPost:
+			var req BookmarkRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid request format",
+				})
+				return
+			}
+			created, err := bc.AddBookmark(userID, req.TargetType, req.TargetID, req.FolderID)
+			if err != nil {
+				writeBookmarkError(w, err)
+				return
+			}
+			if created {
+				w.WriteHeader(http.StatusCreated)
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":     "success",
+				"bookmarked": true,
+			})
+		case http.MethodDelete:
+			targetID, err := strconv.Atoi(r.URL.Query().Get("id"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid target ID",
+				})
+				return
+			}
+			if err := bc.RemoveBookmark(userID, r.URL.Query().Get("type"), targetID); err != nil {
+				writeBookmarkError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":     "success",
+				"bookmarked": false,
+			})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+		}
+	}
+}
+
+func listBookmarks(w http.ResponseWriter, r *http.Request, bc *controllers.BookmarkController, userID int) {
+	q := controllers.BookmarkQuery{Type: r.URL.Query().Get("type")}
+	var err error
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid limit",
+			})
+			return
+		}
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		if q.Before, err = strconv.Atoi(v); err != nil || q.Before < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid before",
+			})
+			return
+		}
+	}
+	if v := r.URL.Query().Get("folder"); v == "none" {
+		q.FolderID = -1
+	} else if v != "" {
+		if q.FolderID, err = strconv.Atoi(v); err != nil || q.FolderID < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid folder",
+			})
+			return
+		}
+	}
+
+	bookmarks, hasMore, err := bc.GetBookmarks(userID, q)
+	if err != nil {
+		writeBookmarkError(w, err)
+		return
+	}
+
+	nextBefore := 0
+	if hasMore {
+		nextBefore = bookmarks[len(bookmarks)-1].ID
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":     "success",
+		"bookmarks":  bookmarks,
+		"hasMore":    hasMore,
+		"nextBefore": nextBefore,
+	})
+}
+
+// BookmarkFoldersHandler lists the user's bookmark folders on GET and
+// creates one on POST ({"name": "..."})
+func BookmarkFoldersHandler(bc *controllers.BookmarkController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(bc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to manage bookmarks",
+			})
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req struct {
+				Name string `json:"name"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid request format",
+				})
+				return
+			}
+			id, err := bc.CreateFolder(userID, req.Name)
+			if err != nil {
+				writeBookmarkError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{
+				"status": "success",
+				"id":     id,
+			})
+			return
+		}
+
+		folders, err := bc.GetFolders(userID)
+		if err != nil {
+			writeBookmarkError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  "success",
+			"folders": folders,
+		})
+	}
+}
+
+// BookmarkFolderHandler renames a bookmark folder on PUT and deletes it on
+// DELETE; deleting a folder keeps its bookmarks
+func BookmarkFolderHandler(bc *controllers.BookmarkController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(bc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to manage bookmarks",
+			})
+			return
+		}
+
+		folderID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid folder ID",
+			})
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var req struct {
+				Name string `json:"name"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid request format",
+				})
+				return
+			}
+			err = bc.RenameFolder(userID, folderID, req.Name)
+		} else {
+			err = bc.DeleteFolder(userID, folderID)
+		}
+		if err != nil {
+			writeBookmarkError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "success",
+		})
+	}
+}
+
+// writeBookmarkError maps bookmark controller errors to responses
+func writeBookmarkError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to handle bookmarks"
+	switch {
+	case errors.Is(err, controllers.ErrInvalidBookmarkTarget), errors.Is(err, controllers.ErrInvalidBookmarkFolder):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, controllers.ErrBookmarkTargetNotFound), errors.Is(err, controllers.ErrBookmarkNotFound),
+		errors.Is(err, controllers.ErrBookmarkFolderNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, controllers.ErrBookmarkFolderExists):
+		status, message = http.StatusConflict, err.Error()
+	default:
+		logger.Error("Failed to handle bookmarks: %v", err)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
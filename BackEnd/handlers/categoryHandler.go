@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// GetCategoriesHandler lists active categories with their post counts
+func GetCategoriesHandler(cc *controllers.CategoryController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		categories, err := cc.GetCategories(false)
+		if err != nil {
+			logger.Error("Failed to fetch categories: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch categories",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     "success",
+			"categories": categories,
+		})
+	}
+}
+
+// AdminCategoriesHandler lists every category, archived ones included, on GET
+// and creates a category on POST
+func AdminCategoriesHandler(cc *controllers.CategoryController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			categories, err := cc.GetCategories(true)
+			if err != nil {
+				logger.Error("Failed to fetch categories: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Failed to fetch categories",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":     "success",
+				"categories": categories,
+			})
+			return
+		}
+
+		var req models.CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("Failed to decode category request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		var category models.Category
+		if req.Slug != nil {
+			category.Slug = *req.Slug
+		}
+		if req.Name != nil {
+			category.Name = *req.Name
+		}
+		if req.Description != nil {
+			category.Description = *req.Description
+		}
+		if req.Position != nil {
+			category.Position = *req.Position
+		}
+		if req.Archived != nil {
+			category.Archived = *req.Archived
+		}
+
+		if err := cc.CreateCategory(&category); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, controllers.ErrCategoryExists) {
+				status = http.StatusConflict
+			}
+			logger.Warning("Failed to create category: %v", err)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		logger.Info("Category %q created", category.Slug)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":   "success",
+			"category": category,
+		})
+	}
+}
+
+// AdminCategoryHandler updates a category on PUT and deletes it on DELETE
+func AdminCategoryHandler(cc *controllers.CategoryController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid category ID",
+			})
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			err := cc.DeleteCategory(id)
+			if errors.Is(err, controllers.ErrCategoryNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Category not found",
+				})
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to delete category %d: %v", id, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Failed to delete category",
+				})
+				return
+			}
+			logger.Info("Category %d deleted", id)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Category deleted successfully",
+			})
+			return
+		}
+
+		var req models.CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("Failed to decode category request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		category, err := cc.UpdateCategory(id, req)
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, controllers.ErrCategoryNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, controllers.ErrCategoryExists):
+				status = http.StatusConflict
+			}
+			logger.Warning("Failed to update category %d: %v", id, err)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		logger.Info("Category %d updated", id)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":   "success",
+			"category": category,
+		})
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,10 +12,11 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
 // CommentHandler handles requests for creating comments
-func CommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc {
+func CommentHandler(cCtrl *controllers.CommentController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if the user is logged in
 		loggedIn, userID := isLoggedIn(cCtrl.DB, r)
@@ -67,6 +69,26 @@ func CommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc {
 			return
 		}
 
+		// Only live posts can be commented on
+		published, err := controllers.NewPostController(cCtrl.DB).IsPublished(postId)
+		if err != nil {
+			logger.Error("Failed to check post %d: %v", postId, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to create comment",
+			})
+			return
+		}
+		if !published {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
+
 		// Decode the request body into a CommentRequest object
 		var commentReq models.CommentRequest
 		if err := json.NewDecoder(r.Body).Decode(&commentReq); err != nil {
@@ -94,6 +116,18 @@ func CommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc {
 			return
 		}
 
+		// An image comes from a completed resumable upload
+		var mediaURL string
+		if commentReq.UploadID != "" {
+			attachment, err := controllers.NewResumableUploadController(cCtrl.DB, controllers.ResumableUploadDir()).
+				CompletedUpload(userID, commentReq.UploadID, controllers.UploadTargetComment)
+			if err != nil {
+				writeAttachmentError(w, err)
+				return
+			}
+			mediaURL = attachment.URL
+		}
+
 		// Get the username for the logged-in user
 		username := controllers.GetUsernameByID(cCtrl.DB, userID)
 
@@ -108,10 +142,27 @@ func CommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc {
 			UserVote:  sql.NullString{String: "", Valid: false},
 			Timestamp: time.Now(),
 			ParentID:  sql.NullInt64{Int64: int64(commentReq.ParentID), Valid: commentReq.ParentID != 0},
+			MediaURL:  mediaURL,
 		}
 
 		// Insert the comment into the database
 		commentID, err := cCtrl.InsertComment(comment)
+		if errors.Is(err, controllers.ErrPostNotFound) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
+		if errors.Is(err, controllers.ErrPostLocked) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "This post is locked and no longer accepts comments",
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to insert comment: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -122,6 +173,21 @@ func CommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc {
 			return
 		}
 
+		hub.NotifyMentions(controllers.MentionSource{
+			Type:     controllers.MentionSourceComment,
+			ID:       commentID,
+			AuthorID: userID,
+			PostID:   postId,
+		}, comment.Content)
+
+		// Tell the post author and the parent comment's author
+		comment.ID = commentID
+		notifications, err := controllers.NewNotificationController(cCtrl.DB).NotifyReply(comment)
+		if err != nil {
+			logger.Error("Failed to notify reply to post %d: %v", postId, err)
+		}
+		hub.PublishNotifications(notifications)
+
 		// Return the created comment ID in the response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -231,7 +297,7 @@ func DeleteCommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc
 	}
 }
 
-func UpdateCommentHandler(cc *controllers.CommentController) http.HandlerFunc {
+func UpdateCommentHandler(cc *controllers.CommentController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get comment ID from query parameters
 		commentID, err := strconv.Atoi(r.URL.Query().Get("id"))
@@ -277,6 +343,18 @@ func UpdateCommentHandler(cc *controllers.CommentController) http.HandlerFunc {
 			return
 		}
 
+		// Only users mentioned for the first time are notified
+		if postID, err := cc.GetCommentPostID(commentID); err != nil {
+			logger.Error("Failed to fetch post of comment %d: %v", commentID, err)
+		} else {
+			hub.NotifyMentions(controllers.MentionSource{
+				Type:     controllers.MentionSourceComment,
+				ID:       commentID,
+				AuthorID: userID,
+				PostID:   postID,
+			}, updateReq.Content)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"message": "Comment updated successfully",
@@ -360,6 +438,20 @@ func CreateCommentHandler(cCtrl *controllers.CommentController) http.HandlerFunc
 
 		// Insert comment
 		commentID, err := cCtrl.InsertComment(comment)
+		if errors.Is(err, controllers.ErrPostNotFound) {
+			sendJSONResponse(http.StatusNotFound, map[string]interface{}{
+				"status": "error",
+				"error":  "Post not found",
+			})
+			return
+		}
+		if errors.Is(err, controllers.ErrPostLocked) {
+			sendJSONResponse(http.StatusForbidden, map[string]interface{}{
+				"status": "error",
+				"error":  "This post is locked and no longer accepts comments",
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to insert comment: %v", err)
 			sendJSONResponse(http.StatusInternalServerError, map[string]interface{}{
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
 type CommentVoteRequest struct {
@@ -13,7 +15,7 @@ type CommentVoteRequest struct {
 	VoteType  string `json:"voteType"`
 }
 
-func CreateCommentVoteHandler(cc *controllers.CommentVotesController) http.HandlerFunc {
+func CreateCommentVoteHandler(cc *controllers.CommentVotesController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -57,6 +59,14 @@ func CreateCommentVoteHandler(cc *controllers.CommentVotesController) http.Handl
 
 		// Handle the vote
 		err := cc.HandleCommentVote(voteReq.CommentId, userID, voteReq.VoteType)
+		if errors.Is(err, controllers.ErrCommentNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "error",
+				"error":  "Comment not found",
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to handle comment vote: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -67,6 +77,8 @@ func CreateCommentVoteHandler(cc *controllers.CommentVotesController) http.Handl
 			return
 		}
 
+		hub.PublishVoteNotification(controllers.MentionSourceComment, voteReq.CommentId, userID)
+
 		// Get updated vote counts
 		likes, dislikes, err := cc.GetCommentVotes(voteReq.CommentId)
 		if err != nil {
@@ -3,7 +3,9 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
@@ -47,10 +49,45 @@ func (h *HomePageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create a PostController instance using the handler's db
+	// Page through posts with an opaque cursor; the list carries comment
+	// counts only, full threads are served by the single post view
+	limit := feedPageSize()
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > controllers.MaxFeedPageSize {
+		limit = controllers.MaxFeedPageSize
+	}
+
 	postController := controllers.NewPostController(h.db)
-	// Fetch posts from the database using the controller
-	posts, err := postController.GetAllPosts()
+	posts, nextCursor, err := postController.GetPostsPage(controllers.FeedQuery{
+		Cursor:   r.URL.Query().Get("cursor"),
+		Limit:    limit,
+		Category: r.URL.Query().Get("category"),
+		Sort:     r.URL.Query().Get("sort"),
+		Window:   r.URL.Query().Get("window"),
+	})
+	if errors.Is(err, controllers.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "Invalid cursor",
+		})
+		return
+	}
+	if errors.Is(err, controllers.ErrInvalidFeedSort) || errors.Is(err, controllers.ErrInvalidFeedWindow) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, controllers.ErrCategoryNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "Category not found",
+		})
+		return
+	}
 	if err != nil {
 		logger.Error("Failed to fetch Posts %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -60,35 +97,27 @@ func (h *HomePageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add IsAuthor field to each post and fetch comment count
-	commentController := controllers.NewCommentController(h.db)
-	for i := range posts {
-		posts[i].IsAuthor = loggedIn && posts[i].UserID == userID
-
-		// Fetch total comment count including replies
-		commentCount, err := commentController.GetCommentCountByPostID(posts[i].ID)
-		if err != nil {
-			logger.Error("Failed to fetch comment count for post %d: %v", posts[i].ID, err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "Failed to fetch comment count",
-			})
-			return
+	// Bookmarks are private, so the flag is only set for the logged-in user
+	bookmarked := make(map[int]bool)
+	if loggedIn && len(posts) > 0 {
+		postIDs := make([]int, len(posts))
+		for i := range posts {
+			postIDs[i] = posts[i].ID
 		}
-		posts[i].Comments = make([]models.Comment, 0)
-		posts[i].CommentCount = commentCount
-
-		// Fetch comments for the post
-		comments, err := commentController.GetCommentsByPostID(strconv.Itoa(posts[i].ID))
-		if err != nil {
-			logger.Error("Failed to fetch comments for post %d: %v", posts[i].ID, err)
+		if bookmarked, err = controllers.NewBookmarkController(h.db).BookmarkedPostIDs(userID, postIDs); err != nil {
+			logger.Error("Failed to fetch bookmarks: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "Failed to fetch comments",
+				"error": "Failed to fetch posts",
 			})
 			return
 		}
-		posts[i].Comments = comments
+	}
+
+	for i := range posts {
+		posts[i].IsAuthor = loggedIn && posts[i].UserID == userID
+		posts[i].Bookmarked = bookmarked[posts[i].ID]
+		posts[i].Comments = make([]models.Comment, 0)
 	}
 
 	response := map[string]interface{}{
@@ -98,6 +127,8 @@ func (h *HomePageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			"csrfToken":       csrfToken,
 			"posts":           posts,
 			"userId":          userID,
+			"nextCursor":      nextCursor,
+			"hasMore":         nextCursor != "",
 		},
 	}
 
@@ -110,3 +141,12 @@ func (h *HomePageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// feedPageSize returns the default feed page size, overridable with the
+// FEED_PAGE_SIZE environment variable.
+func feedPageSize() int {
+	if size, err := strconv.Atoi(os.Getenv("FEED_PAGE_SIZE")); err == nil && size > 0 {
+		return size
+	}
+	return controllers.DefaultFeedPageSize
+}
@@ -146,6 +146,13 @@ func GetMessagesHandler(mc *controllers.MessageController) http.HandlerFunc {
 
 		logger.Info("Retrieved %d messages successfully", len(messages))
 
+		// Opening a conversation marks what the other user sent as read
+		if page == 1 {
+			if err := mc.MarkConversationRead(userID, otherUserID); err != nil {
+				logger.Error("Failed to mark conversation as read: %v", err)
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(messages); err != nil {
 			logger.Error("Failed to encode messages: %v", err)
@@ -154,4 +161,70 @@ func GetMessagesHandler(mc *controllers.MessageController) http.HandlerFunc {
 
 		logger.Info("=== Completed GetMessagesHandler successfully ===")
 	}
-}
\ No newline at end of file
+}
+
+// GetContactsHandler returns a page of every other user, most recently
+// messaged first and then alphabetically
+func GetContactsHandler(mc *controllers.MessageController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		userIDInterface := r.Context().Value("userID")
+		var userID int64
+		switch v := userIDInterface.(type) {
+		case int:
+			userID = int64(v)
+		case int64:
+			userID = v
+		default:
+			logger.Error("Invalid userID type in context: %T", userIDInterface)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":    "Authentication required",
+				"status":   "error",
+				"contacts": []any{},
+			})
+			return
+		}
+
+		page := 1
+		if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+			if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+				page = p
+			}
+		}
+
+		limit := 50
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+				limit = l
+			}
+		}
+
+		// Fetch one extra row to know whether another page exists
+		contacts, err := mc.GetContacts(userID, page, limit+1)
+		if err != nil {
+			logger.Error("Failed to get contacts: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":    "Failed to get contacts",
+				"status":   "error",
+				"contacts": []any{},
+			})
+			return
+		}
+
+		hasMore := len(contacts) > limit
+		if hasMore {
+			contacts = contacts[:limit]
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":   "success",
+			"contacts": contacts,
+			"page":     page,
+			"limit":    limit,
+			"has_more": hasMore,
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// ModeratePostHandler applies a moderator action to a post
+// (/api/moderation/posts/{postId}/{action}). The action is pin, lock or
+// announcement; POST applies it and DELETE reverts it. The optional JSON
+// body gives a reason and, for pins, the category feed and position.
+func ModeratePostHandler(mc *controllers.ModerationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(mc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to moderate posts",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+
+		var req models.ModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		apply := r.Method == http.MethodPost
+		switch r.PathValue("action") {
+		case "pin":
+			if apply {
+				err = mc.PinPost(userID, postID, req)
+			} else {
+				err = mc.UnpinPost(userID, postID, req)
+			}
+		case "lock":
+			err = mc.SetPostLocked(userID, postID, apply, req)
+		case "announcement":
+			err = mc.SetPostAnnouncement(userID, postID, apply, req)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Unknown moderation action",
+			})
+			return
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, controllers.ErrPostNotFound), errors.Is(err, controllers.ErrPostNotPinned),
+			errors.Is(err, controllers.ErrCategoryNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		case errors.Is(err, controllers.ErrPostNotInCategory), errors.Is(err, controllers.ErrInvalidPinPosition),
+			errors.Is(err, controllers.ErrModerationReasonLong):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		default:
+			logger.Error("Failed to moderate post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to moderate post",
+			})
+			return
+		}
+
+		post, err := controllers.NewPostController(mc.DB).GetPostByID(strconv.Itoa(postID))
+		if err != nil {
+			logger.Error("Failed to fetch post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch post",
+			})
+			return
+		}
+		logger.Info("Moderator %d applied %s %s to post %d", userID, r.Method, r.PathValue("action"), postID)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"post":   post,
+		})
+	}
+}
+
+// ModerationLogHandler lists the moderation log, newest first. The before
+// parameter takes the nextBefore value of the previous page, and
+// target_type with target_id narrow it to one target.
+func ModerationLogHandler(mc *controllers.ModerationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		q := controllers.ModerationLogQuery{TargetType: r.URL.Query().Get("target_type")}
+		for name, dest := range map[string]*int{"limit": &q.Limit, "before": &q.Before, "target_id": &q.TargetID} {
+			v := r.URL.Query().Get(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid " + name,
+				})
+				return
+			}
+			*dest = n
+		}
+
+		entries, hasMore, err := mc.GetModerationLog(q)
+		if err != nil {
+			logger.Error("Failed to fetch moderation log: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch moderation log",
+			})
+			return
+		}
+
+		nextBefore := 0
+		if hasMore {
+			nextBefore = entries[len(entries)-1].ID
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     "success",
+			"entries":    entries,
+			"hasMore":    hasMore,
+			"nextBefore": nextBefore,
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// GetNotificationsHandler lists the user's notifications, newest first. The
+// before parameter takes the nextBefore value of the previous page, and
+// unread=true leaves out notifications that have been read.
+func GetNotificationsHandler(nc *controllers.NotificationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(nc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to view notifications",
+			})
+			return
+		}
+
+		var q controllers.NotificationQuery
+		var err error
+		if v := r.URL.Query().Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid limit",
+				})
+				return
+			}
+		}
+		if v := r.URL.Query().Get("before"); v != "" {
+			if q.Before, err = strconv.Atoi(v); err != nil || q.Before < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid before",
+				})
+				return
+			}
+		}
+		q.UnreadOnly = r.URL.Query().Get("unread") == "true"
+
+		notifications, hasMore, err := nc.GetNotifications(userID, q)
+		if err != nil {
+			logger.Error("Failed to fetch notifications for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch notifications",
+			})
+			return
+		}
+		unread, err := nc.UnreadCount(userID)
+		if err != nil {
+			logger.Error("Failed to count notifications for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch notifications",
+			})
+			return
+		}
+
+		nextBefore := 0
+		if hasMore {
+			nextBefore = notifications[len(notifications)-1].ID
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":        "success",
+			"notifications": notifications,
+			"unread_count":  unread,
+			"hasMore":       hasMore,
+			"nextBefore":    nextBefore,
+		})
+	}
+}
+
+// MarkNotificationReadHandler marks one notification as read
+// (POST /api/notifications/{id}/read)
+func MarkNotificationReadHandler(nc *controllers.NotificationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(nc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to update notifications",
+			})
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid notification ID",
+			})
+			return
+		}
+
+		err = nc.MarkRead(userID, id)
+		if errors.Is(err, controllers.ErrNotificationNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Notification not found",
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to mark notification %d read: %v", id, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to update notification",
+			})
+			return
+		}
+
+		writeUnreadCount(w, nc, userID, nil)
+	}
+}
+
+// MarkAllNotificationsReadHandler marks every notification of the user as read
+func MarkAllNotificationsReadHandler(nc *controllers.NotificationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(nc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to update notifications",
+			})
+			return
+		}
+
+		marked, err := nc.MarkAllRead(userID)
+		if err != nil {
+			logger.Error("Failed to mark notifications read for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to update notifications",
+			})
+			return
+		}
+
+		writeUnreadCount(w, nc, userID, map[string]any{"marked": marked})
+	}
+}
+
+// writeUnreadCount answers a mark-read request with the remaining unread count
+func writeUnreadCount(w http.ResponseWriter, nc *controllers.NotificationController, userID int, extra map[string]any) {
+	unread, err := nc.UnreadCount(userID)
+	if err != nil {
+		logger.Error("Failed to count notifications for user %d: %v", userID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to count notifications",
+		})
+		return
+	}
+
+	response := map[string]any{
+		"status":       "success",
+		"unread_count": unread,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// NotificationPreferencesHandler returns the user's per-type notification
+// settings on GET and changes them on PUT, e.g. {"vote": false}
+func NotificationPreferencesHandler(nc *controllers.NotificationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(nc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to manage notifications",
+			})
+			return
+		}
+
+		var prefs map[string]bool
+		var err error
+		if r.Method == http.MethodGet {
+			prefs, err = nc.GetPreferences(userID)
+		} else {
+			var changes map[string]bool
+			if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid request format",
+				})
+				return
+			}
+			prefs, err = nc.UpdatePreferences(userID, changes)
+		}
+		if errors.Is(err, controllers.ErrInvalidNotificationPreference) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to handle notification preferences for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to handle notification preferences",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":      "success",
+			"preferences": prefs,
+		})
+	}
+}
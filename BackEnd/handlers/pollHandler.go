@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
+)
+
+// GetPollHandler returns the poll of a post with its current results
+// (GET /api/posts/{postId}/poll)
+func GetPollHandler(pc *controllers.PollController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+
+		_, userID := isLoggedIn(pc.DB, r)
+		poll, err := pc.GetPostPoll(postID, userID)
+		if err != nil {
+			writePollError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"poll":   poll,
+		})
+	}
+}
+
+// VotePollHandler casts the user's ballot in the poll of a post and
+// broadcasts the new results (POST /api/posts/{postId}/poll/vote)
+func VotePollHandler(pc *controllers.PollController, hub *websockets.MessageHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(pc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to vote",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+
+		var req models.PollVoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		poll, err := pc.GetPostPoll(postID, userID)
+		if err != nil {
+			writePollError(w, err)
+			return
+		}
+		if err := pc.Vote(poll.ID, userID, req.OptionIDs); err != nil {
+			writePollError(w, err)
+			return
+		}
+
+		// Everyone gets the results; only the voter gets their own choices
+		if results, err := pc.GetPoll(poll.ID, 0); err != nil {
+			logger.Error("Failed to fetch results of poll %d: %v", poll.ID, err)
+		} else {
+			hub.PublishPollResults(results)
+		}
+
+		poll, err = pc.GetPoll(poll.ID, userID)
+		if err != nil {
+			writePollError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"poll":   poll,
+		})
+	}
+}
+
+// writePollError maps poll controller errors to responses
+func writePollError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to handle poll"
+	switch {
+	case errors.Is(err, controllers.ErrPollNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, controllers.ErrInvalidPollVote):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, controllers.ErrPollClosed), errors.Is(err, controllers.ErrAlreadyVoted):
+		status, message = http.StatusConflict, err.Error()
+	default:
+		logger.Error("Failed to handle poll: %v", err)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// GetPostAnalyticsHandler returns the views, votes and comments of a post
+// per day. Only the post's author may see them. The days query parameter
+// picks how many days back to go.
+func GetPostAnalyticsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(pc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to view post analytics",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+
+		days := 0
+		if raw := r.URL.Query().Get("days"); raw != "" {
+			days, err = strconv.Atoi(raw)
+			if err != nil || days <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "days must be a positive number",
+				})
+				return
+			}
+		}
+
+		post, err := pc.GetPostByID(strconv.Itoa(postID))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to fetch post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch post",
+			})
+			return
+		}
+		if post.UserID != userID {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Only the author can view post analytics",
+			})
+			return
+		}
+
+		analytics, err := pc.GetPostAnalytics(postID, days, time.Now())
+		if err != nil {
+			logger.Error("Failed to fetch analytics for post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch post analytics",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":    "success",
+			"analytics": analytics,
+		})
+	}
+}
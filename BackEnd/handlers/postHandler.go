@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,9 +13,90 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
-func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
+// parsePublishAt reads the optional publish_at form field (RFC 3339)
+func parsePublishAt(r *http.Request) (*time.Time, error) {
+	value := r.FormValue("publish_at")
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// editedAttachments applies an update request to a post's attachments: the
+// IDs in remove_attachments are dropped, alt-text-<id> replaces the alt text
+// of a kept attachment, and added files go last
+func editedAttachments(r *http.Request, current, added []models.Attachment) ([]models.Attachment, error) {
+	remove := make(map[int]bool)
+	if value := r.FormValue("remove_attachments"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, controllers.ErrAttachmentNotFound
+			}
+			remove[id] = true
+		}
+	}
+
+	attachments := make([]models.Attachment, 0, len(current)+len(added))
+	for _, attachment := range current {
+		if remove[attachment.ID] {
+			delete(remove, attachment.ID)
+			continue
+		}
+		if values, ok := r.MultipartForm.Value[fmt.Sprintf("alt-text-%d", attachment.ID)]; ok && len(values) > 0 {
+			attachment.AltText = strings.TrimSpace(values[0])
+			if len([]rune(attachment.AltText)) > controllers.MaxAltTextLength {
+				return nil, controllers.ErrAltTextTooLong
+			}
+		}
+		attachments = append(attachments, attachment)
+	}
+	if len(remove) > 0 {
+		return nil, controllers.ErrAttachmentNotFound
+	}
+	return append(attachments, added...), nil
+}
+
+// writeAttachmentError answers a post request whose files were rejected
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, controllers.ErrInvalidUpload) || errors.Is(err, controllers.ErrTooManyAttachments) ||
+		errors.Is(err, controllers.ErrAltTextTooLong) || errors.Is(err, controllers.ErrAttachmentNotFound) ||
+		errors.Is(err, controllers.ErrUploadNotFound) || errors.Is(err, controllers.ErrUploadIncomplete) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": err.Error(),
+		})
+		return
+	}
+	logger.Error("Failed to save files: %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Failed to save file",
+	})
+}
+
+// parsePoll reads the optional poll form field, a JSON models.PollRequest
+func parsePoll(r *http.Request) (*models.Poll, error) {
+	value := r.FormValue("poll")
+	if value == "" {
+		return nil, nil
+	}
+	var req models.PollRequest
+	if err := json.Unmarshal([]byte(value), &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", controllers.ErrInvalidPoll, err)
+	}
+	return controllers.NewPoll(req, time.Now())
+}
+
+func CreatePostHandler(pc *controllers.PostController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if the user is logged in
 		loggedIn, userID := isLoggedIn(pc.DB, r)
@@ -34,7 +115,7 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 		// Parse the multipart form
-		err := r.ParseMultipartForm(10 << 20) // 10 MB limit
+		err := r.ParseMultipartForm(10 << 20) // keeps 10MB in memory, the rest in temporary files
 		if err != nil {
 			logger.Error("Failed to parse multipart form: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -65,18 +146,50 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		// Handle file upload
-		filePath, err := controllers.UploadFile(r, "post-file", userID)
+		// Drafts and scheduled posts stay hidden until they are published
+		status := r.FormValue("status")
+		publishAt, err := parsePublishAt(r)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to save file",
+				"message": "publish_at must be an RFC 3339 timestamp",
 			})
 			return
 		}
 
-		if content == "" && filePath == "" {
+		// Validate categories against the categories table
+		postCategories, err := controllers.NewCategoryController(pc.DB).ResolvePostCategories(categories)
+		if err != nil {
+			logger.Warning("Invalid post categories %q: %v", categories, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		// An optional poll is sent as JSON in the poll form field
+		poll, err := parsePoll(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		// Handle file uploads; files of posts that fail to save are deleted
+		// by the upload sweeper
+		attachments, err := controllers.SavePostAttachments(pc.DB, r, userID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+
+		if content == "" && len(attachments) == 0 {
 			logger.Warning("Invalid post creation request: missing content and image  fields  at least one is required - remote_addr: %s, method: %s, path: %s",
 				r.RemoteAddr,
 				r.Method,
@@ -94,20 +207,28 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 
 		// Create a Post object from the form data
 		createPost := models.Post{
-			Title:     title,
-			Author:    userName,
-			UserID:    userID,
-			Category:  categories,
-			Content:   content,
-			Timestamp: time.Now(),
-			ImageUrl: sql.NullString{
-				String: filePath,
-				Valid:  filePath != "",
-			},
+			Title:       title,
+			Author:      userName,
+			UserID:      userID,
+			Content:     content,
+			Timestamp:   time.Now(),
+			Attachments: attachments,
+			Categories:  postCategories,
+			Status:      status,
+			PublishAt:   publishAt,
+			Poll:        poll,
 		}
 
 		// Insert the post into the database
 		postID, err := pc.InsertPost(createPost)
+		if errors.Is(err, controllers.ErrInvalidPostStatus) || errors.Is(err, controllers.ErrInvalidPublishAt) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to insert post: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -118,17 +239,27 @@ func CreatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
+		// Let connected clients know about posts that went live immediately
+		post, err := pc.GetPostByID(strconv.Itoa(postID))
+		if err != nil {
+			logger.Error("Failed to fetch created post %d: %v", postID, err)
+		} else if post.Status == controllers.PostStatusPublished {
+			hub.PublishPostCreated(post)
+			hub.NotifyMentions(controllers.PostMentionSource(post), post.Content)
+		}
+
 		// Return the created post ID in the response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]int{
+		json.NewEncoder(w).Encode(map[string]interface{}{
 			"postID": postID,
+			"status": post.Status,
 		})
 	}
 }
 
 // UpdatePostHandler handles PUT requests for updating a post
-func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
+func UpdatePostHandler(pc *controllers.PostController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if the user is logged in
 		loggedIn, userID := isLoggedIn(pc.DB, r)
@@ -148,7 +279,7 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 		}
 
 		// Parse the multipart form
-		err := r.ParseMultipartForm(10 << 20) // 10 MB limit
+		err := r.ParseMultipartForm(10 << 20) // keeps 10MB in memory, the rest in temporary files
 		if err != nil {
 			logger.Error("Failed to parse multipart form: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -179,6 +310,16 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
+		if existingPost.UserID != userID {
+			logger.Warning("Unauthorized attempt to update Post %d by user %d", existingPost.ID, userID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "You are not authorized to update this Post",
+			})
+			return
+		}
+
 		// Extract form fields
 		title := r.FormValue("title")
 		content := r.FormValue("content")
@@ -192,20 +333,59 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			existingPost.Content = content
 		}
 		if categories != "" {
-			existingPost.Category = categories
+			postCategories, err := controllers.NewCategoryController(pc.DB).ResolvePostCategories(categories)
+			if err != nil {
+				logger.Warning("Invalid post categories %q: %v", categories, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"message": err.Error(),
+				})
+				return
+			}
+			existingPost.Categories = postCategories
 		}
 
-		// Handle file upload (if a new file is provided)
-		filePath, err := controllers.UploadFile(r, "post-file", userID)
-		if err == nil && filePath != "" {
-			existingPost.ImageUrl = sql.NullString{
-				String: filePath,
-				Valid:  true,
+		// A status change is checked before anything is saved so a bad one
+		// leaves the post untouched
+		status := r.FormValue("status")
+		var publishAt *time.Time
+		if status != "" {
+			publishAt, err = parsePublishAt(r)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"message": "publish_at must be an RFC 3339 timestamp",
+				})
+				return
+			}
+			if err := controllers.CheckPostStatus(existingPost.Status, status, publishAt); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"message": err.Error(),
+				})
+				return
 			}
 		}
 
+		// New files are appended to the post's attachments; remove_attachments
+		// lists attachment IDs to drop and alt-text-<id> edits an alt text
+		newAttachments, err := controllers.SavePostAttachments(pc.DB, r, userID)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+		attachments, err := editedAttachments(r, existingPost.Attachments, newAttachments)
+		if err != nil {
+			writeAttachmentError(w, err)
+			return
+		}
+		existingPost.Attachments = attachments
+
 		// Ensure at least content or image exists
-		if existingPost.Content == "" && !existingPost.ImageUrl.Valid {
+		if existingPost.Content == "" && len(existingPost.Attachments) == 0 {
 			logger.Warning("Invalid post update request: missing content and image fields - at least one is required - remote_addr: %s, method: %s, path: %s",
 				r.RemoteAddr,
 				r.Method,
@@ -219,8 +399,22 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			return
 		}
 
-		// Update the post in the database
-		err = pc.UpdatePost(existingPost)
+		// Update the post in the database, moving drafts and scheduled posts
+		// along in the same transaction when a status is given
+		wentLive, err := pc.UpdatePostWithStatus(existingPost, status, publishAt)
+		if errors.Is(err, controllers.ErrTooManyAttachments) {
+			writeAttachmentError(w, err)
+			return
+		}
+		if errors.Is(err, controllers.ErrInvalidPostStatus) || errors.Is(err, controllers.ErrInvalidPublishAt) ||
+			errors.Is(err, controllers.ErrAlreadyPublished) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to update post: %v", err)
 			w.Header().Set("Content-Type", "application/json")
@@ -230,6 +424,16 @@ func UpdatePostHandler(pc *controllers.PostController) http.HandlerFunc {
 			})
 			return
 		}
+		if wentLive {
+			if post, err := pc.GetPostByID(postID); err == nil {
+				hub.PublishPostCreated(post)
+			}
+		}
+
+		// Mentions in drafts wait until the post goes live
+		if wentLive || existingPost.Status == controllers.PostStatusPublished {
+			hub.NotifyMentions(controllers.PostMentionSource(existingPost), existingPost.Content)
+		}
 
 		// Return success response
 		w.Header().Set("Content-Type", "application/json")
@@ -434,3 +638,34 @@ func EditPostHandler(pc *controllers.PostController) http.HandlerFunc {
 		}
 	}
 }
+
+// GetDraftsHandler lists the logged-in user's draft and scheduled posts
+func GetDraftsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(pc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Must be logged in to view drafts",
+			})
+			return
+		}
+
+		drafts, err := pc.GetDrafts(userID)
+		if err != nil {
+			logger.Error("Failed to fetch drafts for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch drafts",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"drafts": drafts,
+		})
+	}
+}
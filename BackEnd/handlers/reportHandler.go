@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
+)
+
+// CreateReportHandler lets a user report a post, comment or direct message
+// to the moderators
+func CreateReportHandler(rc *controllers.ReportController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(rc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to report content",
+			})
+			return
+		}
+
+		var req models.ReportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		report, err := rc.CreateReport(userID, req)
+		switch {
+		case err == nil:
+		case errors.Is(err, controllers.ErrReportTargetNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		case errors.Is(err, controllers.ErrAlreadyReported):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		case errors.Is(err, controllers.ErrInvalidReportTarget), errors.Is(err, controllers.ErrInvalidReportReason),
+			errors.Is(err, controllers.ErrReportDetailsLong), errors.Is(err, controllers.ErrReportOwnContent):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		default:
+			logger.Error("Failed to store report by user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to store report",
+			})
+			return
+		}
+		logger.Info("User %d reported %s %d for %s", userID, report.TargetType, report.TargetID, report.Reason)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"report": report,
+		})
+	}
+}
+
+// ReportQueueHandler lists reported content for moderators, grouped by
+// target. The status parameter picks open, the default, or resolved
+// reports; page starts at 1.
+func ReportQueueHandler(mc *controllers.ModerationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		q := controllers.ReportQueueQuery{Status: r.URL.Query().Get("status")}
+		if q.Status != "" && q.Status != controllers.ReportStatusOpen && q.Status != controllers.ReportStatusResolved {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "status must be open or resolved",
+			})
+			return
+		}
+		for name, dest := range map[string]*int{"limit": &q.Limit, "page": &q.Page} {
+			v := r.URL.Query().Get(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid " + name,
+				})
+				return
+			}
+			*dest = n
+		}
+
+		targets, hasMore, err := mc.GetReportQueue(q)
+		if err != nil {
+			logger.Error("Failed to fetch report queue: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch report queue",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":  "success",
+			"targets": targets,
+			"hasMore": hasMore,
+		})
+	}
+}
+
+// ResolveReportsHandler applies a moderator action to reported content
+// (/api/moderation/reports/{targetType}/{targetId}/{action}) and closes its
+// open reports. The action is dismiss, hide, delete, warn or suspend; the
+// optional JSON body gives a reason and, for suspensions, the days.
+func ResolveReportsHandler(mc *controllers.ModerationController, hub *websockets.MessageHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(mc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to moderate reports",
+			})
+			return
+		}
+
+		targetType := r.PathValue("targetType")
+		targetID, err := strconv.Atoi(r.PathValue("targetId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid target ID",
+			})
+			return
+		}
+
+		var req models.ModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid request format",
+			})
+			return
+		}
+
+		action := r.PathValue("action")
+		res, err := mc.ResolveReports(userID, targetType, targetID, action, req)
+		switch {
+		case err == nil:
+		case errors.Is(err, controllers.ErrInvalidReportAction):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Unknown moderation action",
+			})
+			return
+		case errors.Is(err, controllers.ErrNoOpenReports), errors.Is(err, controllers.ErrReportTargetNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		case errors.Is(err, controllers.ErrModerationReasonLong), errors.Is(err, controllers.ErrInvalidSuspension):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		case errors.Is(err, controllers.ErrCannotSuspendModerator):
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		default:
+			logger.Error("Failed to %s %s %d: %v", action, targetType, targetID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to resolve reports",
+			})
+			return
+		}
+		hub.PublishNotifications(res.Notifications)
+		// Suspended users lose the connections opened before the suspension
+		if res.SuspendedUntil != nil {
+			hub.DisconnectUser(int64(res.SuspendedUserID), "account suspended")
+		}
+		logger.Info("Moderator %d applied %s to %s %d, resolving %d reports", userID, action, targetType, targetID, res.Resolved)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":         "success",
+			"resolved":       res.Resolved,
+			"suspendedUntil": res.SuspendedUntil,
+		})
+	}
+}
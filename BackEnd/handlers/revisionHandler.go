@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// GetPostRevisionsHandler returns the edit history of a post
+func GetPostRevisionsHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+
+		post, err := pc.GetPostByID(strconv.Itoa(postID))
+		if err == nil {
+			// Unpublished posts are only visible to their author, hidden ones
+			// to their author and moderators
+			loggedIn, userID := isLoggedIn(pc.DB, r)
+			if post.Status != controllers.PostStatusPublished && (!loggedIn || userID != post.UserID) {
+				err = sql.ErrNoRows
+			} else if hiddenFromViewer(pc.DB, post, loggedIn, userID) {
+				err = sql.ErrNoRows
+			}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to fetch post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch post",
+			})
+			return
+		}
+
+		revisions, err := pc.GetPostRevisions(postID)
+		if err != nil {
+			logger.Error("Failed to fetch revisions for post %d: %v", postID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to fetch revisions",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":    "success",
+			"post_id":   postID,
+			"edited_at": post.EditedAt,
+			"revisions": revisions,
+		})
+	}
+}
+
+// RollbackPostHandler restores an earlier revision of a post. Only the
+// author or a moderator may roll back.
+func RollbackPostHandler(pc *controllers.PostController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(pc.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Must be logged in to roll back a post",
+			})
+			return
+		}
+
+		postID, err := strconv.Atoi(r.PathValue("postId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid post ID",
+			})
+			return
+		}
+		revision, err := strconv.Atoi(r.PathValue("revision"))
+		if err != nil || revision < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid revision",
+			})
+			return
+		}
+
+		isAuthor, err := pc.IsPostAuthor(postID, userID)
+		if err != nil {
+			logger.Error("Failed to verify Post author: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to verify Post author",
+			})
+			return
+		}
+		if !isAuthor {
+			isModerator, err := controllers.IsModerator(pc.DB, userID)
+			if err != nil {
+				logger.Error("Failed to check role for user %d: %v", userID, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Failed to verify permissions",
+				})
+				return
+			}
+			if !isModerator {
+				logger.Warning("Unauthorized attempt to roll back Post %d by user %d", postID, userID)
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"message": "You are not authorized to roll back this Post",
+				})
+				return
+			}
+		}
+
+		err = pc.RollbackPost(postID, revision, userID)
+		if errors.Is(err, controllers.ErrRevisionNotFound) || errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Revision not found",
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to roll back post %d to revision %d: %v", postID, revision, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to roll back post",
+			})
+			return
+		}
+
+		logger.Info("Post %d rolled back to revision %d by user %d", postID, revision, userID)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Post rolled back successfully",
+		})
+	}
+}
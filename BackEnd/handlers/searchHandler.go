@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// parseSearchDate accepts a date (2006-01-02) or an RFC 3339 timestamp. A
+// bare date used as an upper bound covers the whole day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// SearchHandler serves ranked full-text search over posts and comments
+func SearchHandler(sc *controllers.SearchController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		params := r.URL.Query()
+		from, err := parseSearchDate(params.Get("from"), false)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid from date",
+			})
+			return
+		}
+		to, err := parseSearchDate(params.Get("to"), true)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid to date",
+			})
+			return
+		}
+
+		page, _ := strconv.Atoi(params.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(params.Get("limit"))
+
+		results, hasMore, err := sc.Search(controllers.SearchQuery{
+			Query:    params.Get("q"),
+			Type:     params.Get("type"),
+			Author:   params.Get("author"),
+			Category: params.Get("category"),
+			From:     from,
+			To:       to,
+			Page:     page,
+			Limit:    limit,
+		})
+		if errors.Is(err, controllers.ErrSearchUnavailable) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Search is not available",
+			})
+			return
+		}
+		if errors.Is(err, controllers.ErrEmptySearchQuery) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Search query is required",
+			})
+			return
+		}
+		if errors.Is(err, controllers.ErrInvalidSearchType) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logger.Error("Search failed for %q: %v", params.Get("q"), err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Search failed",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":   "success",
+			"results":  results,
+			"page":     page,
+			"has_more": hasMore,
+		})
+	}
+}
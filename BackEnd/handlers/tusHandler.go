@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// tusChunkTimeout bounds reading one PATCH chunk; the server's own read
+// timeout is too short for large chunks on slow connections
+const tusChunkTimeout = 10 * time.Minute
+
+// ResumableUploadsHandler creates tus uploads (POST /api/uploads/tus). The
+// Upload-Length header gives the file size and Upload-Metadata its target
+// (post, comment or message), filename and alt_text. The new upload's URL is
+// returned in the Location header.
+func ResumableUploadsHandler(rc *controllers.ResumableUploadController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		userID, ok := tusRequest(w, r, rc)
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("Upload-Defer-Length") != "" {
+			writeTusError(w, r, "Deferred upload length is not supported", http.StatusBadRequest)
+			return
+		}
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil {
+			writeTusError(w, r, "Invalid Upload-Length", http.StatusBadRequest)
+			return
+		}
+		metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+		if err != nil {
+			writeTusError(w, r, "Invalid Upload-Metadata", http.StatusBadRequest)
+			return
+		}
+
+		upload, err := rc.Create(userID, length, metadata)
+		if err != nil {
+			writeResumableUploadError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", "/api/uploads/tus/"+upload.ID)
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"upload": upload,
+		})
+	}
+}
+
+// ResumableUploadHandler serves a tus upload (/api/uploads/tus/{id}): HEAD
+// returns the offset to resume from, PATCH appends a chunk at that offset,
+// DELETE terminates the upload and GET describes it, including the stored
+// attachment once it is complete
+func ResumableUploadHandler(rc *controllers.ResumableUploadController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPatch, http.MethodDelete:
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		userID, ok := tusRequest(w, r, rc)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodGet:
+			upload, err := rc.Get(userID, id)
+			if err != nil {
+				writeResumableUploadError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"status": "success",
+				"upload": upload,
+			})
+
+		case http.MethodHead:
+			upload, err := rc.Get(userID, id)
+			if err != nil {
+				writeResumableUploadError(w, r, err)
+				return
+			}
+			setUploadOffsetHeaders(w, upload)
+			w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+
+		case http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+				writeTusError(w, r, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+				return
+			}
+			offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+			if err != nil || offset < 0 {
+				writeTusError(w, r, "Invalid Upload-Offset", http.StatusBadRequest)
+				return
+			}
+
+			deadline := time.Now().Add(tusChunkTimeout)
+			controller := http.NewResponseController(w)
+			controller.SetReadDeadline(deadline)
+			controller.SetWriteDeadline(deadline)
+
+			upload, err := rc.Append(r.Context(), userID, id, offset, r.Body)
+			if err != nil {
+				// The client resumes from the offset it reached
+				if upload.ID != "" {
+					setUploadOffsetHeaders(w, upload)
+				}
+				writeResumableUploadError(w, r, err)
+				return
+			}
+			setUploadOffsetHeaders(w, upload)
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodDelete:
+			if err := rc.Terminate(userID, id); err != nil {
+				writeResumableUploadError(w, r, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// tusRequest checks that the user is logged in and, except for GET, that
+// the client speaks our tus version, answering the request itself otherwise
+func tusRequest(w http.ResponseWriter, r *http.Request, rc *controllers.ResumableUploadController) (int, bool) {
+	loggedIn, userID := isLoggedIn(rc.DB, r)
+	if !loggedIn {
+		writeTusError(w, r, "Must be logged in to upload files", http.StatusUnauthorized)
+		return 0, false
+	}
+	if r.Method != http.MethodGet && r.Header.Get("Tus-Resumable") != controllers.TusVersion {
+		w.Header().Set("Tus-Version", controllers.TusVersion)
+		writeTusError(w, r, "Unsupported tus version", http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return userID, true
+}
+
+func setUploadOffsetHeaders(w http.ResponseWriter, upload models.ResumableUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma separated
+// pairs of a key and a base64 encoded value, which may be left out
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// writeResumableUploadError maps resumable upload errors to responses
+func writeResumableUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, controllers.ErrUploadNotFound):
+		writeTusError(w, r, err.Error(), http.StatusNotFound)
+	case errors.Is(err, controllers.ErrUploadOffsetMismatch):
+		writeTusError(w, r, err.Error(), http.StatusConflict)
+	case errors.Is(err, controllers.ErrUploadBusy):
+		writeTusError(w, r, err.Error(), http.StatusLocked)
+	case errors.Is(err, controllers.ErrUploadTooLarge):
+		writeTusError(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, controllers.ErrInvalidUpload), errors.Is(err, controllers.ErrAltTextTooLong):
+		writeTusError(w, r, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error("Failed to handle resumable upload: %v", err)
+		writeTusError(w, r, "Failed to save file", http.StatusInternalServerError)
+	}
+}
+
+// writeTusError answers with a JSON error, or only the status for HEAD
+func writeTusError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if r.Method == http.MethodHead {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
+)
+
+// ServeUploadsHandler serves uploads from the blob store; it expects the
+// /uploads/ prefix to be stripped. Direct message attachments are private:
+// they are only served with a valid signed URL.
+func ServeUploadsHandler(store storage.BlobStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := r.URL.Path
+		if storage.ValidateKey(key) != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasPrefix(key, controllers.UploadFolderMessages+"/") {
+			verifier, ok := store.(storage.URLVerifier)
+			if !ok || !verifier.VerifyURL(key, r.URL.Query(), time.Now()) {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Cache-Control", "private")
+		}
+
+		blob, err := store.Get(r.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to read upload %s: %v", key, err)
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return
+		}
+		defer blob.Body.Close()
+
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = blob.ContentType
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if body, ok := blob.Body.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, key, blob.ModTime, body)
+			return
+		}
+		if blob.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(blob.Size, 10))
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.Copy(w, blob.Body)
+	})
+}
+
+// maxUploadRequestSize bounds upload requests: one 20MB file plus the form
+const maxUploadRequestSize = 21 << 20
+
+// AvatarHandler sets the user's avatar from the multipart avatar field on
+// POST and removes it on DELETE
+func AvatarHandler(ac *controllers.AvatarController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(ac.DB, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to change your avatar",
+			})
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := ac.RemoveAvatar(userID); err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"status":     "success",
+				"avatar_url": "",
+			})
+			return
+		}
+
+		file, header, ok := formUpload(w, r, "avatar")
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		avatarURL, err := ac.SetAvatar(r.Context(), userID, file, header)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     "success",
+			"avatar_url": avatarURL,
+		})
+	}
+}
+
+// MessageAttachmentHandler stores an image from the multipart file field to
+// send with a direct message (POST /api/messages/attachments). The returned
+// media_path goes into the media_path field of the websocket message;
+// media_url previews it.
+func MessageAttachmentHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		loggedIn, userID := isLoggedIn(db, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to send attachments",
+			})
+			return
+		}
+
+		file, header, ok := formUpload(w, r, "file")
+		if !ok {
+			return
+		}
+		defer file.Close()
+
+		attachment, err := controllers.SaveMessageAttachment(r.Context(), db, userID, file, header)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     "success",
+			"media_path": attachment.URL,
+			"media_url":  attachment.MediumURL,
+			"width":      attachment.Width,
+			"height":     attachment.Height,
+		})
+	}
+}
+
+// UploadSweepHandler reports orphaned uploads on GET without deleting
+// anything, and runs the upload sweeper on POST (admin only)
+func UploadSweepHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Method not allowed",
+			})
+			return
+		}
+
+		report, err := controllers.SweepUploads(r.Context(), db, r.Method == http.MethodGet)
+		if err != nil {
+			logger.Error("Failed to sweep uploads: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to sweep uploads",
+			})
+			return
+		}
+		if !report.DryRun {
+			logger.Info("Deleted %d orphaned uploads (%d bytes) on request", len(report.Orphans), report.OrphanBytes)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "success",
+			"report": report,
+		})
+	}
+}
+
+// formUpload opens the file in the given multipart field, answering the
+// request itself when there is none
+func formUpload(w http.ResponseWriter, r *http.Request, field string) (multipart.File, *multipart.FileHeader, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadRequestSize)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid upload",
+		})
+		return nil, nil, false
+	}
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "No file in the " + field + " field",
+		})
+		return nil, nil, false
+	}
+	return file, header, true
+}
+
+// writeUploadError maps upload errors to responses
+func writeUploadError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to save file"
+	switch {
+	case errors.Is(err, controllers.ErrInvalidUpload):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, controllers.ErrAvatarUserNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	default:
+		logger.Error("Failed to save upload: %v", err)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
 // VoteRequest defines the structure for vote requests
@@ -15,7 +17,7 @@ type VoteRequest struct {
 	Vote   string `json:"vote"`
 }
 
-func CreateUserVoteHandler(lc *controllers.LikesController) http.HandlerFunc {
+func CreateUserVoteHandler(lc *controllers.LikesController, hub *websockets.MessageHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Set JSON content type for all responses
 		w.Header().Set("Content-Type", "application/json")
@@ -66,6 +68,13 @@ func CreateUserVoteHandler(lc *controllers.LikesController) http.HandlerFunc {
 
 		// Handle the vote
 		err := lc.HandleVote(voteReq.PostID, userID, voteReq.Vote)
+		if errors.Is(err, controllers.ErrPostNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Post not found",
+			})
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to handle vote: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -75,6 +84,8 @@ func CreateUserVoteHandler(lc *controllers.LikesController) http.HandlerFunc {
 			return
 		}
 
+		hub.PublishVoteNotification(controllers.MentionSourcePost, voteReq.PostID, userID)
+
 		// After updating the post votes, fetch the updated likes count
 		likesCount, dislikesCount, err := lc.GetPostVotes(voteReq.PostID)
 		if err != nil {
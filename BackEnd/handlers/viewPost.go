@@ -3,11 +3,16 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
 )
 
 type ViewPostHandler struct {
@@ -81,9 +86,60 @@ func (h *ViewPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	logger.Info("Successfully fetched post: %+v", post)
 
+	// Drafts and scheduled posts are only visible to their author
+	if post.Status != controllers.PostStatusPublished && !(loggedIn && userID == post.UserID) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "error",
+			"error":  "Post not found",
+		})
+		return
+	}
+
 	// Determine if the logged-in user is the post author
 	isAuthor := loggedIn && userID == post.UserID
 
+	if hiddenFromViewer(h.db, post, loggedIn, userID) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "error",
+			"error":  "Post not found",
+		})
+		return
+	}
+
+	// Count the view; authors reading their own posts are left out
+	if post.Status == controllers.PostStatusPublished && !post.Hidden && !isAuthor {
+		controllers.RecordPostView(post.ID, postViewer(r, loggedIn, userID), time.Now())
+	}
+
+	if loggedIn {
+		bookmarked, err := controllers.NewBookmarkController(h.db).BookmarkedPostIDs(userID, []int{post.ID})
+		if err != nil {
+			logger.Error("Failed to fetch bookmarks: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "error",
+				"error":  "Failed to fetch post",
+			})
+			return
+		}
+		post.Bookmarked = bookmarked[post.ID]
+	}
+
+	// Attach the post's poll, if it has one
+	poll, err := controllers.NewPollController(h.db).GetPostPoll(post.ID, userID)
+	if err != nil && !errors.Is(err, controllers.ErrPollNotFound) {
+		logger.Error("Failed to fetch poll: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "error",
+			"error":  "Failed to fetch post",
+		})
+		return
+	}
+	post.Poll = poll
+
 	// Create CommentController and fetch comments
 	commentController := controllers.NewCommentController(h.db)
 	comments, err := commentController.GetCommentsByPostID(postID)
@@ -133,3 +189,34 @@ func (h *ViewPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// hiddenFromViewer reports whether a post hidden by a moderator must be kept
+// from the viewer; its author and moderators still see it
+func hiddenFromViewer(db *sql.DB, post models.Post, loggedIn bool, userID int) bool {
+	if !post.Hidden || (loggedIn && userID == post.UserID) {
+		return false
+	}
+	if !loggedIn {
+		return true
+	}
+	moderator, err := controllers.HasRole(db, userID, controllers.RoleModerator)
+	if err != nil {
+		logger.Error("Failed to check role of user %d: %v", userID, err)
+	}
+	return !moderator
+}
+
+// postViewer identifies who is viewing a post for view deduplication: the
+// user when logged in, otherwise their address. A session cookie that did
+// not log anyone in is ignored, since clients can make up a new one for
+// every request.
+func postViewer(r *http.Request, loggedIn bool, userID int) string {
+	if loggedIn {
+		return "user:" + strconv.Itoa(userID)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// IssueWSTicketHandler returns a single-use ticket for opening /ws?ticket=...
+func IssueWSTicketHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		loggedIn, userID := isLoggedIn(db, r)
+		if !loggedIn {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Must be logged in to request a connection ticket",
+			})
+			return
+		}
+
+		ticket, expiresAt, err := controllers.IssueWSTicket(db, userID)
+		if err != nil {
+			logger.Error("Failed to issue websocket ticket for user %d: %v", userID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to issue connection ticket",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     "success",
+			"ticket":     ticket,
+			"expires_at": expiresAt,
+		})
+	}
+}
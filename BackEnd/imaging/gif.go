@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	imagepalette "image/color/palette"
+	"image/draw"
+	"image/gif"
+)
+
+// scaleAnimation renders every frame of g onto the full canvas, honouring
+// the frame disposal methods, and scales the result to width x height. Each
+// output frame covers the whole canvas and is drawn with the colors of its
+// source frame, so animations keep their timing, looping and transparency.
+func scaleAnimation(g *gif.GIF, width, height int) *gif.GIF {
+	canvasBounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(canvasBounds)
+
+	out := &gif.GIF{
+		LoopCount: g.LoopCount,
+		Config:    image.Config{Width: width, Height: height},
+	}
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvasBounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		full := canvas
+		if width != canvasBounds.Dx() || height != canvasBounds.Dy() {
+			full = Resize(canvas, width, height)
+		}
+		palette := framePalette(frame.Palette, hasTransparency(full))
+		scaled := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		draw.FloydSteinberg.Draw(scaled, scaled.Bounds(), full, image.Point{})
+
+		out.Image = append(out.Image, scaled)
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		out.Delay = append(out.Delay, delay)
+		// Output frames cover the canvas, so clearing it keeps earlier frames
+		// from showing through transparent pixels
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return out
+}
+
+// framePalette returns the palette for an output frame: the palette of the
+// source frame, with a transparent entry when the frame needs one
+func framePalette(palette color.Palette, transparent bool) color.Palette {
+	if len(palette) == 0 {
+		palette = imagepalette.Plan9
+	}
+	if !transparent {
+		return palette
+	}
+	for _, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return palette
+		}
+	}
+	p := make(color.Palette, 0, len(palette)+1)
+	p = append(p, palette...)
+	if len(p) == 256 {
+		p = p[:255]
+	}
+	return append(p, color.RGBA{})
+}
+
+func hasTransparency(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 0xFF {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+// Package imaging re-encodes uploaded images. Decoding and encoding drops
+// everything but the pixels, so EXIF data such as GPS positions never reaches
+// the uploads directory; JPEG orientation is applied to the pixels first.
+// Images are scaled down to MaxDimension and smaller variants are produced
+// for feeds and previews. SVG documents are sanitized rather than decoded.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Size limits in pixels
+const (
+	MaxDimension       = 2048
+	MediumDimension    = 1024
+	ThumbnailDimension = 320
+
+	// MaxPixels bounds the decoded size of an upload; for animated GIFs it
+	// bounds the pixels of all frames together
+	MaxPixels = 40_000_000
+)
+
+// JPEGQuality is the quality re-encoded JPEGs are written with
+const JPEGQuality = 85
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidImage      = errors.New("the image could not be decoded")
+	ErrImageTooLarge     = fmt.Errorf("the image is larger than %d pixels", MaxPixels)
+)
+
+// Variant names
+const (
+	VariantMedium    = "medium"
+	VariantThumbnail = "thumb"
+)
+
+// Variants lists the smaller copies produced for every image with the
+// largest dimension they may have
+var Variants = []struct {
+	Name         string
+	MaxDimension int
+}{
+	{VariantMedium, MediumDimension},
+	{VariantThumbnail, ThumbnailDimension},
+}
+
+// Image is an encoded image
+type Image struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Result is a processed upload. Variants only holds the variants that are
+// smaller than the original; callers fall back to the original for the rest.
+type Result struct {
+	Format   string // "jpeg", "png" or "gif"
+	Original Image
+	Variants map[string]Image
+}
+
+// Process decodes an image, strips its metadata, scales it down to
+// MaxDimension and produces its variants. The output keeps the input format.
+func Process(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	switch format {
+	case "jpeg", "png":
+		return processStill(data, format)
+	case "gif":
+		return processGIF(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func processStill(data []byte, format string) (*Result, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	encode := func(maxDimension int) (Image, error) {
+		// Scaling first keeps the rotation cheap; Fit is symmetric so the
+		// bounds hold either way round
+		b := img.Bounds()
+		w, h := Fit(b.Dx(), b.Dy(), maxDimension)
+		var out image.Image = img
+		if w != b.Dx() || h != b.Dy() {
+			out = Resize(img, w, h)
+		}
+		out = orient(out, orientation)
+
+		var buf bytes.Buffer
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: JPEGQuality})
+		} else {
+			err = png.Encode(&buf, out)
+		}
+		if err != nil {
+			return Image{}, fmt.Errorf("failed to encode image: %w", err)
+		}
+		ob := out.Bounds()
+		return Image{Data: buf.Bytes(), Width: ob.Dx(), Height: ob.Dy()}, nil
+	}
+	return buildResult(format, img.Bounds().Dx(), img.Bounds().Dy(), encode)
+}
+
+// buildResult encodes the original and every variant that is smaller than it
+func buildResult(format string, width, height int, encode func(maxDimension int) (Image, error)) (*Result, error) {
+	result := &Result{Format: format, Variants: make(map[string]Image)}
+	var err error
+	if result.Original, err = encode(MaxDimension); err != nil {
+		return nil, err
+	}
+
+	largest := max(width, height, 1)
+	largest = min(largest, MaxDimension)
+	for _, variant := range Variants {
+		if variant.MaxDimension >= largest {
+			continue
+		}
+		if result.Variants[variant.Name], err = encode(variant.MaxDimension); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Fit returns the size of a width x height image scaled down so that neither
+// side exceeds maxDimension, keeping the aspect ratio. Images are never
+// scaled up.
+func Fit(width, height, maxDimension int) (int, int) {
+	if width <= maxDimension && height <= maxDimension {
+		return width, height
+	}
+	if width >= height {
+		return maxDimension, max(1, (height*maxDimension+width/2)/width)
+	}
+	return max(1, (width*maxDimension+height/2)/height), maxDimension
+}
+
+// processGIF treats every GIF as an animation, so single frame GIFs take the
+// same path
+func processGIF(data []byte) (*Result, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(g.Image) == 0 {
+		return nil, ErrInvalidImage
+	}
+	width, height := g.Config.Width, g.Config.Height
+	if int64(width)*int64(height)*int64(len(g.Image)) > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	encode := func(maxDimension int) (Image, error) {
+		w, h := Fit(width, height, maxDimension)
+		out := scaleAnimation(g, w, h)
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, out); err != nil {
+			return Image{}, fmt.Errorf("failed to encode image: %w", err)
+		}
+		return Image{Data: buf.Bytes(), Width: w, Height: h}, nil
+	}
+	return buildResult("gif", width, height, encode)
+}
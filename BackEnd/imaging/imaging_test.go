@@ -0,0 +1,243 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// TestFit checks that images are scaled down to fit and never scaled up
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, max    int
+		wantW, wantH int
+	}{
+		{100, 50, 320, 100, 50},
+		{4000, 3000, 2048, 2048, 1536},
+		{3000, 4000, 1024, 768, 1024},
+		{5000, 1, 320, 320, 1},
+		{320, 320, 320, 320, 320},
+	}
+	for _, tt := range tests {
+		w, h := Fit(tt.w, tt.h, tt.max)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("Fit(%d, %d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.max, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// TestResize checks that target pixels average the source pixels they cover
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				src.Set(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+	dst := Resize(src, 2, 1)
+	for x := 0; x < 2; x++ {
+		if got := dst.RGBAAt(x, 0); got != (color.RGBA{128, 128, 128, 255}) {
+			t.Errorf("pixel %d = %v, want mid grey", x, got)
+		}
+	}
+
+	// Transparent pixels must not darken their opaque neighbours
+	src = image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	dst = Resize(src, 1, 1)
+	if got := color.NRGBAModel.Convert(dst.At(0, 0)).(color.NRGBA); got.R != 255 || got.A != 128 {
+		t.Errorf("Half transparent red = %v, want full red at half alpha", got)
+	}
+}
+
+// withEXIF inserts an APP1 segment holding an orientation tag and a GPS
+// marker right after the JPEG SOI marker
+func withEXIF(jpg []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString("GPS 51.5007N 0.1246W")
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// TestProcessJPEG checks that metadata is dropped, orientation applied and
+// variants produced
+func TestProcessJPEG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3000, 1500))
+	// Mark the top left corner so the rotation can be checked
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			src.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	data := withEXIF(buf.Bytes(), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	result, err := Process(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result.Format != "jpeg" {
+		t.Errorf("Format = %q, want jpeg", result.Format)
+	}
+	if bytes.Contains(result.Original.Data, []byte("Exif")) || bytes.Contains(result.Original.Data, []byte("GPS")) {
+		t.Error("Metadata survived re-encoding")
+	}
+
+	// Rotated a quarter turn clockwise and scaled down to 2048
+	if result.Original.Width != 1024 || result.Original.Height != 2048 {
+		t.Errorf("Original is %dx%d, want 1024x2048", result.Original.Width, result.Original.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(result.Original.Data))
+	if err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 1024 || b.Dy() != 2048 {
+		t.Errorf("Encoded image is %v", b)
+	}
+	// The marked corner is now top right
+	if r, g, _, _ := img.At(1000, 20).RGBA(); r>>8 < 200 || g>>8 > 60 {
+		t.Errorf("Top right pixel is not red after rotation")
+	}
+
+	medium, ok := result.Variants[VariantMedium]
+	if !ok || medium.Width != 512 || medium.Height != 1024 {
+		t.Errorf("Medium variant = %dx%d (%v), want 512x1024", medium.Width, medium.Height, ok)
+	}
+	thumb, ok := result.Variants[VariantThumbnail]
+	if !ok || thumb.Width != 160 || thumb.Height != 320 {
+		t.Errorf("Thumbnail variant = %dx%d (%v), want 160x320", thumb.Width, thumb.Height, ok)
+	}
+}
+
+// TestProcessSmallPNG checks that small images keep their size, keep their
+// transparency and get no variants larger than themselves
+func TestProcessSmallPNG(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 500, 200))
+	src.Set(10, 10, color.NRGBA{0, 0, 255, 255})
+	var buf bytes.Buffer
+	png.Encode(&buf, src)
+
+	result, err := Process(&buf)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result.Original.Width != 500 || result.Original.Height != 200 {
+		t.Errorf("Original is %dx%d, want 500x200", result.Original.Width, result.Original.Height)
+	}
+	if _, ok := result.Variants[VariantMedium]; ok {
+		t.Error("Medium variant produced for an image smaller than it")
+	}
+	if thumb := result.Variants[VariantThumbnail]; thumb.Width != 320 || thumb.Height != 128 {
+		t.Errorf("Thumbnail is %dx%d, want 320x128", thumb.Width, thumb.Height)
+	}
+
+	img, err := png.Decode(bytes.NewReader(result.Original.Data))
+	if err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("Transparency was lost")
+	}
+}
+
+// TestProcessAnimatedGIF checks that frames, timing and looping survive
+func TestProcessAnimatedGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White, color.RGBA{255, 0, 0, 255}}
+	g := &gif.GIF{LoopCount: 0, Config: image.Config{Width: 800, Height: 400, ColorModel: palette}}
+	for i := 0; i < 3; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 800, 400), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i)
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10*(i+1))
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+
+	result, err := Process(&buf)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	thumb, ok := result.Variants[VariantThumbnail]
+	if !ok {
+		t.Fatal("No thumbnail variant")
+	}
+	out, err := gif.DecodeAll(bytes.NewReader(thumb.Data))
+	if err != nil {
+		t.Fatalf("Failed to decode thumbnail: %v", err)
+	}
+	if len(out.Image) != 3 || out.Config.Width != 320 || out.Config.Height != 160 {
+		t.Fatalf("Thumbnail has %d frames of %dx%d", len(out.Image), out.Config.Width, out.Config.Height)
+	}
+	for i, delay := range out.Delay {
+		if delay != 10*(i+1) {
+			t.Errorf("Frame %d delay = %d, want %d", i, delay, 10*(i+1))
+		}
+	}
+	if r, g, b, _ := out.Image[2].At(100, 100).RGBA(); r>>8 != 255 || g != 0 || b != 0 {
+		t.Error("Last frame lost its color")
+	}
+}
+
+// pngHeader builds a PNG that only holds a header claiming the given size
+func pngHeader(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 bit RGBA
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+// TestProcessRejects checks that oversized and broken images are refused
+// before they are decoded
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process(bytes.NewReader(pngHeader(50000, 50000))); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Oversized image: got %v, want ErrImageTooLarge", err)
+	}
+	if _, err := Process(bytes.NewReader([]byte("not an image"))); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Garbage: got %v, want ErrInvalidImage", err)
+	}
+	if _, err := Process(bytes.NewReader(pngHeader(10, 10))); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Truncated image: got %v, want ErrInvalidImage", err)
+	}
+}
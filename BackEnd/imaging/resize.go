@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// contribution is the share a source row or column has in a target one
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// areaWeights maps every target pixel along one axis to the source pixels it
+// covers, weighted by the covered fraction
+func areaWeights(src, dst int) [][]contribution {
+	scale := float64(src) / float64(dst)
+	weights := make([][]contribution, dst)
+	for i := range weights {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < src && float64(j) < end; j++ {
+			covered := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if covered > 0 {
+				weights[i] = append(weights[i], contribution{j, covered / scale})
+			}
+		}
+	}
+	return weights
+}
+
+// Resize scales src down to width x height by averaging the source pixels
+// each target pixel covers. Averaging is done on premultiplied colors so
+// transparent pixels do not bleed into their neighbours. Source rows are
+// converted one at a time, so memory use stays close to the size of the
+// result.
+func Resize(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	columns := areaWeights(b.Dx(), width)
+	rows := areaWeights(b.Dy(), height)
+
+	line := image.NewRGBA(image.Rect(0, 0, b.Dx(), 1))
+	scaled := make([]float64, width*4)
+	cached := -1
+	scaleRow := func(y int) []float64 {
+		if y == cached {
+			return scaled
+		}
+		draw.Draw(line, line.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+y), draw.Src)
+		for x, contributions := range columns {
+			var r, g, bl, a float64
+			for _, c := range contributions {
+				p := line.Pix[c.index*4 : c.index*4+4]
+				r += float64(p[0]) * c.weight
+				g += float64(p[1]) * c.weight
+				bl += float64(p[2]) * c.weight
+				a += float64(p[3]) * c.weight
+			}
+			scaled[x*4], scaled[x*4+1], scaled[x*4+2], scaled[x*4+3] = r, g, bl, a
+		}
+		cached = y
+		return scaled
+	}
+
+	sum := make([]float64, width*4)
+	for y, contributions := range rows {
+		clear(sum)
+		for _, c := range contributions {
+			for i, v := range scaleRow(c.index) {
+				sum[i] += v * c.weight
+			}
+		}
+		pix := dst.Pix[y*dst.Stride : y*dst.Stride+width*4]
+		for i := 0; i < len(pix); i += 4 {
+			a := clampByte(sum[i+3])
+			pix[i+3] = a
+			// Rounding must not leave a color above its alpha
+			pix[i] = min(clampByte(sum[i]), a)
+			pix[i+1] = min(clampByte(sum[i+1]), a)
+			pix[i+2] = min(clampByte(sum[i+2]), a)
+		}
+	}
+	return dst
+}
+
+func clampByte(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
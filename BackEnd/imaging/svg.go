@@ -0,0 +1,199 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrInvalidSVG is returned for documents that are not well-formed SVG
+var ErrInvalidSVG = errors.New("the file is not a valid SVG image")
+
+// maxSVGDepth bounds element nesting
+const maxSVGDepth = 64
+
+// svgElements lists the elements kept by SanitizeSVG. Anything that can run
+// script, embed other documents or load external resources (script, style,
+// foreignObject, animation elements, feImage, a) is left out.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true,
+	"title": true, "desc": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true,
+	"line": true, "polyline": true, "polygon": true,
+	"text": true, "tspan": true, "textPath": true,
+	"linearGradient": true, "radialGradient": true, "stop": true,
+	"clipPath": true, "mask": true, "pattern": true, "marker": true, "image": true,
+	"filter": true, "feBlend": true, "feColorMatrix": true, "feComponentTransfer": true,
+	"feComposite": true, "feDropShadow": true, "feFlood": true, "feFuncA": true,
+	"feFuncB": true, "feFuncG": true, "feFuncR": true, "feGaussianBlur": true,
+	"feMerge": true, "feMergeNode": true, "feMorphology": true, "feOffset": true,
+	"feTile": true, "feTurbulence": true,
+}
+
+// svgDataImagePrefixes are the only external sources an image element may
+// reference: inline raster images
+var svgDataImagePrefixes = []string{
+	"data:image/png;base64,", "data:image/jpeg;base64,", "data:image/gif;base64,",
+}
+
+// SanitizeSVG rewrites an SVG document keeping only an allowlist of drawing
+// elements. Event handler attributes, links other than references into the
+// document itself, url() references to other documents, and risky CSS are
+// removed, as are comments, processing instructions and DOCTYPEs. Custom
+// entities are not expanded, so entity tricks make the document invalid
+// rather than large. The root element must be svg.
+func SanitizeSVG(data []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	var out bytes.Buffer
+	var stack []string
+	skip := 0 // depth inside a removed element
+	root := false
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidSVG
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) >= maxSVGDepth {
+				return nil, ErrInvalidSVG
+			}
+			if len(stack) == 0 {
+				if root || t.Name.Local != "svg" || !svgPrefix(t.Name.Space) {
+					return nil, ErrInvalidSVG
+				}
+				root = true
+			}
+			stack = append(stack, t.Name.Space+":"+t.Name.Local)
+			if skip > 0 || !svgPrefix(t.Name.Space) || !svgElements[t.Name.Local] {
+				skip++
+				continue
+			}
+			writeSVGStart(&out, t, len(stack) == 1)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name.Space+":"+t.Name.Local {
+				return nil, ErrInvalidSVG
+			}
+			stack = stack[:len(stack)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			if len(stack) > 0 && skip == 0 {
+				xml.EscapeText(&out, t)
+			}
+		}
+		// Comments, processing instructions and directives are dropped
+	}
+	if !root || len(stack) != 0 {
+		return nil, ErrInvalidSVG
+	}
+	return out.Bytes(), nil
+}
+
+func svgPrefix(prefix string) bool {
+	return prefix == "" || prefix == "svg"
+}
+
+// writeSVGStart writes a kept start tag with its safe attributes. Namespace
+// declarations are replaced on the root so prefixes cannot be rebound.
+func writeSVGStart(out *bytes.Buffer, t xml.StartElement, root bool) {
+	out.WriteString("<" + t.Name.Local)
+	if root {
+		out.WriteString(` xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"`)
+	}
+	for _, attr := range t.Attr {
+		name, ok := svgAttrName(attr.Name)
+		if !ok || !safeSVGAttr(t.Name.Local, attr.Name.Local, attr.Value) {
+			continue
+		}
+		out.WriteString(" " + name + `="`)
+		xml.EscapeText(out, []byte(attr.Value))
+		out.WriteString(`"`)
+	}
+	out.WriteString(">")
+}
+
+// svgAttrName returns the name to write for an attribute, or false for
+// namespace declarations and attributes of foreign namespaces
+func svgAttrName(name xml.Name) (string, bool) {
+	switch name.Space {
+	case "":
+		if name.Local == "xmlns" {
+			return "", false
+		}
+		return name.Local, true
+	case "xlink":
+		if name.Local == "href" {
+			return "xlink:href", true
+		}
+	case "xml":
+		if name.Local == "space" || name.Local == "lang" {
+			return "xml:" + name.Local, true
+		}
+	}
+	return "", false
+}
+
+// safeSVGAttr reports whether an attribute can be kept
+func safeSVGAttr(element, name, value string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "on") {
+		return false
+	}
+
+	// Browsers ignore whitespace and control characters in URL schemes
+	compact := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+	if strings.Contains(compact, "javascript:") || strings.Contains(compact, "vbscript:") {
+		return false
+	}
+
+	if lower == "href" {
+		if strings.HasPrefix(compact, "#") {
+			return true
+		}
+		if element == "image" {
+			for _, prefix := range svgDataImagePrefixes {
+				if strings.HasPrefix(compact, prefix) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if lower == "style" {
+		for _, risky := range []string{"@import", "expression(", "behavior:", "-moz-binding", `\`} {
+			if strings.Contains(compact, risky) {
+				return false
+			}
+		}
+	}
+
+	// Paint servers, clip paths and filters may only point into the document
+	for rest := compact; ; {
+		i := strings.Index(rest, "url(")
+		if i < 0 {
+			return true
+		}
+		rest = strings.TrimLeft(rest[i+len("url("):], `"'`)
+		if !strings.HasPrefix(rest, "#") {
+			return false
+		}
+	}
+}
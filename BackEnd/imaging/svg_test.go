@@ -0,0 +1,62 @@
+package imaging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestSanitizeSVG checks that active content is removed and drawing kept
+func TestSanitizeSVG(t *testing.T) {
+	const open = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"shapes kept", `<svg viewBox="0 0 10 10"><rect width="10" height="10" fill="red"/></svg>`,
+			open[:len(open)-1] + ` viewBox="0 0 10 10"><rect width="10" height="10" fill="red"></rect></svg>`},
+		{"script removed", `<svg><script>alert(1)</script><circle r="1"/></svg>`, open + `<circle r="1"></circle></svg>`},
+		{"event handler removed", `<svg onload="alert(1)"><g OnClick="x()"/></svg>`, open + `<g></g></svg>`},
+		{"foreign object removed", `<svg><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"><iframe src="x"/></body></foreignObject></svg>`, open + `</svg>`},
+		{"internal reference kept", `<svg><use xlink:href="#a"/><rect fill="url(#g)"/></svg>`, open + `<use xlink:href="#a"></use><rect fill="url(#g)"></rect></svg>`},
+		{"external reference removed", `<svg><use href="https://evil.example/x.svg#a"/><rect fill="url('https://evil.example/p')"/></svg>`, open + `<use></use><rect></rect></svg>`},
+		{"javascript href removed", `<svg><use href=" java&#x09;script:alert(1)"/></svg>`, open + `<use></use></svg>`},
+		{"link removed", `<svg><a href="javascript:alert(1)"><text>hi</text></a></svg>`, open + `</svg>`},
+		{"animation removed", `<svg><set attributeName="href" to="javascript:alert(1)"/></svg>`, open + `</svg>`},
+		{"style removed", `<svg><style>@import url(https://evil.example/x.css)</style><rect style="fill:red"/></svg>`, open + `<rect style="fill:red"></rect></svg>`},
+		{"risky inline style removed", `<svg><rect style="background:url(https://evil.example/t)"/></svg>`, open + `<rect></rect></svg>`},
+		{"inline raster image kept", `<svg><image href="data:image/png;base64,AAAA"/><image href="https://evil.example/t.png"/></svg>`, open + `<image href="data:image/png;base64,AAAA"></image><image></image></svg>`},
+		{"foreign attributes removed", `<svg xmlns:inkscape="x" inkscape:label="l"><!-- note --><?pi x?><g/></svg>`, open + `<g></g></svg>`},
+		{"text escaped", `<svg><text>&lt;script&gt; &amp; co</text></svg>`, open + `<text>&lt;script&gt; &amp; co</text></svg>`},
+		{"prefixed elements", `<svg:svg xmlns:svg="http://www.w3.org/2000/svg"><svg:rect/></svg:svg>`, open + `<rect></rect></svg>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeSVG([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("SanitizeSVG failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("SanitizeSVG(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSanitizeSVGRejects checks that documents that are not plain SVG fail
+func TestSanitizeSVGRejects(t *testing.T) {
+	tests := map[string]string{
+		"not svg":         `<html><body>hi</body></html>`,
+		"plain text":      `just some text`,
+		"unclosed":        `<svg><g></svg>`,
+		"two roots":       `<svg></svg><svg></svg>`,
+		"custom entities": `<!DOCTYPE svg [<!ENTITY a "aaaaaaaa">]><svg><text>&a;</text></svg>`,
+		"too deep":        `<svg>` + strings.Repeat("<g>", maxSVGDepth) + strings.Repeat("</g>", maxSVGDepth) + `</svg>`,
+	}
+	for name, src := range tests {
+		if _, err := SanitizeSVG([]byte(src)); !errors.Is(err, ErrInvalidSVG) {
+			t.Errorf("%s: got %v, want ErrInvalidSVG", name, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// RequireRole only lets users holding one of roles through. It must run
+// after AuthMiddleware, so list it before AuthMiddleware in ApplyMiddleware.
+func RequireRole(db *sql.DB, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value("userID").(int)
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":  "Unauthorized",
+					"status": "error",
+				})
+				return
+			}
+
+			allowed, err := controllers.HasRole(db, userID, roles...)
+			if err != nil {
+				logger.Error("Failed to check role for user %d: %v", userID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":  "Internal server error",
+					"status": "error",
+				})
+				return
+			}
+
+			if !allowed {
+				logger.Warning("Forbidden request - user_id: %d, path: %s, required roles: %v", userID, r.URL.Path, roles)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":  "Forbidden",
+					"status": "error",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// SetTusHeaders advertises a tus resumable upload endpoint: the protocol
+// version, the extensions it supports and the largest upload it takes. Put
+// it outside CORSMiddleware so OPTIONS requests are answered with them too.
+func SetTusHeaders(version string, maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Tus-Resumable", version)
+			w.Header().Set("Tus-Version", version)
+			w.Header().Set("Tus-Extension", "creation,expiration,termination")
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// WebSocketAuthMiddleware authenticates WebSocket upgrades. A "ticket" query
+// parameter issued by /api/ws/ticket takes precedence over the session
+// cookie; without one the request falls back to AuthMiddleware.
+func WebSocketAuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		cookieAuth := AuthMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ticket := r.URL.Query().Get("ticket")
+			if ticket == "" {
+				cookieAuth.ServeHTTP(w, r)
+				return
+			}
+
+			userID, err := controllers.ConsumeWSTicket(db, ticket)
+			if err != nil {
+				logger.Warning("Rejected websocket ticket - remote_addr: %s, error: %v", r.RemoteAddr, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":   "Unauthorized",
+					"status":  "error",
+					"message": "Invalid or expired connection ticket.",
+				})
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "userID", userID)
+			ctx = context.WithValue(ctx, "wsTicketAuth", true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
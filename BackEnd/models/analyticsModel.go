@@ -0,0 +1,23 @@
+package models
+
+// PostAnalytics is the activity on a post shown to its author
+type PostAnalytics struct {
+	PostID int `json:"post_id"`
+	// Totals over the post's lifetime
+	Views    int `json:"views"`
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	Comments int `json:"comments"`
+	// Days holds one entry per UTC day of the requested range, oldest first
+	Days []PostDayStats `json:"days"`
+}
+
+// PostDayStats counts the activity on a post during one UTC day. Votes are
+// counted on the day they were cast and only while they stand.
+type PostDayStats struct {
+	Day      string `json:"day"`
+	Views    int    `json:"views"`
+	Likes    int    `json:"likes"`
+	Dislikes int    `json:"dislikes"`
+	Comments int    `json:"comments"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Announcement is a system-wide or targeted message from an operator
+type Announcement struct {
+	ID        int       `json:"id"`
+	AuthorID  int       `json:"author_id"`
+	Content   string    `json:"content"`
+	Severity  string    `json:"severity"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// UserIDs limits delivery to these users; empty means everyone
+	UserIDs []int `json:"user_ids,omitempty"`
+}
+
+// AnnouncementRequest is the body accepted by the admin announcement endpoint
+type AnnouncementRequest struct {
+	Content  string `json:"content"`
+	Severity string `json:"severity"`
+	// ExpiresInMinutes defaults to one hour when zero
+	ExpiresInMinutes int   `json:"expires_in_minutes"`
+	UserIDs          []int `json:"user_ids,omitempty"`
+}
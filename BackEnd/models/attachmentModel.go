@@ -0,0 +1,19 @@
+package models
+
+// Attachment is a file shown with a post, in Position order
+type Attachment struct {
+	ID       int    `json:"id"`
+	PostID   int    `json:"post_id"`
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+	// MediumURL and ThumbnailURL are scaled down copies for feeds and
+	// previews; they equal URL when the original is small enough
+	MediumURL    string `json:"medium_url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	AltText      string `json:"alt_text"`
+	MimeType     string `json:"mime_type"`
+	// Width and Height are in pixels, or 0 when unknown (SVG)
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Size   int64 `json:"size"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Bookmark is a private reference a user keeps to a post or comment
+type Bookmark struct {
+	ID         int    `json:"id"`
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	// FolderID is 0 for bookmarks outside any folder
+	FolderID   int       `json:"folder_id,omitempty"`
+	FolderName string    `json:"folder_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// PostID, PostTitle, Author and Excerpt summarise the target; for a
+	// comment they describe the comment and the post it belongs to
+	PostID    int    `json:"post_id"`
+	PostTitle string `json:"post_title"`
+	Author    string `json:"author"`
+	Excerpt   string `json:"excerpt"`
+	// Hidden is set when a moderator hid the target or its post; Excerpt is
+	// then empty
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// BookmarkFolder groups a user's bookmarks under a label
+type BookmarkFolder struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"created_at"`
+}
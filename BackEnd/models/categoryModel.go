@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Category is a forum section posts can be filed under
+type Category struct {
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	Archived    bool      `json:"archived"`
+	PostCount   int       `json:"post_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CategoryRequest is the body accepted by the admin category endpoints. On
+// update, nil fields are left unchanged.
+type CategoryRequest struct {
+	Slug        *string `json:"slug"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Position    *int    `json:"position"`
+	Archived    *bool   `json:"archived"`
+}
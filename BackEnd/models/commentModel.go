@@ -20,9 +20,19 @@ type Comment struct {
 	Timestamp time.Time
 	Replies   []Comment `json:"replies,omitempty"`
 	Depth     int       `json:"depth"`
+	// ContentHTML is Content rendered by the richtext package
+	ContentHTML string
+	// MediaURL is the stored path of an image attached with a resumable
+	// upload, or empty
+	MediaURL string
+	// Hidden comments were taken down by a moderator and are listed without
+	// their content
+	Hidden bool
 }
 
 type CommentRequest struct {
 	Content  string `json:"content"`
 	ParentID int    `json:"parentID,omitempty"`
+	// UploadID names a completed resumable upload to attach
+	UploadID string `json:"upload_id,omitempty"`
 }
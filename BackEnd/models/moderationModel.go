@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ModerationLogEntry records one moderator action
+type ModerationLogEntry struct {
+	ID          int    `json:"id"`
+	ModeratorID int    `json:"moderator_id"`
+	Moderator   string `json:"moderator"`
+	Action      string `json:"action"`
+	TargetType  string `json:"target_type"`
+	TargetID    int    `json:"target_id"`
+	// Details describes the action, such as where a post was pinned
+	Details   string    `json:"details,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationRequest is the body of a moderator action; every field is
+// optional
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+	// Category is the slug of the category feed a pin applies to; empty
+	// means the main feed
+	Category string `json:"category"`
+	// Position orders pins, lowest first; a pin placed at a taken position
+	// goes before the pin holding it, and zero places it last
+	Position int `json:"position"`
+	// Days is how long a suspension lasts; zero uses the default
+	Days int `json:"days"`
+}
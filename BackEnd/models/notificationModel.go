@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Notification tells a user that someone interacted with them or their
+// content
+type Notification struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	Type      string `json:"type"`
+	ActorID   int    `json:"actor_id"`
+	ActorName string `json:"actor_name"`
+	// SourceType and SourceID name the post, comment or message that
+	// triggered the notification
+	SourceType string `json:"source_type"`
+	SourceID   int    `json:"source_id"`
+	// PostID is the post to open, or 0 for direct messages
+	PostID    int        `json:"post_id,omitempty"`
+	Excerpt   string     `json:"excerpt"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
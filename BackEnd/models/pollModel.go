@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Poll is a question attached to a post that users vote on once
+type Poll struct {
+	ID             int    `json:"id"`
+	PostID         int    `json:"post_id"`
+	Question       string `json:"question"`
+	MultipleChoice bool   `json:"multiple_choice"`
+	// Anonymous polls only publish vote counts, never who voted
+	Anonymous bool         `json:"anonymous"`
+	ClosesAt  *time.Time   `json:"closes_at,omitempty"`
+	Closed    bool         `json:"closed"`
+	Options   []PollOption `json:"options"`
+	// TotalVoters counts ballots; in a multiple choice poll it can be lower
+	// than the sum of the option votes
+	TotalVoters int `json:"total_voters"`
+	// UserVotes lists the options the requesting user picked
+	UserVotes []int `json:"user_votes,omitempty"`
+}
+
+// PollOption is one answer of a poll with its results
+type PollOption struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+	Votes int    `json:"votes"`
+	// Voters holds the nicknames of the voters of a public poll
+	Voters []string `json:"voters,omitempty"`
+}
+
+// PollRequest is the poll sent along with a new post
+type PollRequest struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+// PollVoteRequest is the body of a poll vote
+type PollVoteRequest struct {
+	OptionIDs []int `json:"option_ids"`
+}
@@ -19,10 +19,43 @@ type Post struct {
 	Dislikes  int
 	UserVote  sql.NullString 
 	Content   string
+	// ContentHTML is Content rendered by the richtext package, cached in
+	// the content_html column
+	ContentHTML string
 	ImageUrl  sql.NullString
 	Timestamp time.Time
+	// EditedAt is nil until the post is first edited
+	EditedAt  *time.Time
+	// Status is draft, scheduled or published; an empty status on insert
+	// publishes immediately
+	Status    string
+	// PublishAt is when a scheduled post goes live
+	PublishAt *time.Time
 	Comments  []Comment
 	CommentCount int
+	// ViewCount counts distinct views; recent views are written in batches
+	// and may not be included yet
+	ViewCount int
+	// Locked posts accept no new comments
+	Locked bool
+	// Announcement marks a post a moderator singled out as an announcement
+	Announcement bool
+	// Pinned is set on the pinned posts leading the first page of a feed
+	Pinned bool
+	// Hidden posts were taken down by a moderator; only their author and
+	// moderators can still open them
+	Hidden bool
+	// Bookmarked reports whether the requesting user bookmarked the post
+	Bookmarked bool
+	// Categories, when set on insert or update, replaces the post's links in
+	// post_categories and the legacy Category text
+	Categories []Category `json:",omitempty"`
+	// Attachments are the post's files in display order. When set on insert
+	// or update they replace the stored set, and ImageUrl follows the first
+	// one for older clients.
+	Attachments []Attachment
+	// Poll, when set on insert, is stored with the post
+	Poll *Poll `json:",omitempty"`
 }
 
 type PostRequest struct {
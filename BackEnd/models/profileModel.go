@@ -24,6 +24,7 @@ type ProfileResponse struct {
 	LastName  string `json:"last_name"`
 	Email     string `json:"email"`
 	CreatedAt string `json:"created_at"`
+	AvatarURL string `json:"avatar_url,omitempty"`
 }
 
 // ProfileUpdateRequest represents the data that can be updated in a profile
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Report is one user's flag on a post, comment or direct message
+type Report struct {
+	ID         int    `json:"id"`
+	ReporterID int    `json:"reporter_id"`
+	Reporter   string `json:"reporter"`
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details,omitempty"`
+	Status     string `json:"status"`
+	// Resolution is the moderator action that closed the report
+	Resolution string     `json:"resolution,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ReportRequest is the body of a new report
+type ReportRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+// ReportedTarget gathers the reports on one post, comment or message for
+// the moderation queue
+type ReportedTarget struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	AuthorID   int    `json:"author_id"`
+	Author     string `json:"author"`
+	// PostID is the post to open for posts and comments, 0 for messages
+	PostID int `json:"post_id,omitempty"`
+	// Excerpt previews the content; Deleted is set once it is gone
+	Excerpt     string `json:"excerpt"`
+	Hidden      bool   `json:"hidden"`
+	Deleted     bool   `json:"deleted"`
+	ReportCount int    `json:"report_count"`
+	// Reasons counts the reports per reason
+	Reasons          map[string]int `json:"reasons"`
+	FirstReportedAt  time.Time      `json:"first_reported_at"`
+	LatestReportedAt time.Time      `json:"latest_reported_at"`
+	Reports          []Report       `json:"reports"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PostRevision is a full snapshot of a post's editable fields after an edit.
+// Revision 1 is the post as originally published.
+type PostRevision struct {
+	ID       int    `json:"id"`
+	PostID   int    `json:"post_id"`
+	Revision int    `json:"revision"`
+	EditorID int    `json:"editor_id"`
+	Editor   string `json:"editor"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	ImageUrl string `json:"image_url,omitempty"`
+	// RolledBackFrom is set when this revision restored an earlier one
+	RolledBackFrom *int      `json:"rolled_back_from,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
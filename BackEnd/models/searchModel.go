@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SearchResult is a post or comment matching a search query. Title and
+// Snippet are HTML escaped with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Author    string    `json:"author"`
+	Timestamp time.Time `json:"timestamp"`
+	Score     float64   `json:"score"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// UploadSweepReport describes a run of the upload sweeper
+type UploadSweepReport struct {
+	DryRun bool `json:"dry_run"`
+	// Scanned counts the stored files, Referenced those still in use and
+	// Pending the unused ones that are within the grace period
+	Scanned    int `json:"scanned"`
+	Referenced int `json:"referenced"`
+	Pending    int `json:"pending"`
+	// Orphans were deleted, or would be in a dry run
+	Orphans     []OrphanUpload `json:"orphans"`
+	OrphanBytes int64          `json:"orphan_bytes"`
+	// Missing lists referenced paths whose file is gone
+	Missing []string `json:"missing"`
+}
+
+// OrphanUpload is a stored file that nothing refers to
+type OrphanUpload struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// ResumableUpload is a file sent in chunks over the tus protocol
+type ResumableUpload struct {
+	ID     string `json:"id"`
+	UserID int    `json:"-"`
+	// Target is what the file is for: post, comment or message
+	Target   string `json:"target"`
+	Filename string `json:"filename"`
+	AltText  string `json:"alt_text"`
+	// Length is the size of the whole file and Offset how much of it has
+	// arrived
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"offset"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Attachment describes the stored file once the upload is complete
+	Attachment *Attachment `json:"attachment,omitempty"`
+}
@@ -0,0 +1,50 @@
+// Package ranking scores posts for the hot, top and controversial feeds.
+// Scores only change when a post is voted on, commented on or published, so
+// they are stored with the post and the feeds sort on an index.
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// epoch is the zero point of the time part of the hot score
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// HotTimeScale is how much later a post has to be published to rank as high
+// as an older one with ten times its score
+const HotTimeScale = 12*time.Hour + 30*time.Minute
+
+// CommentWeight is how many votes a comment counts as in the hot score
+const CommentWeight = 0.5
+
+// Hot returns the hot score of a post. The order of magnitude of its net
+// votes, with comments counted in, is added to the time it was published, so
+// newer posts start higher and older ones need ever more votes to keep up.
+// The score is fixed between votes, which keeps it sortable, and the decay
+// happens as newer posts overtake it.
+func Hot(likes, dislikes, comments int, published time.Time) float64 {
+	score := float64(likes-dislikes) + CommentWeight*float64(comments)
+	order := math.Log10(math.Max(math.Abs(score), 1))
+	if score < 0 {
+		order = -order
+	}
+	return order + published.Sub(epoch).Seconds()/HotTimeScale.Seconds()
+}
+
+// Top returns the top score of a post, its net votes
+func Top(likes, dislikes int) int {
+	return likes - dislikes
+}
+
+// Controversy returns the controversial score of a post: high when it has
+// many votes split evenly between likes and dislikes, and 0 when every vote
+// agrees
+func Controversy(likes, dislikes int) float64 {
+	if likes <= 0 || dislikes <= 0 {
+		return 0
+	}
+	magnitude := float64(likes + dislikes)
+	balance := float64(min(likes, dislikes)) / float64(max(likes, dislikes))
+	return math.Pow(magnitude, balance)
+}
@@ -0,0 +1,62 @@
+package ranking
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHot checks that votes and comments raise a post and age lowers it
+func TestHot(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	if Hot(10, 0, 0, now) <= Hot(1, 0, 0, now) {
+		t.Error("Expected more likes to rank higher")
+	}
+	if Hot(0, 5, 0, now) >= Hot(0, 0, 0, now) {
+		t.Error("Expected dislikes to rank lower")
+	}
+	if Hot(1, 0, 4, now) <= Hot(1, 0, 0, now) {
+		t.Error("Expected comments to rank higher")
+	}
+	if Hot(0, 0, 0, now) != Hot(1, 0, 0, now) {
+		t.Error("Expected a single vote to count as nothing")
+	}
+	if Hot(5, 0, 0, now.Add(-time.Hour)) >= Hot(5, 0, 0, now) {
+		t.Error("Expected an older post with the same votes to rank lower")
+	}
+
+	// Ten times the votes make up for one HotTimeScale
+	older := Hot(100, 0, 0, now.Add(-HotTimeScale))
+	newer := Hot(10, 0, 0, now)
+	if diff := older - newer; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected equal scores, got %v and %v", older, newer)
+	}
+}
+
+// TestTop checks the net vote score
+func TestTop(t *testing.T) {
+	if got := Top(7, 3); got != 4 {
+		t.Errorf("Expected 4, got %d", got)
+	}
+}
+
+// TestControversy checks that evenly split votes rank highest
+func TestControversy(t *testing.T) {
+	tests := []struct {
+		name                    string
+		likes, dislikes         int
+		lessLikes, lessDislikes int
+	}{
+		{"split beats one-sided", 10, 10, 19, 1},
+		{"more votes beat fewer", 50, 50, 5, 5},
+		{"even beats uneven", 10, 10, 15, 5},
+	}
+	for _, tt := range tests {
+		if Controversy(tt.likes, tt.dislikes) <= Controversy(tt.lessLikes, tt.lessDislikes) {
+			t.Errorf("%s: expected %d/%d above %d/%d", tt.name, tt.likes, tt.dislikes, tt.lessLikes, tt.lessDislikes)
+		}
+	}
+	if Controversy(10, 0) != 0 || Controversy(0, 0) != 0 {
+		t.Error("Expected posts without opposing votes to score 0")
+	}
+}
@@ -0,0 +1,322 @@
+// Package richtext renders the Markdown subset accepted in posts, comments and
+// direct messages, and sanitizes the resulting HTML against a strict allowlist.
+package richtext
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// maxDepth bounds nesting of blockquotes and inline emphasis so hostile input
+// cannot drive the renderer into deep recursion
+const maxDepth = 8
+
+// Render converts Markdown source to sanitized HTML. Supported syntax:
+// fenced code blocks, inline code, links and bare URLs, ordered and unordered
+// lists, blockquotes, **strong**, *em*, ~~strikethrough~~ and ||spoilers||.
+// Anything else is rendered as escaped text.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return Sanitize(b.String())
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	var para []string
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		b.WriteString("<p>")
+		for i, line := range para {
+			if i > 0 {
+				b.WriteString("<br>")
+			}
+			b.WriteString(renderInline(line, 0))
+		}
+		b.WriteString("</p>")
+		para = nil
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			lang := codeLanguage(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			i++
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "```" {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // closing fence, or past the end when unterminated
+			b.WriteString("<pre><code")
+			if lang != "" {
+				b.WriteString(` class="language-` + lang + `"`)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>")
+
+		case strings.HasPrefix(trimmed, ">") && depth < maxDepth:
+			flush()
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>")
+			renderBlocks(b, quoted, depth+1)
+			b.WriteString("</blockquote>")
+
+		case listItem(trimmed, false) != "" || listItem(trimmed, true) != "":
+			flush()
+			ordered := listItem(trimmed, true) != ""
+			tag := "ul"
+			if ordered {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag + ">")
+			for i < len(lines) {
+				item := listItem(strings.TrimSpace(lines[i]), ordered)
+				if item == "" {
+					break
+				}
+				b.WriteString("<li>" + renderInline(item, 0) + "</li>")
+				i++
+			}
+			b.WriteString("</" + tag + ">")
+
+		default:
+			para = append(para, trimmed)
+			i++
+		}
+	}
+	flush()
+}
+
+// listItem returns the text of a list item line, or "" if the line is not an
+// item of the requested kind
+func listItem(line string, ordered bool) string {
+	if !ordered {
+		if len(line) > 2 && strings.ContainsRune("-*+", rune(line[0])) && line[1] == ' ' {
+			return strings.TrimSpace(line[2:])
+		}
+		return ""
+	}
+	n := 0
+	for n < len(line) && n < 9 && line[n] >= '0' && line[n] <= '9' {
+		n++
+	}
+	if n == 0 || n+1 >= len(line) || (line[n] != '.' && line[n] != ')') || line[n+1] != ' ' {
+		return ""
+	}
+	return strings.TrimSpace(line[n+2:])
+}
+
+// codeLanguage keeps a fence's info string only when it looks like a
+// language name, since it ends up in a class attribute
+func codeLanguage(info string) string {
+	info = strings.TrimSpace(info)
+	if len(info) == 0 || len(info) > 20 {
+		return ""
+	}
+	for _, r := range info {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '+') {
+			return ""
+		}
+	}
+	return strings.ToLower(info)
+}
+
+// inlineSpans maps paired delimiters to the tags they render as. Longer
+// delimiters come first so ** wins over *.
+var inlineSpans = []struct {
+	delim, open, close string
+}{
+	{"||", `<span class="spoiler">`, "</span>"},
+	{"**", "<strong>", "</strong>"},
+	{"~~", "<del>", "</del>"},
+	{"*", "<em>", "</em>"},
+	{"_", "<em>", "</em>"},
+}
+
+func renderInline(s string, depth int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~|[]()>#+-.!", s[i+1]) >= 0 {
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		}
+
+		if c == '`' {
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				b.WriteString("<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		}
+
+		if c == '[' {
+			if text, href, n, ok := parseLink(s[i:]); ok {
+				if safe := SafeURL(href); safe != "" {
+					b.WriteString(`<a href="` + html.EscapeString(safe) + `" rel="nofollow noopener noreferrer">`)
+					b.WriteString(renderInline(text, depth+1))
+					b.WriteString("</a>")
+				} else {
+					b.WriteString(renderInline(text, depth+1))
+				}
+				i += n
+				continue
+			}
+		}
+
+		if (c == 'h' || c == 'H') && (i == 0 || !isWordByte(s[i-1])) {
+			if n := bareURLLength(s[i:]); n > 0 {
+				if safe := SafeURL(s[i : i+n]); safe != "" {
+					escaped := html.EscapeString(safe)
+					b.WriteString(`<a href="` + escaped + `" rel="nofollow noopener noreferrer">` + escaped + "</a>")
+					i += n
+					continue
+				}
+			}
+		}
+
+		if depth < maxDepth {
+			if n, out := renderSpan(s, i, depth); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// renderSpan tries each paired delimiter at s[i:] and returns the number of
+// bytes consumed and the rendered HTML when one matches
+func renderSpan(s string, i, depth int) (int, string) {
+	for _, span := range inlineSpans {
+		d := span.delim
+		if !strings.HasPrefix(s[i:], d) {
+			continue
+		}
+		// Underscores inside words (snake_case) are not emphasis
+		if d == "_" && i > 0 && isWordByte(s[i-1]) {
+			return 0, ""
+		}
+		rest := s[i+len(d):]
+		end := strings.Index(rest, d)
+		if end <= 0 || rest[0] == ' ' || rest[end-1] == ' ' {
+			continue
+		}
+		if d == "_" && i+len(d)+end+1 < len(s) && isWordByte(s[i+len(d)+end+1]) {
+			continue
+		}
+		return len(d)*2 + end, span.open + renderInline(rest[:end], depth+1) + span.close
+	}
+	return 0, ""
+}
+
+// parseLink parses [text](href) at the start of s
+func parseLink(s string) (text, href string, n int, ok bool) {
+	closeText := strings.Index(s, "](")
+	if closeText < 1 {
+		return "", "", 0, false
+	}
+	// Balance parentheses so URLs like wiki/Go_(language) survive
+	closeHref, nesting := -1, 0
+	for j := closeText + 2; j < len(s) && closeHref < 0; j++ {
+		switch s[j] {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				closeHref = j - closeText - 2
+			}
+			nesting--
+		}
+	}
+	if closeHref < 1 {
+		return "", "", 0, false
+	}
+	text = s[1:closeText]
+	href = strings.TrimSpace(s[closeText+2 : closeText+2+closeHref])
+	if strings.ContainsAny(href, " \t") {
+		return "", "", 0, false
+	}
+	return text, href, closeText + 3 + closeHref, true
+}
+
+// bareURLLength returns the length of an http(s) URL at the start of s,
+// excluding trailing punctuation, or 0 if s does not start with one
+func bareURLLength(s string) int {
+	lower := strings.ToLower(s[:min(len(s), 8)])
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return 0
+	}
+	n := strings.IndexAny(s, " \t\n<>\"")
+	if n < 0 {
+		n = len(s)
+	}
+	for n > 0 && strings.IndexByte(".,;:!?)]'*_~|", s[n-1]) >= 0 {
+		n--
+	}
+	if n <= len("https://") {
+		return 0
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// SafeURL returns href normalised if it is an http, https or mailto URL or a
+// site-relative path, and "" otherwise
+func SafeURL(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || len(href) > 2048 {
+		return ""
+	}
+	for _, r := range href {
+		if r < 0x20 || r == 0x7f || r == ' ' {
+			return ""
+		}
+	}
+	if strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") && !strings.HasPrefix(href, "/\\") {
+		return href
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return ""
+		}
+		return u.String()
+	case "mailto":
+		return u.String()
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package richtext
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRender checks the supported Markdown subset
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"plain text", "hello world", "<p>hello world</p>"},
+		{"line breaks", "one\ntwo", "<p>one<br>two</p>"},
+		{"paragraphs", "one\n\ntwo", "<p>one</p><p>two</p>"},
+		{"ampersand escaped once", "Tom & Jerry <3", "<p>Tom &amp; Jerry &lt;3</p>"},
+		{"emphasis", "**bold** *em* _em_ ~~gone~~", "<p><strong>bold</strong> <em>em</em> <em>em</em> <del>gone</del></p>"},
+		{"snake case", "snake_case_name", "<p>snake_case_name</p>"},
+		{"spoiler", "the end: ||he dies||", `<p>the end: <span class="spoiler">he dies</span></p>`},
+		{"inline code", "run `rm -rf <dir>`", "<p>run <code>rm -rf &lt;dir&gt;</code></p>"},
+		{"code block", "```go\nif a < b {}\n```", `<pre><code class="language-go">if a &lt; b {}</code></pre>`},
+		{"code block bad language", "```\"><script>\nx\n```", "<pre><code>x</code></pre>"},
+		{"unordered list", "- one\n- two", "<ul><li>one</li><li>two</li></ul>"},
+		{"ordered list", "1. one\n2. two", "<ol><li>one</li><li>two</li></ol>"},
+		{"blockquote", "> quoted\n> text", "<blockquote><p>quoted<br>text</p></blockquote>"},
+		{"link", "[site](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">site</a></p>`},
+		{"relative link", "[post](/post/1)", `<p><a href="/post/1" rel="nofollow noopener noreferrer">post</a></p>`},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>"},
+		{"protocol relative link", "[x](//evil.example)", "<p>x</p>"},
+		{"bare url", "see https://example.com.", `<p>see <a href="https://example.com" rel="nofollow noopener noreferrer">https://example.com</a>.</p>`},
+		{"escaped markup", `\*not em\*`, "<p>*not em*</p>"},
+		{"raw html", `<img src=x onerror=alert(1)>`, "<p>&lt;img src=x onerror=alert(1)&gt;</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.src); got != tt.want {
+				t.Errorf("Render(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderDeepNesting makes sure pathological input stays bounded
+func TestRenderDeepNesting(t *testing.T) {
+	src := strings.Repeat(">", 1000) + " x\n" + strings.Repeat("**", 1000)
+	if out := Render(src); !strings.Contains(out, "x") {
+		t.Errorf("expected text to survive, got %q", out)
+	}
+}
+
+// TestSanitize checks the allowlist applied to arbitrary HTML
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script removed", `<p>hi<script>alert(1)</script></p>`, "<p>hi</p>"},
+		{"event handler dropped", `<p onclick="x()">hi</p>`, "<p>hi</p>"},
+		{"unknown tag dropped", `<img src=x onerror=alert(1)>text`, "text"},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"entity encoded scheme", `<a href="jav&#x61;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"safe href", `<a href="https://example.com" target="_blank">x</a>`, `<a href="https://example.com" rel="nofollow noopener noreferrer">x</a>`},
+		{"span class", `<span class="spoiler x">a</span><span class="spoiler">b</span>`, `<span>a</span><span class="spoiler">b</span>`},
+		{"unbalanced", `<strong><em>a</strong>`, "<strong><em>a</em></strong>"},
+		{"stray close", `a</p>b`, "ab"},
+		{"unclosed", `<ul><li>a`, "<ul><li>a</li></ul>"},
+		{"nested anchors", `<a href="/a">x<a href="/b">y</a></a>`, `<a href="/a" rel="nofollow noopener noreferrer">xy</a>`},
+		{"comment", `a<!-- <script> -->b`, "ab"},
+		{"entities kept", `&amp;lt; &lt; & &bogus;`, "&amp;lt; &lt; &amp; &amp;bogus;"},
+		{"lone angle", `a < b`, "a &lt; b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSanitizeIdempotent checks that rendered output passes through the
+// sanitizer unchanged
+func TestSanitizeIdempotent(t *testing.T) {
+	src := "**a** & [b](https://x.test/?q=1&r=2)\n\n```js\n<x>\n```\n- ||c||"
+	out := Render(src)
+	if again := Sanitize(out); again != out {
+		t.Errorf("Sanitize changed rendered output\n got %s\nwant %s", again, out)
+	}
+}
@@ -0,0 +1,279 @@
+package richtext
+
+import (
+	"html"
+	"strings"
+)
+
+// allowedTags lists the elements Sanitize keeps. Every other tag is dropped
+// while its text content is kept and escaped.
+var allowedTags = map[string]bool{
+	"p": true, "br": true, "strong": true, "em": true, "del": true,
+	"code": true, "pre": true, "blockquote": true, "ul": true, "ol": true,
+	"li": true, "a": true, "span": true,
+}
+
+// droppedContent lists elements whose content is removed along with the tag
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "noscript": true, "template": true, "textarea": true,
+	"title": true, "xmp": true, "noembed": true, "noframes": true,
+}
+
+// Sanitize reduces an HTML fragment to the tags and attributes Render
+// produces: a with a safe href, code with a language- class and span with the
+// spoiler class. Text is re-escaped, tags are balanced and comments dropped,
+// so the result is safe to insert with innerHTML.
+func Sanitize(fragment string) string {
+	var b strings.Builder
+	var open []string
+	skipUntil := ""
+
+	for i := 0; i < len(fragment); {
+		c := fragment[i]
+
+		if c == '<' {
+			if strings.HasPrefix(fragment[i:], "<!--") {
+				end := strings.Index(fragment[i+4:], "-->")
+				if end < 0 {
+					break
+				}
+				i += end + 7
+				continue
+			}
+			if tag, ok := parseTag(fragment[i:]); ok {
+				i += tag.length
+				if skipUntil != "" {
+					if tag.closing && tag.name == skipUntil {
+						skipUntil = ""
+					}
+					continue
+				}
+				if droppedContent[tag.name] {
+					if !tag.closing && !tag.selfClosing {
+						skipUntil = tag.name
+					}
+					continue
+				}
+				if !allowedTags[tag.name] {
+					continue
+				}
+				if tag.closing {
+					open = closeTag(&b, open, tag.name)
+					continue
+				}
+				if tag.name == "a" && contains(open, "a") {
+					continue
+				}
+				b.WriteString(renderTag(tag))
+				if tag.name != "br" {
+					open = append(open, tag.name)
+				}
+				continue
+			}
+		}
+
+		if skipUntil != "" {
+			i++
+			continue
+		}
+
+		if c == '&' {
+			if n := entityLength(fragment[i:]); n > 0 {
+				b.WriteString(html.EscapeString(html.UnescapeString(fragment[i : i+n])))
+				i += n
+				continue
+			}
+		}
+
+		// Copy the run of plain text up to the next markup character
+		end := strings.IndexAny(fragment[i+1:], "<&")
+		if end < 0 {
+			end = len(fragment) - i - 1
+		}
+		b.WriteString(html.EscapeString(fragment[i : i+1+end]))
+		i += 1 + end
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j] + ">")
+	}
+	return b.String()
+}
+
+// closeTag closes name and anything opened inside it; a close tag with no
+// matching open tag is dropped
+func closeTag(b *strings.Builder, open []string, name string) []string {
+	for j := len(open) - 1; j >= 0; j-- {
+		if open[j] != name {
+			continue
+		}
+		for k := len(open) - 1; k >= j; k-- {
+			b.WriteString("</" + open[k] + ">")
+		}
+		return open[:j]
+	}
+	return open
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type tagToken struct {
+	name        string
+	closing     bool
+	selfClosing bool
+	attrs       map[string]string
+	length      int
+}
+
+// renderTag writes an allowed start tag with only its allowed attributes
+func renderTag(t tagToken) string {
+	switch t.name {
+	case "a":
+		href := SafeURL(t.attrs["href"])
+		if href == "" {
+			return "<a>"
+		}
+		return `<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">`
+	case "code":
+		if lang := strings.TrimPrefix(t.attrs["class"], "language-"); lang != t.attrs["class"] && codeLanguage(lang) != "" {
+			return `<code class="language-` + codeLanguage(lang) + `">`
+		}
+	case "span":
+		if t.attrs["class"] == "spoiler" {
+			return `<span class="spoiler">`
+		}
+	}
+	return "<" + t.name + ">"
+}
+
+// parseTag parses a start or end tag at the start of s. It reports false when
+// s does not begin with something a browser would treat as a tag, in which
+// case the '<' is escaped as text.
+func parseTag(s string) (tagToken, bool) {
+	var t tagToken
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && isTagNameByte(s[i]) {
+		i++
+	}
+	if i == start || !isASCIILetter(s[start]) {
+		return t, false
+	}
+	t.name = strings.ToLower(s[start:i])
+	t.attrs = map[string]string{}
+
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return t, false
+		}
+		switch s[i] {
+		case '>':
+			t.length = i + 1
+			return t, true
+		case '/':
+			t.selfClosing = true
+			i++
+			continue
+		}
+
+		nameStart := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[nameStart:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i >= len(s) {
+				return t, false
+			}
+			if q := s[i]; q == '"' || q == '\'' {
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					return t, false
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valStart := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[valStart:i]
+			}
+		}
+		if name != "" {
+			if _, seen := t.attrs[name]; !seen {
+				t.attrs[name] = html.UnescapeString(value)
+			}
+		}
+	}
+}
+
+// entityLength returns the length of a character reference at the start of
+// s, or 0 when the '&' is a literal ampersand
+func entityLength(s string) int {
+	end := strings.IndexByte(s, ';')
+	if end < 2 || end > 32 {
+		return 0
+	}
+	ref := s[1:end]
+	if ref[0] == '#' {
+		digits := ref[1:]
+		if len(digits) > 0 && (digits[0] == 'x' || digits[0] == 'X') {
+			digits = digits[1:]
+		}
+		if digits == "" {
+			return 0
+		}
+		for _, r := range digits {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				return 0
+			}
+		}
+	} else {
+		for _, r := range ref {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return 0
+			}
+		}
+	}
+	// Unknown names are left alone by UnescapeString; treat them as text
+	if html.UnescapeString(s[:end+1]) == s[:end+1] {
+		return 0
+	}
+	return end + 1
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isTagNameByte(c byte) bool {
+	return isASCIILetter(c) || c >= '0' && c <= '9' || c == '-'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
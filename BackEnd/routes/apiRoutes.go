@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"compress/flate"
 	"database/sql"
 	"net/http"
 	"time"
@@ -14,8 +15,9 @@ import (
 )
 
 type WebSocketHandler struct {
-	Db  *sql.DB
-	Hub *websockets.MessageHub
+	Db      *sql.DB
+	Hub     *websockets.MessageHub
+	Origins *websockets.OriginPolicy
 }
 
 func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -34,12 +36,24 @@ func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Upgrade HTTP connection to WebSocket
+	// Upgrade HTTP connection to WebSocket, negotiating permessage-deflate
+	// and the frame encoding through the subprotocol header
 	upgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    4096,
+		WriteBufferSize:   4096,
+		EnableCompression: true,
+		Subprotocols:      websockets.Subprotocols(),
 		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins in development
+			// Ticket holders did not rely on the ambient session cookie,
+			// so only cookie authenticated upgrades are origin checked
+			if ticketAuth, _ := r.Context().Value("wsTicketAuth").(bool); ticketAuth {
+				return true
+			}
+			if !h.Origins.Check(r) {
+				logger.Warning("Rejected WebSocket upgrade from origin %q", r.Header.Get("Origin"))
+				return false
+			}
+			return true
 		},
 	}
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -47,21 +61,24 @@ func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		logger.Error("Failed to upgrade to WebSocket: %v", err)
 		return
 	}
+	conn.SetCompressionLevel(flate.BestSpeed)
 
-	logger.Info("WebSocket connection established with userID: %d", userID)
+	codec := websockets.CodecFor(conn.Subprotocol())
+	logger.Info("WebSocket connection established with userID: %d (codec: %s)", userID, codec.Name())
 
 	// Create new client using the properly typed userID
-	client := &websockets.Client{
-		Hub:      h.Hub,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		UserID:   userID,
-		IsOnline: true,
+	client := websockets.NewClient(h.Hub, conn, userID, codec)
+
+	// Register client with hub, unless it is already shutting down
+	select {
+	case h.Hub.Register <- client:
+	case <-h.Hub.Done():
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting"))
+		conn.Close()
+		return
 	}
 
-	// Register client with hub
-	h.Hub.Register <- client
-
 	// Start client routines
 	go client.WritePump()
 	go client.ReadPump()
@@ -77,6 +94,16 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	commentVotesController := controllers.NewCommentVotesController(db)
 	profileHandler := handlers.NewProfileHandler(db)
 	messageController := controllers.NewMessageController(db)
+	announcementController := controllers.NewAnnouncementController(db)
+	categoryController := controllers.NewCategoryController(db)
+	searchController := controllers.NewSearchController(db)
+	notificationController := controllers.NewNotificationController(db)
+	bookmarkController := controllers.NewBookmarkController(db)
+	pollController := controllers.NewPollController(db)
+	avatarController := controllers.NewAvatarController(db)
+	resumableUploadController := controllers.NewResumableUploadController(db, controllers.ResumableUploadDir())
+	moderationController := controllers.NewModerationController(db)
+	reportController := controllers.NewReportController(db)
 
 	// Rate limiters
 	authLimiter := middleware.NewRateLimiter(5, time.Minute)     // 5 attempts per minute
@@ -85,6 +112,7 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	likesLimiter := middleware.NewRateLimiter(30, time.Minute)   // 30 likes per minute
 	viewLimiter := middleware.NewRateLimiter(60, time.Minute)    // 60 views per minute
 	pageLimiter := middleware.NewRateLimiter(30, time.Minute)    // 30 requests per minute
+	reportLimiter := middleware.NewRateLimiter(10, time.Minute)  // 10 reports per minute
 
 	// Auth routes
 	http.Handle("/api/login", middleware.ApplyMiddleware(
@@ -126,7 +154,7 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	))
 
 	http.Handle("/api/posts/create", middleware.ApplyMiddleware(
-		handlers.CreatePostHandler(postController),
+		handlers.CreatePostHandler(postController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -136,6 +164,27 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 		middleware.ValidatePathAndMethod("/api/posts/create", http.MethodPost),
 	))
 
+	http.Handle("/api/posts/update", middleware.ApplyMiddleware(
+		handlers.UpdatePostHandler(postController, hub),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		postLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.ValidatePathAndMethod("/api/posts/update", http.MethodPut),
+	))
+
+	http.Handle("/api/posts/drafts", middleware.ApplyMiddleware(
+		handlers.GetDraftsHandler(postController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		viewLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/posts/drafts", http.MethodGet),
+	))
+
 	http.Handle("/api/posts/", middleware.ApplyMiddleware(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract post ID from URL path
@@ -167,9 +216,77 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 		middleware.ErrorHandler(handlers.ServeErrorPage),
 	))
 
+	// Revision routes
+	http.Handle("/api/posts/{postId}/revisions", middleware.ApplyMiddleware(
+		handlers.GetPostRevisionsHandler(postController),
+		middleware.SetCSPHeaders,
+		middleware.CORSMiddleware,
+		viewLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+	))
+
+	http.Handle("/api/posts/{postId}/revisions/{revision}/rollback", middleware.ApplyMiddleware(
+		handlers.RollbackPostHandler(postController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		postLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Post analytics, for the post's author
+	http.Handle("/api/posts/{postId}/analytics", middleware.ApplyMiddleware(
+		handlers.GetPostAnalyticsHandler(postController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		viewLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+	))
+
+	// Poll routes
+	http.Handle("/api/posts/{postId}/poll", middleware.ApplyMiddleware(
+		handlers.GetPollHandler(pollController),
+		middleware.SetCSPHeaders,
+		middleware.CORSMiddleware,
+		viewLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+	))
+
+	http.Handle("/api/posts/{postId}/poll/vote", middleware.ApplyMiddleware(
+		handlers.VotePollHandler(pollController, hub),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		likesLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Category routes
+	http.Handle("/api/categories", middleware.ApplyMiddleware(
+		handlers.GetCategoriesHandler(categoryController),
+		middleware.SetCSPHeaders,
+		middleware.CORSMiddleware,
+		viewLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/categories", http.MethodGet),
+	))
+
+	// Search routes
+	http.Handle("/api/search", middleware.ApplyMiddleware(
+		handlers.SearchHandler(searchController),
+		middleware.SetCSPHeaders,
+		middleware.CORSMiddleware,
+		pageLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/search", http.MethodGet),
+	))
+
 	// Comments routes
 	http.Handle("/api/posts/{postId}/comments", middleware.ApplyMiddleware(
-		handlers.CommentHandler(commentController),
+		handlers.CommentHandler(commentController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -180,7 +297,7 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 
 	// Votes routes
 	http.Handle("/api/posts/vote", middleware.ApplyMiddleware(
-		handlers.CreateUserVoteHandler(likesController),
+		handlers.CreateUserVoteHandler(likesController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -191,7 +308,7 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	))
 
 	http.Handle("/api/comments/vote", middleware.ApplyMiddleware(
-		handlers.CreateCommentVoteHandler(commentVotesController),
+		handlers.CreateCommentVoteHandler(commentVotesController, hub),
 		middleware.AuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
 		middleware.VerifyCSRFMiddleware(db),
@@ -253,6 +370,45 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 		middleware.ValidatePathAndMethod("/api/user/likes", http.MethodGet),
 	))
 
+	http.Handle("/api/user/avatar", middleware.ApplyMiddleware(
+		handlers.AvatarHandler(avatarController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		postLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Bookmark routes
+	http.Handle("/api/user/bookmarks", middleware.ApplyMiddleware(
+		handlers.BookmarksHandler(bookmarkController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		pageLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/user/bookmarks/folders", middleware.ApplyMiddleware(
+		handlers.BookmarkFoldersHandler(bookmarkController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/user/bookmarks/folders/{id}", middleware.ApplyMiddleware(
+		handlers.BookmarkFolderHandler(bookmarkController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
 	// Message routes
 	http.Handle("/api/messages/conversations", middleware.ApplyMiddleware(
 		handlers.GetConversationsHandler(messageController),
@@ -262,6 +418,46 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 		middleware.ErrorHandler(handlers.ServeErrorPage),
 	))
 
+	http.Handle("/api/messages/contacts", middleware.ApplyMiddleware(
+		handlers.GetContactsHandler(messageController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/messages/contacts", http.MethodGet),
+	))
+
+	http.Handle("/api/messages/attachments", middleware.ApplyMiddleware(
+		handlers.MessageAttachmentHandler(db),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		postLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Resumable uploads speak tus, whose clients rely on the exact status
+	// codes; the error page middleware would turn them all into 200
+	http.Handle("/api/uploads/tus", middleware.ApplyMiddleware(
+		handlers.ResumableUploadsHandler(resumableUploadController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.SetTusHeaders(controllers.TusVersion, controllers.MaxResumableUploadSize),
+		postLimiter.RateLimit,
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/uploads/tus/{id}", middleware.ApplyMiddleware(
+		handlers.ResumableUploadHandler(resumableUploadController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.SetTusHeaders(controllers.TusVersion, controllers.MaxResumableUploadSize),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
 	http.Handle("/api/messages/{userId}", middleware.ApplyMiddleware(
 		handlers.GetMessagesHandler(messageController),
 		middleware.SetCSPHeaders,
@@ -270,15 +466,161 @@ func APIRoutes(db *sql.DB, hub *websockets.MessageHub) {
 		middleware.ErrorHandler(handlers.ServeErrorPage),
 	))
 
-	// WebSocket route
+	// Notification routes
+	http.Handle("/api/notifications", middleware.ApplyMiddleware(
+		handlers.GetNotificationsHandler(notificationController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		pageLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/notifications", http.MethodGet),
+	))
+
+	http.Handle("/api/notifications/{id}/read", middleware.ApplyMiddleware(
+		handlers.MarkNotificationReadHandler(notificationController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/notifications/read-all", middleware.ApplyMiddleware(
+		handlers.MarkAllNotificationsReadHandler(notificationController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.ValidatePathAndMethod("/api/notifications/read-all", http.MethodPost),
+	))
+
+	http.Handle("/api/notifications/preferences", middleware.ApplyMiddleware(
+		handlers.NotificationPreferencesHandler(notificationController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Report routes
+	http.Handle("/api/reports", middleware.ApplyMiddleware(
+		handlers.CreateReportHandler(reportController),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		reportLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.ValidatePathAndMethod("/api/reports", http.MethodPost),
+	))
+
+	// Admin routes
+	http.Handle("/api/admin/announcements", middleware.ApplyMiddleware(
+		handlers.CreateAnnouncementHandler(announcementController, hub),
+		middleware.RequireRole(db, controllers.RoleAdmin),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.ValidatePathAndMethod("/api/admin/announcements", http.MethodPost),
+	))
+
+	http.Handle("/api/admin/categories", middleware.ApplyMiddleware(
+		handlers.AdminCategoriesHandler(categoryController),
+		middleware.RequireRole(db, controllers.RoleAdmin),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/admin/categories/{id}", middleware.ApplyMiddleware(
+		handlers.AdminCategoryHandler(categoryController),
+		middleware.RequireRole(db, controllers.RoleAdmin),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/admin/uploads/sweep", middleware.ApplyMiddleware(
+		handlers.UploadSweepHandler(db),
+		middleware.RequireRole(db, controllers.RoleAdmin),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// Moderation routes
+	http.Handle("/api/moderation/posts/{postId}/{action}", middleware.ApplyMiddleware(
+		handlers.ModeratePostHandler(moderationController),
+		middleware.RequireRole(db, controllers.RoleModerator),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	http.Handle("/api/moderation/log", middleware.ApplyMiddleware(
+		handlers.ModerationLogHandler(moderationController),
+		middleware.RequireRole(db, controllers.RoleModerator),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/moderation/log", http.MethodGet),
+	))
+
+	http.Handle("/api/moderation/reports", middleware.ApplyMiddleware(
+		handlers.ReportQueueHandler(moderationController),
+		middleware.RequireRole(db, controllers.RoleModerator),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.ValidatePathAndMethod("/api/moderation/reports", http.MethodGet),
+	))
+
+	http.Handle("/api/moderation/reports/{targetType}/{targetId}/{action}", middleware.ApplyMiddleware(
+		handlers.ResolveReportsHandler(moderationController, hub),
+		middleware.RequireRole(db, controllers.RoleModerator),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+	))
+
+	// WebSocket routes
+	http.Handle("/api/ws/ticket", middleware.ApplyMiddleware(
+		handlers.IssueWSTicketHandler(db),
+		middleware.SetCSPHeaders,
+		middleware.AuthMiddleware,
+		middleware.CORSMiddleware,
+		pageLimiter.RateLimit,
+		middleware.ErrorHandler(handlers.ServeErrorPage),
+		middleware.VerifyCSRFMiddleware(db),
+		middleware.ValidatePathAndMethod("/api/ws/ticket", http.MethodPost),
+	))
+
 	http.Handle("/ws", middleware.ApplyMiddleware(
 		&WebSocketHandler{
-			Db:  db,
-			Hub: hub,
+			Db:      db,
+			Hub:     hub,
+			Origins: websockets.OriginPolicyFromEnv(),
 		},
 		middleware.SetCSPHeaders,
 		middleware.CORSMiddleware,
-		middleware.AuthMiddleware,
+		middleware.WebSocketAuthMiddleware(db),
 	))
 	// User list route (returns registered users)
 	http.Handle("/api/users", middleware.ApplyMiddleware(
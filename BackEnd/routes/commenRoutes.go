@@ -8,9 +8,10 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/handlers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/middleware"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
-func CommentRoute(db *sql.DB) {
+func CommentRoute(db *sql.DB, hub *websockets.MessageHub) {
 	commentController := controllers.NewCommentController(db)
 
 	// Rate limit for comments
@@ -18,7 +19,7 @@ func CommentRoute(db *sql.DB) {
 
 	// Handle POST /api/posts/{postId}/comments
 	http.Handle("/api/posts/", middleware.ApplyMiddleware(
-		handlers.CommentHandler(commentController),
+		handlers.CommentHandler(commentController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -40,7 +41,7 @@ func CommentRoute(db *sql.DB) {
 
 	// Handle PUT /api/comments/{commentId}
 	http.Handle("/api/comments/", middleware.ApplyMiddleware(
-		handlers.UpdateCommentHandler(commentController),
+		handlers.UpdateCommentHandler(commentController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
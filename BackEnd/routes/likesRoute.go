@@ -8,9 +8,10 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/handlers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/middleware"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
-func LikesRoutes(db *sql.DB) {
+func LikesRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	// Create controllers
 	LikesController := controllers.NewLikesController(db)
 	CommentVotesController := controllers.NewCommentVotesController(db)
@@ -20,7 +21,7 @@ func LikesRoutes(db *sql.DB) {
 
 	// Post vote routes
 	http.Handle("/likePost", middleware.ApplyMiddleware(
-		handlers.CreateUserVoteHandler(LikesController),
+		handlers.CreateUserVoteHandler(LikesController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -42,7 +43,7 @@ func LikesRoutes(db *sql.DB) {
 
 	// Comment vote routes
 	http.Handle("/commentVote", middleware.ApplyMiddleware(
-		handlers.CreateCommentVoteHandler(CommentVotesController),
+		handlers.CreateCommentVoteHandler(CommentVotesController, hub),
 		middleware.AuthMiddleware,
 		middleware.ErrorHandler(handlers.ServeErrorPage),
 		middleware.VerifyCSRFMiddleware(db),
@@ -8,9 +8,10 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/handlers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/middleware"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
-func PostRoutes(db *sql.DB) {
+func PostRoutes(db *sql.DB, hub *websockets.MessageHub) {
 	PostController := controllers.NewPostController(db)
 
 	// Rate limit for post creation
@@ -39,7 +40,7 @@ func PostRoutes(db *sql.DB) {
 	))
 
 	http.Handle("/createPost", middleware.ApplyMiddleware(
-		handlers.CreatePostHandler(PostController),
+		handlers.CreatePostHandler(PostController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
@@ -50,7 +51,7 @@ func PostRoutes(db *sql.DB) {
 	))
 
 	http.Handle("/updatePost", middleware.ApplyMiddleware(
-		handlers.UpdatePostHandler(PostController),
+		handlers.UpdatePostHandler(PostController, hub),
 		middleware.SetCSPHeaders,
 		middleware.AuthMiddleware,
 		middleware.CORSMiddleware,
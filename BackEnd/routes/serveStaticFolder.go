@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"path/filepath"
 
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/handlers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/middleware"
 )
 
@@ -51,8 +53,8 @@ func ServeStaticFolder() {
 
 	// Serve user uploads
 	http.Handle("/uploads/", middleware.ApplyMiddleware(
-		http.StripPrefix("/uploads/", fileServer(http.Dir("./uploads"))),
-		middleware.SetCSPHeaders,
+		http.StripPrefix("/uploads/", handlers.ServeUploadsHandler(controllers.Blobs())),
+		middleware.SetUploadHeaders(controllers.SVGUploadMode() == controllers.SVGModeAttachment),
 	))
 
 	// SPA handler - serve index.html for all non-file routes
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore keeps blobs as files below a directory. Its signed URLs point
+// at /uploads/ on this server and carry an HMAC of the key and expiry.
+type LocalStore struct {
+	root   string
+	secret []byte
+}
+
+// NewLocalStore returns a store below root. Without a secret, a random one
+// is used, so signed URLs stop working when the process restarts.
+func NewLocalStore(root string, secret []byte) *LocalStore {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return &LocalStore{root: root, secret: secret}
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file first, so readers never see a
+// partial file
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	os.Chmod(tmp.Name(), 0o644)
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (*Blob, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+	return &Blob{
+		Body:        f,
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// List walks the directory; files left behind by interrupted writes are
+// listed too, so they can be cleaned up
+func (s *LocalStore) List(ctx context.Context, prefix string, fn func(BlobInfo) error) error {
+	return filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		// A missing root or a file deleted during the walk is not an error
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
+
+func (s *LocalStore) SignedURL(key string, expires time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": {expiry}, "signature": {s.sign(key, expiry)}}
+	return "/" + PathForKey(key) + "?" + query.Encode(), nil
+}
+
+// VerifyURL checks the expires and signature parameters of a signed URL
+func (s *LocalStore) VerifyURL(key string, query url.Values, now time.Time) bool {
+	expiry := query.Get("expires")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(key, expiry)))
+}
+
+func (s *LocalStore) sign(key, expiry string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
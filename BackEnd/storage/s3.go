@@ -0,0 +1,353 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket on an S3-compatible service. Objects are
+// addressed path-style (endpoint/bucket/key), which every S3-compatible
+// service accepts.
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store keeps blobs in an S3 bucket, signing requests with AWS Signature
+// Version 4
+type S3Store struct {
+	config   S3Config
+	endpoint *url.URL
+	Client   *http.Client
+	// now is replaced in tests
+	now func() time.Time
+}
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" || config.Region == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("S3 bucket, region and credentials are required")
+	}
+	return &S3Store{
+		config:   config,
+		endpoint: endpoint,
+		Client:   &http.Client{Timeout: 60 * time.Second},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the path-style URL of an object
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.config.Bucket + "/" + key
+	u.RawPath = ""
+	return &u, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	// The payload hash is part of the signature, so the body is read first;
+	// uploads are bounded well below what that makes costly
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(body)
+	resp, err := s.do(req, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (*Blob, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &Blob{
+		Body:        resp.Body,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+		ModTime:     modTime,
+	}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the part of a ListObjectsV2 response the store reads
+type listResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List pages through the bucket with ListObjectsV2
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(BlobInfo) error) error {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.config.Bucket
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		u.RawQuery = canonicalQuery(query)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read S3 listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			if err := fn(BlobInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// SignedURL returns a presigned GET URL for the object
+func (s *S3Store) SignedURL(key string, expires time.Duration) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	return s.presign(http.MethodGet, u, s.now(), expires), nil
+}
+
+// do signs and sends a request; error responses are turned into errors
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// sign adds Signature Version 4 authorization headers to req
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	canonicalHeaders, signedHeaders := canonicalHeaderList(headers)
+
+	scope := s.scope(now)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := s.signature(now, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// presign returns u with Signature Version 4 query authentication
+func (s *S3Store) presign(method string, u *url.URL, now time.Time, expires time.Duration) string {
+	now = now.UTC()
+	scope := s.scope(now)
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.config.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalHeaders, signedHeaders := canonicalHeaderList(map[string]string{"host": u.Host})
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", s.signature(now, scope, canonicalRequest))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(query)
+	return signed.String()
+}
+
+func (s *S3Store) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+func (s *S3Store) signature(now time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" + now.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalHeaderList returns the canonical header block and the signed
+// header list for lowercase header names
+func canonicalHeaderList(headers map[string]string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// canonicalURI encodes every path segment; S3 paths are not normalized
+func canonicalURI(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	if p := strings.Join(segments, "/"); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the RFC 3986 unreserved
+// characters, as Signature Version 4 requires
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+// Package storage keeps uploaded files in a blob store. Files are addressed
+// by keys such as "posts/1_20240101120000_ab12cd34.png"; the local store
+// keeps them on disk and the S3 store in any S3-compatible bucket, so several
+// instances can share their media.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Blob is a stored file being read. Body is an io.ReadSeeker when the
+// backend supports it.
+type Blob struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobInfo describes a stored blob when listing
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores uploaded files
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens a blob; it returns ErrNotFound when there is none
+	Get(ctx context.Context, key string) (*Blob, error)
+	// Delete removes a blob; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that grants read access to a blob until it
+	// expires, for blobs that are not served publicly
+	SignedURL(key string, expires time.Duration) (string, error)
+	// List calls fn for every blob whose key starts with prefix, stopping at
+	// the first error fn returns
+	List(ctx context.Context, prefix string, fn func(BlobInfo) error) error
+}
+
+// URLVerifier is implemented by stores whose signed URLs point back at this
+// server; the uploads handler uses it to check them
+type URLVerifier interface {
+	VerifyURL(key string, query url.Values, now time.Time) bool
+}
+
+// URLPrefix is where blobs are served. Stored paths such as
+// "uploads/posts/a.png" are the URL of the blob with key "posts/a.png".
+const URLPrefix = "uploads/"
+
+// PathForKey returns the stored path of a blob
+func PathForKey(key string) string {
+	return URLPrefix + key
+}
+
+// KeyForPath returns the key of a stored path, accepting a leading slash
+func KeyForPath(p string) (string, error) {
+	key := strings.TrimPrefix(strings.TrimPrefix(p, "/"), URLPrefix)
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ValidateKey checks that a key is a clean relative path, so it can neither
+// escape the local store's directory nor address another bucket
+func ValidateKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "\\\x00") || path.IsAbs(key) ||
+		path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+// FromEnv builds the store selected by BLOB_STORE:
+//
+//	local (default)  files under UPLOADS_DIR (default "uploads"); signed URLs
+//	                 are signed with BLOB_SIGNING_KEY, or a random key that
+//	                 changes on restart
+//	s3               S3_BUCKET at S3_ENDPOINT in S3_REGION (default
+//	                 us-east-1) with S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY
+func FromEnv() (BlobStore, error) {
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		root := os.Getenv("UPLOADS_DIR")
+		if root == "" {
+			root = "uploads"
+		}
+		return NewLocalStore(root, []byte(os.Getenv("BLOB_SIGNING_KEY"))), nil
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          region,
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", backend)
+	}
+}
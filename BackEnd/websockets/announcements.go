@@ -0,0 +1,91 @@
+package websockets
+
+import (
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// AnnouncementMessage is the system_announcement frame sent to clients
+type AnnouncementMessage struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Content   string    `json:"content"`
+	Severity  string    `json:"severity"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newAnnouncementMessage(a *models.Announcement) *AnnouncementMessage {
+	return &AnnouncementMessage{
+		Type:      "system_announcement",
+		ID:        a.ID,
+		Content:   a.Content,
+		Severity:  a.Severity,
+		CreatedAt: a.CreatedAt,
+		ExpiresAt: a.ExpiresAt,
+	}
+}
+
+// Announce queues a stored announcement for delivery to connected clients.
+// It is a no-op once the hub has stopped.
+func (h *MessageHub) Announce(a *models.Announcement) {
+	select {
+	case h.Announcements <- a:
+	case <-h.done:
+	}
+}
+
+// deliverAnnouncement sends an announcement to every client, or only to the
+// announcement's recipients when it has any
+func (h *MessageHub) deliverAnnouncement(a *models.Announcement) {
+	recipients := make(map[int64]bool, len(a.UserIDs))
+	for _, id := range a.UserIDs {
+		recipients[int64(id)] = true
+	}
+
+	msg := newAnnouncementMessage(a)
+	for client := range h.Clients {
+		if len(recipients) > 0 && !recipients[client.UserID] {
+			continue
+		}
+		h.sendTo(client, msg)
+	}
+}
+
+// sendActiveAnnouncements sends every unexpired announcement to a client
+// that has just registered. Delivery is not tracked, so a client gets them
+// again on each connect until they expire.
+func (h *MessageHub) sendActiveAnnouncements(client *Client) {
+	announcements, err := controllers.NewAnnouncementController(h.Db).GetActiveAnnouncements(int(client.UserID))
+	if err != nil {
+		logger.Error("Failed to load announcements for user %d: %v", client.UserID, err)
+		return
+	}
+	for i := range announcements {
+		if !h.sendTo(client, newAnnouncementMessage(&announcements[i])) {
+			return
+		}
+	}
+}
+
+// sendTo encodes v with the client's codec and queues it, dropping the
+// client if its buffer is full. It reports whether the client is still
+// connected; a dropped client must not be sent anything else.
+func (h *MessageHub) sendTo(client *Client, v any) bool {
+	data, err := client.codec().Marshal(v)
+	if err != nil {
+		logger.Error("Failed to serialize frame for client %d: %v", client.UserID, err)
+		return true
+	}
+	select {
+	case client.Send <- data:
+		return true
+	default:
+		close(client.Send)
+		delete(h.Clients, client)
+		return false
+	}
+}
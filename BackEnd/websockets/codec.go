@@ -0,0 +1,84 @@
+package websockets
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocol names a client can request in Sec-WebSocket-Protocol to pick
+// the frame encoding. Clients that request none get JSON.
+const (
+	SubprotocolJSON    = "forum.json.v1"
+	SubprotocolMsgpack = "forum.msgpack.v1"
+)
+
+// Codec encodes and decodes hub frames for a single connection.
+type Codec interface {
+	// Name returns the subprotocol the codec is negotiated under.
+	Name() string
+	// FrameType returns the websocket frame type used for encoded payloads.
+	FrameType() int
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default codec and the one browsers use.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string   { return SubprotocolJSON }
+func (JSONCodec) FrameType() int { return websocket.TextMessage }
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec encodes frames as MessagePack binary messages. Struct fields
+// keep their json tag names so both codecs expose the same keys.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string   { return SubprotocolMsgpack }
+func (MsgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+var codecs = map[string]Codec{
+	SubprotocolJSON:    JSONCodec{},
+	SubprotocolMsgpack: MsgpackCodec{},
+}
+
+// Subprotocols lists the supported subprotocols in order of preference.
+// JSON comes first, so MessagePack is only used by clients that ask for it
+// alone.
+func Subprotocols() []string {
+	return []string{SubprotocolJSON, SubprotocolMsgpack}
+}
+
+// CodecFor returns the codec for a negotiated subprotocol, falling back to
+// JSON when the client did not ask for one.
+func CodecFor(subprotocol string) Codec {
+	if codec, ok := codecs[subprotocol]; ok {
+		return codec
+	}
+	return JSONCodec{}
+}
@@ -0,0 +1,176 @@
+package websockets
+
+import (
+	"compress/flate"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestCodecRoundTrip checks that every codec decodes what it encodes
+func TestCodecRoundTrip(t *testing.T) {
+	sent := Message{
+		Type:       "message",
+		Content:    "Hello **there**",
+		SenderID:   1,
+		ReceiverID: 2,
+		Timestamp:  time.Date(2025, time.March, 1, 12, 30, 0, 0, time.UTC),
+		TempID:     "temp-1",
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := codec.Marshal(sent)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			var got Message
+			if err := codec.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Failed to unmarshal: %v", err)
+			}
+			if !got.Timestamp.Equal(sent.Timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", sent.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = sent.Timestamp
+			if got != sent {
+				t.Errorf("Expected %+v, got %+v", sent, got)
+			}
+
+			// Both codecs expose the json tag names
+			var fields map[string]any
+			if err := codec.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Failed to unmarshal into a map: %v", err)
+			}
+			for _, key := range []string{"type", "sender_id", "receiver_id", "temp_id"} {
+				if _, ok := fields[key]; !ok {
+					t.Errorf("Expected key %q, got %v", key, fields)
+				}
+			}
+			if _, ok := fields["content_html"]; ok {
+				t.Error("Expected empty content_html to be omitted")
+			}
+		})
+	}
+
+	if (JSONCodec{}).FrameType() != websocket.TextMessage || (MsgpackCodec{}).FrameType() != websocket.BinaryMessage {
+		t.Error("Expected JSON in text frames and MessagePack in binary frames")
+	}
+}
+
+// TestCodecFor checks the fallback to JSON
+func TestCodecFor(t *testing.T) {
+	for subprotocol, want := range map[string]string{
+		SubprotocolJSON:    SubprotocolJSON,
+		SubprotocolMsgpack: SubprotocolMsgpack,
+		"":                 SubprotocolJSON,
+		"forum.cbor.v1":    SubprotocolJSON,
+	} {
+		if got := CodecFor(subprotocol).Name(); got != want {
+			t.Errorf("CodecFor(%q) = %s, want %s", subprotocol, got, want)
+		}
+	}
+}
+
+// echoServer upgrades like the /ws route and echoes every frame back
+// through the negotiated codec
+func echoServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{
+		EnableCompression: true,
+		Subprotocols:      Subprotocols(),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetCompressionLevel(flate.BestSpeed)
+
+		codec := CodecFor(conn.Subprotocol())
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg Message
+			if err := codec.Unmarshal(data, &msg); err != nil {
+				t.Errorf("Failed to decode frame: %v", err)
+				return
+			}
+			out, err := codec.Marshal(msg)
+			if err != nil {
+				t.Errorf("Failed to encode frame: %v", err)
+				return
+			}
+			if err := conn.WriteMessage(codec.FrameType(), out); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+// TestSubprotocolNegotiation checks that the requested encoding and
+// permessage-deflate are agreed on, and that frames survive the trip
+func TestSubprotocolNegotiation(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		name      string
+		requested []string
+		want      string
+		compress  bool
+	}{
+		{"msgpack", []string{SubprotocolMsgpack}, SubprotocolMsgpack, true},
+		{"json", []string{SubprotocolJSON}, SubprotocolJSON, false},
+		{"preference", []string{SubprotocolMsgpack, SubprotocolJSON}, SubprotocolJSON, true},
+		{"none", nil, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: tc.requested, EnableCompression: tc.compress}
+			conn, resp, err := dialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+
+			if conn.Subprotocol() != tc.want {
+				t.Fatalf("Expected subprotocol %q, got %q", tc.want, conn.Subprotocol())
+			}
+			deflate := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+			if deflate != tc.compress {
+				t.Errorf("Expected permessage-deflate %v, got %v", tc.compress, deflate)
+			}
+
+			// A large, repetitive body exercises the compressor
+			codec := CodecFor(conn.Subprotocol())
+			sent := Message{Type: "message", Content: strings.Repeat("compress me ", 2000), SenderID: 1, ReceiverID: 2}
+			data, err := codec.Marshal(sent)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			conn.EnableWriteCompression(tc.compress)
+			if err := conn.WriteMessage(codec.FrameType(), data); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			frameType, reply, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if frameType != codec.FrameType() {
+				t.Errorf("Expected frame type %d, got %d", codec.FrameType(), frameType)
+			}
+			var got Message
+			if err := codec.Unmarshal(reply, &got); err != nil || got.Content != sent.Content {
+				t.Errorf("Expected the message echoed back, got %d bytes (%v)", len(reply), err)
+			}
+		})
+	}
+}
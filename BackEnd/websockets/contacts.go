@@ -0,0 +1,51 @@
+package websockets
+
+import (
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+)
+
+// ContactUpdateMessage tells a client that a contact moved to the top of its
+// contact list because a message was exchanged with them
+type ContactUpdateMessage struct {
+	Type            string    `json:"type"`
+	UserID          int64     `json:"user_id"`
+	LastMessage     string    `json:"last_message"`
+	LastMessageTime time.Time `json:"last_message_time"`
+	LastSenderID    int64     `json:"last_sender_id"`
+	UnreadCount     int       `json:"unread_count"`
+}
+
+// pushContactUpdates sends a contact_update to both participants of a
+// stored direct message
+func (h *MessageHub) pushContactUpdates(message *Message) {
+	mc := controllers.NewMessageController(h.Db)
+	preview := controllers.PreviewText(message.Content, controllers.MessagePreviewLength)
+
+	participants := []struct{ owner, contact int64 }{
+		{message.ReceiverID, message.SenderID},
+		{message.SenderID, message.ReceiverID},
+	}
+	for _, p := range participants {
+		unread, err := mc.GetUnreadCount(p.owner, p.contact)
+		if err != nil {
+			logger.Error("Failed to count unread messages for user %d: %v", p.owner, err)
+		}
+
+		update := &ContactUpdateMessage{
+			Type:            "contact_update",
+			UserID:          p.contact,
+			LastMessage:     preview,
+			LastMessageTime: message.Timestamp,
+			LastSenderID:    message.SenderID,
+			UnreadCount:     unread,
+		}
+		for client := range h.Clients {
+			if client.UserID == p.owner {
+				h.sendTo(client, update)
+			}
+		}
+	}
+}
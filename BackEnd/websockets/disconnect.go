@@ -0,0 +1,42 @@
+package websockets
+
+import (
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/gorilla/websocket"
+)
+
+// disconnectRequest asks the hub to close every connection of a user
+type disconnectRequest struct {
+	userID int64
+	reason string
+}
+
+// DisconnectUser closes every connection of a user, such as one just
+// suspended, with a policy violation close frame giving reason. It is a
+// no-op once the hub has stopped.
+func (h *MessageHub) DisconnectUser(userID int64, reason string) {
+	select {
+	case h.disconnects <- disconnectRequest{userID: userID, reason: reason}:
+	case <-h.done:
+	}
+}
+
+// disconnectUser drops the user's clients; WritePump flushes what is
+// buffered and then sends the close frame
+func (h *MessageHub) disconnectUser(req disconnectRequest) {
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, req.reason)
+	dropped := false
+	for client := range h.Clients {
+		if client.UserID != req.userID {
+			continue
+		}
+		client.closeReason = closeMsg
+		close(client.Send)
+		delete(h.Clients, client)
+		dropped = true
+	}
+	if dropped {
+		logger.Info("Disconnected WebSocket clients of user %d: %s", req.userID, req.reason)
+		h.updateUserStatus(req.userID, false)
+	}
+}
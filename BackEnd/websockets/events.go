@@ -0,0 +1,78 @@
+package websockets
+
+import (
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// Event is a server-side frame queued for delivery through the hub
+type Event struct {
+	// UserIDs limits delivery to these users; empty means every client
+	UserIDs []int64
+	Payload any
+}
+
+// PostCreatedMessage tells clients that a post has gone live
+type PostCreatedMessage struct {
+	Type      string    `json:"type"`
+	PostID    int       `json:"post_id"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	UserID    int       `json:"user_id"`
+	Category  string    `json:"category"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PollResultsMessage carries the current results of a poll after a vote
+type PollResultsMessage struct {
+	Type   string       `json:"type"`
+	PostID int          `json:"post_id"`
+	Poll   *models.Poll `json:"poll"`
+}
+
+// Publish queues payload for the given users, or for everyone when no users
+// are given. It is a no-op once the hub has stopped.
+func (h *MessageHub) Publish(payload any, userIDs ...int64) {
+	select {
+	case h.Events <- &Event{UserIDs: userIDs, Payload: payload}:
+	case <-h.done:
+	}
+}
+
+// PublishPostCreated announces a newly published post to every client
+func (h *MessageHub) PublishPostCreated(post models.Post) {
+	h.Publish(&PostCreatedMessage{
+		Type:      "post_created",
+		PostID:    post.ID,
+		Title:     post.Title,
+		Author:    post.Author,
+		UserID:    post.UserID,
+		Category:  post.Category,
+		Timestamp: post.Timestamp,
+	})
+}
+
+// PublishPollResults sends the results of a poll to every client. The
+// poll must not carry a viewer's own votes.
+func (h *MessageHub) PublishPollResults(poll *models.Poll) {
+	h.Publish(&PollResultsMessage{
+		Type:   "poll_results",
+		PostID: poll.PostID,
+		Poll:   poll,
+	})
+}
+
+// deliverEvent sends an event to its recipients
+func (h *MessageHub) deliverEvent(event *Event) {
+	recipients := make(map[int64]bool, len(event.UserIDs))
+	for _, id := range event.UserIDs {
+		recipients[id] = true
+	}
+	for client := range h.Clients {
+		if len(recipients) > 0 && !recipients[client.UserID] {
+			continue
+		}
+		h.sendTo(client, event.Payload)
+	}
+}
@@ -1,12 +1,15 @@
 package websockets
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"sync"
 	"time"
 
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/richtext"
 	"github.com/gorilla/websocket"
 )
 
@@ -15,8 +18,18 @@ type MessageHub struct {
     Broadcast  chan *Message
     Register   chan *Client
     Unregister chan *Client
+    // Announcements carries stored announcements to deliver live
+    Announcements chan *models.Announcement
+    // Events carries server-side frames such as post_created
+    Events     chan *Event
+    // disconnects carries users whose connections must be closed, see
+    // DisconnectUser
+    disconnects chan disconnectRequest
     Mu         sync.RWMutex
     Db         *sql.DB
+    // DrainTimeout bounds how long shutdown waits for clients to flush
+    DrainTimeout time.Duration
+    done         chan struct{}
 }
 
 type Client struct {
@@ -26,11 +39,27 @@ type Client struct {
     UserID   int64
     IsOnline bool
     LastSeen time.Time
+    // Codec encodes frames for this connection; nil means JSON.
+    Codec    Codec
+    // closeReason is sent in the close frame once Send is closed
+    closeReason []byte
+    done        chan struct{}
 }
 
 type Message struct {
     Type       string    `json:"type"`
+    // ID is the stored message's ID, set by the server
+    ID         int64     `json:"id,omitempty"`
     Content    string    `json:"content,omitempty"`
+    // ContentHTML is filled in by the server from Content; clients never set it
+    ContentHTML string   `json:"content_html,omitempty"`
+    // MediaPath is the stored path of an image uploaded to
+    // /api/messages/attachments; MediaURL is its signed URL, set by the server
+    MediaPath  string    `json:"media_path,omitempty"`
+    MediaURL   string    `json:"media_url,omitempty"`
+    // UploadID names a completed resumable upload to send instead of
+    // MediaPath
+    UploadID   string    `json:"upload_id,omitempty"`
     SenderID   int64     `json:"sender_id,omitempty"`
     ReceiverID int64     `json:"receiver_id,omitempty"`
     Timestamp  time.Time `json:"timestamp"`
@@ -53,34 +82,64 @@ type HeartbeatMessage struct {
 
 func NewMessageHub(db *sql.DB) *MessageHub {
     return &MessageHub{
-        Clients:    make(map[*Client]bool),
-        Broadcast:  make(chan *Message),
-        Register:   make(chan *Client),
-        Unregister: make(chan *Client),
-        Db:         db,
+        Clients:       make(map[*Client]bool),
+        Broadcast:     make(chan *Message),
+        Register:      make(chan *Client),
+        Unregister:    make(chan *Client),
+        Announcements: make(chan *models.Announcement),
+        Events:        make(chan *Event, 64),
+        disconnects:   make(chan disconnectRequest, 16),
+        Db:            db,
+        DrainTimeout:  5 * time.Second,
+        done:          make(chan struct{}),
     }
 }
 
-func (h *MessageHub) Run() {
+// NewClient creates a client for an upgraded connection.
+func NewClient(hub *MessageHub, conn *websocket.Conn, userID int64, codec Codec) *Client {
+    return &Client{
+        Hub:      hub,
+        Conn:     conn,
+        Send:     make(chan []byte, 256),
+        UserID:   userID,
+        IsOnline: true,
+        Codec:    codec,
+        done:     make(chan struct{}),
+    }
+}
+
+// Done is closed once Run has returned.
+func (h *MessageHub) Done() <-chan struct{} {
+    return h.done
+}
+
+// Run processes hub events until ctx is cancelled, then drains every
+// connection and returns.
+func (h *MessageHub) Run(ctx context.Context) {
     logger.Info("WebSocket MessageHub is running...")
+    defer close(h.done)
     
     // Start a ticker to periodically check for inactive users
     statusCheckTicker := time.NewTicker(60 * time.Second)
-    
-    go func() {
-        for range statusCheckTicker.C {
-            h.checkInactiveUsers()
-        }
-    }()
+    defer statusCheckTicker.Stop()
     
     for {
         select {
+        case <-ctx.Done():
+            h.shutdown()
+            return
+
+        case <-statusCheckTicker.C:
+            h.checkInactiveUsers()
+
         case client := <-h.Register:
             logger.Info("Registering new WebSocket client: %d", client.UserID)
             client.LastSeen = time.Now().UTC()
             h.Clients[client] = true
             // Update user status to online
             h.updateUserStatus(client.UserID, true)
+            // Send the announcements that are still active
+            h.sendActiveAnnouncements(client)
 
         case client := <-h.Unregister:
             logger.Info("Unregistering WebSocket client: %d", client.UserID)
@@ -94,6 +153,16 @@ func (h *MessageHub) Run() {
         case message := <-h.Broadcast:
             logger.Info("Broadcasting WebSocket message: %+v", message)
             h.handleMessage(message)
+
+        case announcement := <-h.Announcements:
+            logger.Info("Delivering announcement %d", announcement.ID)
+            h.deliverAnnouncement(announcement)
+
+        case event := <-h.Events:
+            h.deliverEvent(event)
+
+        case req := <-h.disconnects:
+            h.disconnectUser(req)
         }
     }
 }
@@ -126,6 +195,12 @@ func (h *MessageHub) handleMessage(message *Message) {
     
     // For regular messages, store in database
     if message.Type == "message" {
+        // Suspended users may still hold a connection opened before the
+        // suspension
+        if err := controllers.CheckNotSuspended(h.Db, int(message.SenderID)); err != nil {
+            logger.Warning("Dropping message from user %d: %v", message.SenderID, err)
+            return
+        }
         if err := h.storeMessage(message); err != nil {
             logger.Error("Failed to store message: %v", err)
             return
@@ -135,13 +210,35 @@ func (h *MessageHub) handleMessage(message *Message) {
         for client := range h.Clients {
             if client.UserID == message.ReceiverID || client.UserID == message.SenderID {
                 select {
-                case client.Send <- message.serialize():
+                case client.Send <- message.serialize(client.codec()):
                 default:
                     close(client.Send)
                     delete(h.Clients, client)
                 }
             }
         }
+
+        // Keep both participants' contact lists ordered
+        h.pushContactUpdates(message)
+
+        // A direct message can only mention its recipient
+        notifications, err := controllers.NewMentionController(h.Db).RecordMentions(controllers.MentionSource{
+            Type:     controllers.MentionSourceMessage,
+            ID:       int(message.ID),
+            AuthorID: int(message.SenderID),
+            Audience: []int{int(message.ReceiverID)},
+        }, message.Content)
+        if err != nil {
+            logger.Error("Failed to record mentions in message %d: %v", message.ID, err)
+        }
+        h.deliverNotifications(notifications)
+
+        notifications, err = controllers.NewNotificationController(h.Db).NotifyMessage(
+            int(message.ID), int(message.SenderID), int(message.ReceiverID), message.Content)
+        if err != nil {
+            logger.Error("Failed to notify message %d: %v", message.ID, err)
+        }
+        h.deliverNotifications(notifications)
     }
 }
 
@@ -186,15 +283,44 @@ func (h *MessageHub) updateUserStatus(userID int64, isOnline bool) error {
 }
 
 func (h *MessageHub) storeMessage(message *Message) error {
-    _, err := h.Db.Exec(`
-        INSERT INTO messages (sender_id, receiver_id, content, created_at)
-        VALUES (?, ?, ?, ?)
-    `, message.SenderID, message.ReceiverID, message.Content, message.Timestamp)
-    return err
+    message.ContentHTML = richtext.Render(message.Content)
+    message.MediaURL = ""
+    if message.UploadID != "" {
+        attachment, err := controllers.NewResumableUploadController(h.Db, controllers.ResumableUploadDir()).
+            CompletedUpload(int(message.SenderID), message.UploadID, controllers.UploadTargetMessage)
+        if err != nil {
+            logger.Warning("User %d sent a message with upload %q: %v", message.SenderID, message.UploadID, err)
+        } else {
+            message.MediaPath = attachment.URL
+        }
+        message.UploadID = ""
+    }
+    var mediaPath sql.NullString
+    if message.MediaPath != "" {
+        if !controllers.ValidMessageMedia(h.Db, int(message.SenderID), message.MediaPath) {
+            logger.Warning("User %d sent a message with foreign media %q", message.SenderID, message.MediaPath)
+            message.MediaPath = ""
+        } else {
+            mediaPath = sql.NullString{String: message.MediaPath, Valid: true}
+        }
+    }
+    result, err := h.Db.Exec(`
+        INSERT INTO messages (sender_id, receiver_id, content, content_html, media_url, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, message.SenderID, message.ReceiverID, message.Content, message.ContentHTML, mediaPath, message.Timestamp)
+    if err != nil {
+        return err
+    }
+    message.ID, err = result.LastInsertId()
+    if err != nil {
+        return err
+    }
+    message.MediaURL = controllers.MessageMediaURL(message.MediaPath)
+    return nil
 }
 
-func (m *Message) serialize() []byte {
-    data, err := json.Marshal(m)
+func (m *Message) serialize(codec Codec) []byte {
+    data, err := codec.Marshal(m)
     if err != nil {
         logger.Error("Failed to serialize message: %v", err)
         return nil
@@ -202,24 +328,40 @@ func (m *Message) serialize() []byte {
     return data
 }
 
+// codec returns the client's negotiated codec, defaulting to JSON.
+func (c *Client) codec() Codec {
+    if c.Codec == nil {
+        return JSONCodec{}
+    }
+    return c.Codec
+}
+
 // WritePump pumps messages from the Send channel to the WebSocket connection.
 func (c *Client) WritePump() {
     ticker := time.NewTicker(45 * time.Second)
     defer func() {
         ticker.Stop()
         c.Conn.Close()
+        if c.done != nil {
+            close(c.done)
+        }
     }()
 
     for {
         select {
         case message, ok := <-c.Send:
+            c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
             if !ok {
                 // The channel was closed.
-                c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+                reason := c.closeReason
+                if reason == nil {
+                    reason = []byte{}
+                }
+                c.Conn.WriteMessage(websocket.CloseMessage, reason)
                 return
             }
 
-            w, err := c.Conn.NextWriter(websocket.TextMessage)
+            w, err := c.Conn.NextWriter(c.codec().FrameType())
             if err != nil {
                 return
             }
@@ -230,6 +372,7 @@ func (c *Client) WritePump() {
             }
 
         case <-ticker.C:
+            c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
             if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
                 return
             }
@@ -241,7 +384,10 @@ func (c *Client) WritePump() {
 func (c *Client) ReadPump() {
     defer func() {
         // Unregister the client and close the connection.
-        c.Hub.Unregister <- c
+        select {
+        case c.Hub.Unregister <- c:
+        case <-c.Hub.done:
+        }
         c.Conn.Close()
     }()
 
@@ -265,21 +411,24 @@ func (c *Client) ReadPump() {
         }
 
         var message Message
-        if err := json.Unmarshal(data, &message); err != nil {
+        if err := c.codec().Unmarshal(data, &message); err != nil {
             logger.Error("Failed to unmarshal message: %v", err)
             continue
         }
 
-        // Set sender information and timestamp.
+        // Set sender information and timestamp. The server clock orders
+        // conversations, so a client supplied timestamp is ignored.
         message.SenderID = c.UserID
-        if message.Timestamp.IsZero() {
-            message.Timestamp = time.Now()
-        }
+        message.Timestamp = time.Now().UTC()
         
         // Update the client's last seen time
         c.LastSeen = time.Now().UTC()
 
-        c.Hub.Broadcast <- &message
+        select {
+        case c.Hub.Broadcast <- &message:
+        case <-c.Hub.done:
+            return
+        }
     }
 }
 
@@ -304,15 +453,20 @@ func (h *MessageHub) broadcastStatusUpdate(userID int64, isOnline bool) {
         LastSeen: lastSeen,
     }
     
-    // Serialize the status message
-    msgData, err := json.Marshal(statusMsg)
-    if err != nil {
-        logger.Error("Failed to serialize status update: %v", err)
-        return
-    }
-    
-    // Send to all connected clients
+    // Send to all connected clients, encoding once per codec in use
+    encoded := make(map[string][]byte)
     for client := range h.Clients {
+        codec := client.codec()
+        msgData, ok := encoded[codec.Name()]
+        if !ok {
+            data, err := codec.Marshal(statusMsg)
+            if err != nil {
+                logger.Error("Failed to serialize status update: %v", err)
+                return
+            }
+            encoded[codec.Name()] = data
+            msgData = data
+        }
         select {
         case client.Send <- msgData:
         default:
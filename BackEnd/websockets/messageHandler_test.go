@@ -0,0 +1,79 @@
+package websockets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestMessageTimestamp checks that the hub stamps messages with the server
+// clock, whatever time the client sends
+func TestMessageTimestamp(t *testing.T) {
+	senderID := insertTestUser(t)
+	receiverID := insertTestUser(t)
+
+	hub := NewMessageHub(testDB)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		<-hub.Done()
+	}()
+	go hub.Run(ctx)
+
+	upgrader := websocket.Upgrader{Subprotocols: Subprotocols()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		client := NewClient(hub, conn, senderID, CodecFor(conn.Subprotocol()))
+		hub.Register <- client
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	before := time.Now().UTC().Add(-time.Second)
+	err = conn.WriteJSON(Message{
+		Type:       "message",
+		Content:    "From the past",
+		ReceiverID: receiverID,
+		Timestamp:  time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	// The sender gets its stored message echoed back
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var echoed Message
+	for echoed.Type != "message" {
+		echoed = Message{}
+		if err := conn.ReadJSON(&echoed); err != nil {
+			t.Fatalf("Failed to read the echoed message: %v", err)
+		}
+	}
+	if echoed.Timestamp.Before(before) {
+		t.Errorf("Expected a server timestamp after %v, got %v", before, echoed.Timestamp)
+	}
+
+	var stored time.Time
+	if err := testDB.QueryRow("SELECT created_at FROM messages WHERE id = ?", echoed.ID).Scan(&stored); err != nil {
+		t.Fatalf("Failed to fetch stored message: %v", err)
+	}
+	if stored.Before(before) {
+		t.Errorf("Expected the message stored with the server time, got %v", stored)
+	}
+}
@@ -0,0 +1,82 @@
+package websockets
+
+import (
+	"fmt"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
+)
+
+// NotificationMessage is the notification frame pushed to the notified user.
+// Message is a ready-made line for toasts.
+type NotificationMessage struct {
+	Type         string               `json:"type"`
+	Message      string               `json:"message"`
+	Notification *models.Notification `json:"notification"`
+}
+
+func newNotificationMessage(n *models.Notification) *NotificationMessage {
+	return &NotificationMessage{
+		Type:         "notification",
+		Message:      describeNotification(n),
+		Notification: n,
+	}
+}
+
+// describeNotification renders a notification as a short sentence
+func describeNotification(n *models.Notification) string {
+	switch n.Type {
+	case controllers.NotificationMention:
+		return fmt.Sprintf("%s mentioned you in a %s", n.ActorName, n.SourceType)
+	case controllers.NotificationReply:
+		return fmt.Sprintf("%s replied: %s", n.ActorName, n.Excerpt)
+	case controllers.NotificationVote:
+		return fmt.Sprintf("%s voted on your %s", n.ActorName, n.SourceType)
+	case controllers.NotificationMessage:
+		return fmt.Sprintf("New message from %s", n.ActorName)
+	case controllers.NotificationModeration:
+		return fmt.Sprintf("A moderator warned you about your %s: %s", n.SourceType, n.Excerpt)
+	}
+	return fmt.Sprintf("New notification from %s", n.ActorName)
+}
+
+// PublishNotifications queues stored notifications for their users. It must
+// not be called from the hub's own goroutine.
+func (h *MessageHub) PublishNotifications(notifications []models.Notification) {
+	for i := range notifications {
+		n := &notifications[i]
+		h.Publish(newNotificationMessage(n), int64(n.UserID))
+	}
+}
+
+// NotifyMentions records the mentions in content and notifies the users
+// mentioned in src for the first time
+func (h *MessageHub) NotifyMentions(src controllers.MentionSource, content string) {
+	notifications, err := controllers.NewMentionController(h.Db).RecordMentions(src, content)
+	if err != nil {
+		logger.Error("Failed to record mentions in %s %d: %v", src.Type, src.ID, err)
+		return
+	}
+	h.PublishNotifications(notifications)
+}
+
+// PublishVoteNotification notifies the author of a post or comment that
+// actorID voted on it
+func (h *MessageHub) PublishVoteNotification(sourceType string, sourceID, actorID int) {
+	notifications, err := controllers.NewNotificationController(h.Db).NotifyVote(sourceType, sourceID, actorID)
+	if err != nil {
+		logger.Error("Failed to notify vote on %s %d: %v", sourceType, sourceID, err)
+		return
+	}
+	h.PublishNotifications(notifications)
+}
+
+// deliverNotifications sends notifications straight to connected clients;
+// the hub goroutine uses it instead of PublishNotifications
+func (h *MessageHub) deliverNotifications(notifications []models.Notification) {
+	for i := range notifications {
+		n := &notifications[i]
+		h.deliverEvent(&Event{UserIDs: []int64{int64(n.UserID)}, Payload: newNotificationMessage(n)})
+	}
+}
@@ -0,0 +1,61 @@
+package websockets
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// OriginPolicy decides which browser origins may open a cookie
+// authenticated WebSocket connection.
+type OriginPolicy struct {
+	allowAll bool
+	origins  map[string]bool
+}
+
+// NewOriginPolicy builds a policy from a list of origins such as
+// "https://forum.example.com". A "*" entry allows every origin.
+func NewOriginPolicy(origins []string) *OriginPolicy {
+	p := &OriginPolicy{origins: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			p.allowAll = true
+			continue
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return p
+}
+
+// OriginPolicyFromEnv reads the comma-separated WS_ALLOWED_ORIGINS variable.
+// When it is unset only same-origin requests are accepted.
+func OriginPolicyFromEnv() *OriginPolicy {
+	return NewOriginPolicy(strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ","))
+}
+
+// Check reports whether the request's Origin is acceptable. Requests without
+// an Origin header come from non-browser clients and are let through; they
+// still have to authenticate.
+func (p *OriginPolicy) Check(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if p.allowAll {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.origins[strings.ToLower(u.Scheme+"://"+u.Host)]
+}
@@ -0,0 +1,64 @@
+package websockets
+
+import (
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/gorilla/websocket"
+)
+
+// writeWait is the time allowed to write a single frame to a client.
+const writeWait = 10 * time.Second
+
+// shutdown closes every client with a "server restarting" close frame,
+// waits up to DrainTimeout for their pending Send buffers to flush and
+// marks all users offline in a single statement.
+func (h *MessageHub) shutdown() {
+	logger.Info("Shutting down WebSocket MessageHub, draining %d clients...", len(h.Clients))
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	clients := make([]*Client, 0, len(h.Clients))
+	for client := range h.Clients {
+		// WritePump flushes whatever is still buffered in Send before it
+		// sees the closed channel and writes the close frame
+		client.closeReason = closeMsg
+		close(client.Send)
+		delete(h.Clients, client)
+		clients = append(clients, client)
+	}
+
+	deadline := time.NewTimer(h.DrainTimeout)
+	defer deadline.Stop()
+
+	for _, client := range clients {
+		if client.done == nil {
+			continue
+		}
+		select {
+		case <-client.done:
+		case <-deadline.C:
+			logger.Warning("Drain deadline exceeded, closing remaining WebSocket connections")
+			for _, c := range clients {
+				c.Conn.Close()
+			}
+			h.markAllOffline()
+			return
+		}
+	}
+
+	h.markAllOffline()
+	logger.Info("WebSocket MessageHub stopped")
+}
+
+// markAllOffline flags every user still marked online as offline.
+func (h *MessageHub) markAllOffline() {
+	_, err := h.Db.Exec(`
+		UPDATE user_status
+		SET is_online = false,
+		    last_seen = ?
+		WHERE is_online = true
+	`, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to mark users offline on shutdown: %v", err)
+	}
+}
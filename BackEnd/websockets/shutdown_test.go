@@ -0,0 +1,152 @@
+package websockets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/gorilla/websocket"
+)
+
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	// The logger and the test database live under the working directory
+	dir, err := os.MkdirTemp("", "websockets-test")
+	if err != nil {
+		fmt.Printf("Error creating test directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Printf("Error entering test directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init(); err != nil {
+		fmt.Printf("Error initializing logger: %v\n", err)
+		os.Exit(1)
+	}
+	testDB, err = database.Init("Test")
+	if err != nil {
+		fmt.Printf("Error setting up test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	testDB.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// insertTestUser stores a user for the hub to track
+func insertTestUser(t *testing.T) int64 {
+	nickname := fmt.Sprintf("user_%d", time.Now().UnixNano())
+	result, err := testDB.Exec(`
+		INSERT INTO users (nickname, age, gender, first_name, last_name, email, password)
+		VALUES (?, 20, 'other', 'Test', 'User', ?, 'x')
+	`, nickname, nickname+"@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("Failed to get user ID: %v", err)
+	}
+	return id
+}
+
+// TestHubShutdown checks that cancelling the hub's context closes a
+// connected client with a restart close frame, waits for it to drain and
+// marks its user offline
+func TestHubShutdown(t *testing.T) {
+	userID := insertTestUser(t)
+
+	hub := NewMessageHub(testDB)
+	hub.DrainTimeout = 2 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	registered := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{Subprotocols: Subprotocols()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		client := NewClient(hub, conn, userID, CodecFor(conn.Subprotocol()))
+		hub.Register <- client
+		go client.WritePump()
+		go client.ReadPump()
+		registered <- client
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	var client *Client
+	select {
+	case client = <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("Client was never registered")
+	}
+
+	// Registration marks the user online on the hub goroutine
+	var online bool
+	deadline := time.Now().Add(time.Second)
+	for !online && time.Now().Before(deadline) {
+		testDB.QueryRow("SELECT is_online FROM user_status WHERE user_id = ?", userID).Scan(&online)
+		if !online {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !online {
+		t.Fatal("Expected the user to be online once registered")
+	}
+
+	cancel()
+	select {
+	case <-hub.Done():
+	case <-time.After(hub.DrainTimeout + time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+
+	select {
+	case <-client.done:
+	default:
+		t.Error("Expected the client to be drained before Run returned")
+	}
+
+	// Anything still buffered comes first, then the close frame
+	var closeErr *websocket.CloseError
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseServiceRestart || closeErr.Text != "server restarting" {
+		t.Errorf("Expected a server restarting close frame, got %v", err)
+	}
+
+	if err := testDB.QueryRow("SELECT is_online FROM user_status WHERE user_id = ?", userID).Scan(&online); err != nil {
+		t.Fatalf("Failed to fetch user status: %v", err)
+	}
+	if online {
+		t.Error("Expected the user to be marked offline after shutdown")
+	}
+}
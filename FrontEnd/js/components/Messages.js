@@ -423,7 +423,8 @@ export class MessagesView {
                 messagesHtml += `
                     <div class="message ${isCurrentUser ? 'sent' : 'received'} ${isContinuation ? 'continuation' : ''}">
                         ${!isContinuation ? `<div class="message-username">${username}</div>` : ''}
-                        <div class="message-content">${msg.content}</div>
+                        <div class="message-content">${msg.content_html || this.escapeHtml(msg.content)}</div>
+                        ${msg.media_url ? `<img class="message-media" src="${String(msg.media_url).replace(/&/g, '&amp;').replace(/"/g, '&quot;')}" alt="Attachment" loading="lazy">` : ''}
                         <div class="message-info">
                             <span class="message-time">${formatTimestamp(msg.created_at, true)}</span>
                         </div>
@@ -447,6 +448,16 @@ export class MessagesView {
     }
     
     // Helper method to group messages by date
+    escapeHtml(unsafe) {
+        if (!unsafe) return '';
+        return String(unsafe)
+            .replace(/&/g, "&amp;")
+            .replace(/</g, "&lt;")
+            .replace(/>/g, "&gt;")
+            .replace(/"/g, "&quot;")
+            .replace(/'/g, "&#039;");
+    }
+
     groupMessagesByDate(messages) {
         const groups = {};
         
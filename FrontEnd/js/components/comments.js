@@ -59,7 +59,7 @@ export default class CommentManager {
                         </div>
                     </div>
                     <div class="comment-content" id="comment-content-${comment.ID}">
-                        ${comment.Content}
+                        ${comment.ContentHTML}
                     </div>
                     <div class="comment-footer">
                         <div class="vote-buttons">
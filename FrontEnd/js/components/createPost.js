@@ -42,10 +42,9 @@ export class CreatePost {
               </div>
 
               <div id="text-content" class="tab-content active">
-                <div class="post-body" 
-                     id="post-body" 
-                     contenteditable="true" 
-                     data-placeholder="Share your thoughts..."></div>
+                <textarea class="post-body" 
+                          id="post-body" 
+                          placeholder="Share your thoughts... (Markdown supported)"></textarea>
               </div>
 
               <div id="media-content" class="tab-content">
@@ -248,7 +247,8 @@ export class CreatePost {
   async handleSubmit(e) {
     e.preventDefault();
     const title = document.getElementById('post-title').value;
-    const content = document.getElementById('post-body').innerHTML;
+    // The body is Markdown; the server renders it to safe HTML
+    const content = document.getElementById('post-body').value.trim();
     const categories = Array.from(this.selectedCategories).join(',');
 
     if (!title || !content || !categories) {
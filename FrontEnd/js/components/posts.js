@@ -11,6 +11,9 @@ import {
 // Get vote states from the utility
 const { userVotes, userCommentVotes } = getUserVotes();
 
+// Cursor of the next feed page, empty once the last page is shown
+let nextCursor = '';
+
 export async function initPosts() {
     // Get container from the mainContent component
     const container = window.mainContent.getContainer();
@@ -36,11 +39,9 @@ async function loadPosts() {
 
     try {
         const response = await postsAPI.list();
-        if (response.status === 'success' && response.data.posts) {
-            // Create HTML for each post
-            const postsHTML = response.data.posts.map(post => createPostHTML(post)).join('');
-            container.innerHTML = postsHTML;
-            attachPostEventListeners();
+        if (response.status === 'success' && response.data.posts && response.data.posts.length > 0) {
+            container.innerHTML = '';
+            appendPostsPage(container, response.data);
         } else {
             container.innerHTML = '<p class="no-posts-message">No posts available</p>';
         }
@@ -49,6 +50,56 @@ async function loadPosts() {
     }
 }
 
+async function loadMorePosts(button) {
+    const container = window.mainContent.getContainer();
+    if (!container || !nextCursor) {
+        return;
+    }
+
+    button.disabled = true;
+    try {
+        const response = await postsAPI.list(nextCursor);
+        if (response.status === 'success') {
+            button.remove();
+            appendPostsPage(container, response.data);
+            return;
+        }
+        showToast('Failed to load more posts');
+    } catch (error) {
+        showToast('Failed to load more posts');
+    }
+    button.disabled = false;
+}
+
+// appendPostsPage adds a page of the feed, followed by a load more button
+// while there are more pages
+function appendPostsPage(container, data) {
+    nextCursor = data.hasMore ? data.nextCursor : '';
+
+    const page = document.createElement('div');
+    page.className = 'posts-page';
+    page.innerHTML = (data.posts || []).map(post => createPostHTML(post)).join('');
+    container.appendChild(page);
+    attachPostEventListeners(page);
+
+    if (nextCursor) {
+        const button = document.createElement('button');
+        button.className = 'load-more-btn';
+        button.textContent = 'Load more';
+        button.addEventListener('click', () => loadMorePosts(button));
+        container.appendChild(button);
+    }
+}
+
+function escapeAttribute(value) {
+    return String(value)
+        .replace(/&/g, "&amp;")
+        .replace(/</g, "&lt;")
+        .replace(/>/g, "&gt;")
+        .replace(/"/g, "&quot;")
+        .replace(/'/g, "&#039;");
+}
+
 function createPostHTML(post) {
     // Get the current user from centralized state management.
     const user = userStore.getCurrentUser();
@@ -102,15 +153,15 @@ function createPostHTML(post) {
             <div class="post-content">
                 ${post.Content ? 
                     (post.Content.length > 300 
-                        ? `${post.Content.slice(0, 300)}...
+                        ? `${escapeAttribute(post.Content.slice(0, 300))}...
                            <a href="/viewPost?id=${post.ID}" data-link class="read-more">Read more</a>`
-                        : post.Content)
+                        : escapeAttribute(post.Content))
                     : 'No content'
                 }
             </div>
-            ${post.ImageUrl && post.ImageUrl.Valid ? `
+            ${post.Attachments && post.Attachments.length > 0 ? `
                 <div class="post-image">
-                    <img src="${post.ImageUrl.String}" alt="Post image" loading="lazy">
+                    <img src="${escapeAttribute(post.Attachments[0].medium_url || post.Attachments[0].url)}" alt="${escapeAttribute(post.Attachments[0].alt_text || 'Post image')}" loading="lazy">
                 </div>
             ` : ''}
             <div class="post-footer">
@@ -128,7 +179,7 @@ function createPostHTML(post) {
                     <div class="comments-count">
                         <a href="/viewPost?id=${post.ID}#commentText" data-link>
                             <i class="fa-regular fa-comment"></i>
-                            <span class="counter" id="comments-count-${post.ID}">${(post.Comments || []).length}</span>
+                            <span class="counter" id="comments-count-${post.ID}">${post.CommentCount || 0}</span>
                         </a>
                     </div>
                 </div>
@@ -137,9 +188,16 @@ function createPostHTML(post) {
     `;
 }
 
-function attachPostEventListeners() {
+// Close any open options menu when clicking outside
+document.addEventListener('click', () => {
+    document.querySelectorAll('.options-menu.show').forEach(menu => {
+        menu.classList.remove('show');
+    });
+});
+
+function attachPostEventListeners(root = document) {
     // Toggle options menu visibility
-    document.querySelectorAll('.options-btn').forEach(button => {
+    root.querySelectorAll('.options-btn').forEach(button => {
         button.addEventListener('click', (e) => {
             e.stopPropagation();
             const menu = button.nextElementSibling;
@@ -147,15 +205,8 @@ function attachPostEventListeners() {
         });
     });
 
-    // Close any open options menu when clicking outside
-    document.addEventListener('click', () => {
-        document.querySelectorAll('.options-menu.show').forEach(menu => {
-            menu.classList.remove('show');
-        });
-    });
-
     // Edit post action
-    document.querySelectorAll('.edit-post-btn').forEach(button => {
+    root.querySelectorAll('.edit-post-btn').forEach(button => {
         button.addEventListener('click', async (e) => {
             e.stopPropagation();
             const postId = e.target.closest('.edit-post-btn').dataset.postId;
@@ -164,7 +215,7 @@ function attachPostEventListeners() {
     });
 
     // Delete post action
-    document.querySelectorAll('.delete-post-btn').forEach(button => {
+    root.querySelectorAll('.delete-post-btn').forEach(button => {
         button.addEventListener('click', async (e) => {
             e.stopPropagation();
             const postId = e.target.closest('.delete-post-btn').dataset.postId;
@@ -191,7 +242,7 @@ function attachPostEventListeners() {
     });
 
     // Vote button actions
-    document.querySelectorAll('[id="Like"], [id="DisLike"]').forEach(button => {
+    root.querySelectorAll('[id="Like"], [id="DisLike"]').forEach(button => {
         button.addEventListener('click', async (e) => {
             e.preventDefault();
             const postId = button.dataset.postId;
@@ -129,12 +129,8 @@ export default class ViewPost {
               <h3 class="post-title">${this.post.Title}</h3>
             </div>
           </div>
-          <div class="post-content">${this.post.Content}</div>
-          ${this.post.ImageUrl && this.post.ImageUrl.Valid ? `
-            <div class="post-image">
-              <img src="${this.post.ImageUrl.String}" alt="Post image" loading="lazy">
-            </div>
-          ` : ''}
+          <div class="post-content">${this.post.ContentHTML}</div>
+          ${this.renderAttachments()}
           <div class="post-footer">
             <div class="footer-icons">
               <div class="vote-buttons">
@@ -290,6 +286,31 @@ export default class ViewPost {
     }
   }
 
+  renderAttachments() {
+    const attachments = this.post.Attachments || [];
+    if (attachments.length === 0) {
+      return '';
+    }
+    return attachments.map(attachment => `
+            <div class="post-image">
+              <a href="${this.escapeHtml(attachment.url)}" target="_blank" rel="noopener">
+                <img src="${this.escapeHtml(attachment.medium_url || attachment.url)}" alt="${this.escapeHtml(attachment.alt_text || 'Post image')}"
+                     ${attachment.width ? `width="${attachment.width}" height="${attachment.height}"` : ''} loading="lazy">
+              </a>
+            </div>
+          `).join('');
+  }
+
+  escapeHtml(unsafe) {
+    if (!unsafe) return '';
+    return String(unsafe)
+      .replace(/&/g, "&amp;")
+      .replace(/</g, "&lt;")
+      .replace(/>/g, "&gt;")
+      .replace(/"/g, "&quot;")
+      .replace(/'/g, "&#039;");
+  }
+
   showToast(message) {
     const toast = document.getElementById('toast');
     if (toast) {
@@ -109,7 +109,9 @@ export const authAPI = {
 
 // Posts API
 export const postsAPI = {
-    list: () => apiRequest(API_ENDPOINTS.posts.list),
+    list: (cursor = '') => apiRequest(cursor
+        ? `${API_ENDPOINTS.posts.list}?cursor=${encodeURIComponent(cursor)}`
+        : API_ENDPOINTS.posts.list),
     
     create: (formData) => apiRequest(API_ENDPOINTS.posts.create, {
         method: 'POST',
@@ -14,7 +14,9 @@ import (
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/controllers"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/database"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/logger"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/models"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/routes"
+	"github.com/Vincent-Omondi/real-time-forum/BackEnd/storage"
 	"github.com/Vincent-Omondi/real-time-forum/BackEnd/websockets"
 )
 
@@ -35,6 +37,14 @@ func main() {
 	}
 	log.Println("Database initialized successfully")
 
+	// Keep uploads in the configured blob store
+	blobs, err := storage.FromEnv()
+	if err != nil {
+		logger.Error("Failed to configure blob storage: %v", err)
+		os.Exit(1)
+	}
+	controllers.SetBlobStore(blobs)
+
 	// Create a context that cancels on interrupt signals (e.g., Ctrl+C)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -55,6 +65,35 @@ func main() {
 		controllers.CleanupExpiredSessions(ctx, db)
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.CleanupExpiredWSTickets(ctx, db)
+	}()
+
+	// Delete uploads nothing refers to any more
+	if mode := controllers.UploadSweepMode(); mode != controllers.UploadSweepOff {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			controllers.SweepUploadsPeriodically(ctx, db, mode == controllers.UploadSweepDryRun)
+		}()
+	}
+
+	// Delete resumable uploads that were abandoned or never linked
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.CleanupExpiredResumableUploads(ctx, db, controllers.ResumableUploadDir())
+	}()
+
+	// Write buffered post views in batches, and once more on shutdown
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.FlushPostViewsPeriodically(ctx, db)
+	}()
+
 	// Determine the port to listen on
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -73,9 +112,24 @@ func main() {
 
 	// Create a single MessageHub instance
 	globalHub = websockets.NewMessageHub(db)
-	// Start the hub's processing goroutine
-	go globalHub.Run()
-	
+	// Start the hub's processing goroutine; it drains connections and
+	// returns once ctx is cancelled
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		globalHub.Run(ctx)
+	}()
+
+	// Publish scheduled posts as they fall due, announce them live and
+	// notify the users they mention
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		controllers.PublishScheduledPosts(ctx, db, func(post models.Post) {
+			globalHub.PublishPostCreated(post)
+			globalHub.NotifyMentions(controllers.PostMentionSource(post), post.Content)
+		})
+	}()
 
 	// Register routes in the correct order
 	// 1. First serve static files
@@ -100,16 +154,19 @@ func main() {
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
-	// Shutdown the server gracefully
+	// Shutdown the server gracefully, giving in-flight requests such as long
+	// upload PATCHes a bounded time to finish
 	log.Println("Shutting down server...")
-	if err := server.Shutdown(ctx); err != nil {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Server shutdown error: %v\n", err)
 	}
 
-	// Cancel the context to signal cleanup tasks to stop
+	// Cancel the context to signal cleanup tasks and the hub to stop
 	cancel()
 
-	// Wait for cleanup tasks to finish
+	// Wait for cleanup tasks and hub draining to finish
 	wg.Wait()
 
 	log.Println("Application stopped gracefully")